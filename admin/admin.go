@@ -7,6 +7,8 @@ import (
 
 	"github.com/dracory/req"
 	"github.com/dracory/statsstore"
+	"github.com/dracory/statsstore/admin/experiments"
+	"github.com/dracory/statsstore/admin/goals"
 	"github.com/dracory/statsstore/admin/home"
 	pageviewactivity "github.com/dracory/statsstore/admin/page-view-activity"
 	"github.com/dracory/statsstore/admin/settings"
@@ -64,6 +66,8 @@ func (a *admin) findHandlerFromPath(path string) http.Handler {
 		shared.PathHome:             home.New(options),
 		shared.PathVisitorActivity:  visitoractivity.New(options),
 		shared.PathVisitorPaths:     visitorpaths.New(options),
+		shared.PathGoals:            goals.New(options),
+		shared.PathExperiments:      experiments.New(options),
 		shared.PathPageViewActivity: pageviewactivity.New(options),
 		shared.PathSettings:         settings.New(options),
 	}
@@ -0,0 +1,97 @@
+(function() {
+    const { createApp, ref, onMounted } = Vue;
+
+    createApp({
+        setup() {
+            const experiments = ref([]);
+            const goals = ref([]);
+            const range = ref('30d');
+            const experiment = ref('');
+            const goalId = ref('');
+            const result = ref(null);
+            const loading = ref(false);
+            const loaded = ref(false);
+            const error = ref('');
+
+            function buildApiUrl() {
+                const params = new URLSearchParams();
+                params.set('path', '/admin/experiments');
+                return window.location.pathname + '?' + params.toString();
+            }
+
+            async function fetchSection(action, formData) {
+                formData.set('action', action);
+                const resp = await fetch(buildApiUrl(), {
+                    method: 'POST',
+                    body: formData
+                });
+                const data = await resp.json();
+                if (data.status !== 'success') throw new Error(data.message || 'Request failed');
+                return data.data || {};
+            }
+
+            async function load() {
+                loading.value = true;
+                error.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('range', range.value);
+                    const data = await fetchSection('list-ajax', formData);
+                    experiments.value = data.experiments || [];
+                    goals.value = data.goals || [];
+                    if (!experiments.value.includes(experiment.value)) {
+                        experiment.value = experiments.value[0] || '';
+                    }
+                    if (!goals.value.some(g => g.id === goalId.value)) {
+                        goalId.value = goals.value.length > 0 ? goals.value[0].id : '';
+                    }
+                    await loadResults();
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                    loaded.value = true;
+                }
+            }
+
+            async function loadResults() {
+                if (!experiment.value || !goalId.value) {
+                    result.value = null;
+                    return;
+                }
+                const formData = new FormData();
+                formData.set('experiment', experiment.value);
+                formData.set('goal_id', goalId.value);
+                formData.set('range', range.value);
+                const data = await fetchSection('results-ajax', formData);
+                result.value = data.result || null;
+            }
+
+            async function changeResults() {
+                loading.value = true;
+                error.value = '';
+                try {
+                    await loadResults();
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            function formatUplift(variant) {
+                if (variant.control) return '-';
+                return (variant.uplift > 0 ? '+' : '') + variant.uplift + '%';
+            }
+
+            onMounted(() => {
+                load();
+            });
+
+            return {
+                experiments, goals, range, experiment, goalId, result,
+                loading, loaded, error, load, changeResults, formatUplift
+            };
+        }
+    }).mount('#experiments-app');
+})();
@@ -0,0 +1,100 @@
+package experiments
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/dracory/cdn"
+	"github.com/dracory/hb"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore/admin/shared"
+)
+
+//go:embed experiments.html
+var experimentsHTML string
+
+//go:embed experiments.js
+var experimentsJS string
+
+// == CONSTRUCTOR ==============================================================
+
+// New creates a new experiments controller
+func New(ui shared.ControllerOptions) http.Handler {
+	return &experimentsController{
+		ui: ui,
+	}
+}
+
+// == CONTROLLER ===============================================================
+
+// experimentsController handles the A/B experiments page
+type experimentsController struct {
+	ui shared.ControllerOptions
+}
+
+// ServeHTTP implements the http.Handler interface
+func (c *experimentsController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(c.Handler(w, r)))
+}
+
+// Handler renders the controller output using the shared layout
+func (c *experimentsController) Handler(w http.ResponseWriter, r *http.Request) string {
+	action := req.GetString(r, "action")
+
+	// AJAX endpoints for Vue.js
+	switch action {
+	case "list-ajax":
+		return c.handleListAjax(w, r)
+	case "results-ajax":
+		return c.handleResultsAjax(w, r)
+	}
+
+	c.ui.Layout.SetTitle("Experiments | Visitor Analytics")
+
+	scriptURLs := []string{
+		cdn.VueJs_3_5_32(),
+	}
+
+	scripts := []string{
+		experimentsJS,
+	}
+
+	c.ui.Layout.SetBody(c.pageShell(r).ToHTML())
+	c.ui.Layout.SetScriptURLs(scriptURLs)
+	c.ui.Layout.SetScripts(scripts)
+
+	return c.ui.Layout.Render(w, r)
+}
+
+// pageShell builds the page shell (breadcrumbs, header, nav) and embeds
+// the Vue.js experiments template. No DB queries are made here — all data is
+// loaded via AJAX.
+func (c *experimentsController) pageShell(r *http.Request) hb.TagInterface {
+	breadcrumbs := shared.Breadcrumbs(r, []shared.Breadcrumb{
+		{
+			Name: "Home",
+			URL:  shared.UrlHome(r),
+		},
+		{
+			Name: "Visitor Analytics",
+			URL:  shared.UrlHome(r),
+		},
+		{
+			Name: "Experiments",
+			URL:  shared.UrlExperiments(r),
+		},
+	})
+
+	title := hb.Heading1().
+		Class("mt-3 mb-4 text-primary").
+		HTML("A/B Experiments")
+
+	return hb.Div().
+		Class("container").
+		Child(breadcrumbs).
+		Child(hb.HR()).
+		Child(shared.AdminHeaderUI(r, c.ui.HomeURL)).
+		Child(hb.HR()).
+		Child(title).
+		Child(hb.Raw(experimentsHTML))
+}
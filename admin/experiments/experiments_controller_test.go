@@ -0,0 +1,148 @@
+package experiments
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/statsstore"
+	"github.com/dracory/statsstore/admin/shared"
+	_ "modernc.org/sqlite"
+)
+
+func TestExperimentsControllerHandlerSuccess(t *testing.T) {
+	layout := &fakeLayout{renderReturn: "rendered"}
+
+	controller := New(shared.ControllerOptions{
+		Store:   newTestStore(t),
+		Layout:  layout,
+		HomeURL: "https://admin.local",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/experiments", nil)
+	rr := httptest.NewRecorder()
+
+	controller.ServeHTTP(rr, req)
+
+	if body := rr.Body.String(); body != "rendered" {
+		t.Fatalf("unexpected response body: %s", body)
+	}
+
+	if layout.title != "Experiments | Visitor Analytics" {
+		t.Fatalf("unexpected title: %s", layout.title)
+	}
+
+	if !strings.Contains(layout.body, "experiments-app") {
+		t.Fatalf("expected body to contain Vue app div, got: %s", layout.body)
+	}
+}
+
+func TestExperimentsControllerListAndResultsAjax(t *testing.T) {
+	store := newTestStore(t)
+	controller := New(shared.ControllerOptions{
+		Store:   store,
+		Layout:  &fakeLayout{},
+		HomeURL: "https://admin.local",
+	})
+	ctx := context.Background()
+
+	goal, err := store.GoalSave(ctx, statsstore.Goal{Name: "Purchase", Type: statsstore.GoalTypeEvent, EventName: "Purchase"})
+	if err != nil {
+		t.Fatalf("failed to save goal: %v", err)
+	}
+
+	for i, variant := range []string{"control", "control", "green", "green"} {
+		fingerprint := variant + string(rune('0'+i))
+		if err := store.EventCreate(ctx, statsstore.NewExperimentExposure("checkout", variant).SetFingerprint(fingerprint)); err != nil {
+			t.Fatalf("failed to seed exposure: %v", err)
+		}
+		if i == 2 {
+			if err := store.EventCreate(ctx, statsstore.NewEvent().SetName("Purchase").SetFingerprint(fingerprint)); err != nil {
+				t.Fatalf("failed to seed conversion: %v", err)
+			}
+		}
+	}
+
+	body := postForm(controller, "action=list-ajax&range=24h")
+	if !strings.Contains(body, `"experiments":["checkout"]`) || !strings.Contains(body, goal.ID) {
+		t.Fatalf("unexpected list response: %s", body)
+	}
+
+	body = postForm(controller, "action=results-ajax&range=24h&experiment=checkout&goal_id="+goal.ID)
+
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result statsstore.ExperimentResult `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+
+	result := response.Data.Result
+	if result.Control != "control" || len(result.Variants) != 2 || result.Variants[1].Conversions != 1 || result.Variants[1].ConversionRate != 50 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if body := postForm(controller, "action=results-ajax&experiment=checkout"); !strings.Contains(body, `"status":"error"`) {
+		t.Errorf("expected an error without a goal, got: %s", body)
+	}
+}
+
+// == TEST HELPERS ============================================================
+
+func postForm(handler http.Handler, form string) string {
+	req := httptest.NewRequest(http.MethodPost, "/admin/experiments", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Body.String()
+}
+
+func newTestStore(t testing.TB) statsstore.StoreInterface {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?parseTime=true")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+
+	store, err := statsstore.NewStore(statsstore.NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+	})
+	if err != nil {
+		_ = db.Close()
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return store
+}
+
+type fakeLayout struct {
+	title        string
+	scripts      []string
+	scriptURLs   []string
+	body         string
+	renderReturn string
+}
+
+func (l *fakeLayout) SetTitle(title string)                                { l.title = title }
+func (l *fakeLayout) SetScriptURLs(scripts []string)                       { l.scriptURLs = scripts }
+func (l *fakeLayout) SetScripts(scripts []string)                          { l.scripts = scripts }
+func (l *fakeLayout) SetStyleURLs(styles []string)                         {}
+func (l *fakeLayout) SetStyles(styles []string)                            {}
+func (l *fakeLayout) SetBody(body string)                                  { l.body = body }
+func (l *fakeLayout) SetCountryNameByIso2(fn func(string) (string, error)) {}
+func (l *fakeLayout) Render(w http.ResponseWriter, r *http.Request) string {
+	return l.renderReturn
+}
@@ -0,0 +1,51 @@
+package experiments
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+)
+
+// handleListAjax returns the experiments with exposures in the selected
+// range and the goals they can be measured against.
+func (c *experimentsController) handleListAjax(w http.ResponseWriter, r *http.Request) string {
+	from, to := rangeBounds(strings.TrimSpace(req.GetString(r, "range")))
+
+	experiments, err := c.ui.Store.ExperimentList(r.Context(), from, to)
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	goals, err := c.ui.Store.GoalList(r.Context())
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
+		"experiments": experiments,
+		"goals":       goals,
+	}))
+
+	return ""
+}
+
+// rangeBounds returns the bounds of a range code, defaulting to the last 30
+// days.
+func rangeBounds(value string) (time.Time, time.Time) {
+	now := time.Now().UTC()
+	switch strings.ToLower(value) {
+	case "24h":
+		return now.Add(-24 * time.Hour), now
+	case "7d":
+		return now.Add(-7 * 24 * time.Hour), now
+	case "90d":
+		return now.Add(-90 * 24 * time.Hour), now
+	default:
+		return now.Add(-30 * 24 * time.Hour), now
+	}
+}
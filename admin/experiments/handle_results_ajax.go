@@ -0,0 +1,39 @@
+package experiments
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+)
+
+// handleResultsAjax returns the per-variant results of an experiment
+// against a goal over the selected range.
+func (c *experimentsController) handleResultsAjax(w http.ResponseWriter, r *http.Request) string {
+	experiment := strings.TrimSpace(req.GetString(r, "experiment"))
+	if experiment == "" {
+		api.Respond(w, r, api.Error("experiment is required"))
+		return ""
+	}
+
+	goalID := strings.TrimSpace(req.GetString(r, "goal_id"))
+	if goalID == "" {
+		api.Respond(w, r, api.Error("goal_id is required"))
+		return ""
+	}
+
+	from, to := rangeBounds(strings.TrimSpace(req.GetString(r, "range")))
+
+	result, err := c.ui.Store.ExperimentResults(r.Context(), experiment, goalID, from, to)
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
+		"result": result,
+	}))
+
+	return ""
+}
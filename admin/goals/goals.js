@@ -0,0 +1,192 @@
+(function() {
+    const { createApp, ref, onMounted } = Vue;
+
+    createApp({
+        setup() {
+            const goals = ref([]);
+            const funnels = ref([]);
+            const conversions = ref([]);
+            const funnelStats = ref([]);
+            const segments = ref([]);
+            const range = ref('30d');
+            const segment = ref('');
+            const loading = ref(false);
+            const loaded = ref(false);
+            const error = ref('');
+            const success = ref('');
+
+            const goalForm = ref(emptyGoalForm());
+            const funnelForm = ref({ name: '', goalIds: [] });
+            const funnelStep = ref('');
+
+            const attributionGoal = ref('');
+            const attributionModel = ref('last_touch');
+            const attribution = ref(null);
+
+            function emptyGoalForm() {
+                return { id: '', name: '', type: 'page', path: '', eventName: '', value: '' };
+            }
+
+            function buildApiUrl() {
+                const params = new URLSearchParams();
+                params.set('path', '/admin/goals');
+                return window.location.pathname + '?' + params.toString();
+            }
+
+            async function fetchSection(action, formData) {
+                formData.set('action', action);
+                const resp = await fetch(buildApiUrl(), {
+                    method: 'POST',
+                    body: formData
+                });
+                const data = await resp.json();
+                if (data.status !== 'success') throw new Error(data.message || 'Request failed');
+                return data.data || {};
+            }
+
+            async function load() {
+                loading.value = true;
+                error.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('range', range.value);
+                    formData.set('segment', segment.value);
+                    const data = await fetchSection('list-ajax', formData);
+                    goals.value = data.goals || [];
+                    funnels.value = data.funnels || [];
+                    conversions.value = data.conversions || [];
+                    funnelStats.value = data.funnelStats || [];
+                    segments.value = data.segments || [];
+                    if (attributionGoal.value && !goals.value.some(g => g.id === attributionGoal.value)) {
+                        attributionGoal.value = '';
+                    }
+                    await loadAttribution();
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                    loaded.value = true;
+                }
+            }
+
+            async function loadAttribution() {
+                if (!attributionGoal.value) {
+                    attribution.value = null;
+                    return;
+                }
+                const formData = new FormData();
+                formData.set('goal_id', attributionGoal.value);
+                formData.set('model', attributionModel.value);
+                formData.set('range', range.value);
+                const data = await fetchSection('attribution-ajax', formData);
+                attribution.value = data.attribution || null;
+            }
+
+            async function changeAttribution() {
+                error.value = '';
+                try {
+                    await loadAttribution();
+                } catch (e) {
+                    error.value = e.message;
+                }
+            }
+
+            async function run(action, formData, message) {
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    await fetchSection(action, formData);
+                    await load();
+                    success.value = message;
+                    return true;
+                } catch (e) {
+                    error.value = e.message;
+                    return false;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            async function saveGoal() {
+                const form = goalForm.value;
+                const formData = new FormData();
+                formData.set('id', form.id);
+                formData.set('name', form.name.trim());
+                formData.set('type', form.type);
+                formData.set('goal_path', form.type === 'page' ? form.path.trim() : '');
+                formData.set('event_name', form.type === 'event' ? form.eventName.trim() : '');
+                formData.set('value', form.value === null ? '' : String(form.value));
+                if (await run('save-goal-ajax', formData, 'Goal saved')) {
+                    resetGoalForm();
+                }
+            }
+
+            function editGoal(goal) {
+                goalForm.value = {
+                    id: goal.id,
+                    name: goal.name,
+                    type: goal.type,
+                    path: goal.path || '',
+                    eventName: goal.event_name || '',
+                    value: goal.value || ''
+                };
+            }
+
+            function resetGoalForm() {
+                goalForm.value = emptyGoalForm();
+            }
+
+            async function deleteGoal(goal) {
+                if (!confirm('Delete goal "' + goal.name + '"?')) return;
+                const formData = new FormData();
+                formData.set('id', goal.id);
+                await run('delete-goal-ajax', formData, 'Goal deleted');
+            }
+
+            function addFunnelStep() {
+                if (funnelStep.value) {
+                    funnelForm.value.goalIds.push(funnelStep.value);
+                }
+                funnelStep.value = '';
+            }
+
+            function removeFunnelStep(index) {
+                funnelForm.value.goalIds.splice(index, 1);
+            }
+
+            function goalName(id) {
+                const goal = goals.value.find(g => g.id === id);
+                return goal ? goal.name : id;
+            }
+
+            async function saveFunnel() {
+                const formData = new FormData();
+                formData.set('name', funnelForm.value.name.trim());
+                formData.set('goal_ids', funnelForm.value.goalIds.join(','));
+                if (await run('save-funnel-ajax', formData, 'Funnel saved')) {
+                    funnelForm.value = { name: '', goalIds: [] };
+                }
+            }
+
+            async function deleteFunnel(funnel) {
+                if (!confirm('Delete funnel "' + funnel.name + '"?')) return;
+                const formData = new FormData();
+                formData.set('id', funnel.id);
+                await run('delete-funnel-ajax', formData, 'Funnel deleted');
+            }
+
+            onMounted(() => {
+                load();
+            });
+
+            return {
+                goals, funnels, conversions, funnelStats, segments, range, segment,
+                loading, loaded, error, success, goalForm, funnelForm, funnelStep,
+                attributionGoal, attributionModel, attribution, changeAttribution,
+                load, saveGoal, editGoal, resetGoalForm, deleteGoal,
+                addFunnelStep, removeFunnelStep, goalName, saveFunnel, deleteFunnel
+            };
+        }
+    }).mount('#goals-app');
+})();
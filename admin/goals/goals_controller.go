@@ -0,0 +1,108 @@
+package goals
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/dracory/cdn"
+	"github.com/dracory/hb"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore/admin/shared"
+)
+
+//go:embed goals.html
+var goalsHTML string
+
+//go:embed goals.js
+var goalsJS string
+
+// == CONSTRUCTOR ==============================================================
+
+// New creates a new goals controller
+func New(ui shared.ControllerOptions) http.Handler {
+	return &goalsController{
+		ui: ui,
+	}
+}
+
+// == CONTROLLER ===============================================================
+
+// goalsController handles the goals and funnels page
+type goalsController struct {
+	ui shared.ControllerOptions
+}
+
+// ServeHTTP implements the http.Handler interface
+func (c *goalsController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(c.Handler(w, r)))
+}
+
+// Handler renders the controller output using the shared layout
+func (c *goalsController) Handler(w http.ResponseWriter, r *http.Request) string {
+	action := req.GetString(r, "action")
+
+	// AJAX endpoints for Vue.js
+	switch action {
+	case "list-ajax":
+		return c.handleListAjax(w, r)
+	case "save-goal-ajax":
+		return c.handleSaveGoalAjax(w, r)
+	case "delete-goal-ajax":
+		return c.handleDeleteGoalAjax(w, r)
+	case "save-funnel-ajax":
+		return c.handleSaveFunnelAjax(w, r)
+	case "delete-funnel-ajax":
+		return c.handleDeleteFunnelAjax(w, r)
+	case "attribution-ajax":
+		return c.handleAttributionAjax(w, r)
+	}
+
+	c.ui.Layout.SetTitle("Goals | Visitor Analytics")
+
+	scriptURLs := []string{
+		cdn.VueJs_3_5_32(),
+	}
+
+	scripts := []string{
+		goalsJS,
+	}
+
+	c.ui.Layout.SetBody(c.pageShell(r).ToHTML())
+	c.ui.Layout.SetScriptURLs(scriptURLs)
+	c.ui.Layout.SetScripts(scripts)
+
+	return c.ui.Layout.Render(w, r)
+}
+
+// pageShell builds the page shell (breadcrumbs, header, nav) and embeds
+// the Vue.js goals template. No DB queries are made here — all data is
+// loaded via AJAX.
+func (c *goalsController) pageShell(r *http.Request) hb.TagInterface {
+	breadcrumbs := shared.Breadcrumbs(r, []shared.Breadcrumb{
+		{
+			Name: "Home",
+			URL:  shared.UrlHome(r),
+		},
+		{
+			Name: "Visitor Analytics",
+			URL:  shared.UrlHome(r),
+		},
+		{
+			Name: "Goals",
+			URL:  shared.UrlGoals(r),
+		},
+	})
+
+	title := hb.Heading1().
+		Class("mt-3 mb-4 text-primary").
+		HTML("Goals & Funnels")
+
+	return hb.Div().
+		Class("container").
+		Child(breadcrumbs).
+		Child(hb.HR()).
+		Child(shared.AdminHeaderUI(r, c.ui.HomeURL)).
+		Child(hb.HR()).
+		Child(title).
+		Child(hb.Raw(goalsHTML))
+}
@@ -0,0 +1,225 @@
+package goals
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/statsstore"
+	"github.com/dracory/statsstore/admin/shared"
+	"github.com/dromara/carbon/v2"
+	_ "modernc.org/sqlite"
+)
+
+func TestGoalsControllerHandlerSuccess(t *testing.T) {
+	layout := &fakeLayout{renderReturn: "rendered"}
+
+	controller := New(shared.ControllerOptions{
+		Store:   newTestStore(t),
+		Layout:  layout,
+		HomeURL: "https://admin.local",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/goals", nil)
+	rr := httptest.NewRecorder()
+
+	controller.ServeHTTP(rr, req)
+
+	if body := rr.Body.String(); body != "rendered" {
+		t.Fatalf("unexpected response body: %s", body)
+	}
+
+	if layout.title != "Goals | Visitor Analytics" {
+		t.Fatalf("unexpected title: %s", layout.title)
+	}
+
+	if !strings.Contains(layout.body, "goals-app") {
+		t.Fatalf("expected body to contain Vue app div, got: %s", layout.body)
+	}
+}
+
+func TestGoalsControllerSaveAndListAjax(t *testing.T) {
+	store := newTestStore(t)
+	controller := New(shared.ControllerOptions{
+		Store:   store,
+		Layout:  &fakeLayout{},
+		HomeURL: "https://admin.local",
+	})
+
+	now := carbon.Now(carbon.UTC).StdTime()
+	for i, fingerprint := range []string{"a", "b"} {
+		visitor := statsstore.NewVisitor().
+			SetFingerprint(fingerprint).
+			SetPath("/pricing").
+			SetUserDeviceType("desktop").
+			SetCreatedAt(carbon.CreateFromStdTime(now.Add(-time.Duration(i+2) * time.Minute)).ToDateTimeString(carbon.UTC))
+		if err := store.VisitorCreate(context.Background(), visitor); err != nil {
+			t.Fatalf("failed to seed visitor: %v", err)
+		}
+	}
+	signup := statsstore.NewVisitor().
+		SetFingerprint("a").
+		SetPath("/signup/done").
+		SetUserDeviceType("desktop").
+		SetCreatedAt(carbon.CreateFromStdTime(now.Add(-time.Minute)).ToDateTimeString(carbon.UTC))
+	if err := store.VisitorCreate(context.Background(), signup); err != nil {
+		t.Fatalf("failed to seed visitor: %v", err)
+	}
+
+	for _, form := range []string{
+		"action=save-goal-ajax&name=Pricing&type=page&goal_path=/pricing",
+		"action=save-goal-ajax&name=Signup&type=page&goal_path=/signup/*&value=10",
+	} {
+		if body := postForm(controller, form); !strings.Contains(body, `"status":"success"`) {
+			t.Fatalf("%s: unexpected response: %s", form, body)
+		}
+	}
+
+	if body := postForm(controller, "action=save-goal-ajax&name=Broken&type=page&goal_path=nope"); !strings.Contains(body, `"status":"error"`) {
+		t.Fatalf("expected a validation error, got: %s", body)
+	}
+
+	goals, err := store.GoalList(context.Background())
+	if err != nil || len(goals) != 2 {
+		t.Fatalf("unexpected goals: %+v (%v)", goals, err)
+	}
+
+	form := "action=save-funnel-ajax&name=Signup+Flow&goal_ids=" + goals[0].ID + "," + goals[1].ID
+	if body := postForm(controller, form); !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("unexpected response: %s", body)
+	}
+
+	body := postForm(controller, "action=list-ajax&range=24h&segment=device")
+
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			Conversions []statsstore.GoalConversion   `json:"conversions"`
+			FunnelStats []statsstore.FunnelConversion `json:"funnelStats"`
+			Segments    []struct {
+				Label string `json:"label"`
+			} `json:"segments"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+
+	if len(response.Data.Conversions) != 2 || response.Data.Conversions[1].Visitors != 1 || response.Data.Conversions[1].Value != 10 {
+		t.Errorf("unexpected conversions: %+v", response.Data.Conversions)
+	}
+	if len(response.Data.FunnelStats) != 1 || len(response.Data.FunnelStats[0].Steps) != 2 || response.Data.FunnelStats[0].Steps[1].DropOff != 1 {
+		t.Errorf("unexpected funnel stats: %+v", response.Data.FunnelStats)
+	}
+	if len(response.Data.Segments) != 1 || response.Data.Segments[0].Label != "desktop" {
+		t.Errorf("unexpected segments: %+v", response.Data.Segments)
+	}
+}
+
+func TestGoalsControllerAttributionAjax(t *testing.T) {
+	store := newTestStore(t)
+	controller := New(shared.ControllerOptions{
+		Store:   store,
+		Layout:  &fakeLayout{},
+		HomeURL: "https://admin.local",
+	})
+
+	now := carbon.Now(carbon.UTC).StdTime()
+	for i, referrer := range []string{"https://www.google.com/", ""} {
+		visitor := statsstore.NewVisitor().
+			SetFingerprint("a").
+			SetPath([]string{"/", "/signup/done"}[i]).
+			SetUserReferrer(referrer).
+			SetCreatedAt(carbon.CreateFromStdTime(now.Add(-time.Duration(2-i) * time.Hour)).ToDateTimeString(carbon.UTC))
+		if err := store.VisitorCreate(context.Background(), visitor); err != nil {
+			t.Fatalf("failed to seed visitor: %v", err)
+		}
+	}
+
+	goal, err := store.GoalSave(context.Background(), statsstore.Goal{Name: "Signup", Type: statsstore.GoalTypePage, Path: "/signup/done"})
+	if err != nil {
+		t.Fatalf("failed to save goal: %v", err)
+	}
+
+	body := postForm(controller, "action=attribution-ajax&range=24h&model=first_touch&goal_id="+goal.ID)
+
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			Attribution statsstore.AttributionReport `json:"attribution"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+
+	report := response.Data.Attribution
+	if report.Conversions != 1 || len(report.ByChannel) != 1 || report.ByChannel[0].Label != statsstore.ChannelOrganicSearch {
+		t.Errorf("unexpected attribution: %+v", report)
+	}
+
+	if body := postForm(controller, "action=attribution-ajax&model=position&goal_id="+goal.ID); !strings.Contains(body, `"status":"error"`) {
+		t.Errorf("expected an error for an unknown model, got: %s", body)
+	}
+	if body := postForm(controller, "action=attribution-ajax"); !strings.Contains(body, `"status":"error"`) {
+		t.Errorf("expected an error without a goal, got: %s", body)
+	}
+}
+
+// == TEST HELPERS ============================================================
+
+func postForm(handler http.Handler, form string) string {
+	req := httptest.NewRequest(http.MethodPost, "/admin/goals", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Body.String()
+}
+
+func newTestStore(t testing.TB) statsstore.StoreInterface {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?parseTime=true")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+
+	store, err := statsstore.NewStore(statsstore.NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+	})
+	if err != nil {
+		_ = db.Close()
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return store
+}
+
+type fakeLayout struct {
+	title        string
+	scripts      []string
+	scriptURLs   []string
+	body         string
+	renderReturn string
+}
+
+func (l *fakeLayout) SetTitle(title string)                                { l.title = title }
+func (l *fakeLayout) SetScriptURLs(scripts []string)                       { l.scriptURLs = scripts }
+func (l *fakeLayout) SetScripts(scripts []string)                          { l.scripts = scripts }
+func (l *fakeLayout) SetStyleURLs(styles []string)                         {}
+func (l *fakeLayout) SetStyles(styles []string)                            {}
+func (l *fakeLayout) SetBody(body string)                                  { l.body = body }
+func (l *fakeLayout) SetCountryNameByIso2(fn func(string) (string, error)) {}
+func (l *fakeLayout) Render(w http.ResponseWriter, r *http.Request) string {
+	return l.renderReturn
+}
@@ -0,0 +1,46 @@
+package goals
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// handleAttributionAjax returns the attribution of a goal's conversions in
+// the selected range to channels, mediums and campaigns.
+func (c *goalsController) handleAttributionAjax(w http.ResponseWriter, r *http.Request) string {
+	goalID := strings.TrimSpace(req.GetString(r, "goal_id"))
+	if goalID == "" {
+		api.Respond(w, r, api.Error("goal_id is required"))
+		return ""
+	}
+
+	model := strings.TrimSpace(req.GetString(r, "model"))
+	if model == "" {
+		model = statsstore.AttributionLastTouch
+	}
+
+	from, to := rangeBounds(strings.TrimSpace(req.GetString(r, "range")))
+	fromTime, _ := time.Parse(time.RFC3339, from)
+	toTime, _ := time.Parse(time.RFC3339, to)
+
+	report, err := c.ui.Store.GoalAttribution(r.Context(), goalID, statsstore.AttributionOptions{
+		Model: model,
+		From:  fromTime,
+		To:    toTime,
+	})
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
+		"attribution": report,
+	}))
+
+	return ""
+}
@@ -0,0 +1,111 @@
+package goals
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// segmentJSON holds the conversions of one segment value.
+type segmentJSON struct {
+	Label       string                        `json:"label"`
+	Visitors    int                           `json:"visitors"`
+	Conversions []statsstore.GoalConversion   `json:"conversions"`
+	Funnels     []statsstore.FunnelConversion `json:"funnels"`
+}
+
+// handleListAjax returns the goal and funnel definitions with their
+// conversions over the selected range, optionally split by segment.
+func (c *goalsController) handleListAjax(w http.ResponseWriter, r *http.Request) string {
+	rangeValue := strings.TrimSpace(req.GetString(r, "range"))
+	from, to := rangeBounds(rangeValue)
+	segmentName := strings.TrimSpace(req.GetString(r, "segment"))
+
+	goals, err := c.ui.Store.GoalList(r.Context())
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	funnels, err := c.ui.Store.FunnelList(r.Context())
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	visitors, err := c.ui.Store.VisitorList(r.Context(), statsstore.VisitorQuery().
+		SetCreatedAtGte(from).
+		SetCreatedAtLte(to))
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	events, err := c.ui.Store.EventList(r.Context(), statsstore.EventQuery().
+		SetCreatedAtGte(from).
+		SetCreatedAtLte(to))
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	segments := []segmentJSON{}
+	if segment := statsstore.SegmentByName(segmentName); segment != nil {
+		for label, segmentVisitors := range statsstore.SegmentVisitors(visitors, segment) {
+			segments = append(segments, segmentJSON{
+				Label:       label,
+				Visitors:    len(segmentVisitors),
+				Conversions: statsstore.ComputeGoalConversions(goals, segmentVisitors, events),
+				Funnels:     funnelConversions(funnels, goals, segmentVisitors, events),
+			})
+		}
+		sort.Slice(segments, func(i, j int) bool {
+			if segments[i].Visitors != segments[j].Visitors {
+				return segments[i].Visitors > segments[j].Visitors
+			}
+			return segments[i].Label < segments[j].Label
+		})
+	}
+
+	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
+		"goals":       goals,
+		"funnels":     funnels,
+		"conversions": statsstore.ComputeGoalConversions(goals, visitors, events),
+		"funnelStats": funnelConversions(funnels, goals, visitors, events),
+		"segments":    segments,
+		"range":       rangeValue,
+		"segment":     segmentName,
+	}))
+
+	return ""
+}
+
+func funnelConversions(funnels []statsstore.Funnel, goals []statsstore.Goal, visitors []statsstore.VisitorInterface, events []statsstore.EventInterface) []statsstore.FunnelConversion {
+	results := make([]statsstore.FunnelConversion, 0, len(funnels))
+	for _, funnel := range funnels {
+		results = append(results, statsstore.ComputeFunnelConversion(funnel, goals, visitors, events))
+	}
+	return results
+}
+
+// rangeBounds returns the RFC 3339 bounds of a range code, defaulting to
+// the last 30 days.
+func rangeBounds(value string) (string, string) {
+	now := time.Now().UTC()
+	switch strings.ToLower(value) {
+	case "24h":
+		return now.Add(-24 * time.Hour).Format(time.RFC3339), now.Format(time.RFC3339)
+	case "today":
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format(time.RFC3339), start.Add(24 * time.Hour).Format(time.RFC3339)
+	case "7d":
+		return now.Add(-7 * 24 * time.Hour).Format(time.RFC3339), now.Format(time.RFC3339)
+	default:
+		return now.Add(-30 * 24 * time.Hour).Format(time.RFC3339), now.Format(time.RFC3339)
+	}
+}
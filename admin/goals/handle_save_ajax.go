@@ -0,0 +1,90 @@
+package goals
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// handleSaveGoalAjax adds or updates a goal
+func (c *goalsController) handleSaveGoalAjax(w http.ResponseWriter, r *http.Request) string {
+	goal := statsstore.Goal{
+		ID:        strings.TrimSpace(req.GetString(r, "id")),
+		Name:      req.GetString(r, "name"),
+		Type:      strings.TrimSpace(req.GetString(r, "type")),
+		Path:      req.GetString(r, "goal_path"),
+		EventName: req.GetString(r, "event_name"),
+	}
+
+	if value := strings.TrimSpace(req.GetString(r, "value")); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			api.Respond(w, r, api.Error("Goal value must be a number"))
+			return ""
+		}
+		goal.Value = parsed
+	}
+
+	if _, err := c.ui.Store.GoalSave(r.Context(), goal); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Goal saved"))
+
+	return ""
+}
+
+// handleDeleteGoalAjax removes a goal
+func (c *goalsController) handleDeleteGoalAjax(w http.ResponseWriter, r *http.Request) string {
+	if err := c.ui.Store.GoalDelete(r.Context(), strings.TrimSpace(req.GetString(r, "id"))); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Goal deleted"))
+
+	return ""
+}
+
+// handleSaveFunnelAjax adds or updates a funnel. Steps are passed as a
+// comma-separated list of goal IDs, in order.
+func (c *goalsController) handleSaveFunnelAjax(w http.ResponseWriter, r *http.Request) string {
+	goalIDs := []string{}
+	for _, id := range strings.Split(req.GetString(r, "goal_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			goalIDs = append(goalIDs, id)
+		}
+	}
+
+	funnel := statsstore.Funnel{
+		ID:      strings.TrimSpace(req.GetString(r, "id")),
+		Name:    req.GetString(r, "name"),
+		GoalIDs: goalIDs,
+	}
+
+	if _, err := c.ui.Store.FunnelSave(r.Context(), funnel); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Funnel saved"))
+
+	return ""
+}
+
+// handleDeleteFunnelAjax removes a funnel
+func (c *goalsController) handleDeleteFunnelAjax(w http.ResponseWriter, r *http.Request) string {
+	if err := c.ui.Store.FunnelDelete(r.Context(), strings.TrimSpace(req.GetString(r, "id"))); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Funnel deleted"))
+
+	return ""
+}
@@ -0,0 +1,8 @@
+package goals
+
+import (
+	"github.com/dracory/statsstore/admin/shared"
+)
+
+// ControllerOptions alias for shared controller options
+type ControllerOptions = shared.ControllerOptions
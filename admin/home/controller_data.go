@@ -8,6 +8,10 @@ import (
 // ControllerData contains the data needed for traffic source computation.
 type ControllerData struct {
 	visitors []statsstore.VisitorInterface
+	events   []statsstore.EventInterface
+	rollups  []statsstore.RollupRow // daily rollups of the days without raw visitors
+	dates    []string               // days of the period, oldest first
+	rates    statsstore.CurrencyRates
 	ui       shared.ControllerOptions
 }
 
@@ -2,14 +2,16 @@ package home
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/dracory/api"
-	"github.com/dracory/statsstore"
 	"github.com/samber/lo"
 )
 
 // handleComparisonAjax returns the period comparison table data as JSON.
 // This loads visitors for both current and previous periods to compute extended stats.
+// Days read from the rollups count towards the visit totals only; session
+// metrics come from the raw rows, which sessionNote points out.
 func (c *Controller) handleComparisonAjax(w http.ResponseWriter, r *http.Request) string {
 	periodBounds, err := c.getPeriodBounds(r)
 	if err != "" {
@@ -17,24 +19,20 @@ func (c *Controller) handleComparisonAjax(w http.ResponseWriter, r *http.Request
 		return ""
 	}
 
-	visitors, dbErr := c.ui.Store.VisitorList(r.Context(), statsstore.VisitorQuery().
-		SetCreatedAtGte(periodBounds.createdAtGte).
-		SetCreatedAtLte(periodBounds.createdAtLte))
+	visitors, rollups, dbErr := c.loadPeriodVisitors(r.Context(), periodBounds.createdAtGte, periodBounds.createdAtLte, periodBounds.dateRange)
 	if dbErr != nil {
 		api.Respond(w, r, api.Error(dbErr.Error()))
 		return ""
 	}
 
-	prevVisitors, dbErr := c.ui.Store.VisitorList(r.Context(), statsstore.VisitorQuery().
-		SetCreatedAtGte(periodBounds.prevCreatedAtGte).
-		SetCreatedAtLte(periodBounds.prevCreatedAtLte))
+	prevVisitors, prevRollups, dbErr := c.loadPeriodVisitors(r.Context(), periodBounds.prevCreatedAtGte, periodBounds.prevCreatedAtLte, periodBounds.prevDateRange)
 	if dbErr != nil {
 		api.Respond(w, r, api.Error(dbErr.Error()))
 		return ""
 	}
 
-	currentStats := computePeriodStats(visitors, periodBounds.dateRange)
-	prevStats := computePeriodStats(prevVisitors, periodBounds.prevDateRange)
+	currentStats := computePeriodStatsWithRollups(visitors, rollups, periodBounds.dateRange)
+	prevStats := computePeriodStatsWithRollups(prevVisitors, prevRollups, periodBounds.prevDateRange)
 
 	totalUniqueVisitors := lo.Sum(currentStats.uniqueVisits)
 	totalVisitors := lo.Sum(currentStats.totalVisits)
@@ -51,6 +49,8 @@ func (c *Controller) handleComparisonAjax(w http.ResponseWriter, r *http.Request
 		{"Returning Visits", formatCount(totalReturningVisits), formatCount(prevStats.totalReturning), changePercentInt(totalReturningVisits, prevStats.totalReturning), false},
 		{"Bounce Rate", formatFloat2(ext.BounceRateValue) + "%", formatFloat2(prevExt.BounceRateValue) + "%", changePercentFloat(ext.BounceRateValue, prevExt.BounceRateValue), true},
 		{"Avg. Visit Duration", formatDuration(ext.SessionDurationSeconds), formatDuration(prevExt.SessionDurationSeconds), changePercentFloat(ext.SessionDurationSeconds, prevExt.SessionDurationSeconds), false},
+		{"Avg. Time on Page", formatDuration(ext.TimeOnPageSeconds), formatDuration(prevExt.TimeOnPageSeconds), changePercentFloat(ext.TimeOnPageSeconds, prevExt.TimeOnPageSeconds), false},
+		{"Avg. Scroll Depth", formatFloat2(ext.ScrollDepthValue) + "%", formatFloat2(prevExt.ScrollDepthValue) + "%", changePercentFloat(ext.ScrollDepthValue, prevExt.ScrollDepthValue), false},
 	}
 
 	statCards := []statCardJSON{
@@ -63,10 +63,18 @@ func (c *Controller) handleComparisonAjax(w http.ResponseWriter, r *http.Request
 		{"Pages per Session", ext.PagesPerSession, "bi bi-diagram-3", "info"},
 		{"Bounce Rate", ext.BounceRate, "bi bi-arrow-repeat", "warning"},
 		{"Avg. Visit Duration", ext.SessionDuration, "bi bi-clock-history", "secondary"},
+		{"Avg. Time on Page", ext.TimeOnPage, "bi bi-hourglass-split", "info"},
+		{"Avg. Scroll Depth", ext.ScrollDepth, "bi bi-arrow-down-circle", "dark"},
+	}
+
+	sessionNote := rollupCoverageNote(rollups, periodBounds.dateRange)
+	if prevNote := rollupCoverageNote(prevRollups, periodBounds.prevDateRange); prevNote != "" {
+		sessionNote = strings.TrimSpace(sessionNote + " Previous period: " + prevNote)
 	}
 
 	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
 		"statCards":           statCards,
+		"sessionNote":         sessionNote,
 		"comparisonRows":      comparisons,
 		"previousPeriodLabel": periodBounds.prevLabel,
 	}))
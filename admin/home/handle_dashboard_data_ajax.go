@@ -19,7 +19,13 @@ func (c *Controller) handleDashboardDataAjax(w http.ResponseWriter, r *http.Requ
 		return ""
 	}
 
-	visitors, dbErr := c.ui.Store.VisitorList(r.Context(), statsstore.VisitorQuery().
+	visitors, rollups, dbErr := c.loadPeriodVisitors(r.Context(), periodBounds.createdAtGte, periodBounds.createdAtLte, periodBounds.dateRange)
+	if dbErr != nil {
+		api.Respond(w, r, api.Error(dbErr.Error()))
+		return ""
+	}
+
+	events, dbErr := c.ui.Store.EventList(r.Context(), statsstore.EventQuery().
 		SetCreatedAtGte(periodBounds.createdAtGte).
 		SetCreatedAtLte(periodBounds.createdAtLte))
 	if dbErr != nil {
@@ -27,8 +33,14 @@ func (c *Controller) handleDashboardDataAjax(w http.ResponseWriter, r *http.Requ
 		return ""
 	}
 
+	rates, dbErr := c.ui.Store.CurrencyRatesGet(r.Context())
+	if dbErr != nil {
+		api.Respond(w, r, api.Error(dbErr.Error()))
+		return ""
+	}
+
 	// Daily stats
-	currentStats := computePeriodStats(visitors, periodBounds.dateRange)
+	currentStats := computePeriodStatsWithRollups(visitors, rollups, periodBounds.dateRange)
 	daily := make([]dailyStatJSON, 0, len(currentStats.dates))
 	for i, date := range currentStats.dates {
 		daily = append(daily, dailyStatJSON{
@@ -43,6 +55,10 @@ func (c *Controller) handleDashboardDataAjax(w http.ResponseWriter, r *http.Requ
 	// Traffic cards
 	data := ControllerData{
 		visitors: visitors,
+		events:   events,
+		rollups:  rollups,
+		dates:    periodBounds.dateRange,
+		rates:    rates,
 		ui:       c.ui,
 	}
 	tsd := computeTrafficSources(data)
@@ -67,6 +83,7 @@ func (c *Controller) handleDashboardDataAjax(w http.ResponseWriter, r *http.Requ
 			Days:        hm.Days,
 			Slots:       hm.Slots,
 			Intensities: hm.Intensities,
+			Note:        tsd.RollupNote,
 		},
 	}))
 
@@ -1,7 +1,6 @@
 package home
 
 import (
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,54 +17,74 @@ func buildTrafficCardsJSON(tsd trafficSourcesData) []trafficCardJSON {
 		}
 		return entries
 	}
+	tab := func(label string, entries []trafficSourceEntry) trafficTabJSON {
+		return trafficTabJSON{Label: label, Entries: entries}
+	}
+	// rawTab is a tab computed from the raw visitor rows only, which do not
+	// cover the rolled-up days.
+	rawTab := func(label string, entries []trafficSourceEntry) trafficTabJSON {
+		return trafficTabJSON{Label: label, Entries: entries, Note: tsd.RollupNote}
+	}
 
 	return []trafficCardJSON{
 		{
 			Title: "Referrers", ValueLabel: "Sessions",
 			Tabs: []trafficTabJSON{
-				{"Referrers", ensure(tsd.Referrers, "(No data)")},
-				{"Channels", ensure(tsd.Channels, "(No data)")},
-				{"Source", ensure(tsd.Sources, "(No data)")},
-				{"Medium", ensure(tsd.Mediums, "(No data)")},
-				{"Campaign", ensure(tsd.Campaigns, "(No data)")},
-				{"Term", ensure(tsd.Terms, "(No data)")},
+				tab("Referrers", ensure(tsd.Referrers, "(No data)")),
+				tab("Channels", ensure(tsd.Channels, "(No data)")),
+				tab("Source", ensure(tsd.Sources, "(No data)")),
+				rawTab("Medium", ensure(tsd.Mediums, "(No data)")),
+				rawTab("Campaign", ensure(tsd.Campaigns, "(No data)")),
+				rawTab("Term", ensure(tsd.Terms, "(No data)")),
 			},
 		},
 		{
 			Title: "Pages", ValueLabel: "Sessions",
 			Tabs: []trafficTabJSON{
-				{"Pages", ensure(tsd.Pages, "(No data)")},
-				{"Page Titles", ensure(nil, "(No data)")},
-				{"Entry Pages", ensure(tsd.EntryPages, "(No data)")},
-				{"Exit Pages", ensure(tsd.ExitPages, "(No data)")},
-				{"Hostnames", ensure(nil, "(No data)")},
+				tab("Pages", ensure(tsd.Pages, "(No data)")),
+				rawTab("Page Titles", ensure(tsd.PageTitles, "(No data)")),
+				rawTab("Entry Pages", ensure(tsd.EntryPages, "(No data)")),
+				rawTab("Exit Pages", ensure(tsd.ExitPages, "(No data)")),
+				tab("Hostnames", ensure(nil, "(No data)")),
 			},
 		},
 		{
 			Title: "Browsers", ValueLabel: "Sessions",
 			Tabs: []trafficTabJSON{
-				{"Browsers", ensure(tsd.Browsers, "(No data)")},
-				{"Devices", ensure(tsd.Devices, "(No data)")},
-				{"Operating Systems", ensure(tsd.OperatingSystems, "(No data)")},
-				{"Screen Dimensions", ensure(nil, "(No data)")},
+				tab("Browsers", ensure(tsd.Browsers, "(No data)")),
+				tab("Devices", ensure(tsd.Devices, "(No data)")),
+				tab("Operating Systems", ensure(tsd.OperatingSystems, "(No data)")),
+				rawTab("In-App Browsers", ensure(tsd.InAppBrowsers, "(No data)")),
+				rawTab("Engines", ensure(tsd.Engines, "(No data)")),
+				rawTab("Screen Dimensions", ensure(tsd.ScreenSizes, "(No data)")),
 			},
 		},
 		{
 			Title: "Countries", ValueLabel: "Sessions",
 			Tabs: []trafficTabJSON{
-				{"Countries", ensure(tsd.Countries, "(No data)")},
-				{"Regions", ensure(nil, "(No data)")},
-				{"Cities", ensure(nil, "(No data)")},
-				{"Languages", ensure(tsd.Languages, "(No data)")},
-				{"Map", ensure(nil, "(No map data)")},
-				{"Timezones", ensure(nil, "(No data)")},
+				tab("Countries", ensure(tsd.Countries, "(No data)")),
+				tab("Regions", ensure(nil, "(No data)")),
+				tab("Cities", ensure(nil, "(No data)")),
+				rawTab("Languages", ensure(tsd.Languages, "(No data)")),
+				tab("Map", ensure(nil, "(No map data)")),
+				tab("Timezones", ensure(nil, "(No data)")),
 			},
 		},
 		{
 			Title: "Custom Events", ValueLabel: "Count",
 			Tabs: []trafficTabJSON{
-				{"Custom Events", ensure(tsd.Events, "(No events)")},
-				{"Outbound Links", ensure(tsd.OutboundLinks, "(No outbound links)")},
+				tab("Custom Events", ensure(tsd.Events, "(No events)")),
+				tab("Outbound Links", ensure(tsd.OutboundLinks, "(No outbound links)")),
+				tab("File Downloads", ensure(tsd.Downloads, "(No downloads)")),
+			},
+		},
+		{
+			Title: "Revenue", ValueLabel: "Revenue (" + tsd.RevenueCurrency + ")",
+			Tabs: []trafficTabJSON{
+				rawTab("Sources", ensure(tsd.RevenueSources, "(No orders)")),
+				rawTab("Campaigns", ensure(tsd.RevenueCampaigns, "(No orders)")),
+				rawTab("Landing Pages", ensure(tsd.RevenueLandingPages, "(No orders)")),
+				rawTab("Countries", ensure(tsd.RevenueCountries, "(No orders)")),
 			},
 		},
 	}
@@ -85,6 +104,7 @@ type trafficSourceEntry struct {
 type trafficSourcesData struct {
 	Referrers        []trafficSourceEntry
 	Pages            []trafficSourceEntry
+	PageTitles       []trafficSourceEntry
 	Browsers         []trafficSourceEntry
 	Countries        []trafficSourceEntry
 	Events           []trafficSourceEntry
@@ -97,8 +117,23 @@ type trafficSourcesData struct {
 	ExitPages        []trafficSourceEntry
 	Devices          []trafficSourceEntry
 	OperatingSystems []trafficSourceEntry
+	InAppBrowsers    []trafficSourceEntry
+	Engines          []trafficSourceEntry
+	ScreenSizes      []trafficSourceEntry
 	Languages        []trafficSourceEntry
 	OutboundLinks    []trafficSourceEntry
+	Downloads        []trafficSourceEntry
+
+	// Revenue breakdowns, in RevenueCurrency (see statsstore.ComputeRevenue).
+	RevenueCurrency     string
+	RevenueSources      []trafficSourceEntry
+	RevenueCampaigns    []trafficSourceEntry
+	RevenueLandingPages []trafficSourceEntry
+	RevenueCountries    []trafficSourceEntry
+
+	// RollupNote, when set, explains that the breakdowns without rollup
+	// dimensions leave out the rolled-up days (see rollupCoverageNote).
+	RollupNote string
 }
 
 // weeklyHeatmapData holds the computed weekly trends heatmap.
@@ -120,6 +155,8 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 
 	referrerCounts := map[string]int64{}
 	pageCounts := map[string]int64{}
+	pageTitleCounts := map[string]int64{}
+	screenSizeCounts := map[string]int64{}
 	browserCounts := map[string]int64{}
 	countryCounts := map[string]int64{}
 	eventCounts := map[string]int64{}
@@ -130,8 +167,11 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 	termCounts := map[string]int64{}
 	deviceCounts := map[string]int64{}
 	osCounts := map[string]int64{}
+	inAppCounts := map[string]int64{}
+	engineCounts := map[string]int64{}
 	languageCounts := map[string]int64{}
 	outboundCounts := map[string]int64{}
+	downloadCounts := map[string]int64{}
 
 	for _, v := range visitors {
 		referrer := normalizeReferrer(v.GetUserReferrer())
@@ -143,6 +183,14 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 		}
 		pageCounts[page]++
 
+		// Page titles and screen sizes are only reported by the JS tracker.
+		if title := strings.TrimSpace(v.GetPageTitle()); title != "" {
+			pageTitleCounts[title]++
+		}
+		if screen := strings.TrimSpace(v.GetScreenSize()); screen != "" {
+			screenSizeCounts[screen]++
+		}
+
 		browser := strings.TrimSpace(v.GetUserBrowser())
 		if browser == "" {
 			browser = "Unknown"
@@ -169,8 +217,8 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 
 		// Channels, Sources, Mediums
 		rawReferrer := strings.TrimSpace(v.GetUserReferrer())
-		domain := extractDomain(rawReferrer)
-		channelCounts[classifyChannel(domain)]++
+		domain := statsstore.ReferrerDomain(rawReferrer)
+		channelCounts[statsstore.ClassifyChannel(domain)]++
 		if rawReferrer == "" {
 			sourceCounts["(Direct)"]++
 		} else {
@@ -179,12 +227,17 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 			}
 			sourceCounts[domain]++
 		}
-		mediumCounts[classifyMedium(rawReferrer)]++
+		mediumCounts[statsstore.ClassifyMedium(rawReferrer)]++
 
-		// Campaigns, Terms
-		if u := parseReferrerURL(rawReferrer); u != nil {
-			if campaign := strings.TrimSpace(u.Query().Get("utm_campaign")); campaign != "" {
-				campaignCounts[campaign]++
+		// Campaigns, Terms. A stored campaign (e.g. from the tracking
+		// pixel) takes precedence over utm_campaign in the referrer.
+		campaign := strings.TrimSpace(v.GetCampaign())
+		if campaign != "" {
+			campaignCounts[campaign]++
+		}
+		if u := statsstore.ParseReferrerURL(rawReferrer); u != nil {
+			if utmCampaign := strings.TrimSpace(u.Query().Get("utm_campaign")); utmCampaign != "" && campaign == "" {
+				campaignCounts[utmCampaign]++
 			}
 			if term := strings.TrimSpace(u.Query().Get("utm_term")); term != "" {
 				termCounts[term]++
@@ -205,6 +258,24 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 		}
 		osCounts[os]++
 
+		// In-app browsers / webviews, with standalone browsers as one bucket
+		// so the embedded share is visible.
+		if v.GetUserWebview() == statsstore.VALUE_YES {
+			app := strings.TrimSpace(v.GetUserApp())
+			if app == "" {
+				app = "Unknown WebView"
+			}
+			inAppCounts[app]++
+		} else {
+			inAppCounts["Standalone Browser"]++
+		}
+
+		engine := strings.TrimSpace(v.GetUserBrowserEngine())
+		if engine == "" {
+			engine = "Unknown"
+		}
+		engineCounts[engine]++
+
 		if lang := strings.TrimSpace(v.GetUserAcceptLanguage()); lang != "" {
 			parts := strings.Split(lang, ",")
 			primary := strings.TrimSpace(parts[0])
@@ -220,29 +291,34 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 				languageCounts[primary]++
 			}
 		}
+	}
 
-		// Outbound links
-		rawPath := v.GetPath()
-		if rawPath != "" && rawPath != "/" {
-			lower := strings.ToLower(rawPath)
-			if strings.HasPrefix(lower, "/outbound/") || strings.HasPrefix(lower, "/out/") {
-				name := strings.TrimSpace(rawPath[strings.Index(lower, "/")+1:])
-				name = strings.TrimPrefix(name, "outbound/")
-				name = strings.TrimPrefix(name, "out/")
-				if name == "" {
-					name = "unnamed"
-				}
-				outboundCounts[name]++
-			}
+	addRollupCounts(data, referrerCounts, pageCounts, browserCounts, countryCounts, channelCounts, sourceCounts, deviceCounts, osCounts)
+
+	// Stored events. Link clicks (see statsstore.NewRedirectHandler) are
+	// broken down by destination in their own tabs.
+	for _, e := range data.events {
+		switch e.GetName() {
+		case statsstore.EVENT_NAME_OUTBOUND_LINK:
+			outboundCounts[eventDestination(e)]++
+		case statsstore.EVENT_NAME_DOWNLOAD:
+			downloadCounts[eventDestination(e)]++
+		default:
+			eventCounts[e.GetName()]++
 		}
 	}
 
 	// Session-based: entry + exit pages from a single session map
 	entryCounts, exitCounts := computeEntryExitPagesSinglePass(visitors)
 
+	// Revenue, credited to the visit each order was placed in.
+	revenue := statsstore.ComputeRevenue(visitors, data.events, data.rates)
+	countryName := func(code string) string { return shared.ResolvedCountryName(data.ui, code) }
+
 	return trafficSourcesData{
 		Referrers:        topEntries(referrerCounts, 10),
 		Pages:            topEntries(pageCounts, 10),
+		PageTitles:       topEntries(pageTitleCounts, 10),
 		Browsers:         topEntries(browserCounts, 10),
 		Countries:        topEntries(countryCounts, 10),
 		Events:           topEntries(eventCounts, 10),
@@ -255,11 +331,49 @@ func computeTrafficSources(data ControllerData) trafficSourcesData {
 		ExitPages:        topEntries(exitCounts, 10),
 		Devices:          topEntries(deviceCounts, 10),
 		OperatingSystems: topEntries(osCounts, 10),
+		InAppBrowsers:    topEntries(inAppCounts, 10),
+		Engines:          topEntries(engineCounts, 10),
+		ScreenSizes:      topEntries(screenSizeCounts, 10),
 		Languages:        topEntries(languageCounts, 10),
 		OutboundLinks:    topEntries(outboundCounts, 10),
+		Downloads:        topEntries(downloadCounts, 10),
+
+		RevenueCurrency:     revenue.Currency,
+		RevenueSources:      revenueEntries(revenue.BySource, nil, 10),
+		RevenueCampaigns:    revenueEntries(revenue.ByCampaign, nil, 10),
+		RevenueLandingPages: revenueEntries(revenue.ByLandingPage, nil, 10),
+		RevenueCountries:    revenueEntries(revenue.ByCountry, countryName, 10),
+
+		RollupNote: rollupCoverageNote(data.rollups, data.dates),
 	}
 }
 
+// revenueEntries converts revenue breakdowns, already sorted by revenue,
+// into trafficSourceEntry rows capped at maxItems. label, when set, maps
+// the breakdown label for display.
+func revenueEntries(breakdowns []statsstore.RevenueBreakdown, label func(string) string, maxItems int) []trafficSourceEntry {
+	entries := make([]trafficSourceEntry, 0, maxItems)
+	for i := 0; i < len(breakdowns) && i < maxItems; i++ {
+		name := breakdowns[i].Label
+		if label != nil {
+			name = label(name)
+		}
+		entries = append(entries, trafficSourceEntry{
+			Label:    name,
+			Sessions: strconv.FormatFloat(breakdowns[i].Revenue, 'f', 2, 64),
+		})
+	}
+	return entries
+}
+
+// eventDestination returns the destination URL of a link click event.
+func eventDestination(e statsstore.EventInterface) string {
+	if destination := strings.TrimSpace(e.GetPropsMap()["url"]); destination != "" {
+		return destination
+	}
+	return "(unknown)"
+}
+
 // topEntries converts a count map into a sorted slice of trafficSourceEntry
 // capped at maxItems, sorted by count descending.
 func topEntries(counts map[string]int64, maxItems int) []trafficSourceEntry {
@@ -424,7 +538,8 @@ func intensityLevel(ratio float64) int {
 }
 
 // computeStatsOverview computes the extended statistics (sessions, pageviews,
-// pages per session, bounce rate, session duration) from the visitor list.
+// pages per session, bounce rate, session duration, time on page, scroll
+// depth) from the visitor list.
 type extendedStats struct {
 	Sessions               string
 	Pageviews              string
@@ -433,22 +548,19 @@ type extendedStats struct {
 	BounceRateValue        float64
 	SessionDuration        string
 	SessionDurationSeconds float64
+	TimeOnPage             string
+	TimeOnPageSeconds      float64
+	ScrollDepth            string
+	ScrollDepthValue       float64
 }
 
 func computeStatsOverview(visitors []statsstore.VisitorInterface) extendedStats {
 	totalPageviews := int64(len(visitors))
 
-	// Group by fingerprint (calculating it on the fly if not stored) to
-	// identify unique visitors/sessions for bounce rate and visit duration.
+	// Group by session (see sessionKey) for bounce rate and visit duration.
 	sessions := map[string][]statsstore.VisitorInterface{}
 	for _, v := range visitors {
-		key := strings.TrimSpace(v.GetFingerprint())
-		if key == "" {
-			key = v.FingerprintCalculate()
-		}
-		if key == "" {
-			key = "unknown"
-		}
+		key := sessionKey(v)
 		sessions[key] = append(sessions[key], v)
 	}
 
@@ -467,6 +579,17 @@ func computeStatsOverview(visitors []statsstore.VisitorInterface) extendedStats
 	for _, sessionVisitors := range sessions {
 		if len(sessionVisitors) == 1 {
 			bounceSessions++
+		}
+
+		// Sessions with tracker engagement pings use the measured visible
+		// time, which also covers the last page and bounces.
+		if engaged, ok := sessionEngagedSeconds(sessionVisitors); ok {
+			totalIntervalSeconds += engaged
+			intervalCount++
+			continue
+		}
+
+		if len(sessionVisitors) == 1 {
 			continue
 		}
 
@@ -501,6 +624,8 @@ func computeStatsOverview(visitors []statsstore.VisitorInterface) extendedStats
 		avgVisitDuration = totalIntervalSeconds / float64(intervalCount)
 	}
 
+	timeOnPage, scrollDepth := pageEngagementAverages(visitors)
+
 	return extendedStats{
 		Sessions:               formatCount(sessionCount),
 		Pageviews:              formatCount(totalPageviews),
@@ -509,7 +634,52 @@ func computeStatsOverview(visitors []statsstore.VisitorInterface) extendedStats
 		BounceRateValue:        bounceRate,
 		SessionDuration:        formatDuration(avgVisitDuration),
 		SessionDurationSeconds: avgVisitDuration,
+		TimeOnPage:             formatDuration(timeOnPage),
+		TimeOnPageSeconds:      timeOnPage,
+		ScrollDepth:            formatFloat2(scrollDepth) + "%",
+		ScrollDepthValue:       scrollDepth,
+	}
+}
+
+// sessionEngagedSeconds sums the engagement seconds reported by the tracker
+// for a session. ok is false when no page view in the session has
+// engagement data.
+func sessionEngagedSeconds(sessionVisitors []statsstore.VisitorInterface) (float64, bool) {
+	total := float64(0)
+	found := false
+	for _, v := range sessionVisitors {
+		if seconds, err := strconv.Atoi(v.GetEngagementSeconds()); err == nil {
+			total += float64(seconds)
+			found = true
+		}
 	}
+	return total, found
+}
+
+// pageEngagementAverages returns the average time on page (seconds) and
+// scroll depth (percent) over page views that reported engagement.
+func pageEngagementAverages(visitors []statsstore.VisitorInterface) (float64, float64) {
+	var totalSeconds, totalDepth float64
+	var secondsCount, depthCount int
+	for _, v := range visitors {
+		if seconds, err := strconv.Atoi(v.GetEngagementSeconds()); err == nil {
+			totalSeconds += float64(seconds)
+			secondsCount++
+		}
+		if depth, err := strconv.Atoi(v.GetScrollDepth()); err == nil {
+			totalDepth += float64(depth)
+			depthCount++
+		}
+	}
+
+	timeOnPage, scrollDepth := float64(0), float64(0)
+	if secondsCount > 0 {
+		timeOnPage = totalSeconds / float64(secondsCount)
+	}
+	if depthCount > 0 {
+		scrollDepth = totalDepth / float64(depthCount)
+	}
+	return timeOnPage, scrollDepth
 }
 
 func formatFloat2(f float64) string {
@@ -533,90 +703,32 @@ func formatDuration(seconds float64) string {
 	return formatInt(int64(secs)) + "s"
 }
 
-// == TRAFFIC SOURCE BREAKDOWN COMPUTATIONS ====================================
-
-var searchEngines = map[string]bool{
-	"google.com": true, "bing.com": true, "duckduckgo.com": true,
-	"yahoo.com": true, "baidu.com": true, "yandex.com": true,
-	"ecosia.org": true, "ask.com": true, "aol.com": true,
-}
-
-var socialNetworks = map[string]bool{
-	"facebook.com": true, "twitter.com": true, "x.com": true,
-	"linkedin.com": true, "instagram.com": true, "pinterest.com": true,
-	"reddit.com": true, "tiktok.com": true, "youtube.com": true,
-	"tumblr.com": true, "mastodon.social": true, "threads.net": true,
-}
-
-func extractDomain(rawURL string) string {
-	rawURL = strings.TrimSpace(rawURL)
-	for _, prefix := range []string{"https://", "http://", "www."} {
-		rawURL = strings.TrimPrefix(rawURL, prefix)
+// sessionKey returns the key page views are grouped into sessions by: the
+// fingerprint, calculated on the fly if not stored. Visits recorded
+// aggregate only have no fingerprint to calculate, so each is a session of
+// its own.
+func sessionKey(v statsstore.VisitorInterface) string {
+	if v.GetPrivacy() != "" {
+		return "aggregate:" + v.GetID()
 	}
-	if idx := strings.Index(rawURL, "/"); idx > 0 {
-		rawURL = rawURL[:idx]
+	key := strings.TrimSpace(v.GetFingerprint())
+	if key == "" {
+		key = v.FingerprintCalculate()
 	}
-	return rawURL
-}
-
-func parseReferrerURL(referrer string) *url.URL {
-	referrer = strings.TrimSpace(referrer)
-	if referrer == "" {
-		return nil
-	}
-	if !strings.HasPrefix(referrer, "http://") && !strings.HasPrefix(referrer, "https://") {
-		referrer = "https://" + referrer
-	}
-	u, err := url.Parse(referrer)
-	if err != nil {
-		return nil
-	}
-	return u
-}
-
-func classifyChannel(domain string) string {
-	if domain == "" {
-		return "Direct"
-	}
-	if searchEngines[domain] {
-		return "Organic Search"
+	if key == "" {
+		key = "unknown"
 	}
-	if socialNetworks[domain] {
-		return "Social"
-	}
-	return "Referral"
+	return key
 }
 
-func classifyMedium(referrer string) string {
-	if referrer == "" {
-		return "direct"
-	}
-	u := parseReferrerURL(referrer)
-	if u == nil {
-		return "referral"
-	}
-	if q := u.Query(); q.Get("utm_medium") != "" {
-		return q.Get("utm_medium")
-	}
-	domain := extractDomain(referrer)
-	if searchEngines[domain] {
-		return "organic"
-	}
-	return "referral"
-}
+// == TRAFFIC SOURCE BREAKDOWN COMPUTATIONS ====================================
 
 // computeEntryExitPagesSinglePass builds a session map once and extracts
 // both entry and exit page counts from it.
 func computeEntryExitPagesSinglePass(visitors []statsstore.VisitorInterface) (entryCounts, exitCounts map[string]int64) {
 	sessions := map[string][]statsstore.VisitorInterface{}
 	for _, v := range visitors {
-		key := strings.TrimSpace(v.GetFingerprint())
-		if key == "" {
-			key = v.FingerprintCalculate()
-		}
-		if key == "" {
-			key = "unknown"
-		}
+		key := sessionKey(v)
 		sessions[key] = append(sessions[key], v)
 	}
 
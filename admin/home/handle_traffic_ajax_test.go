@@ -0,0 +1,184 @@
+package home
+
+import (
+	"testing"
+
+	"github.com/dracory/statsstore"
+)
+
+func TestComputeTrafficSourcesInAppBrowsers(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetUserWebview(statsstore.VALUE_YES).SetUserApp("Instagram").SetUserBrowserEngine(statsstore.EngineWebKit),
+		statsstore.NewVisitor().SetUserWebview(statsstore.VALUE_YES).SetUserApp("Instagram").SetUserBrowserEngine(statsstore.EngineWebKit),
+		statsstore.NewVisitor().SetUserWebview(statsstore.VALUE_YES).SetUserBrowserEngine(statsstore.EngineBlink),
+		statsstore.NewVisitor().SetUserWebview(statsstore.VALUE_NO).SetUserBrowserEngine(statsstore.EngineBlink),
+	}
+
+	tsd := computeTrafficSources(ControllerData{visitors: visitors})
+
+	want := map[string]string{"Instagram": "2", "Unknown WebView": "1", "Standalone Browser": "1"}
+	if len(tsd.InAppBrowsers) != len(want) {
+		t.Fatalf("unexpected in-app breakdown: %+v", tsd.InAppBrowsers)
+	}
+	for _, entry := range tsd.InAppBrowsers {
+		if want[entry.Label] != entry.Sessions {
+			t.Errorf("%s = %s, want %s", entry.Label, entry.Sessions, want[entry.Label])
+		}
+	}
+
+	for _, entry := range tsd.Engines {
+		if entry.Sessions != "2" {
+			t.Errorf("engine %s = %s, want 2", entry.Label, entry.Sessions)
+		}
+	}
+	if len(tsd.Engines) != 2 {
+		t.Errorf("unexpected engine breakdown: %+v", tsd.Engines)
+	}
+}
+
+func TestComputeTrafficSourcesPageTitlesAndScreens(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetPageTitle("Pricing").SetScreenSize("1920x1080"),
+		statsstore.NewVisitor().SetPageTitle("Pricing").SetScreenSize("390x844"),
+		statsstore.NewVisitor(),
+	}
+
+	tsd := computeTrafficSources(ControllerData{visitors: visitors})
+
+	if len(tsd.PageTitles) != 1 || tsd.PageTitles[0].Label != "Pricing" || tsd.PageTitles[0].Sessions != "2" {
+		t.Errorf("unexpected page titles: %+v", tsd.PageTitles)
+	}
+	if len(tsd.ScreenSizes) != 2 {
+		t.Errorf("unexpected screen sizes: %+v", tsd.ScreenSizes)
+	}
+}
+
+func TestComputeTrafficSourcesCampaigns(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetCampaign("nl-42"),
+		statsstore.NewVisitor().SetCampaign("nl-42").SetUserReferrer("https://example.com/?utm_campaign=spring"),
+		statsstore.NewVisitor().SetUserReferrer("https://example.com/?utm_campaign=spring"),
+	}
+
+	tsd := computeTrafficSources(ControllerData{visitors: visitors})
+
+	want := map[string]string{"nl-42": "2", "spring": "1"}
+	if len(tsd.Campaigns) != len(want) {
+		t.Fatalf("unexpected campaigns: %+v", tsd.Campaigns)
+	}
+	for _, entry := range tsd.Campaigns {
+		if want[entry.Label] != entry.Sessions {
+			t.Errorf("%s = %s, want %s", entry.Label, entry.Sessions, want[entry.Label])
+		}
+	}
+}
+
+func TestComputeTrafficSourcesStoredEvents(t *testing.T) {
+	outbound := func(url string) statsstore.EventInterface {
+		return statsstore.NewEvent().SetName(statsstore.EVENT_NAME_OUTBOUND_LINK).SetPropsMap(map[string]string{"url": url})
+	}
+	events := []statsstore.EventInterface{
+		outbound("https://partner.example.org/"),
+		outbound("https://partner.example.org/"),
+		outbound("https://docs.example.net/"),
+		statsstore.NewEvent().SetName(statsstore.EVENT_NAME_DOWNLOAD).SetPropsMap(map[string]string{"url": "https://cdn.example.com/report.pdf"}),
+		statsstore.NewEvent().SetName("Signup"),
+	}
+
+	tsd := computeTrafficSources(ControllerData{events: events})
+
+	if len(tsd.OutboundLinks) != 2 || tsd.OutboundLinks[0].Label != "https://partner.example.org/" || tsd.OutboundLinks[0].Sessions != "2" {
+		t.Errorf("unexpected outbound links: %+v", tsd.OutboundLinks)
+	}
+	if len(tsd.Downloads) != 1 || tsd.Downloads[0].Label != "https://cdn.example.com/report.pdf" {
+		t.Errorf("unexpected downloads: %+v", tsd.Downloads)
+	}
+	if len(tsd.Events) != 1 || tsd.Events[0].Label != "Signup" {
+		t.Errorf("unexpected custom events: %+v", tsd.Events)
+	}
+}
+
+func TestComputeTrafficSourcesRevenue(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetFingerprint("a").SetPath("/pricing").SetCountry("DE").SetUserReferrer("https://www.google.com/"),
+		statsstore.NewVisitor().SetFingerprint("b").SetPath("/").SetCountry("US"),
+	}
+	order := func(fingerprint, orderID, amount, currency string) statsstore.EventInterface {
+		return statsstore.NewEvent().SetName("Purchase").SetFingerprint(fingerprint).SetOrderID(orderID).
+			SetRevenueAmount(amount).SetRevenueCurrency(currency)
+	}
+	events := []statsstore.EventInterface{
+		order("a", "1001", "100", "EUR"),
+		order("a", "1001", "100", "EUR"),
+		order("b", "1002", "20", "USD"),
+	}
+	rates := statsstore.CurrencyRates{Base: "USD", Rates: map[string]float64{"EUR": 1.1}}
+
+	tsd := computeTrafficSources(ControllerData{visitors: visitors, events: events, rates: rates})
+
+	if tsd.RevenueCurrency != "USD" {
+		t.Errorf("revenue currency = %q, want USD", tsd.RevenueCurrency)
+	}
+	if len(tsd.RevenueSources) != 2 || tsd.RevenueSources[0].Label != "google.com" || tsd.RevenueSources[0].Sessions != "110.00" {
+		t.Errorf("unexpected revenue sources: %+v", tsd.RevenueSources)
+	}
+	if len(tsd.RevenueLandingPages) != 2 || tsd.RevenueLandingPages[1].Label != "/" || tsd.RevenueLandingPages[1].Sessions != "20.00" {
+		t.Errorf("unexpected revenue landing pages: %+v", tsd.RevenueLandingPages)
+	}
+	if len(tsd.RevenueCountries) != 2 {
+		t.Errorf("unexpected revenue countries: %+v", tsd.RevenueCountries)
+	}
+}
+
+func TestComputeStatsOverviewEngagement(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetFingerprint("a").SetEngagementSeconds("30").SetScrollDepth("50"),
+		statsstore.NewVisitor().SetFingerprint("a").SetEngagementSeconds("60").SetScrollDepth("100"),
+		statsstore.NewVisitor().SetFingerprint("b").SetEngagementSeconds("90").SetScrollDepth("30"),
+	}
+
+	stats := computeStatsOverview(visitors)
+
+	// Session "a" engaged 90s and bounce "b" engaged 90s.
+	if stats.SessionDurationSeconds != 90 {
+		t.Errorf("session duration = %v, want 90", stats.SessionDurationSeconds)
+	}
+	if stats.TimeOnPageSeconds != 60 {
+		t.Errorf("time on page = %v, want 60", stats.TimeOnPageSeconds)
+	}
+	if stats.ScrollDepthValue != 60 {
+		t.Errorf("scroll depth = %v, want 60", stats.ScrollDepthValue)
+	}
+	if stats.BounceRateValue != 50 {
+		t.Errorf("bounce rate = %v, want 50", stats.BounceRateValue)
+	}
+}
+
+func TestSessionsAggregateOnly(t *testing.T) {
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetFingerprint("a").SetPath("/").SetCreatedAt("2025-03-01 10:00:00"),
+		statsstore.NewVisitor().SetFingerprint("a").SetPath("/pricing").SetCreatedAt("2025-03-01 10:01:00"),
+	}
+	for _, path := range []string{"/blog", "/docs", "/about"} {
+		visitors = append(visitors, statsstore.NewVisitor().
+			SetPrivacy(statsstore.PrivacyReasonCookieless).
+			SetPath(path).
+			SetCreatedAt("2025-03-01 10:02:00"))
+	}
+
+	// Each aggregate-only visit is a bounced session of its own.
+	stats := computeStatsOverview(visitors)
+	if stats.Sessions != "4" || stats.BounceRateValue != 75 {
+		t.Errorf("expected 4 sessions with a 75%% bounce rate, got %s and %v", stats.Sessions, stats.BounceRateValue)
+	}
+
+	entries, exits := computeEntryExitPagesSinglePass(visitors)
+	for _, path := range []string{"/blog", "/docs", "/about"} {
+		if entries[path] != 1 || exits[path] != 1 {
+			t.Errorf("expected %s to be an entry and exit page, got %d and %d", path, entries[path], exits[path])
+		}
+	}
+	if entries["/"] != 1 || exits["/pricing"] != 1 {
+		t.Errorf("unexpected entry pages %v and exit pages %v", entries, exits)
+	}
+}
@@ -9,6 +9,7 @@
             const statCards = ref([]);
             const comparisonRows = ref([]);
             const previousPeriodLabel = ref('');
+            const sessionNote = ref('');
             const dailyStats = ref([]);
             const totals = ref({});
             const trafficCards = ref([]);
@@ -88,6 +89,7 @@
                     statCards.value = data.statCards || [];
                     comparisonRows.value = data.comparisonRows || [];
                     previousPeriodLabel.value = data.previousPeriodLabel || '';
+                    sessionNote.value = data.sessionNote || '';
                 } catch (e) {
                     comparisonError.value = e.message;
                 } finally {
@@ -231,7 +233,7 @@
 
             return {
                 selectedPeriod, periodOptions, liveVisitorCount,
-                statCards, comparisonRows, previousPeriodLabel, dailyStats, totals,
+                statCards, comparisonRows, previousPeriodLabel, sessionNote, dailyStats, totals,
                 trafficCards, heatmap, metrics, selectedMetric, chartCanvas,
                 loadingOverview, loadingComparison, loadingDaily, loadingTraffic, loadingHeatmap,
                 overviewError, comparisonError, dailyError, trafficError, heatmapError,
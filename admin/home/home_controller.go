@@ -90,6 +90,10 @@ func previousPeriodBounds(selectedPeriod string, start, end *carbon.Carbon) (*ca
 		return start.Copy().SubMonths(1).StartOfMonth(), start.Copy().SubDays(1).EndOfDay(), "Previous Month"
 	case "last-month":
 		return start.Copy().SubMonths(1).StartOfMonth(), start.Copy().SubDays(1).EndOfDay(), "Previous Month"
+	case "last-90-days":
+		return start.Copy().SubDays(90), end.Copy().SubDays(90), "Previous 90 Days"
+	case "last-12-months":
+		return start.Copy().SubMonthsNoOverflow(12).StartOfMonth(), start.Copy().SubDays(1).EndOfDay(), "Previous 12 Months"
 	default: // this-week
 		return start.Copy().SubDays(7), end.Copy().SubDays(7), "Previous Week"
 	}
@@ -30,6 +30,8 @@ func (c *Controller) getPeriodBounds(r *http.Request) (periodBoundsData, string)
 		{Value: "last-week", Label: "Last Week"},
 		{Value: "this-month", Label: "This Month"},
 		{Value: "last-month", Label: "Last Month"},
+		{Value: "last-90-days", Label: "Last 90 Days"},
+		{Value: "last-12-months", Label: "Last 12 Months"},
 	}
 
 	selectedPeriod := req.GetStringOr(r, "period", "this-week")
@@ -60,6 +62,12 @@ func (c *Controller) getPeriodBounds(r *http.Request) (periodBoundsData, string)
 	case "last-month":
 		start = now.SubMonths(1).StartOfMonth()
 		end = start.Copy().EndOfMonth()
+	case "last-90-days":
+		start = now.Copy().SubDays(89).StartOfDay()
+		end = now.Copy().EndOfDay()
+	case "last-12-months":
+		start = now.Copy().SubMonthsNoOverflow(11).StartOfMonth()
+		end = now.Copy().EndOfDay()
 	default:
 		start = now.StartOfWeek()
 		end = now.EndOfWeek()
@@ -1,6 +1,8 @@
 package home
 
 import (
+	"sort"
+
 	"github.com/dracory/statsstore"
 )
 
@@ -17,12 +19,25 @@ type periodStats struct {
 	totalReturning int64
 }
 
+// dayCounts holds the page views and unique-visitor sketch of one day.
+// Days rolled up by earlier versions have no sketch, only their count.
+type dayCounts struct {
+	pageviews int64
+	uniques   int64
+	sketch    *statsstore.HyperLogLog
+}
+
 // computePeriodStats aggregates visitor records into daily and total counts for
-// the supplied date range. Unique visitors are identified by IP address.
+// the supplied date range. Unique visitors are identified by IP address and
+// counted with a HyperLogLog sketch per day; visits recorded aggregate only
+// count as page views only.
 func computePeriodStats(visitors []statsstore.VisitorInterface, dates []string) periodStats {
-	dailyPageViews := map[string]int64{}
-	dailyUniqueIPs := map[string]map[string]struct{}{}
-	firstVisitByIP := map[string]string{}
+	return periodStatsFromDays(visitorDayCounts(visitors), dates)
+}
+
+// visitorDayCounts counts the visitor records by day.
+func visitorDayCounts(visitors []statsstore.VisitorInterface) map[string]*dayCounts {
+	days := map[string]*dayCounts{}
 
 	for _, visitor := range visitors {
 		createdAt := visitor.GetCreatedAtCarbon()
@@ -36,44 +51,69 @@ func computePeriodStats(visitors []statsstore.VisitorInterface, dates []string)
 			identifier = "unknown-ip"
 		}
 
-		dailyPageViews[visitDate]++
+		day, ok := days[visitDate]
+		if !ok {
+			sketch, _ := statsstore.NewHyperLogLog(statsstore.HyperLogLogPrecisionDefault)
+			day = &dayCounts{sketch: sketch}
+			days[visitDate] = day
+		}
 
-		if _, ok := dailyUniqueIPs[visitDate]; !ok {
-			dailyUniqueIPs[visitDate] = map[string]struct{}{}
+		day.pageviews++
+		if visitor.GetPrivacy() == "" {
+			day.sketch.Add(identifier)
 		}
+	}
 
-		dailyUniqueIPs[visitDate][identifier] = struct{}{}
+	return days
+}
 
-		if existingDate, ok := firstVisitByIP[identifier]; !ok || visitDate < existingDate {
-			firstVisitByIP[identifier] = visitDate
+// periodStatsFromDays builds the period stats of dates from daily counts.
+// A visitor's first visit is on the day the running union of the daily
+// sketches, in date order, grows by them; days without a sketch report
+// neither first nor returning visits.
+func periodStatsFromDays(days map[string]*dayCounts, dates []string) periodStats {
+	sorted := make([]string, 0, len(days))
+	for date := range days {
+		sorted = append(sorted, date)
+	}
+	sort.Strings(sorted)
+
+	firstVisits := map[string]int64{}
+	var seen *statsstore.HyperLogLog
+	var seenCount int64
+	for _, date := range sorted {
+		sketch := days[date].sketch
+		if sketch == nil {
+			continue
 		}
+
+		seen = statsstore.MergeHyperLogLogs(seen, sketch)
+		count := int64(seen.Count())
+		firstVisits[date] = max(count-seenCount, 0)
+		seenCount = count
 	}
 
 	result := periodStats{dates: dates}
 
 	for _, date := range dates {
-		uniqueSet := dailyUniqueIPs[date]
-		uniqueCount := int64(len(uniqueSet))
-
-		var firstCount int64
-		for ip := range uniqueSet {
-			if firstVisitByIP[ip] == date {
-				firstCount++
+		var uniqueCount, pageViews, firstCount, returnCount int64
+		if day, ok := days[date]; ok {
+			pageViews = day.pageviews
+			uniqueCount = day.uniques
+			if day.sketch != nil {
+				uniqueCount = int64(day.sketch.Count())
+				firstCount = min(firstVisits[date], uniqueCount)
+				returnCount = uniqueCount - firstCount
 			}
 		}
 
-		returnCount := uniqueCount - firstCount
-		if returnCount < 0 {
-			returnCount = 0
-		}
-
 		result.uniqueVisits = append(result.uniqueVisits, uniqueCount)
-		result.totalVisits = append(result.totalVisits, dailyPageViews[date])
+		result.totalVisits = append(result.totalVisits, pageViews)
 		result.firstVisits = append(result.firstVisits, firstCount)
 		result.returnVisits = append(result.returnVisits, returnCount)
 
 		result.totalUnique += uniqueCount
-		result.totalTotal += dailyPageViews[date]
+		result.totalTotal += pageViews
 		result.totalFirst += firstCount
 		result.totalReturning += returnCount
 	}
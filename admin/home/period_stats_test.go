@@ -37,3 +37,20 @@ func TestComputePeriodStatsUnknownIP(t *testing.T) {
 		t.Fatalf("expected 1 unique (unknown-ip fallback), got %d", stats.totalUnique)
 	}
 }
+
+func TestComputePeriodStatsAggregateOnly(t *testing.T) {
+	base := time.Date(2026, 7, 13, 12, 0, 0, 0, time.UTC)
+	visitors := []statsstore.VisitorInterface{
+		statsstore.NewVisitor().SetIpAddress("1.1.1.1").SetCreatedAt(carbon.CreateFromStdTime(base).ToDateTimeString()),
+	}
+	for range 3 {
+		visitors = append(visitors, statsstore.NewVisitor().
+			SetPrivacy(statsstore.PrivacyReasonDoNotTrack).
+			SetCreatedAt(carbon.CreateFromStdTime(base).ToDateTimeString()))
+	}
+
+	stats := computePeriodStats(visitors, []string{"2026-07-13"})
+	if stats.totalTotal != 4 || stats.totalUnique != 1 {
+		t.Fatalf("expected 4 page views by 1 unique visitor, got %d by %d", stats.totalTotal, stats.totalUnique)
+	}
+}
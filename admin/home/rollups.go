@@ -0,0 +1,158 @@
+package home
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dracory/statsstore"
+	"github.com/dracory/statsstore/admin/shared"
+	"github.com/dromara/carbon/v2"
+)
+
+// rollupRawWindowDays is the number of recent days always read from the raw
+// visitor rows. Older days are read from the daily rollups when they have
+// been built (see statsstore.StoreInterface.RollupBuild).
+const rollupRawWindowDays = 31
+
+// loadPeriodVisitors loads the data of a period: the daily rollup rows of
+// the days older than rollupRawWindowDays that have been rolled up, and the
+// raw visitor rows of the other days.
+func (c *Controller) loadPeriodVisitors(ctx context.Context, createdAtGte, createdAtLte string, dates []string) ([]statsstore.VisitorInterface, []statsstore.RollupRow, error) {
+	rollups := []statsstore.RollupRow{}
+	covered := map[string]bool{}
+
+	rawFrom := carbon.Now(carbon.UTC).SubDays(rollupRawWindowDays).ToDateString(carbon.UTC)
+	if len(dates) > 0 && dates[0] < rawFrom {
+		from, _ := time.Parse("2006-01-02", dates[0])
+		to, _ := time.Parse("2006-01-02", rawFrom)
+
+		rows, err := c.ui.Store.RollupList(ctx, "", from, to.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, row := range rows {
+			if row.Dimension == statsstore.RollupDimensionTotal {
+				covered[row.Date] = true
+			}
+		}
+		rollups = rows
+	}
+
+	// Raw rows are loaded from the first day without a rollup.
+	first := ""
+	for _, date := range dates {
+		if !covered[date] {
+			first = date
+			break
+		}
+	}
+	if len(covered) > 0 && first == "" {
+		return []statsstore.VisitorInterface{}, rollups, nil
+	}
+	if len(covered) > 0 {
+		createdAtGte = first + " 00:00:00"
+	}
+
+	visitors, err := c.ui.Store.VisitorList(ctx, statsstore.VisitorQuery().
+		SetCreatedAtGte(createdAtGte).
+		SetCreatedAtLte(createdAtLte))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(covered) == 0 {
+		return visitors, rollups, nil
+	}
+
+	raw := make([]statsstore.VisitorInterface, 0, len(visitors))
+	for _, v := range visitors {
+		if createdAt := v.GetCreatedAtCarbon(); createdAt != nil && covered[createdAt.ToDateString()] {
+			continue
+		}
+		raw = append(raw, v)
+	}
+
+	return raw, rollups, nil
+}
+
+// rollupCoverageNote returns the note shown on the figures computed from
+// the raw visitor rows only (sessions, entry/exit pages, revenue, heatmap),
+// which leave out the days of the period read from the rollups. It is empty
+// when no day was read from the rollups.
+func rollupCoverageNote(rollups []statsstore.RollupRow, dates []string) string {
+	covered := map[string]bool{}
+	for _, row := range rollups {
+		if row.Dimension == statsstore.RollupDimensionTotal {
+			covered[row.Date] = true
+		}
+	}
+	if len(covered) == 0 {
+		return ""
+	}
+
+	for _, date := range dates {
+		if !covered[date] {
+			return "Covers " + formatSummaryDate(date) + " onwards only; older days are kept as daily totals."
+		}
+	}
+	return "Not available for this period; its days are kept as daily totals only."
+}
+
+// computePeriodStatsWithRollups is computePeriodStats with the counts of
+// the rolled-up days taken from their rollup totals. Their sketches take
+// part in the first/returning split like the raw days.
+func computePeriodStatsWithRollups(visitors []statsstore.VisitorInterface, rollups []statsstore.RollupRow, dates []string) periodStats {
+	days := visitorDayCounts(visitors)
+	for _, row := range rollups {
+		if row.Dimension == statsstore.RollupDimensionTotal {
+			days[row.Date] = &dayCounts{pageviews: row.Pageviews, uniques: row.Visitors, sketch: row.Sketch}
+		}
+	}
+
+	return periodStatsFromDays(days, dates)
+}
+
+// addRollupCounts adds the rollup rows to the traffic-source counts, using
+// the same labels as the raw visitor breakdowns.
+func addRollupCounts(data ControllerData, referrers, pages, browsers, countries, channels, sources, devices, oses map[string]int64) {
+	for _, row := range data.rollups {
+		value := row.Value
+
+		switch row.Dimension {
+		case statsstore.RollupDimensionReferrer:
+			referrer := value
+			source := value
+			if value == "" {
+				referrer = "(Direct / None)"
+				source = "(Direct)"
+			}
+			referrers[referrer] += row.Pageviews
+			sources[source] += row.Pageviews
+		case statsstore.RollupDimensionPath:
+			if value == "" {
+				value = "/"
+			}
+			pages[value] += row.Pageviews
+		case statsstore.RollupDimensionBrowser:
+			if value == "" {
+				value = "Unknown"
+			}
+			browsers[value] += row.Pageviews
+		case statsstore.RollupDimensionCountry:
+			countries[shared.ResolvedCountryName(data.ui, value)] += row.Pageviews
+		case statsstore.RollupDimensionChannel:
+			channels[value] += row.Pageviews
+		case statsstore.RollupDimensionDevice:
+			if value == "" {
+				value = "Unknown"
+			}
+			devices[strings.Title(strings.ToLower(value))] += row.Pageviews
+		case statsstore.RollupDimensionOS:
+			if value == "" {
+				value = "Unknown"
+			}
+			oses[value] += row.Pageviews
+		}
+	}
+}
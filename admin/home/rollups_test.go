@@ -0,0 +1,147 @@
+package home
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dracory/statsstore"
+	"github.com/dracory/statsstore/admin/shared"
+	"github.com/dromara/carbon/v2"
+)
+
+func TestComputePeriodStatsWithRollups(t *testing.T) {
+	sketch, _ := statsstore.NewHyperLogLog(statsstore.HyperLogLogPrecisionDefault)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4"} {
+		sketch.Add(ip)
+	}
+
+	visitors := []statsstore.VisitorInterface{}
+	for _, ip := range []string{"1.1.1.1", "5.5.5.5", "5.5.5.5"} {
+		visitors = append(visitors, statsstore.NewVisitor().SetIpAddress(ip).SetCreatedAt("2025-03-02 10:00:00"))
+	}
+
+	stats := computePeriodStatsWithRollups(visitors, []statsstore.RollupRow{
+		{Date: "2025-03-01", Dimension: statsstore.RollupDimensionTotal, Pageviews: 10, Visitors: 4, Sketch: sketch},
+		{Date: "2025-03-01", Dimension: statsstore.RollupDimensionPath, Value: "/", Pageviews: 10, Visitors: 4},
+	}, []string{"2025-03-01", "2025-03-02"})
+
+	if stats.totalVisits[0] != 10 || stats.uniqueVisits[0] != 4 || stats.firstVisits[0] != 4 {
+		t.Errorf("expected the rollup counts for the first day, got %+v", stats)
+	}
+	// 1.1.1.1 returns on the second day, after its rolled-up visit.
+	if stats.totalVisits[1] != 3 || stats.uniqueVisits[1] != 2 || stats.firstVisits[1] != 1 || stats.returnVisits[1] != 1 {
+		t.Errorf("unexpected counts for the second day %+v", stats)
+	}
+	if stats.totalTotal != 13 || stats.totalUnique != 6 || stats.totalFirst != 5 || stats.totalReturning != 1 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+
+	// Rows without a sketch keep their count but cannot be split.
+	stats = computePeriodStatsWithRollups(nil, []statsstore.RollupRow{
+		{Date: "2025-03-01", Dimension: statsstore.RollupDimensionTotal, Pageviews: 10, Visitors: 4},
+	}, []string{"2025-03-01"})
+	if stats.totalUnique != 4 || stats.totalFirst != 0 || stats.totalReturning != 0 {
+		t.Errorf("unexpected totals without a sketch %+v", stats)
+	}
+}
+
+func TestAddRollupCounts(t *testing.T) {
+	data := ControllerData{rollups: []statsstore.RollupRow{
+		{Dimension: statsstore.RollupDimensionReferrer, Value: "", Pageviews: 3},
+		{Dimension: statsstore.RollupDimensionReferrer, Value: "google.com", Pageviews: 2},
+		{Dimension: statsstore.RollupDimensionDevice, Value: "MOBILE", Pageviews: 4},
+		{Dimension: statsstore.RollupDimensionOS, Value: "", Pageviews: 1},
+	}}
+
+	referrers, sources, devices, oses := map[string]int64{}, map[string]int64{}, map[string]int64{}, map[string]int64{"Unknown": 1}
+	empty := func() map[string]int64 { return map[string]int64{} }
+	addRollupCounts(data, referrers, empty(), empty(), empty(), empty(), sources, devices, oses)
+
+	if referrers["(Direct / None)"] != 3 || referrers["google.com"] != 2 || sources["(Direct)"] != 3 {
+		t.Errorf("unexpected referrers %v and sources %v", referrers, sources)
+	}
+	if devices["Mobile"] != 4 || oses["Unknown"] != 2 {
+		t.Errorf("unexpected devices %v and operating systems %v", devices, oses)
+	}
+}
+
+func TestLoadPeriodVisitorsUsesRollups(t *testing.T) {
+	store := newTestStore(t, true)
+	ctx := context.Background()
+
+	old := carbon.Now(carbon.UTC).SubDays(60).StartOfDay().AddHours(10)
+	recent := carbon.Now(carbon.UTC).SubDays(5).StartOfDay().AddHours(10)
+
+	for _, v := range []struct {
+		ip string
+		at *carbon.Carbon
+	}{
+		{"1.1.1.1", old},
+		{"1.1.1.1", old.Copy().AddHour()},
+		{"2.2.2.2", recent},
+	} {
+		visitor := statsstore.NewVisitor().SetIpAddress(v.ip).SetCreatedAt(v.at.ToDateTimeString(carbon.UTC))
+		if err := store.VisitorCreate(ctx, visitor); err != nil {
+			t.Fatalf("failed to create visitor: %v", err)
+		}
+	}
+
+	if _, err := store.RollupBuild(ctx, old.StdTime(), old.StdTime()); err != nil {
+		t.Fatalf("failed to build rollups: %v", err)
+	}
+
+	// A raw row added after the rollup is not counted twice.
+	late := statsstore.NewVisitor().SetIpAddress("3.3.3.3").SetCreatedAt(old.Copy().AddHours(2).ToDateTimeString(carbon.UTC))
+	if err := store.VisitorCreate(ctx, late); err != nil {
+		t.Fatalf("failed to create visitor: %v", err)
+	}
+
+	controller := &Controller{ui: shared.ControllerOptions{Store: store}}
+	start := old.Copy().StartOfDay()
+	end := carbon.Now(carbon.UTC).EndOfDay()
+	dates := datesInRange(start.Copy(), end.Copy())
+
+	visitors, rollups, err := controller.loadPeriodVisitors(ctx, start.ToDateTimeString(carbon.UTC), end.ToDateTimeString(carbon.UTC), dates)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(visitors) != 1 || visitors[0].GetIpAddress() != "2.2.2.2" {
+		t.Fatalf("expected only the recent raw visitor, got %d", len(visitors))
+	}
+
+	stats := computePeriodStatsWithRollups(visitors, rollups, dates)
+	if stats.totalTotal != 3 || stats.totalUnique != 2 {
+		t.Errorf("expected 3 visits by 2 visitors, got %d by %d", stats.totalTotal, stats.totalUnique)
+	}
+}
+
+func TestRollupCoverageNote(t *testing.T) {
+	dates := []string{"2025-03-01", "2025-03-02", "2025-03-03"}
+	total := func(date string) statsstore.RollupRow {
+		return statsstore.RollupRow{Date: date, Dimension: statsstore.RollupDimensionTotal}
+	}
+
+	if note := rollupCoverageNote(nil, dates); note != "" {
+		t.Errorf("expected no note without rollups, got %q", note)
+	}
+	if note := rollupCoverageNote([]statsstore.RollupRow{total("2025-03-01")}, dates); !strings.Contains(note, formatSummaryDate("2025-03-02")) {
+		t.Errorf("expected the note to name the first raw day, got %q", note)
+	}
+	if note := rollupCoverageNote([]statsstore.RollupRow{total("2025-03-01"), total("2025-03-02"), total("2025-03-03")}, dates); !strings.HasPrefix(note, "Not available") {
+		t.Errorf("expected the figures to be unavailable, got %q", note)
+	}
+
+	tsd := computeTrafficSources(ControllerData{rollups: []statsstore.RollupRow{total("2025-03-01")}, dates: dates})
+	for _, card := range buildTrafficCardsJSON(tsd) {
+		for _, tab := range card.Tabs {
+			rawOnly := tab.Label == "Entry Pages" || card.Title == "Revenue"
+			if rawOnly && tab.Note == "" {
+				t.Errorf("expected a note on %s / %s", card.Title, tab.Label)
+			}
+			if tab.Label == "Referrers" && tab.Note != "" {
+				t.Errorf("expected no note on the rolled-up referrers, got %q", tab.Note)
+			}
+		}
+	}
+}
@@ -47,6 +47,7 @@ type trafficCardJSON struct {
 type trafficTabJSON struct {
 	Label   string               `json:"label"`
 	Entries []trafficSourceEntry `json:"entries"`
+	Note    string               `json:"note,omitempty"`
 }
 
 // heatmapJSON represents the weekly heatmap data.
@@ -54,6 +55,7 @@ type heatmapJSON struct {
 	Days        []string `json:"days"`
 	Slots       []string `json:"slots"`
 	Intensities [][]int  `json:"intensities"`
+	Note        string   `json:"note,omitempty"`
 }
 
 // jsonMarshal marshals v to JSON string, returning an error JSON on failure.
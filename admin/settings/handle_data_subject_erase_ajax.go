@@ -0,0 +1,41 @@
+package settings
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// handleDataSubjectEraseAjax deletes or anonymizes the records of a data
+// subject. The erasure is recorded in the audit log.
+func (c *Controller) handleDataSubjectEraseAjax(w http.ResponseWriter, r *http.Request) string {
+	subject := dataSubjectFromRequest(r)
+	if subject.IsEmpty() {
+		api.Respond(w, r, api.Error("Enter an IP address, fingerprint or user ID"))
+		return ""
+	}
+
+	mode := req.GetStringOr(r, "mode", statsstore.DataSubjectErasureDelete)
+	result, err := c.UI.Store.DataSubjectErase(r.Context(), subject, mode, dataSubjectActor(r))
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	verb := "Deleted"
+	if result.Mode == statsstore.DataSubjectErasureAnonymize {
+		verb = "Anonymized"
+	}
+
+	api.Respond(w, r, api.SuccessWithData(
+		fmt.Sprintf("%s %d visitor record(s) and %d event(s)", verb, result.Visitors, result.Events),
+		map[string]any{
+			"result": result,
+		},
+	))
+
+	return ""
+}
@@ -0,0 +1,29 @@
+package settings
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// handleDataSubjectExport downloads the records of a data subject as JSON,
+// for an access request. The export is recorded in the audit log.
+func (c *Controller) handleDataSubjectExport(w http.ResponseWriter, r *http.Request) string {
+	subject := dataSubjectFromRequest(r)
+	if subject.IsEmpty() {
+		w.WriteHeader(http.StatusBadRequest)
+		return "Enter an IP address, fingerprint or user ID"
+	}
+
+	data, err := c.UI.Store.DataSubjectExport(r.Context(), subject, dataSubjectActor(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return "Failed to generate export"
+	}
+
+	filename := fmt.Sprintf("data-subject-%s.json", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	return string(data)
+}
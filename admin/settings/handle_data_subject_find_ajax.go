@@ -0,0 +1,30 @@
+package settings
+
+import (
+	"net/http"
+
+	"github.com/dracory/api"
+)
+
+// handleDataSubjectFindAjax counts the visitor and event records tied to the
+// IP, fingerprint or user ID of a data subject
+func (c *Controller) handleDataSubjectFindAjax(w http.ResponseWriter, r *http.Request) string {
+	subject := dataSubjectFromRequest(r)
+	if subject.IsEmpty() {
+		api.Respond(w, r, api.Error("Enter an IP address, fingerprint or user ID"))
+		return ""
+	}
+
+	records, err := c.UI.Store.DataSubjectFind(r.Context(), subject)
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
+		"visitors": len(records.Visitors),
+		"events":   len(records.Events),
+	}))
+
+	return ""
+}
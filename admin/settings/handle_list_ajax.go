@@ -2,11 +2,21 @@ package settings
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/dracory/api"
+	"github.com/dracory/statsstore"
 )
 
-// handleListAjax returns the current excluded IPs list as JSON
+// privacyReportPeriod is the period the privacy card counts visits over.
+const privacyReportPeriod = 30 * 24 * time.Hour
+
+// dataSubjectAuditLimit is the number of audit log entries the data subject
+// card lists.
+const dataSubjectAuditLimit = 20
+
+// handleListAjax returns the current excluded IPs list, currency rates,
+// privacy report, data-subject audit log and retention rules as JSON
 func (c *Controller) handleListAjax(w http.ResponseWriter, r *http.Request) string {
 	ips, err := c.UI.Store.ExcludedIPList(r.Context())
 	if err != nil {
@@ -14,8 +24,38 @@ func (c *Controller) handleListAjax(w http.ResponseWriter, r *http.Request) stri
 		return ""
 	}
 
+	rates, err := c.UI.Store.CurrencyRatesGet(r.Context())
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	now := time.Now().UTC()
+	privacy, err := c.UI.Store.PrivacyReport(r.Context(), now.Add(-privacyReportPeriod), now)
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	audit, err := c.UI.Store.DataSubjectAuditList(r.Context(), dataSubjectAuditLimit)
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	retentionRules, err := c.UI.Store.RetentionRuleList(r.Context())
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
 	api.Respond(w, r, api.SuccessWithData("success", map[string]any{
-		"excludedIps": ips,
+		"excludedIps":      ips,
+		"currencyRates":    rates,
+		"privacy":          privacy,
+		"audit":            audit,
+		"retentionRules":   retentionRules,
+		"retentionTargets": statsstore.RetentionTargets,
 	}))
 
 	return ""
@@ -0,0 +1,85 @@
+package settings
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// handleSaveRetentionRuleAjax adds or updates a retention rule
+func (c *Controller) handleSaveRetentionRuleAjax(w http.ResponseWriter, r *http.Request) string {
+	rule := statsstore.RetentionRule{
+		ID:     strings.TrimSpace(req.GetString(r, "id")),
+		Name:   req.GetString(r, "name"),
+		Target: strings.TrimSpace(req.GetString(r, "target")),
+	}
+
+	for _, field := range []struct {
+		name  string
+		label string
+		value *int
+	}{
+		{"max_age_months", "Months", &rule.MaxAgeMonths},
+		{"max_age_days", "Days", &rule.MaxAgeDays},
+	} {
+		value := strings.TrimSpace(req.GetString(r, field.name))
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			api.Respond(w, r, api.Error(field.label+" must be a whole number"))
+			return ""
+		}
+		*field.value = parsed
+	}
+
+	if _, err := c.UI.Store.RetentionRuleSave(r.Context(), rule); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Retention rule saved"))
+
+	return ""
+}
+
+// handleDeleteRetentionRuleAjax removes a retention rule
+func (c *Controller) handleDeleteRetentionRuleAjax(w http.ResponseWriter, r *http.Request) string {
+	if err := c.UI.Store.RetentionRuleDelete(r.Context(), strings.TrimSpace(req.GetString(r, "id"))); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Retention rule deleted"))
+
+	return ""
+}
+
+// handleRetentionPurgeAjax applies the retention rules, or previews them
+// when dry_run is "yes"
+func (c *Controller) handleRetentionPurgeAjax(w http.ResponseWriter, r *http.Request) string {
+	dryRun := req.GetString(r, "dry_run") == "yes"
+
+	report, err := c.UI.Store.RetentionPurge(r.Context(), statsstore.RetentionPurgeOptions{DryRun: dryRun})
+	if err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	message := fmt.Sprintf("Deleted %d row(s)", report.Deleted)
+	if dryRun {
+		message = "Preview ready"
+	}
+
+	api.Respond(w, r, api.SuccessWithData(message, map[string]any{
+		"report": report,
+	}))
+
+	return ""
+}
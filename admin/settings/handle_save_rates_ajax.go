@@ -0,0 +1,49 @@
+package settings
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dracory/api"
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// handleSaveRatesAjax stores the currency rates revenue is reported with.
+// The rates are sent one per line as "CODE=rate", e.g. "EUR=1.08".
+func (c *Controller) handleSaveRatesAjax(w http.ResponseWriter, r *http.Request) string {
+	rates := statsstore.CurrencyRates{
+		Base:  strings.TrimSpace(req.GetString(r, "base")),
+		Rates: map[string]float64{},
+	}
+
+	for _, line := range strings.Split(req.GetString(r, "rates"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		currency, value, ok := strings.Cut(line, "=")
+		if !ok {
+			api.Respond(w, r, api.Error("Invalid rate line: "+line))
+			return ""
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			api.Respond(w, r, api.Error("Invalid rate line: "+line))
+			return ""
+		}
+		rates.Rates[strings.TrimSpace(currency)] = rate
+	}
+
+	if err := c.UI.Store.CurrencyRatesSave(r.Context(), rates); err != nil {
+		api.Respond(w, r, api.Error(err.Error()))
+		return ""
+	}
+
+	api.Respond(w, r, api.Success("Currency rates saved"))
+
+	return ""
+}
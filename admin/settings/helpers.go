@@ -0,0 +1,27 @@
+package settings
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dracory/req"
+	"github.com/dracory/statsstore"
+)
+
+// dataSubjectFromRequest reads the identifiers of a data-subject request
+func dataSubjectFromRequest(r *http.Request) statsstore.DataSubject {
+	return statsstore.DataSubject{
+		IP:          strings.TrimSpace(req.GetString(r, "ip_address")),
+		Fingerprint: strings.TrimSpace(req.GetString(r, "fingerprint")),
+		UserID:      strings.TrimSpace(req.GetString(r, "user_id")),
+	}
+}
+
+// dataSubjectActor returns who handles a data-subject request for the audit
+// log: the signed-in user set with statsstore.WithUserID, or "admin"
+func dataSubjectActor(r *http.Request) string {
+	if actor := statsstore.UserIDFromContext(r.Context()); actor != "" {
+		return actor
+	}
+	return "admin"
+}
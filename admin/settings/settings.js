@@ -5,11 +5,26 @@
         setup() {
             const excludedIps = ref([]);
             const newIp = ref('');
+            const ratesBase = ref('USD');
+            const ratesText = ref('');
+            const privacy = ref(null);
+            const subject = ref({ ip: '', fingerprint: '', userId: '' });
+            const subjectFound = ref(null);
+            const audit = ref([]);
+            const retentionRules = ref([]);
+            const retentionTargets = ref([]);
+            const newRule = ref({ name: '', target: 'visitors', months: '', days: '' });
+            const retentionReport = ref(null);
             const loading = ref(false);
             const loaded = ref(false);
             const error = ref('');
             const success = ref('');
 
+            function percentOfVisits(count) {
+                if (!privacy.value || !privacy.value.visits) return '0%';
+                return (count / privacy.value.visits * 100).toFixed(1) + '%';
+            }
+
             function buildApiUrl() {
                 const params = new URLSearchParams();
                 params.set('path', '/admin/settings');
@@ -34,6 +49,15 @@
                     const formData = new FormData();
                     const data = await fetchSection('list-ajax', formData);
                     excludedIps.value = data.excludedIps || [];
+                    const rates = data.currencyRates || {};
+                    ratesBase.value = rates.base || 'USD';
+                    ratesText.value = Object.keys(rates.rates || {}).sort()
+                        .map(code => code + '=' + rates.rates[code])
+                        .join('\n');
+                    privacy.value = data.privacy || null;
+                    audit.value = data.audit || [];
+                    retentionRules.value = data.retentionRules || [];
+                    retentionTargets.value = data.retentionTargets || [];
                 } catch (e) {
                     error.value = e.message;
                 } finally {
@@ -78,6 +102,24 @@
                 }
             }
 
+            async function saveRates() {
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('base', ratesBase.value.trim());
+                    formData.set('rates', ratesText.value);
+                    await fetchSection('save-rates-ajax', formData);
+                    await loadIps();
+                    success.value = 'Currency rates saved';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
             async function deleteVisitorsByIp(ip) {
                 if (!confirm('Permanently delete ALL visitor records from IP ' + ip + '? This cannot be undone.')) return;
                 loading.value = true;
@@ -96,13 +138,161 @@
                 }
             }
 
+            function subjectEmpty() {
+                const s = subject.value;
+                return !s.ip.trim() && !s.fingerprint.trim() && !s.userId.trim();
+            }
+
+            function subjectFormData() {
+                const formData = new FormData();
+                formData.set('ip_address', subject.value.ip.trim());
+                formData.set('fingerprint', subject.value.fingerprint.trim());
+                formData.set('user_id', subject.value.userId.trim());
+                return formData;
+            }
+
+            async function findSubject() {
+                if (subjectEmpty()) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    subjectFound.value = await fetchSection('data-subject-find-ajax', subjectFormData());
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            async function exportSubject() {
+                if (subjectEmpty()) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = subjectFormData();
+                    formData.set('action', 'data-subject-export');
+                    const resp = await fetch(buildApiUrl(), {
+                        method: 'POST',
+                        body: formData
+                    });
+                    if (!resp.ok) throw new Error(await resp.text() || 'Export failed');
+                    const disposition = resp.headers.get('Content-Disposition') || '';
+                    const match = disposition.match(/filename="([^"]+)"/);
+                    const link = document.createElement('a');
+                    link.href = URL.createObjectURL(await resp.blob());
+                    link.download = match ? match[1] : 'data-subject.json';
+                    link.click();
+                    URL.revokeObjectURL(link.href);
+                    await loadIps();
+                    success.value = 'Data subject export downloaded';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            async function eraseSubject(mode) {
+                if (subjectEmpty()) return;
+                const what = mode === 'anonymize' ? 'Anonymize' : 'Permanently delete';
+                if (!confirm(what + ' ALL visitor and event records of this data subject? This cannot be undone.')) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = subjectFormData();
+                    formData.set('mode', mode);
+                    const data = await fetchSection('data-subject-erase-ajax', formData);
+                    subjectFound.value = null;
+                    await loadIps();
+                    success.value = data.message || 'Data subject erased';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            function ruleAge(rule) {
+                const parts = [];
+                if (rule.max_age_months) parts.push(rule.max_age_months + ' month(s)');
+                if (rule.max_age_days) parts.push(rule.max_age_days + ' day(s)');
+                return parts.join(' ');
+            }
+
+            async function saveRule() {
+                if (!newRule.value.name.trim()) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('name', newRule.value.name.trim());
+                    formData.set('target', newRule.value.target);
+                    formData.set('max_age_months', String(newRule.value.months).trim());
+                    formData.set('max_age_days', String(newRule.value.days).trim());
+                    await fetchSection('save-retention-rule-ajax', formData);
+                    newRule.value = { name: '', target: 'visitors', months: '', days: '' };
+                    retentionReport.value = null;
+                    await loadIps();
+                    success.value = 'Retention rule saved';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            async function deleteRule(rule) {
+                if (!confirm('Delete the retention rule "' + rule.name + '"?')) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('id', rule.id);
+                    await fetchSection('delete-retention-rule-ajax', formData);
+                    retentionReport.value = null;
+                    await loadIps();
+                    success.value = 'Retention rule deleted';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
+            async function runRetention(dryRun) {
+                if (!dryRun && !confirm('Permanently delete all rows matched by the retention rules? This cannot be undone.')) return;
+                loading.value = true;
+                error.value = '';
+                success.value = '';
+                try {
+                    const formData = new FormData();
+                    formData.set('dry_run', dryRun ? 'yes' : 'no');
+                    const data = await fetchSection('retention-purge-ajax', formData);
+                    retentionReport.value = data.report || null;
+                    if (!dryRun) success.value = 'Deleted ' + (data.report ? data.report.deleted : 0) + ' row(s)';
+                } catch (e) {
+                    error.value = e.message;
+                } finally {
+                    loading.value = false;
+                }
+            }
+
             onMounted(() => {
                 loadIps();
             });
 
             return {
-                excludedIps, newIp, loading, loaded, error, success,
-                addIp, removeIp, deleteVisitorsByIp
+                excludedIps, newIp, ratesBase, ratesText, privacy, subject, subjectFound, audit,
+                retentionRules, retentionTargets, newRule, retentionReport,
+                loading, loaded, error, success,
+                addIp, removeIp, saveRates, deleteVisitorsByIp, percentOfVisits,
+                subjectEmpty, findSubject, exportSubject, eraseSubject,
+                ruleAge, saveRule, deleteRule, runRetention
             };
         }
     }).mount('#settings-app');
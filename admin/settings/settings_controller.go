@@ -43,8 +43,22 @@ func (c *Controller) Handler(w http.ResponseWriter, r *http.Request) string {
 		return c.handleAddIpAjax(w, r)
 	case "remove-ip-ajax":
 		return c.handleRemoveIpAjax(w, r)
+	case "save-rates-ajax":
+		return c.handleSaveRatesAjax(w, r)
 	case "delete-visitors-ajax":
 		return c.handleDeleteVisitorsAjax(w, r)
+	case "data-subject-find-ajax":
+		return c.handleDataSubjectFindAjax(w, r)
+	case "data-subject-export":
+		return c.handleDataSubjectExport(w, r)
+	case "data-subject-erase-ajax":
+		return c.handleDataSubjectEraseAjax(w, r)
+	case "save-retention-rule-ajax":
+		return c.handleSaveRetentionRuleAjax(w, r)
+	case "delete-retention-rule-ajax":
+		return c.handleDeleteRetentionRuleAjax(w, r)
+	case "retention-purge-ajax":
+		return c.handleRetentionPurgeAjax(w, r)
 	}
 
 	c.UI.Layout.SetTitle("Settings | Visitor Analytics")
@@ -32,6 +32,16 @@ func AdminHeaderUI(r *http.Request, homeURL string) hb.TagInterface {
 			href:  UrlVisitorPaths(r),
 			path:  PathVisitorPaths,
 		},
+		{
+			title: "Goals",
+			href:  UrlGoals(r),
+			path:  PathGoals,
+		},
+		{
+			title: "Experiments",
+			href:  UrlExperiments(r),
+			path:  PathExperiments,
+		},
 		{
 			title: "Page View Activity",
 			href:  UrlPageViewActivity(r),
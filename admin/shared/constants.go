@@ -14,6 +14,8 @@ const (
 	ControllerHome             = "home"
 	ControllerVisitorActivity  = "visitor-activity"
 	ControllerVisitorPaths     = "visitor-paths"
+	ControllerGoals            = "goals"
+	ControllerExperiments      = "experiments"
 	ControllerPageViewActivity = "page-view-activity"
 	ControllerSettings         = "settings"
 )
@@ -23,6 +25,8 @@ const (
 	PathHome             = "/admin/home"
 	PathVisitorActivity  = "/admin/visitor-activity"
 	PathVisitorPaths     = "/admin/visitor-paths"
+	PathGoals            = "/admin/goals"
+	PathExperiments      = "/admin/experiments"
 	PathPageViewActivity = "/admin/page-view-activity"
 	PathSettings         = "/admin/settings"
 )
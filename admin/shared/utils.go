@@ -38,6 +38,26 @@ func UrlVisitorPaths(r *http.Request, params ...map[string]string) string {
 	return URL(r, endpoint, p)
 }
 
+func UrlGoals(r *http.Request, params ...map[string]string) string {
+	endpoint := lo.IfF(r.Context().Value(KeyEndpoint) != nil, func() string { return r.Context().Value(KeyEndpoint).(string) }).Else("/")
+
+	p := lo.IfF(len(params) > 0, func() map[string]string { return params[0] }).Else(map[string]string{})
+
+	p["path"] = PathGoals
+
+	return URL(r, endpoint, p)
+}
+
+func UrlExperiments(r *http.Request, params ...map[string]string) string {
+	endpoint := lo.IfF(r.Context().Value(KeyEndpoint) != nil, func() string { return r.Context().Value(KeyEndpoint).(string) }).Else("/")
+
+	p := lo.IfF(len(params) > 0, func() map[string]string { return params[0] }).Else(map[string]string{})
+
+	p["path"] = PathExperiments
+
+	return URL(r, endpoint, p)
+}
+
 func UrlPageViewActivity(r *http.Request, params ...map[string]string) string {
 	endpoint := lo.IfF(r.Context().Value(KeyEndpoint) != nil, func() string { return r.Context().Value(KeyEndpoint).(string) }).Else("/")
 
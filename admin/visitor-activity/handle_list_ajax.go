@@ -34,6 +34,9 @@ type visitorJSON struct {
 	OSVer         string `json:"osVer"`
 	UserAgent     string `json:"userAgent"`
 	Fingerprint   string `json:"fingerprint"`
+	UserID        string `json:"userId"`
+	Privacy       string `json:"privacy"`
+	PrivacyLabel  string `json:"privacyLabel"`
 }
 
 // handleListAjax returns the visitor list as JSON for the Vue.js frontend
@@ -63,6 +66,9 @@ func (c *Controller) handleListAjax(w http.ResponseWriter, r *http.Request) stri
 	if filters.Device != "" {
 		options = options.SetDeviceType(filters.Device)
 	}
+	if filters.UserID != "" {
+		options = options.SetUserID(filters.UserID)
+	}
 
 	visitors, err := c.UI.Store.VisitorList(r.Context(), options)
 	if err != nil {
@@ -83,6 +89,9 @@ func (c *Controller) handleListAjax(w http.ResponseWriter, r *http.Request) stri
 	if filters.Device != "" {
 		countOptions = countOptions.SetDeviceType(filters.Device)
 	}
+	if filters.UserID != "" {
+		countOptions = countOptions.SetUserID(filters.UserID)
+	}
 
 	visitorCount, err := c.UI.Store.VisitorCount(r.Context(), countOptions)
 	if err != nil {
@@ -118,9 +127,15 @@ func (c *Controller) handleListAjax(w http.ResponseWriter, r *http.Request) stri
 			OSVer:       v.GetUserOsVersion(),
 			UserAgent:   v.GetUserAgent(),
 			Fingerprint: v.GetFingerprint(),
+			UserID:      v.GetUserID(),
 			Duration:    formatVisitDuration(v, visitors, i),
 		}
 
+		if v.GetPrivacy() != "" {
+			vj.Privacy = v.GetPrivacy()
+			vj.PrivacyLabel = statsstore.PrivacyReasonLabel(v.GetPrivacy())
+		}
+
 		vj.DeviceIcon = deviceIconClass(v)
 		vj.OSIcon = osIconClass(v)
 
@@ -166,6 +181,7 @@ func parseFiltersFromReq(r *http.Request) FilterOptions {
 		Range:   get("range"),
 		Country: get("country"),
 		Device:  get("device"),
+		UserID:  get("user_id"),
 	}
 
 	if filters.Range != "" {
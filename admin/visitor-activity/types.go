@@ -14,4 +14,5 @@ type FilterOptions struct {
 	To      string
 	Country string
 	Device  string
+	UserID  string
 }
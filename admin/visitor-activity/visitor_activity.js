@@ -20,6 +20,7 @@
                 range: '',
                 country: '',
                 device: '',
+                userId: '',
             });
 
             const exportUrl = computed(() => {
@@ -29,6 +30,7 @@
                 if (filters.range) params.set('range', filters.range);
                 if (filters.country) params.set('country', filters.country);
                 if (filters.device) params.set('device', filters.device);
+                if (filters.userId) params.set('user_id', filters.userId);
                 if (page.value > 1) params.set('page', String(page.value));
                 if (pageSize.value !== 10) params.set('per_page', String(pageSize.value));
                 return window.location.pathname + '?' + params.toString();
@@ -72,6 +74,7 @@
                     if (filters.range) formData.set('range', filters.range);
                     if (filters.country) formData.set('country', filters.country);
                     if (filters.device) formData.set('device', filters.device);
+                    if (filters.userId) formData.set('user_id', filters.userId);
 
                     const resp = await fetch(buildApiUrl(), { method: 'POST', body: formData });
                     const data = await resp.json();
@@ -99,6 +102,7 @@
                 filters.range = '';
                 filters.country = '';
                 filters.device = '';
+                filters.userId = '';
                 page.value = 1;
                 fetchList();
             }
@@ -116,6 +120,13 @@
                 fetchList();
             }
 
+            function filterByUser(userId) {
+                filters.userId = userId;
+                page.value = 1;
+                if (modalInstance) modalInstance.hide();
+                fetchList();
+            }
+
             function showDetail(visitor) {
                 selectedVisitor.value = visitor;
                 if (!modalInstance && detailModal.value) {
@@ -138,11 +149,13 @@
                 const range = urlParams.get('range');
                 const country = urlParams.get('country');
                 const device = urlParams.get('device');
+                const userId = urlParams.get('user_id');
                 const p = urlParams.get('page');
                 const pp = urlParams.get('per_page');
                 if (range) filters.range = range;
                 if (country) filters.country = country;
                 if (device) filters.device = device;
+                if (userId) filters.userId = userId;
                 if (p) page.value = parseInt(p) || 1;
                 if (pp) pageSize.value = parseInt(pp) || 10;
                 fetchList();
@@ -154,7 +167,7 @@
                 showFilters, filters, selectedVisitor, detailModal,
                 exportUrl, paginationSummary, pageNumbers,
                 fetchList, applyFilters, clearFilters, changePage, changePageSize,
-                showDetail, rangeLabel, capitalize,
+                showDetail, filterByUser, rangeLabel, capitalize,
             };
         }
     }).mount('#visitor-activity-app');
@@ -87,6 +87,9 @@ func (c *Controller) handleExport(w http.ResponseWriter, r *http.Request) string
 	if filters.Device != "" {
 		options = options.SetDeviceType(filters.Device)
 	}
+	if filters.UserID != "" {
+		options = options.SetUserID(filters.UserID)
+	}
 
 	visitors, err := c.UI.Store.VisitorList(r.Context(), options)
 	if err != nil {
@@ -103,6 +106,7 @@ func (c *Controller) handleExport(w http.ResponseWriter, r *http.Request) string
 		"Browser",
 		"OS",
 		"User Agent",
+		"User ID",
 	}
 
 	rows := make([][]string, 0, len(visitors))
@@ -116,6 +120,7 @@ func (c *Controller) handleExport(w http.ResponseWriter, r *http.Request) string
 			strings.TrimSpace(visitor.GetUserBrowser() + " " + visitor.GetUserBrowserVersion()),
 			strings.TrimSpace(visitor.GetUserOs() + " " + visitor.GetUserOsVersion()),
 			visitor.GetUserAgent(),
+			visitor.GetUserID(),
 		})
 	}
 
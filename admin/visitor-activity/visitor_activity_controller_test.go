@@ -269,6 +269,65 @@ func TestVisitorActivityListAjax(t *testing.T) {
 	}
 }
 
+func TestVisitorActivityListAjaxUserFilter(t *testing.T) {
+	store := newTestStore(t, true)
+
+	for i, userID := range []string{"42", "42", ""} {
+		visitor := statsstore.NewVisitor().
+			SetPath("/hello").
+			SetIpAddress("127.0.0." + string(rune('1'+i))).
+			SetUserID(userID)
+		if err := store.VisitorCreate(context.Background(), visitor); err != nil {
+			t.Fatalf("failed to seed visitor: %v", err)
+		}
+	}
+
+	controller := New(shared.ControllerOptions{
+		Store:   store,
+		Layout:  &fakeLayout{},
+		HomeURL: "https://admin.local",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/visitor-activity", strings.NewReader("action=list-ajax&user_id=42"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	controller.ServeHTTP(rr, req)
+
+	respBody := rr.Body.String()
+	if !strings.Contains(respBody, `"totalCount":2`) || !strings.Contains(respBody, `"userId":"42"`) {
+		t.Fatalf("expected the 2 visits of user 42, got: %s", respBody)
+	}
+}
+
+func TestVisitorActivityListAjaxPrivacy(t *testing.T) {
+	store := newTestStore(t, true)
+
+	visitor := statsstore.NewVisitor().
+		SetPath("/hello").
+		SetPrivacy(statsstore.PrivacyReasonDoNotTrack)
+	if err := store.VisitorCreate(context.Background(), visitor); err != nil {
+		t.Fatalf("failed to seed visitor: %v", err)
+	}
+
+	controller := New(shared.ControllerOptions{
+		Store:   store,
+		Layout:  &fakeLayout{},
+		HomeURL: "https://admin.local",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/visitor-activity", strings.NewReader("action=list-ajax"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	controller.ServeHTTP(rr, req)
+
+	respBody := rr.Body.String()
+	if !strings.Contains(respBody, `"privacy":"dnt"`) || !strings.Contains(respBody, `"privacyLabel":"Do Not Track"`) {
+		t.Fatalf("expected the privacy reason in JSON, got: %s", respBody)
+	}
+}
+
 func newTestStore(t testing.TB, automigrate bool) statsstore.StoreInterface {
 	t.Helper()
 	db, err := sql.Open("sqlite", ":memory:?parseTime=true")
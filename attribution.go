@@ -0,0 +1,418 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// == ATTRIBUTION MODELS =======================================================
+
+// Attribution models.
+const (
+	// AttributionFirstTouch credits the first visit before the conversion.
+	AttributionFirstTouch = "first_touch"
+
+	// AttributionLastTouch credits the last visit before the conversion.
+	AttributionLastTouch = "last_touch"
+
+	// AttributionLinear splits the credit equally across the visits.
+	AttributionLinear = "linear"
+
+	// AttributionTimeDecay splits the credit across the visits, halving the
+	// weight of a visit for every AttributionOptions.HalfLife it happened
+	// before the conversion.
+	AttributionTimeDecay = "time_decay"
+)
+
+const (
+	// AttributionLookbackDefault is how far before a conversion visits are
+	// credited when AttributionOptions.Lookback is not set.
+	AttributionLookbackDefault = 30 * 24 * time.Hour
+
+	// AttributionHalfLifeDefault is the time-decay half-life when
+	// AttributionOptions.HalfLife is not set.
+	AttributionHalfLifeDefault = 7 * 24 * time.Hour
+
+	// AttributionVisitTimeout is the inactivity after which the next page
+	// view of a visitor starts a new visit.
+	AttributionVisitTimeout = 30 * time.Minute
+)
+
+// attributionNone labels visits without a campaign.
+const attributionNone = "(none)"
+
+// touchpointDirect labels visits without a referrer.
+const touchpointDirect = "(Direct)"
+
+// AttributionOptions configures an attribution computation.
+type AttributionOptions struct {
+	// Model is one of the Attribution* models. Defaults to last touch.
+	Model string
+
+	// Lookback limits the credited visits to this long before the
+	// conversion. Defaults to AttributionLookbackDefault.
+	Lookback time.Duration
+
+	// HalfLife is the time-decay half-life. Defaults to
+	// AttributionHalfLifeDefault.
+	HalfLife time.Duration
+
+	// From and To, when set, limit the conversions to this period. Visits
+	// before From still receive credit within the lookback window.
+	From time.Time
+	To   time.Time
+}
+
+func (o AttributionOptions) withDefaults() AttributionOptions {
+	if o.Model == "" {
+		o.Model = AttributionLastTouch
+	}
+	if o.Lookback <= 0 {
+		o.Lookback = AttributionLookbackDefault
+	}
+	if o.HalfLife <= 0 {
+		o.HalfLife = AttributionHalfLifeDefault
+	}
+	return o
+}
+
+// ValidAttributionModel reports whether model is one of the Attribution*
+// models.
+func ValidAttributionModel(model string) bool {
+	switch model {
+	case AttributionFirstTouch, AttributionLastTouch, AttributionLinear, AttributionTimeDecay:
+		return true
+	}
+	return false
+}
+
+// Touchpoint is a visit credited by attribution: the page view that started
+// it, classified like the dashboard's channels, sources and mediums.
+type Touchpoint struct {
+	At       time.Time
+	Channel  string
+	Source   string
+	Medium   string
+	Campaign string
+
+	// LandingPage is the path of the first page view of the visit.
+	LandingPage string
+
+	// Country is the ISO 3166-1 alpha-2 code of the visit, or "Unknown".
+	Country string
+}
+
+// AttributionCredit is the share of conversions credited to a channel or
+// campaign.
+type AttributionCredit struct {
+	Label       string  `json:"label"`
+	Conversions float64 `json:"conversions"`
+	Value       float64 `json:"value"`
+}
+
+// AttributionReport is the attribution of a goal's conversions.
+type AttributionReport struct {
+	Goal  Goal   `json:"goal"`
+	Model string `json:"model"`
+
+	// Conversions counts the goal completions in the period.
+	Conversions int64 `json:"conversions"`
+
+	ByChannel  []AttributionCredit `json:"by_channel"`
+	ByMedium   []AttributionCredit `json:"by_medium"`
+	ByCampaign []AttributionCredit `json:"by_campaign"`
+}
+
+// ComputeAttribution credits the completions of goal to the visits that
+// preceded them. Visits are keyed by user ID when the visit or an event
+// links the fingerprint to one, so visits from several devices of a user
+// are combined, and by fingerprint otherwise. A conversion without any
+// visit in the lookback window is credited to the Direct channel.
+func ComputeAttribution(goal Goal, visitors []VisitorInterface, events []EventInterface, opts AttributionOptions) AttributionReport {
+	opts = opts.withDefaults()
+
+	identities := attributionIdentities(visitors, events)
+	identity := func(fingerprint string) string {
+		if userID, ok := identities[fingerprint]; ok {
+			return "user:" + userID
+		}
+		return fingerprint
+	}
+
+	touchpoints := attributionTouchpoints(visitors, identity)
+
+	byChannel := map[string]*AttributionCredit{}
+	byMedium := map[string]*AttributionCredit{}
+	byCampaign := map[string]*AttributionCredit{}
+	report := AttributionReport{Goal: goal, Model: opts.Model}
+
+	for _, hit := range attributionConversions(goal, visitors, events, identity) {
+		if (!opts.From.IsZero() && hit.at.Before(opts.From)) || (!opts.To.IsZero() && hit.at.After(opts.To)) {
+			continue
+		}
+		report.Conversions++
+
+		window := []Touchpoint{}
+		for _, touch := range touchpoints[hit.visitor] {
+			if !touch.At.After(hit.at) && !touch.At.Before(hit.at.Add(-opts.Lookback)) {
+				window = append(window, touch)
+			}
+		}
+		if len(window) == 0 {
+			window = []Touchpoint{{At: hit.at, Channel: ChannelDirect, Medium: "direct", Campaign: attributionNone}}
+		}
+
+		for i, weight := range attributionWeights(window, hit.at, opts) {
+			if weight == 0 {
+				continue
+			}
+			addAttributionCredit(byChannel, window[i].Channel, weight, goal.Value)
+			addAttributionCredit(byMedium, window[i].Medium, weight, goal.Value)
+			addAttributionCredit(byCampaign, window[i].Campaign, weight, goal.Value)
+		}
+	}
+
+	report.ByChannel = sortedAttributionCredits(byChannel)
+	report.ByMedium = sortedAttributionCredits(byMedium)
+	report.ByCampaign = sortedAttributionCredits(byCampaign)
+
+	return report
+}
+
+// attributionConversions returns the completions of goal, keyed by
+// identity. Event completions carrying a user ID are keyed by it even when
+// their fingerprint was never seen, e.g. for server-side events.
+// Completions recorded aggregate only cannot be linked to earlier visits and
+// are left out.
+func attributionConversions(goal Goal, visitors []VisitorInterface, events []EventInterface, identity func(fingerprint string) string) []goalHit {
+	if goal.Type != GoalTypeEvent {
+		hits := []goalHit{}
+		for _, hit := range goalHits(goal, visitors, events) {
+			if hit.visitor != "" {
+				hit.visitor = identity(hit.visitor)
+				hits = append(hits, hit)
+			}
+		}
+		return hits
+	}
+
+	hits := []goalHit{}
+	for _, e := range events {
+		if !goal.MatchesEvent(e) {
+			continue
+		}
+		key := identity(e.GetFingerprint())
+		if e.GetUserID() != "" {
+			key = "user:" + e.GetUserID()
+		}
+		if key == "" {
+			continue
+		}
+		hits = append(hits, goalHit{visitor: key, at: e.GetCreatedAtCarbon().StdTime()})
+	}
+	return hits
+}
+
+// attributionIdentities maps fingerprints to the user IDs page views and
+// events link them to.
+func attributionIdentities(visitors []VisitorInterface, events []EventInterface) map[string]string {
+	identities := map[string]string{}
+	for _, v := range visitors {
+		if userID := v.GetUserID(); userID != "" {
+			identities[visitorFingerprint(v)] = userID
+		}
+	}
+	for _, e := range events {
+		if e.GetFingerprint() != "" && e.GetUserID() != "" {
+			identities[e.GetFingerprint()] = e.GetUserID()
+		}
+	}
+	return identities
+}
+
+// attributionTouchpoints returns the visits of each identity in time order.
+// A page view starts a visit when it is the identity's first, or follows
+// its previous page view by more than AttributionVisitTimeout.
+func attributionTouchpoints(visitors []VisitorInterface, identity func(fingerprint string) string) map[string][]Touchpoint {
+	sorted := make([]VisitorInterface, len(visitors))
+	copy(sorted, visitors)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].GetCreatedAtCarbon().StdTime().Before(sorted[b].GetCreatedAtCarbon().StdTime())
+	})
+
+	lastSeen := map[string]time.Time{}
+	touchpoints := map[string][]Touchpoint{}
+	for _, v := range sorted {
+		key := visitorKey(v)
+		if key == "" {
+			continue
+		}
+		key = identity(key)
+		at := v.GetCreatedAtCarbon().StdTime()
+
+		previous, seen := lastSeen[key]
+		lastSeen[key] = at
+		if seen && at.Sub(previous) <= AttributionVisitTimeout {
+			continue
+		}
+
+		referrer := strings.TrimSpace(v.GetUserReferrer())
+		touchpoints[key] = append(touchpoints[key], Touchpoint{
+			At:          at,
+			Channel:     ClassifyChannel(ReferrerDomain(referrer)),
+			Source:      touchpointSource(referrer),
+			Medium:      ClassifyMedium(referrer),
+			Campaign:    touchpointCampaign(v, referrer),
+			LandingPage: touchpointLandingPage(v),
+			Country:     touchpointCountry(v),
+		})
+	}
+
+	return touchpoints
+}
+
+// touchpointCampaign returns the stored campaign of a page view, falling
+// back to utm_campaign in its referrer, as the dashboard does.
+func touchpointCampaign(v VisitorInterface, referrer string) string {
+	if campaign := strings.TrimSpace(v.GetCampaign()); campaign != "" {
+		return campaign
+	}
+	if u := ParseReferrerURL(referrer); u != nil {
+		if campaign := strings.TrimSpace(u.Query().Get("utm_campaign")); campaign != "" {
+			return campaign
+		}
+	}
+	return attributionNone
+}
+
+// touchpointSource returns the referrer domain of a visit, or "(Direct)",
+// as the dashboard's sources do.
+func touchpointSource(referrer string) string {
+	if domain := ReferrerDomain(referrer); domain != "" {
+		return domain
+	}
+	return touchpointDirect
+}
+
+func touchpointLandingPage(v VisitorInterface) string {
+	if path := strings.TrimSpace(v.GetPath()); path != "" {
+		return path
+	}
+	return "/"
+}
+
+func touchpointCountry(v VisitorInterface) string {
+	if country := strings.ToUpper(strings.TrimSpace(v.GetCountry())); country != "" {
+		return country
+	}
+	return "Unknown"
+}
+
+// attributionWeights returns the credit of each touchpoint (in time order)
+// for a conversion at convertedAt. The weights add up to 1.
+func attributionWeights(touchpoints []Touchpoint, convertedAt time.Time, opts AttributionOptions) []float64 {
+	weights := make([]float64, len(touchpoints))
+
+	switch opts.Model {
+	case AttributionFirstTouch:
+		weights[0] = 1
+	case AttributionLinear:
+		for i := range weights {
+			weights[i] = 1 / float64(len(weights))
+		}
+	case AttributionTimeDecay:
+		total := 0.0
+		for i, touch := range touchpoints {
+			age := convertedAt.Sub(touch.At).Seconds() / opts.HalfLife.Seconds()
+			weights[i] = math.Pow(2, -age)
+			total += weights[i]
+		}
+		for i := range weights {
+			weights[i] /= total
+		}
+	default:
+		weights[len(weights)-1] = 1
+	}
+
+	return weights
+}
+
+func addAttributionCredit(credits map[string]*AttributionCredit, label string, weight, value float64) {
+	credit, ok := credits[label]
+	if !ok {
+		credit = &AttributionCredit{Label: label}
+		credits[label] = credit
+	}
+	credit.Conversions += weight
+	credit.Value += weight * value
+}
+
+// sortedAttributionCredits returns the credits by conversions descending,
+// rounded to two decimals.
+func sortedAttributionCredits(credits map[string]*AttributionCredit) []AttributionCredit {
+	list := make([]AttributionCredit, 0, len(credits))
+	for _, credit := range credits {
+		list = append(list, AttributionCredit{
+			Label:       credit.Label,
+			Conversions: math.Round(credit.Conversions*100) / 100,
+			Value:       math.Round(credit.Value*100) / 100,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Conversions != list[j].Conversions {
+			return list[i].Conversions > list[j].Conversions
+		}
+		return list[i].Label < list[j].Label
+	})
+	return list
+}
+
+// == STORE ====================================================================
+
+// GoalAttribution attributes the conversions of the goal with goalID in the
+// period opts.From to opts.To, loading the page views and events from the
+// lookback window before opts.From onwards.
+func (st *storeImplementation) GoalAttribution(ctx context.Context, goalID string, opts AttributionOptions) (AttributionReport, error) {
+	opts = opts.withDefaults()
+	if !ValidAttributionModel(opts.Model) {
+		return AttributionReport{}, errors.New("unknown attribution model: " + opts.Model)
+	}
+
+	goals, err := st.GoalList(ctx)
+	if err != nil {
+		return AttributionReport{}, err
+	}
+	goal, ok := findGoal(goals, goalID)
+	if !ok {
+		return AttributionReport{}, errors.New("goal not found")
+	}
+
+	visitorQuery := VisitorQuery()
+	eventQuery := EventQuery()
+	if !opts.From.IsZero() {
+		since := opts.From.Add(-opts.Lookback).UTC().Format(time.RFC3339)
+		visitorQuery = visitorQuery.SetCreatedAtGte(since)
+		eventQuery = eventQuery.SetCreatedAtGte(since)
+	}
+	if !opts.To.IsZero() {
+		until := opts.To.UTC().Format(time.RFC3339)
+		visitorQuery = visitorQuery.SetCreatedAtLte(until)
+		eventQuery = eventQuery.SetCreatedAtLte(until)
+	}
+
+	visitors, err := st.VisitorList(ctx, visitorQuery)
+	if err != nil {
+		return AttributionReport{}, err
+	}
+
+	events, err := st.EventList(ctx, eventQuery)
+	if err != nil {
+		return AttributionReport{}, err
+	}
+
+	return ComputeAttribution(goal, visitors, events, opts), nil
+}
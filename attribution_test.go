@@ -0,0 +1,158 @@
+package statsstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func attributionTestVisitor(fingerprint, path, referrer string, at time.Time) VisitorInterface {
+	return NewVisitor().
+		SetFingerprint(fingerprint).
+		SetPath(path).
+		SetUserReferrer(referrer).
+		SetCreatedAt(carbon.CreateFromStdTime(at).ToDateTimeString(carbon.UTC))
+}
+
+func attributionCredit(credits []AttributionCredit, label string) float64 {
+	for _, credit := range credits {
+		if credit.Label == label {
+			return credit.Conversions
+		}
+	}
+	return 0
+}
+
+func TestComputeAttributionModels(t *testing.T) {
+	converted := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	visitors := []VisitorInterface{
+		attributionTestVisitor("a", "/", "https://www.google.com/", converted.Add(-9*24*time.Hour)),
+		attributionTestVisitor("a", "/pricing", "https://facebook.com/?utm_campaign=spring", converted.Add(-5*time.Minute)),
+		attributionTestVisitor("a", "/signup/done", "", converted),
+	}
+	goal := Goal{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done", Value: 10}
+
+	cases := []struct {
+		model           string
+		organic, social float64
+	}{
+		{AttributionFirstTouch, 1, 0},
+		{AttributionLastTouch, 0, 1},
+		{AttributionLinear, 0.5, 0.5},
+		{AttributionTimeDecay, 0.29, 0.71},
+	}
+	for _, c := range cases {
+		report := ComputeAttribution(goal, visitors, nil, AttributionOptions{Model: c.model})
+		if report.Conversions != 1 {
+			t.Fatalf("%s: conversions = %d, want 1", c.model, report.Conversions)
+		}
+		if got := attributionCredit(report.ByChannel, ChannelOrganicSearch); got != c.organic {
+			t.Errorf("%s: organic = %v, want %v", c.model, got, c.organic)
+		}
+		if got := attributionCredit(report.ByChannel, ChannelSocial); got != c.social {
+			t.Errorf("%s: social = %v, want %v", c.model, got, c.social)
+		}
+	}
+
+	linear := ComputeAttribution(goal, visitors, nil, AttributionOptions{Model: AttributionLinear})
+	if got := attributionCredit(linear.ByCampaign, "spring"); got != 0.5 {
+		t.Errorf("spring campaign = %v, want 0.5", got)
+	}
+	if linear.ByChannel[0].Value != 5 {
+		t.Errorf("value = %v, want 5", linear.ByChannel[0].Value)
+	}
+
+	short := ComputeAttribution(goal, visitors, nil, AttributionOptions{Model: AttributionFirstTouch, Lookback: 24 * time.Hour})
+	if got := attributionCredit(short.ByChannel, ChannelSocial); got != 1 {
+		t.Errorf("first touch within one day: social = %v, want 1", got)
+	}
+}
+
+func TestComputeAttributionByUserID(t *testing.T) {
+	converted := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	visitors := []VisitorInterface{
+		attributionTestVisitor("laptop", "/", "https://www.google.com/", converted.Add(-48*time.Hour)),
+	}
+	events := []EventInterface{
+		NewEvent().SetName("Login").SetFingerprint("laptop").SetUserID("42").
+			SetCreatedAt(carbon.CreateFromStdTime(converted.Add(-47 * time.Hour)).ToDateTimeString(carbon.UTC)),
+		NewEvent().SetName("Purchase").SetFingerprint("phone").SetUserID("42").
+			SetCreatedAt(carbon.CreateFromStdTime(converted).ToDateTimeString(carbon.UTC)),
+		NewEvent().SetName("Purchase").SetFingerprint("stranger").
+			SetCreatedAt(carbon.CreateFromStdTime(converted).ToDateTimeString(carbon.UTC)),
+	}
+	goal := Goal{ID: "purchase", Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"}
+
+	report := ComputeAttribution(goal, visitors, events, AttributionOptions{Model: AttributionLastTouch})
+	if report.Conversions != 2 {
+		t.Fatalf("conversions = %d, want 2", report.Conversions)
+	}
+	if got := attributionCredit(report.ByChannel, ChannelOrganicSearch); got != 1 {
+		t.Errorf("organic = %v, want 1 (from the user's laptop visit)", got)
+	}
+	if got := attributionCredit(report.ByChannel, ChannelDirect); got != 1 {
+		t.Errorf("direct = %v, want 1 (no visits)", got)
+	}
+}
+
+func TestComputeAttributionByVisitUserID(t *testing.T) {
+	converted := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	visitors := []VisitorInterface{
+		// The user found the site on a laptop and signed up on a phone.
+		attributionTestVisitor("laptop", "/", "https://www.google.com/", converted.Add(-48*time.Hour)).SetUserID("42"),
+		attributionTestVisitor("phone", "/signup/done", "", converted).SetUserID("42"),
+	}
+	goal := Goal{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done"}
+
+	report := ComputeAttribution(goal, visitors, nil, AttributionOptions{Model: AttributionFirstTouch})
+	if report.Conversions != 1 {
+		t.Fatalf("conversions = %d, want 1", report.Conversions)
+	}
+	if got := attributionCredit(report.ByChannel, ChannelOrganicSearch); got != 1 {
+		t.Errorf("organic = %v, want 1 (from the user's laptop visit)", got)
+	}
+}
+
+func TestStoreGoalAttribution(t *testing.T) {
+	store, err := initStoreWithSettings()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	goal, err := store.GoalSave(ctx, Goal{Name: "Signup", Type: GoalTypePage, Path: "/signup/done"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, v := range []VisitorInterface{
+		attributionTestVisitor("a", "/", "https://www.google.com/", now.Add(-3*24*time.Hour)),
+		attributionTestVisitor("a", "/signup/done", "https://twitter.com/", now.Add(-time.Hour)),
+	} {
+		if err := store.VisitorCreate(ctx, v); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	report, err := store.GoalAttribution(ctx, goal.ID, AttributionOptions{
+		Model: AttributionFirstTouch,
+		From:  now.Add(-24 * time.Hour),
+		To:    now,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if report.Conversions != 1 || attributionCredit(report.ByChannel, ChannelOrganicSearch) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err := store.GoalAttribution(ctx, goal.ID, AttributionOptions{Model: "position"}); err == nil {
+		t.Error("expected an error for an unknown model")
+	}
+	if _, err := store.GoalAttribution(ctx, "missing", AttributionOptions{}); err == nil {
+		t.Error("expected an error for an unknown goal")
+	}
+}
@@ -0,0 +1,99 @@
+package statsstore
+
+import (
+	"net/url"
+	"strings"
+)
+
+// == CHANNEL CONSTANTS ========================================================
+
+// Channel names returned by ClassifyChannel.
+const (
+	ChannelDirect        = "Direct"
+	ChannelOrganicSearch = "Organic Search"
+	ChannelSocial        = "Social"
+	ChannelReferral      = "Referral"
+)
+
+// searchEngines lists referrer domains classified as organic search.
+var searchEngines = map[string]bool{
+	"google.com": true, "bing.com": true, "duckduckgo.com": true,
+	"yahoo.com": true, "baidu.com": true, "yandex.com": true,
+	"ecosia.org": true, "ask.com": true, "aol.com": true,
+}
+
+// socialNetworks lists referrer domains classified as social.
+var socialNetworks = map[string]bool{
+	"facebook.com": true, "twitter.com": true, "x.com": true,
+	"linkedin.com": true, "instagram.com": true, "pinterest.com": true,
+	"reddit.com": true, "tiktok.com": true, "youtube.com": true,
+	"tumblr.com": true, "mastodon.social": true, "threads.net": true,
+}
+
+// == CHANNEL CLASSIFICATION ===================================================
+
+// ReferrerDomain returns the host part of a referrer URL with the scheme and
+// a leading "www." stripped, e.g. "https://www.google.com/search" becomes
+// "google.com". Returns an empty string for an empty referrer.
+func ReferrerDomain(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	for _, prefix := range []string{"https://", "http://", "www."} {
+		rawURL = strings.TrimPrefix(rawURL, prefix)
+	}
+	if idx := strings.Index(rawURL, "/"); idx > 0 {
+		rawURL = rawURL[:idx]
+	}
+	return rawURL
+}
+
+// ParseReferrerURL parses a referrer into a URL, assuming https:// when the
+// scheme is missing. Returns nil for empty or unparsable referrers.
+func ParseReferrerURL(referrer string) *url.URL {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return nil
+	}
+	if !strings.HasPrefix(referrer, "http://") && !strings.HasPrefix(referrer, "https://") {
+		referrer = "https://" + referrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// ClassifyChannel maps a referrer domain (see ReferrerDomain) to a marketing
+// channel: Direct, Organic Search, Social or Referral.
+func ClassifyChannel(domain string) string {
+	if domain == "" {
+		return ChannelDirect
+	}
+	if searchEngines[domain] {
+		return ChannelOrganicSearch
+	}
+	if socialNetworks[domain] {
+		return ChannelSocial
+	}
+	return ChannelReferral
+}
+
+// ClassifyMedium returns the traffic medium for a referrer: the utm_medium
+// query parameter when present, otherwise "direct", "organic" or "referral".
+func ClassifyMedium(referrer string) string {
+	if referrer == "" {
+		return "direct"
+	}
+	u := ParseReferrerURL(referrer)
+	if u == nil {
+		return "referral"
+	}
+	if q := u.Query(); q.Get("utm_medium") != "" {
+		return q.Get("utm_medium")
+	}
+	domain := ReferrerDomain(referrer)
+	if searchEngines[domain] {
+		return "organic"
+	}
+	return "referral"
+}
@@ -0,0 +1,31 @@
+package statsstore
+
+import "testing"
+
+func TestClassifyChannel(t *testing.T) {
+	tests := map[string]string{
+		"":                       ChannelDirect,
+		"https://www.google.com": ChannelOrganicSearch,
+		"https://x.com/status/1": ChannelSocial,
+		"https://example.org/a":  ChannelReferral,
+	}
+	for referrer, want := range tests {
+		if got := ClassifyChannel(ReferrerDomain(referrer)); got != want {
+			t.Errorf("ClassifyChannel(%q) = %q, want %q", referrer, got, want)
+		}
+	}
+}
+
+func TestClassifyMedium(t *testing.T) {
+	tests := map[string]string{
+		"":                                      "direct",
+		"https://www.bing.com/search":           "organic",
+		"https://example.org/?utm_medium=email": "email",
+		"https://news.example.com/article":      "referral",
+	}
+	for referrer, want := range tests {
+		if got := ClassifyMedium(referrer); got != want {
+			t.Errorf("ClassifyMedium(%q) = %q, want %q", referrer, got, want)
+		}
+	}
+}
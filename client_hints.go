@@ -0,0 +1,279 @@
+package statsstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// == CLIENT HINT HEADERS ======================================================
+
+// User-Agent Client Hints request headers.
+const (
+	HeaderSecCHUA                = "Sec-CH-UA"
+	HeaderSecCHUAMobile          = "Sec-CH-UA-Mobile"
+	HeaderSecCHUAPlatform        = "Sec-CH-UA-Platform"
+	HeaderSecCHUAPlatformVersion = "Sec-CH-UA-Platform-Version"
+	HeaderSecCHUAModel           = "Sec-CH-UA-Model"
+	HeaderSecCHUAFullVersionList = "Sec-CH-UA-Full-Version-List"
+)
+
+// ClientHintsAcceptCH is the value for the Accept-CH response header that
+// asks Chromium browsers to send the high-entropy hints (real OS version,
+// device model, full browser version) on subsequent requests. Without it
+// only Sec-CH-UA, Sec-CH-UA-Mobile and Sec-CH-UA-Platform are sent.
+const ClientHintsAcceptCH = HeaderSecCHUAPlatformVersion + ", " + HeaderSecCHUAModel + ", " + HeaderSecCHUAFullVersionList
+
+// == CLIENT HINTS =============================================================
+
+// ClientHints holds the raw User-Agent Client Hints header values of a
+// request. They are stored on the visitor (client_hints column) as JSON so
+// that the UA-derived fields can be recomputed later.
+type ClientHints struct {
+	UA              string `json:"ua,omitempty"`
+	Mobile          string `json:"mobile,omitempty"`
+	Platform        string `json:"platform,omitempty"`
+	PlatformVersion string `json:"platform_version,omitempty"`
+	Model           string `json:"model,omitempty"`
+	FullVersionList string `json:"full_version_list,omitempty"`
+}
+
+// ClientHintBrand is a single brand/version entry from Sec-CH-UA or
+// Sec-CH-UA-Full-Version-List.
+type ClientHintBrand struct {
+	Brand   string
+	Version string
+}
+
+// ClientHintsFromRequest reads the User-Agent Client Hints headers from r.
+func ClientHintsFromRequest(r *http.Request) ClientHints {
+	return ClientHints{
+		UA:              r.Header.Get(HeaderSecCHUA),
+		Mobile:          r.Header.Get(HeaderSecCHUAMobile),
+		Platform:        r.Header.Get(HeaderSecCHUAPlatform),
+		PlatformVersion: r.Header.Get(HeaderSecCHUAPlatformVersion),
+		Model:           r.Header.Get(HeaderSecCHUAModel),
+		FullVersionList: r.Header.Get(HeaderSecCHUAFullVersionList),
+	}
+}
+
+// ParseClientHints decodes client hints stored with Encode. Invalid input
+// yields empty hints.
+func ParseClientHints(encoded string) ClientHints {
+	hints := ClientHints{}
+	if encoded == "" {
+		return hints
+	}
+	if err := json.Unmarshal([]byte(encoded), &hints); err != nil {
+		return ClientHints{}
+	}
+	return hints
+}
+
+// IsZero reports whether no client hints were sent.
+func (h ClientHints) IsZero() bool {
+	return h == ClientHints{}
+}
+
+// Encode returns the hints as JSON for storage, or an empty string when no
+// hints were sent.
+func (h ClientHints) Encode() string {
+	if h.IsZero() {
+		return ""
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Brands returns the browser brands, preferring the full version list over
+// Sec-CH-UA. GREASE entries such as "Not-A.Brand" are omitted.
+func (h ClientHints) Brands() []ClientHintBrand {
+	list := h.FullVersionList
+	if list == "" {
+		list = h.UA
+	}
+
+	brands := []ClientHintBrand{}
+	for _, item := range strings.Split(list, ",") {
+		parts := strings.Split(item, ";")
+		brand := unquoteHint(parts[0])
+		if brand == "" || isGreaseBrand(brand) {
+			continue
+		}
+		version := ""
+		for _, param := range parts[1:] {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "v" {
+				version = unquoteHint(value)
+			}
+		}
+		brands = append(brands, ClientHintBrand{Brand: brand, Version: version})
+	}
+	return brands
+}
+
+// == APPLYING HINTS ===========================================================
+
+// ApplyClientHints overrides the UA-derived fields in info with the more
+// accurate values from client hints. Chromium freezes the OS version and
+// hides the device model in its UA string, so the hints take precedence
+// whenever they are present.
+func ApplyClientHints(info UserAgentInfo, hints ClientHints) UserAgentInfo {
+	if hints.IsZero() {
+		return info
+	}
+
+	if platform := clientHintsPlatform(unquoteHint(hints.Platform)); platform != "" {
+		info.Os = platform
+	}
+
+	if version := unquoteHint(hints.PlatformVersion); version != "" {
+		if info.Os == "Windows" {
+			if v := windowsVersionFromHint(version); v != "" {
+				info.OsVersion = v
+			}
+		} else if v := normalizeVersion(version); v != "" {
+			info.OsVersion = v
+		}
+	}
+
+	if model := unquoteHint(hints.Model); model != "" {
+		info.DeviceBrand, info.DeviceModel = DetectDeviceBrand(model)
+		info.Device = deviceName(info.DeviceBrand, info.DeviceModel)
+	}
+
+	if hints.Mobile == "?1" && info.DeviceType == "" {
+		info.DeviceType = "mobile"
+	}
+
+	brands := hints.Brands()
+	for _, brand := range brands {
+		if brand.Brand == "Chromium" {
+			info.Engine = EngineBlink
+			break
+		}
+	}
+
+	// Sec-CH-UA only carries major versions, so the browser is only taken
+	// from it when the UA string gave nothing.
+	if hints.FullVersionList != "" || info.Browser == "" {
+		if brand, ok := primaryBrand(brands); ok {
+			info.Browser = brand.Brand
+			info.BrowserVersion = normalizeVersion(brand.Version)
+		}
+	}
+
+	return clampUserAgentInfo(info)
+}
+
+// clientHintsPlatform maps Sec-CH-UA-Platform values to the OS names used by
+// ParseUserAgent.
+func clientHintsPlatform(platform string) string {
+	switch platform {
+	case "", "Unknown":
+		return ""
+	case "Chrome OS", "Chromium OS":
+		return "ChromeOS"
+	default:
+		return platform
+	}
+}
+
+// windowsVersionFromHint maps Sec-CH-UA-Platform-Version on Windows to the
+// marketing version: 13 and above is Windows 11, 1-10 is Windows 10, and
+// 0.1-0.3 are Windows 7, 8 and 8.1.
+func windowsVersionFromHint(version string) string {
+	major, minor := versionParts(version)
+	switch {
+	case major >= 13:
+		return "11"
+	case major >= 1:
+		return "10"
+	case minor == 3:
+		return "8.1"
+	case minor == 2:
+		return "8"
+	case minor == 1:
+		return "7"
+	default:
+		return ""
+	}
+}
+
+// browserBrandNames maps client-hint brands to the names used by
+// ParseUserAgent.
+var browserBrandNames = map[string]string{
+	"Google Chrome":  "Chrome",
+	"Microsoft Edge": "Edge",
+}
+
+// primaryBrand picks the most specific brand, skipping the generic
+// "Chromium" entry unless it is the only one.
+func primaryBrand(brands []ClientHintBrand) (ClientHintBrand, bool) {
+	var chromium *ClientHintBrand
+	for i, brand := range brands {
+		if brand.Brand == "Chromium" {
+			chromium = &brands[i]
+			continue
+		}
+		if name, ok := browserBrandNames[brand.Brand]; ok {
+			brand.Brand = name
+		}
+		return brand, true
+	}
+	if chromium != nil {
+		return *chromium, true
+	}
+	return ClientHintBrand{}, false
+}
+
+// isGreaseBrand reports whether a brand is a GREASE placeholder such as
+// "Not-A.Brand", "Not_A Brand" or "Not A(Brand".
+func isGreaseBrand(brand string) bool {
+	return strings.HasPrefix(brand, "Not") && strings.ContainsAny(brand, " -_.;():/=?")
+}
+
+// unquoteHint trims whitespace and surrounding quotes from a structured
+// header string value.
+func unquoteHint(value string) string {
+	value = strings.TrimSpace(value)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return strings.Trim(value, `"`)
+}
+
+// normalizeVersion formats a dotted version the same way versionToString
+// does: "14.0.0" becomes "14.0" and "124" becomes "124.0".
+func normalizeVersion(version string) string {
+	parts := strings.Split(version, ".")
+	nums := make([]int, 3)
+	for i := 0; i < len(parts) && i < 3; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return ""
+		}
+		nums[i] = n
+	}
+	if nums[0] == 0 {
+		return ""
+	}
+	if nums[2] > 0 {
+		return strconv.Itoa(nums[0]) + "." + strconv.Itoa(nums[1]) + "." + strconv.Itoa(nums[2])
+	}
+	return strconv.Itoa(nums[0]) + "." + strconv.Itoa(nums[1])
+}
+
+// versionParts returns the major and minor components of a dotted version.
+func versionParts(version string) (int, int) {
+	parts := strings.Split(version, ".")
+	major, _ := strconv.Atoi(parts[0])
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
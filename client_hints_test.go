@@ -0,0 +1,174 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const chromeReducedAndroidUA = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+
+func TestClientHintsBrands(t *testing.T) {
+	hints := ClientHints{
+		UA: `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+	}
+
+	brands := hints.Brands()
+	if len(brands) != 2 {
+		t.Fatalf("expected 2 brands (GREASE removed), got %+v", brands)
+	}
+	if brands[1].Brand != "Google Chrome" || brands[1].Version != "124" {
+		t.Errorf("unexpected brand: %+v", brands[1])
+	}
+
+	hints.FullVersionList = `"Not_A Brand";v="8.0.0.0", "Chromium";v="124.0.6367.91", "Microsoft Edge";v="124.0.2478.67"`
+	brands = hints.Brands()
+	if len(brands) != 2 || brands[1].Version != "124.0.2478.67" {
+		t.Errorf("expected full version list to take precedence, got %+v", brands)
+	}
+}
+
+func TestClientHintsEncodeRoundTrip(t *testing.T) {
+	if (ClientHints{}).Encode() != "" {
+		t.Error("expected empty hints to encode to an empty string")
+	}
+
+	hints := ClientHints{Platform: `"Android"`, Model: `"Pixel 8"`}
+	if got := ParseClientHints(hints.Encode()); got != hints {
+		t.Errorf("round trip mismatch: got %+v, want %+v", got, hints)
+	}
+
+	if !ParseClientHints("not json").IsZero() {
+		t.Error("expected invalid input to decode to empty hints")
+	}
+}
+
+func TestApplyClientHints(t *testing.T) {
+	tests := []struct {
+		name  string
+		ua    string
+		hints ClientHints
+		want  UserAgentInfo
+	}{
+		{
+			name: "Android model and version hidden by reduced UA",
+			ua:   chromeReducedAndroidUA,
+			hints: ClientHints{
+				UA:              `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+				Mobile:          "?1",
+				Platform:        `"Android"`,
+				PlatformVersion: `"14.0.0"`,
+				Model:           `"SM-S918B"`,
+			},
+			want: UserAgentInfo{
+				Browser:        "Chrome",
+				BrowserVersion: "124.0",
+				Os:             "Android",
+				OsVersion:      "14.0",
+				Device:         "Samsung SM-S918B",
+				DeviceType:     "mobile",
+				DeviceBrand:    "Samsung",
+				DeviceModel:    "SM-S918B",
+				Engine:         "Blink",
+			},
+		},
+		{
+			name: "Windows 11 reported as NT 10.0",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			hints: ClientHints{
+				Platform:        `"Windows"`,
+				PlatformVersion: `"15.0.0"`,
+				FullVersionList: `"Chromium";v="124.0.6367.91", "Microsoft Edge";v="124.0.2478.67", "Not-A.Brand";v="99.0.0.0"`,
+			},
+			want: UserAgentInfo{
+				Browser:        "Edge",
+				BrowserVersion: "124.0.2478",
+				Os:             "Windows",
+				OsVersion:      "11",
+				DeviceType:     "desktop",
+				Engine:         "Blink",
+			},
+		},
+		{
+			name: "no hints keeps UA values",
+			ua:   chromeReducedAndroidUA,
+			want: ParseUserAgent(chromeReducedAndroidUA),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyClientHints(ParseUserAgent(tt.ua), tt.hints)
+			if got != tt.want {
+				t.Errorf("ApplyClientHints\n  got:  %+v\n  want: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyClientHintsClampsColumns(t *testing.T) {
+	model := "Pixel " + strings.Repeat("é", 100)
+	got := ApplyClientHints(ParseUserAgent(chromeReducedAndroidUA), ClientHints{Model: `"` + model + `"`})
+
+	if n := utf8.RuneCountInString(got.DeviceModel); n != 64 || !utf8.ValidString(got.DeviceModel) {
+		t.Errorf("expected a valid 64-character model, got %d characters", n)
+	}
+	if n := utf8.RuneCountInString(got.Device); n != 40 || got.DeviceBrand != "Google" {
+		t.Errorf("expected a 40-character Google device, got %q (%d)", got.DeviceBrand, n)
+	}
+}
+
+func TestWindowsVersionFromHint(t *testing.T) {
+	tests := map[string]string{
+		"15.0.0": "11",
+		"13.0.0": "11",
+		"10.0.0": "10",
+		"0.3.0":  "8.1",
+		"0.1.0":  "7",
+		"0.0.0":  "",
+	}
+	for in, want := range tests {
+		if got := windowsVersionFromHint(in); got != want {
+			t.Errorf("windowsVersionFromHint(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestVisitorRegister_ClientHints(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", chromeReducedAndroidUA)
+	r.Header.Set(HeaderSecCHUAPlatform, `"Android"`)
+	r.Header.Set(HeaderSecCHUAPlatformVersion, `"14.0.0"`)
+	r.Header.Set(HeaderSecCHUAModel, `"Pixel 8"`)
+
+	if err := store.VisitorRegister(context.Background(), r); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+
+	v := list[0]
+	if v.GetUserOsVersion() != "14.0" {
+		t.Errorf("os version = %q, want 14.0", v.GetUserOsVersion())
+	}
+	if v.GetUserDeviceBrand() != "Google" || v.GetUserDeviceModel() != "Pixel 8" {
+		t.Errorf("device = %q / %q, want Google / Pixel 8", v.GetUserDeviceBrand(), v.GetUserDeviceModel())
+	}
+	if ParseClientHints(v.GetClientHints()).Model != `"Pixel 8"` {
+		t.Errorf("expected raw client hints to be stored, got %q", v.GetClientHints())
+	}
+}
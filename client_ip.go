@@ -0,0 +1,234 @@
+package statsstore
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/dracory/req"
+)
+
+// == CONSTANTS ================================================================
+
+// Forwarding headers understood by ResolveClientIP.
+const (
+	HeaderXForwardedFor  = "X-Forwarded-For"
+	HeaderXRealIP        = "X-Real-IP"
+	HeaderForwarded      = "Forwarded"
+	HeaderCFConnectingIP = "CF-Connecting-IP"
+)
+
+// ClientIPHeadersDefault is the header precedence used when trusted proxies
+// are configured but no explicit header order is given.
+var ClientIPHeadersDefault = []string{
+	HeaderXForwardedFor,
+	HeaderXRealIP,
+	HeaderForwarded,
+	HeaderCFConnectingIP,
+}
+
+// == TYPES ====================================================================
+
+// ClientIPOptions configures how the client IP is extracted from a request.
+//
+// When TrustedProxies is empty the legacy behaviour is kept: forwarding
+// headers are trusted from any peer (see req.GetIP). Once at least one
+// trusted proxy is configured, forwarding headers are only honoured when the
+// direct peer (RemoteAddr) falls within a trusted range, so a client talking
+// to the application directly cannot spoof its address.
+type ClientIPOptions struct {
+	// TrustedProxies lists CIDRs (e.g. "10.0.0.0/8") or single IPs of the
+	// reverse proxies / load balancers in front of the application.
+	TrustedProxies []string
+
+	// Headers lists the forwarding headers to consult, in order of
+	// precedence. Defaults to ClientIPHeadersDefault.
+	Headers []string
+}
+
+// == PUBLIC FUNCTIONS =========================================================
+
+// ResolveClientIP returns the client IP and the direct peer IP for a request.
+//
+// The peer IP is always taken from RemoteAddr. The client IP is resolved
+// from the configured forwarding headers only when the peer is trusted;
+// otherwise the peer IP is returned as the client IP. For list-valued
+// headers (X-Forwarded-For, Forwarded) the chain is walked right-to-left and
+// the first address that is not itself a trusted proxy is used.
+func ResolveClientIP(r *http.Request, opts ClientIPOptions) (clientIP string, peerIP string) {
+	return resolveClientIP(r, opts, parseTrustedProxies(opts.TrustedProxies))
+}
+
+// == PRIVATE FUNCTIONS ========================================================
+
+// resolveClientIP is ResolveClientIP with the trusted proxies of opts
+// already parsed, so the store parses them once instead of per request.
+func resolveClientIP(r *http.Request, opts ClientIPOptions, trusted []netip.Prefix) (clientIP string, peerIP string) {
+	if r == nil {
+		return "", ""
+	}
+
+	peerIP = remoteAddrIP(r.RemoteAddr)
+
+	if len(opts.TrustedProxies) == 0 {
+		return req.GetIP(r), peerIP
+	}
+
+	if !isTrustedIP(trusted, peerIP) {
+		return peerIP, peerIP
+	}
+
+	headers := opts.Headers
+	if len(headers) == 0 {
+		headers = ClientIPHeadersDefault
+	}
+
+	for _, header := range headers {
+		value := strings.TrimSpace(r.Header.Get(header))
+		if value == "" {
+			continue
+		}
+
+		var candidates []string
+		switch http.CanonicalHeaderKey(header) {
+		case http.CanonicalHeaderKey(HeaderXForwardedFor):
+			candidates = splitForwardedFor(r.Header.Values(header))
+		case http.CanonicalHeaderKey(HeaderForwarded):
+			candidates = parseForwardedFor(r.Header.Values(header))
+		default:
+			candidates = []string{value}
+		}
+
+		if ip := pickClientIP(trusted, candidates); ip != "" {
+			return ip, peerIP
+		}
+	}
+
+	return peerIP, peerIP
+}
+
+// parseTrustedProxies converts CIDR strings and single IPs into prefixes.
+// Invalid entries are ignored.
+func parseTrustedProxies(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			if p, err := netip.ParsePrefix(v); err == nil {
+				prefixes = append(prefixes, p.Masked())
+			}
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// isTrustedIP reports whether ip falls within any of the trusted prefixes.
+func isTrustedIP(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickClientIP walks the candidate chain from the right (closest hop) to the
+// left and returns the first valid address that is not a trusted proxy. If
+// every hop is trusted, the left-most valid address is returned.
+func pickClientIP(trusted []netip.Prefix, candidates []string) string {
+	leftmost := ""
+	for i := len(candidates) - 1; i >= 0; i-- {
+		ip := normalizeIP(candidates[i])
+		if ip == "" {
+			continue
+		}
+		leftmost = ip
+		if !isTrustedIP(trusted, ip) {
+			return ip
+		}
+	}
+	return leftmost
+}
+
+// splitForwardedFor flattens one or more X-Forwarded-For header values into
+// an ordered list of hops.
+func splitForwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				hops = append(hops, part)
+			}
+		}
+	}
+	return hops
+}
+
+// parseForwardedFor extracts the for= parameters from RFC 7239 Forwarded
+// header values, e.g. `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`.
+func parseForwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "for") {
+					continue
+				}
+				hops = append(hops, strings.Trim(strings.TrimSpace(val), `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// normalizeIP strips ports and IPv6 brackets from a header value and returns
+// the canonical textual form, or an empty string if it is not a valid IP
+// (e.g. the RFC 7239 "unknown" or obfuscated identifiers).
+func normalizeIP(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap().String()
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return ""
+}
+
+// remoteAddrIP returns the host part of a RemoteAddr value.
+func remoteAddrIP(remoteAddr string) string {
+	if ip := normalizeIP(remoteAddr); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,149 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP_NoTrustedProxiesKeepsLegacyBehaviour(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.10:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	client, peer := ResolveClientIP(r, ClientIPOptions{})
+	if client != "198.51.100.7" {
+		t.Errorf("client = %q, want 198.51.100.7", client)
+	}
+	if peer != "203.0.113.10" {
+		t.Errorf("peer = %q, want 203.0.113.10", peer)
+	}
+}
+
+func TestResolveClientIP_UntrustedPeerIgnoresHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.10:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("X-Real-IP", "198.51.100.8")
+
+	client, peer := ResolveClientIP(r, ClientIPOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+	if client != "203.0.113.10" {
+		t.Errorf("client = %q, want peer address 203.0.113.10", client)
+	}
+	if peer != "203.0.113.10" {
+		t.Errorf("peer = %q, want 203.0.113.10", peer)
+	}
+}
+
+func TestResolveClientIP_TrustedPeerWalksForwardedFor(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:443"
+	// Left-most entry is attacker controlled; the right-most untrusted hop
+	// is what the trusted proxy actually saw.
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 198.51.100.7, 10.0.0.5")
+
+	client, peer := ResolveClientIP(r, ClientIPOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+	if client != "198.51.100.7" {
+		t.Errorf("client = %q, want 198.51.100.7", client)
+	}
+	if peer != "10.0.0.2" {
+		t.Errorf("peer = %q, want 10.0.0.2", peer)
+	}
+}
+
+func TestResolveClientIP_HeaderPrecedence(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:443"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("CF-Connecting-IP", "198.51.100.9")
+
+	client, _ := ResolveClientIP(r, ClientIPOptions{
+		TrustedProxies: []string{"10.0.0.2"},
+		Headers:        []string{HeaderCFConnectingIP, HeaderXForwardedFor},
+	})
+	if client != "198.51.100.9" {
+		t.Errorf("client = %q, want 198.51.100.9", client)
+	}
+}
+
+func TestResolveClientIP_ForwardedHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "[::1]:8080"
+	r.Header.Set("Forwarded", `for="[2001:db8::1]:4711";proto=https, for=unknown`)
+
+	client, peer := ResolveClientIP(r, ClientIPOptions{
+		TrustedProxies: []string{"::1/128"},
+		Headers:        []string{HeaderForwarded},
+	})
+	if client != "2001:db8::1" {
+		t.Errorf("client = %q, want 2001:db8::1", client)
+	}
+	if peer != "::1" {
+		t.Errorf("peer = %q, want ::1", peer)
+	}
+}
+
+func TestResolveClientIP_InvalidHeaderFallsBackToPeer(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:443"
+	r.Header.Set("X-Real-IP", "not-an-ip")
+
+	client, _ := ResolveClientIP(r, ClientIPOptions{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Headers:        []string{HeaderXRealIP},
+	})
+	if client != "10.0.0.2" {
+		t.Errorf("client = %q, want 10.0.0.2", client)
+	}
+}
+
+func TestVisitorRegister_TrustedProxies(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		TrustedProxies:     []string{"10.0.0.0/8"},
+		ExcludedIPs:        []string{"198.51.100.7"},
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+
+	// Spoofed header from an untrusted peer must not bypass the exclusion
+	// list nor change the recorded address.
+	spoofed := httptest.NewRequest(http.MethodGet, "/spoofed", nil)
+	spoofed.RemoteAddr = "198.51.100.7:5555"
+	spoofed.Header.Set("X-Forwarded-For", "203.0.113.99")
+	if err := store.VisitorRegister(ctx, spoofed); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	proxied := httptest.NewRequest(http.MethodGet, "/proxied", nil)
+	proxied.RemoteAddr = "10.1.2.3:5555"
+	proxied.Header.Set("X-Forwarded-For", "203.0.113.50")
+	if err := store.VisitorRegister(ctx, proxied); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := store.VisitorList(ctx, VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor (spoofed excluded IP skipped), got %d", len(list))
+	}
+	if list[0].GetIpAddress() != "203.0.113.50" {
+		t.Errorf("ip_address = %q, want 203.0.113.50", list[0].GetIpAddress())
+	}
+	if list[0].GetPeerIpAddress() != "10.1.2.3" {
+		t.Errorf("peer_ip_address = %q, want 10.1.2.3", list[0].GetPeerIpAddress())
+	}
+}
@@ -21,6 +21,24 @@ const (
 	COLUMN_USER_REFERRER        = "user_referrer"
 	COLUMN_BOT                  = "bot"
 	COLUMN_THREAT               = "threat"
+	COLUMN_PEER_IP_ADDRESS      = "peer_ip_address"
+	COLUMN_CHANNEL              = "channel"
+	COLUMN_TAGS                 = "tags"
+	COLUMN_ENRICHMENT           = "enrichment"
+	COLUMN_USER_DEVICE_BRAND    = "user_device_brand"
+	COLUMN_USER_DEVICE_MODEL    = "user_device_model"
+	COLUMN_CLIENT_HINTS         = "client_hints"
+	COLUMN_USER_BROWSER_ENGINE  = "user_browser_engine"
+	COLUMN_USER_WEBVIEW         = "user_webview"
+	COLUMN_USER_APP             = "user_app"
+	COLUMN_PAGE_TITLE           = "page_title"
+	COLUMN_SCREEN_SIZE          = "screen_size"
+	COLUMN_ENGAGEMENT_SECONDS   = "engagement_seconds"
+	COLUMN_SCROLL_DEPTH         = "scroll_depth"
+	COLUMN_CAMPAIGN             = "campaign"
+	COLUMN_USER_ID              = "user_id"
+	COLUMN_PRIVACY              = "privacy"
+	COLUMN_IP_INDEX             = "ip_index"
 )
 
 // Yes/No string values used for boolean-like columns (bot, threat).
@@ -35,10 +53,71 @@ const DEFAULT_SETTINGS_TABLE = "statsstore_settings"
 
 // Settings table column names.
 const (
-	COLUMN_KEY           = "key"
-	COLUMN_VALUE         = "value"
-	SETTING_EXCLUDED_IPS = "excluded_ips"
+	COLUMN_KEY              = "key"
+	COLUMN_VALUE            = "value"
+	SETTING_EXCLUDED_IPS    = "excluded_ips"
+	SETTING_GOALS           = "goals"
+	SETTING_FUNNELS         = "funnels"
+	SETTING_CURRENCY_RATES  = "currency_rates"
+	SETTING_RETENTION_RULES = "retention_rules"
 )
 
+// Default table name for custom events.
+const DEFAULT_EVENT_TABLE = "statsstore_event"
+
+// Event table column names (path, fingerprint, user_referrer, campaign,
+// user_id and the timestamps reuse the visitor column names).
+const (
+	COLUMN_NAME             = "name"
+	COLUMN_VISITOR_ID       = "visitor_id"
+	COLUMN_DOMAIN           = "domain"
+	COLUMN_PROPS            = "props"
+	COLUMN_REVENUE_AMOUNT   = "revenue_amount"
+	COLUMN_REVENUE_CURRENCY = "revenue_currency"
+	COLUMN_CLIENT_ID        = "client_id"
+	COLUMN_IDEMPOTENCY_KEY  = "idempotency_key"
+	COLUMN_ORDER_ID         = "order_id"
+	COLUMN_ITEMS            = "items"
+)
+
+// Default table name for the data-subject audit log.
+const DEFAULT_AUDIT_TABLE = "statsstore_audit"
+
+// Audit table column names (id and created_at reuse the visitor column
+// names).
+const (
+	COLUMN_ACTION   = "action"
+	COLUMN_SUBJECT  = "subject"
+	COLUMN_ACTOR    = "actor"
+	COLUMN_VISITORS = "visitors"
+	COLUMN_EVENTS   = "events"
+)
+
+// Default table name for the daily rollups.
+const DEFAULT_ROLLUP_TABLE = "statsstore_rollup"
+
+// Rollup table column names (id, value, visitors and created_at reuse the
+// names above).
+const (
+	COLUMN_DATE      = "date"
+	COLUMN_DIMENSION = "dimension"
+	COLUMN_PAGEVIEWS = "pageviews"
+	COLUMN_SKETCH    = "sketch"
+)
+
+// EVENT_NAME_PAGEVIEW is the reserved event name for page views. Page views
+// are stored as visitor rows, never in the event table.
+const EVENT_NAME_PAGEVIEW = "pageview"
+
+// Event names recorded for link clicks by the redirect and Matomo handlers.
+const (
+	EVENT_NAME_OUTBOUND_LINK = "Outbound Link"
+	EVENT_NAME_DOWNLOAD      = "Download"
+)
+
+// EVENT_NAME_EXPERIMENT_EXPOSURE is the event name recorded when a visitor
+// sees a variant of an A/B experiment (see NewExperimentExposure).
+const EVENT_NAME_EXPERIMENT_EXPOSURE = "Experiment Exposure"
+
 // MAX_DATETIME is a far-future datetime used as the default soft-delete sentinel.
 const MAX_DATETIME = "9999-12-31 23:59:59"
@@ -0,0 +1,566 @@
+package statsstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	contractsschema "github.com/dracory/neat/contracts/database/schema"
+	neatuid "github.com/dracory/neat/support/uid"
+	"github.com/dromara/carbon/v2"
+)
+
+// == CONSTANTS ================================================================
+
+// Erasure modes of DataSubjectErase.
+const (
+	// DataSubjectErasureDelete permanently deletes the records.
+	DataSubjectErasureDelete = "delete"
+
+	// DataSubjectErasureAnonymize strips the identifying fields of the
+	// records, as for visits recorded aggregate only, so they still count
+	// in reports.
+	DataSubjectErasureAnonymize = "anonymize"
+)
+
+// Audit log actions.
+const (
+	DataSubjectActionAccess    = "access"
+	DataSubjectActionDelete    = "delete"
+	DataSubjectActionAnonymize = "anonymize"
+
+	// DataSubjectActionDeleteFailed and DataSubjectActionAnonymizeFailed
+	// record an erasure that stopped on an error, with the rows erased
+	// before it.
+	DataSubjectActionDeleteFailed    = "delete_failed"
+	DataSubjectActionAnonymizeFailed = "anonymize_failed"
+)
+
+const (
+	// dataSubjectScanBatchSize is the number of visitor rows read per query
+	// when matching calculated fingerprints.
+	dataSubjectScanBatchSize = 1000
+
+	// dataSubjectDigestLength is the number of hex characters of the
+	// identifier digests kept in the audit log.
+	dataSubjectDigestLength = 16
+
+	// dataSubjectAuditListLimit is the default number of entries returned
+	// by DataSubjectAuditList.
+	dataSubjectAuditListLimit = 100
+)
+
+// == TYPES ====================================================================
+
+// DataSubject identifies the person behind a data-subject request (GDPR
+// access or erasure). A record belongs to the subject when it matches any
+// of the identifiers set.
+type DataSubject struct {
+	IP          string `json:"ip,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+}
+
+// IsEmpty reports whether no identifier is set.
+func (s DataSubject) IsEmpty() bool {
+	return s.IP == "" && s.Fingerprint == "" && s.UserID == ""
+}
+
+// Digest describes the subject for the audit log with truncated SHA-256
+// digests of its identifiers, e.g. "ip:1a2b3c4d5e6f7a8b", so the log does
+// not keep erased identifiers in clear. Compute the digest of an
+// identifier to look it up in the log.
+func (s DataSubject) Digest() string {
+	parts := []string{}
+	for _, identifier := range []struct{ name, value string }{
+		{"ip", s.IP},
+		{"fingerprint", s.Fingerprint},
+		{"user_id", s.UserID},
+	} {
+		if identifier.value == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(identifier.value))
+		parts = append(parts, identifier.name+":"+hex.EncodeToString(sum[:])[:dataSubjectDigestLength])
+	}
+	return strings.Join(parts, " ")
+}
+
+// DataSubjectRecords are the records of a data subject.
+type DataSubjectRecords struct {
+	Visitors []VisitorInterface
+	Events   []EventInterface
+}
+
+// DataSubjectDocument is the JSON document returned by DataSubjectExport.
+// Visitors and events are listed as column name to value maps, with IPs
+// decrypted.
+type DataSubjectDocument struct {
+	Subject    DataSubject         `json:"subject"`
+	ExportedAt string              `json:"exported_at"`
+	Visitors   []map[string]string `json:"visitors"`
+	Events     []map[string]string `json:"events"`
+}
+
+// DataSubjectEraseResult is the outcome of DataSubjectErase.
+type DataSubjectEraseResult struct {
+	Mode     string `json:"mode"`
+	Visitors int    `json:"visitors"`
+	Events   int    `json:"events"`
+}
+
+// DataSubjectAuditEntry is an entry of the data-subject audit log.
+type DataSubjectAuditEntry struct {
+	ID        string `json:"id"`
+	Action    string `json:"action"`
+	Subject   string `json:"subject"`
+	Actor     string `json:"actor"`
+	Visitors  int    `json:"visitors"`
+	Events    int    `json:"events"`
+	CreatedAt string `json:"created_at"`
+}
+
+// == MIGRATE ==================================================================
+
+// migrateAuditTable creates the data-subject audit log table if it does not
+// exist.
+func (st *storeImplementation) migrateAuditTable() error {
+	if st.auditTableName == "" || st.db.Schema().HasTable(st.auditTableName) {
+		return nil
+	}
+
+	err := st.db.Schema().Create(st.auditTableName, func(table contractsschema.Blueprint) {
+		table.String(COLUMN_ID, 40)
+		table.Primary(COLUMN_ID)
+		table.String(COLUMN_ACTION, 20)
+		table.String(COLUMN_SUBJECT, 255)
+		table.String(COLUMN_ACTOR, 120)
+		table.String(COLUMN_VISITORS, 20)
+		table.String(COLUMN_EVENTS, 20)
+		table.DateTime(COLUMN_CREATED_AT)
+
+		table.Index(COLUMN_CREATED_AT)
+	})
+
+	if err != nil && st.debugEnabled {
+		st.logger.Error("MigrateUp: audit table creation failed", "error", err)
+	}
+
+	return err
+}
+
+// == STORE ====================================================================
+
+// DataSubjectFind returns the visitors (soft-deleted ones included) and
+// events of a data subject. Visitors match by IP, fingerprint (stored, or
+// calculated for rows recorded before it was stored) or user ID;
+// events by user ID, by the fingerprint of the subject or of any of its
+// visitors, or by the visitor row they are linked to, which ties events to
+// an IP.
+func (st *storeImplementation) DataSubjectFind(ctx context.Context, subject DataSubject) (DataSubjectRecords, error) {
+	records := DataSubjectRecords{Visitors: []VisitorInterface{}, Events: []EventInterface{}}
+	if subject.IsEmpty() {
+		return records, errors.New("data subject has no identifier")
+	}
+
+	clauses := []string{}
+	args := []any{}
+	if subject.IP != "" {
+		clause, ipArgs := st.ipMatchClause([]string{subject.IP})
+		clauses = append(clauses, clause)
+		args = append(args, ipArgs...)
+	}
+	if subject.Fingerprint != "" {
+		clauses = append(clauses, COLUMN_FINGERPRINT+" = ?")
+		args = append(args, subject.Fingerprint)
+	}
+	if subject.UserID != "" {
+		clauses = append(clauses, COLUMN_USER_ID+" = ?")
+		args = append(args, subject.UserID)
+	}
+
+	visitors, err := st.visitorListQuery(st.db.Query().
+		Table(st.visitorTableName).
+		WithSoftDeleted().
+		Where("("+strings.Join(clauses, " OR ")+")", args...).
+		OrderBy(COLUMN_CREATED_AT, "asc"))
+	if err != nil {
+		return records, err
+	}
+
+	if subject.Fingerprint != "" {
+		calculated, err := st.dataSubjectCalculatedMatches(ctx, subject.Fingerprint)
+		if err != nil {
+			return records, err
+		}
+		visitors = dataSubjectMergeVisitors(visitors, calculated)
+	}
+	records.Visitors = visitors
+
+	if st.eventTableName == "" {
+		return records, nil
+	}
+
+	fingerprints := []any{}
+	seen := map[string]bool{}
+	for _, fingerprint := range append([]string{subject.Fingerprint}, dataSubjectFingerprints(visitors)...) {
+		if fingerprint == "" || seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	visitorIDs := make([]any, 0, len(visitors))
+	for _, visitor := range visitors {
+		visitorIDs = append(visitorIDs, visitor.GetID())
+	}
+
+	clauses = []string{}
+	args = []any{}
+	if len(fingerprints) > 0 {
+		clauses = append(clauses, COLUMN_FINGERPRINT+" IN ("+dataSubjectPlaceholders(len(fingerprints))+")")
+		args = append(args, fingerprints...)
+	}
+	if len(visitorIDs) > 0 {
+		clauses = append(clauses, COLUMN_VISITOR_ID+" IN ("+dataSubjectPlaceholders(len(visitorIDs))+")")
+		args = append(args, visitorIDs...)
+	}
+	if subject.UserID != "" {
+		clauses = append(clauses, COLUMN_USER_ID+" = ?")
+		args = append(args, subject.UserID)
+	}
+	if len(clauses) == 0 {
+		return records, nil
+	}
+
+	events, err := st.eventListQuery(st.db.Query().
+		Table(st.eventTableName).
+		Where("("+strings.Join(clauses, " OR ")+")", args...).
+		OrderBy(COLUMN_CREATED_AT, "asc"))
+	if err != nil {
+		return records, err
+	}
+	records.Events = events
+
+	return records, nil
+}
+
+// DataSubjectExport returns the records of a data subject as an indented
+// JSON DataSubjectDocument, for an access request. The export is recorded
+// in the audit log with actor, who handled the request.
+func (st *storeImplementation) DataSubjectExport(ctx context.Context, subject DataSubject, actor string) ([]byte, error) {
+	records, err := st.DataSubjectFind(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	document := DataSubjectDocument{
+		Subject:    subject,
+		ExportedAt: carbon.Now(carbon.UTC).ToIso8601String(carbon.UTC),
+		Visitors:   make([]map[string]string, 0, len(records.Visitors)),
+		Events:     make([]map[string]string, 0, len(records.Events)),
+	}
+	for _, visitor := range records.Visitors {
+		document.Visitors = append(document.Visitors, dataSubjectVisitorMap(visitor))
+	}
+	for _, event := range records.Events {
+		document.Events = append(document.Events, dataSubjectEventMap(event))
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.dataSubjectAudit(ctx, DataSubjectActionAccess, subject, actor, len(records.Visitors), len(records.Events)); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DataSubjectErase deletes or anonymizes (see DataSubjectErasureDelete and
+// DataSubjectErasureAnonymize) the records found by DataSubjectFind, and
+// records the erasure in the audit log with actor. Sessions are derived
+// from the visitor rows, so they go with them.
+func (st *storeImplementation) DataSubjectErase(ctx context.Context, subject DataSubject, mode string, actor string) (DataSubjectEraseResult, error) {
+	result := DataSubjectEraseResult{Mode: mode}
+	if mode != DataSubjectErasureDelete && mode != DataSubjectErasureAnonymize {
+		return result, errors.New("unknown erasure mode: " + mode)
+	}
+
+	records, err := st.DataSubjectFind(ctx, subject)
+	if err != nil {
+		return result, err
+	}
+
+	action, failedAction := DataSubjectActionDelete, DataSubjectActionDeleteFailed
+	if mode == DataSubjectErasureAnonymize {
+		action, failedAction = DataSubjectActionAnonymize, DataSubjectActionAnonymizeFailed
+	}
+
+	// A failure leaves the rows erased so far erased; the audit log records
+	// how many before the error is returned.
+	fail := func(err error) (DataSubjectEraseResult, error) {
+		if auditErr := st.dataSubjectAudit(ctx, failedAction, subject, actor, result.Visitors, result.Events); auditErr != nil {
+			return result, errors.Join(err, auditErr)
+		}
+		return result, err
+	}
+
+	for _, visitor := range records.Visitors {
+		if mode == DataSubjectErasureDelete {
+			err = st.VisitorDelete(ctx, visitor)
+		} else {
+			privacyAnonymizeVisitor(visitor, PrivacyReasonErased)
+			err = st.VisitorUpdate(ctx, visitor)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		result.Visitors++
+	}
+
+	for _, event := range records.Events {
+		if mode == DataSubjectErasureDelete {
+			err = st.EventDeleteByID(ctx, event.GetID())
+		} else {
+			privacyAnonymizeEvent(event)
+			_, err = st.db.Query().
+				Table(st.eventTableName).
+				Where(COLUMN_ID+" = ?", event.GetID()).
+				Update(map[string]any{
+					COLUMN_FINGERPRINT: event.GetFingerprint(),
+					COLUMN_USER_ID:     event.GetUserID(),
+					COLUMN_CLIENT_ID:   event.GetClientID(),
+					COLUMN_VISITOR_ID:  event.GetVisitorID(),
+					COLUMN_UPDATED_AT:  carbon.Now(carbon.UTC).StdTime(),
+				})
+		}
+		if err != nil {
+			return fail(err)
+		}
+		result.Events++
+	}
+
+	if err := st.dataSubjectAudit(ctx, action, subject, actor, result.Visitors, result.Events); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// DataSubjectAuditList returns the latest entries of the data-subject audit
+// log, newest first. limit defaults to 100.
+func (st *storeImplementation) DataSubjectAuditList(ctx context.Context, limit int) ([]DataSubjectAuditEntry, error) {
+	if st.auditTableName == "" {
+		return []DataSubjectAuditEntry{}, nil
+	}
+	if limit <= 0 {
+		limit = dataSubjectAuditListLimit
+	}
+
+	type auditRow struct {
+		ID        string    `db:"id"`
+		Action    string    `db:"action"`
+		Subject   string    `db:"subject"`
+		Actor     string    `db:"actor"`
+		Visitors  string    `db:"visitors"`
+		Events    string    `db:"events"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	var rows []auditRow
+	err := st.db.Query().
+		Table(st.auditTableName).
+		OrderBy(COLUMN_CREATED_AT, "desc").
+		Limit(limit).
+		Get(&rows)
+	if err != nil {
+		return []DataSubjectAuditEntry{}, err
+	}
+
+	entries := make([]DataSubjectAuditEntry, 0, len(rows))
+	for _, r := range rows {
+		visitors, _ := strconv.Atoi(r.Visitors)
+		events, _ := strconv.Atoi(r.Events)
+		entries = append(entries, DataSubjectAuditEntry{
+			ID:        r.ID,
+			Action:    r.Action,
+			Subject:   r.Subject,
+			Actor:     r.Actor,
+			Visitors:  visitors,
+			Events:    events,
+			CreatedAt: carbon.CreateFromStdTime(r.CreatedAt, carbon.UTC).ToDateTimeString(carbon.UTC),
+		})
+	}
+
+	return entries, nil
+}
+
+// dataSubjectAudit appends an entry to the audit log.
+func (st *storeImplementation) dataSubjectAudit(ctx context.Context, action string, subject DataSubject, actor string, visitors, events int) error {
+	if st.auditTableName == "" {
+		return nil
+	}
+
+	err := st.db.Query().Table(st.auditTableName).Create(map[string]any{
+		COLUMN_ID:         neatuid.GenerateShortID(),
+		COLUMN_ACTION:     action,
+		COLUMN_SUBJECT:    subject.Digest(),
+		COLUMN_ACTOR:      actor,
+		COLUMN_VISITORS:   strconv.Itoa(visitors),
+		COLUMN_EVENTS:     strconv.Itoa(events),
+		COLUMN_CREATED_AT: carbon.Now(carbon.UTC).StdTime(),
+	})
+	if err != nil && st.debugEnabled {
+		st.logger.Error("data-subject: audit log failed", "action", action, "error", err)
+	}
+
+	return err
+}
+
+// == PRIVATE FUNCTIONS ========================================================
+
+// dataSubjectFingerprints returns the fingerprints events of the visitors
+// are stored with: the stored one, or else the one calculated from the IP
+// address and user agent, as linked events get it. Visits recorded
+// aggregate only have neither.
+func dataSubjectFingerprints(visitors []VisitorInterface) []string {
+	fingerprints := make([]string, 0, len(visitors))
+	for _, visitor := range visitors {
+		if visitor.GetPrivacy() == "" {
+			fingerprints = append(fingerprints, visitorFingerprint(visitor))
+		}
+	}
+	return fingerprints
+}
+
+// dataSubjectCalculatedMatches returns the visitors without a stored
+// fingerprint whose calculated fingerprint is fingerprint. The IP address
+// may be encrypted, so the rows are compared in Go, in batches.
+func (st *storeImplementation) dataSubjectCalculatedMatches(ctx context.Context, fingerprint string) ([]VisitorInterface, error) {
+	matches := []VisitorInterface{}
+	for offset := 0; ; offset += dataSubjectScanBatchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		batch, err := st.visitorListQuery(st.db.Query().
+			Table(st.visitorTableName).
+			WithSoftDeleted().
+			Where("("+COLUMN_FINGERPRINT+" = ? OR "+COLUMN_FINGERPRINT+" IS NULL)", "").
+			OrderBy(COLUMN_ID, "asc").
+			Offset(offset).
+			Limit(dataSubjectScanBatchSize))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, visitor := range batch {
+			if visitor.GetPrivacy() == "" && visitor.FingerprintCalculate() == fingerprint {
+				matches = append(matches, visitor)
+			}
+		}
+		if len(batch) < dataSubjectScanBatchSize {
+			return matches, nil
+		}
+	}
+}
+
+// dataSubjectMergeVisitors adds the visitors of more missing from visitors,
+// keeping them ordered by creation time.
+func dataSubjectMergeVisitors(visitors, more []VisitorInterface) []VisitorInterface {
+	if len(more) == 0 {
+		return visitors
+	}
+
+	seen := make(map[string]bool, len(visitors))
+	for _, visitor := range visitors {
+		seen[visitor.GetID()] = true
+	}
+	for _, visitor := range more {
+		if !seen[visitor.GetID()] {
+			visitors = append(visitors, visitor)
+		}
+	}
+
+	sort.SliceStable(visitors, func(a, b int) bool {
+		return visitors[a].GetCreatedAt() < visitors[b].GetCreatedAt()
+	})
+	return visitors
+}
+
+// dataSubjectPlaceholders returns n comma-separated "?" placeholders.
+func dataSubjectPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func dataSubjectVisitorMap(v VisitorInterface) map[string]string {
+	return map[string]string{
+		COLUMN_ID:                   v.GetID(),
+		COLUMN_PATH:                 v.GetPath(),
+		COLUMN_PAGE_TITLE:           v.GetPageTitle(),
+		COLUMN_FINGERPRINT:          v.GetFingerprint(),
+		COLUMN_USER_ID:              v.GetUserID(),
+		COLUMN_IP_ADDRESS:           v.GetIpAddress(),
+		COLUMN_PEER_IP_ADDRESS:      v.GetPeerIpAddress(),
+		COLUMN_COUNTRY:              v.GetCountry(),
+		COLUMN_USER_AGENT:           v.GetUserAgent(),
+		COLUMN_CLIENT_HINTS:         v.GetClientHints(),
+		COLUMN_USER_ACCEPT_LANGUAGE: v.GetUserAcceptLanguage(),
+		COLUMN_USER_ACCEPT_ENCODING: v.GetUserAcceptEncoding(),
+		COLUMN_USER_OS:              v.GetUserOs(),
+		COLUMN_USER_OS_VERSION:      v.GetUserOsVersion(),
+		COLUMN_USER_DEVICE:          v.GetUserDevice(),
+		COLUMN_USER_DEVICE_TYPE:     v.GetUserDeviceType(),
+		COLUMN_USER_DEVICE_BRAND:    v.GetUserDeviceBrand(),
+		COLUMN_USER_DEVICE_MODEL:    v.GetUserDeviceModel(),
+		COLUMN_USER_BROWSER:         v.GetUserBrowser(),
+		COLUMN_USER_BROWSER_VERSION: v.GetUserBrowserVersion(),
+		COLUMN_USER_BROWSER_ENGINE:  v.GetUserBrowserEngine(),
+		COLUMN_USER_WEBVIEW:         v.GetUserWebview(),
+		COLUMN_USER_APP:             v.GetUserApp(),
+		COLUMN_USER_REFERRER:        v.GetUserReferrer(),
+		COLUMN_CHANNEL:              v.GetChannel(),
+		COLUMN_CAMPAIGN:             v.GetCampaign(),
+		COLUMN_SCREEN_SIZE:          v.GetScreenSize(),
+		COLUMN_ENGAGEMENT_SECONDS:   v.GetEngagementSeconds(),
+		COLUMN_SCROLL_DEPTH:         v.GetScrollDepth(),
+		COLUMN_BOT:                  v.GetBot(),
+		COLUMN_THREAT:               v.GetThreat(),
+		COLUMN_TAGS:                 v.GetTags(),
+		COLUMN_ENRICHMENT:           v.GetEnrichment(),
+		COLUMN_PRIVACY:              v.GetPrivacy(),
+		COLUMN_CREATED_AT:           v.GetCreatedAt(),
+		COLUMN_SOFT_DELETED_AT:      v.GetSoftDeletedAt(),
+	}
+}
+
+func dataSubjectEventMap(e EventInterface) map[string]string {
+	return map[string]string{
+		COLUMN_ID:               e.GetID(),
+		COLUMN_NAME:             e.GetName(),
+		COLUMN_VISITOR_ID:       e.GetVisitorID(),
+		COLUMN_FINGERPRINT:      e.GetFingerprint(),
+		COLUMN_USER_ID:          e.GetUserID(),
+		COLUMN_CLIENT_ID:        e.GetClientID(),
+		COLUMN_DOMAIN:           e.GetDomain(),
+		COLUMN_PATH:             e.GetPath(),
+		COLUMN_USER_REFERRER:    e.GetReferrer(),
+		COLUMN_CAMPAIGN:         e.GetCampaign(),
+		COLUMN_PROPS:            e.GetProps(),
+		COLUMN_REVENUE_AMOUNT:   e.GetRevenueAmount(),
+		COLUMN_REVENUE_CURRENCY: e.GetRevenueCurrency(),
+		COLUMN_ORDER_ID:         e.GetOrderID(),
+		COLUMN_ITEMS:            e.GetItems(),
+		COLUMN_CREATED_AT:       e.GetCreatedAt(),
+	}
+}
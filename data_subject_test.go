@@ -0,0 +1,271 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dataSubjectTestStore stores the visits and events of two people: Jane
+// (IP 203.0.113.7, user 42) and Joe (IP 198.51.100.1).
+func dataSubjectTestStore(t *testing.T) StoreInterface {
+	t.Helper()
+
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	visitors := []VisitorInterface{
+		NewVisitor().SetIpAddress("203.0.113.7").SetUserAgent("jane-phone").SetPath("/pricing"),
+		NewVisitor().SetIpAddress("203.0.113.7").SetUserAgent("jane-phone").SetPath("/signup").SetUserID("42"),
+		NewVisitor().SetIpAddress("192.0.2.10").SetUserAgent("jane-laptop").SetPath("/account").SetUserID("42"),
+		NewVisitor().SetIpAddress("198.51.100.1").SetUserAgent("joe").SetPath("/pricing"),
+	}
+	for _, visitor := range visitors {
+		visitor.SetFingerprint(visitor.FingerprintCalculate())
+		if err := store.VisitorCreate(ctx, visitor); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	if err := store.VisitorSoftDelete(ctx, visitors[0]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	events := []EventInterface{
+		NewEvent().SetName("Download").SetFingerprint(visitors[0].GetFingerprint()),
+		NewEvent().SetName("Signup").SetUserID("42").SetClientID("abc"),
+		NewEvent().SetName("Download").SetFingerprint(visitors[3].GetFingerprint()),
+	}
+	for _, event := range events {
+		if err := store.EventCreate(ctx, event); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return store
+}
+
+func TestDataSubjectDigest(t *testing.T) {
+	digest := DataSubject{IP: "203.0.113.7", UserID: "42"}.Digest()
+	if !strings.HasPrefix(digest, "ip:") || !strings.Contains(digest, " user_id:") || strings.Contains(digest, "203.0.113.7") {
+		t.Errorf("unexpected digest %q", digest)
+	}
+	if digest != (DataSubject{IP: "203.0.113.7", UserID: "42"}).Digest() {
+		t.Error("expected a deterministic digest")
+	}
+	if (DataSubject{}).Digest() != "" {
+		t.Error("expected an empty digest for an empty subject")
+	}
+}
+
+func TestStoreDataSubjectFind(t *testing.T) {
+	store := dataSubjectTestStore(t)
+	ctx := context.Background()
+
+	if _, err := store.DataSubjectFind(ctx, DataSubject{}); err == nil {
+		t.Error("expected an error for an empty subject")
+	}
+
+	tests := []struct {
+		name     string
+		subject  DataSubject
+		visitors int
+		events   int
+	}{
+		{"by ip", DataSubject{IP: "203.0.113.7"}, 2, 1},
+		{"by user id", DataSubject{UserID: "42"}, 2, 2},
+		{"by ip and user id", DataSubject{IP: "203.0.113.7", UserID: "42"}, 3, 2},
+		{"unknown", DataSubject{IP: "192.0.2.99"}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		records, err := store.DataSubjectFind(ctx, tt.subject)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if len(records.Visitors) != tt.visitors || len(records.Events) != tt.events {
+			t.Errorf("%s: got %d visitors and %d events, want %d and %d",
+				tt.name, len(records.Visitors), len(records.Events), tt.visitors, tt.events)
+		}
+	}
+}
+
+func TestStoreDataSubjectFindCalculatedFingerprint(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "jane-phone")
+	registered, err := store.VisitorRegisterHit(ctx, r, PageHit{Path: "/pricing"})
+	if err != nil || registered == nil {
+		t.Fatalf("expected a visitor, got %v (%v)", registered, err)
+	}
+	if registered.GetFingerprint() != registered.FingerprintCalculate() {
+		t.Errorf("expected the calculated fingerprint to be stored, got %q", registered.GetFingerprint())
+	}
+
+	// A row recorded before fingerprints were stored at ingestion.
+	legacy := NewVisitor().SetIpAddress("203.0.113.7").SetUserAgent("jane-phone").SetPath("/signup")
+	if err := store.VisitorCreate(ctx, legacy); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	other := NewVisitor().SetIpAddress("198.51.100.1").SetUserAgent("joe").SetPath("/pricing")
+	if err := store.VisitorCreate(ctx, other); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	records, err := store.DataSubjectFind(ctx, DataSubject{Fingerprint: legacy.FingerprintCalculate()})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(records.Visitors) != 2 {
+		t.Fatalf("expected the registered and the legacy visit, got %d", len(records.Visitors))
+	}
+	if records.Visitors[0].GetID() != registered.GetID() || records.Visitors[1].GetID() != legacy.GetID() {
+		t.Errorf("expected the visits in creation order, got %s and %s", records.Visitors[0].GetID(), records.Visitors[1].GetID())
+	}
+}
+
+func TestStoreDataSubjectExport(t *testing.T) {
+	store := dataSubjectTestStore(t)
+	ctx := context.Background()
+
+	data, err := store.DataSubjectExport(ctx, DataSubject{UserID: "42"}, "dpo@example.com")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := DataSubjectDocument{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if document.Subject.UserID != "42" || len(document.Visitors) != 2 || len(document.Events) != 2 {
+		t.Fatalf("unexpected document: %s", data)
+	}
+	if document.Visitors[0][COLUMN_IP_ADDRESS] == "" || document.Visitors[0][COLUMN_USER_ID] != "42" {
+		t.Errorf("expected the identifying fields in the export, got %v", document.Visitors[0])
+	}
+
+	entries, err := store.DataSubjectAuditList(ctx, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d (%v)", len(entries), err)
+	}
+	entry := entries[0]
+	if entry.Action != DataSubjectActionAccess || entry.Actor != "dpo@example.com" || entry.Visitors != 2 || entry.Events != 2 ||
+		entry.Subject != (DataSubject{UserID: "42"}).Digest() {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestStoreDataSubjectErase(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delete", func(t *testing.T) {
+		store := dataSubjectTestStore(t)
+
+		result, err := store.DataSubjectErase(ctx, DataSubject{IP: "203.0.113.7", UserID: "42"}, DataSubjectErasureDelete, "admin")
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if want := (DataSubjectEraseResult{Mode: DataSubjectErasureDelete, Visitors: 3, Events: 2}); result != want {
+			t.Errorf("DataSubjectErase() = %+v, want %+v", result, want)
+		}
+
+		visitors, err := store.VisitorCount(ctx, VisitorQuery().SetSoftDeletedIncluded(true))
+		if err != nil || visitors != 1 {
+			t.Errorf("expected only Joe's visitor to remain, got %d (%v)", visitors, err)
+		}
+		events, err := store.EventCount(ctx, EventQuery())
+		if err != nil || events != 1 {
+			t.Errorf("expected only Joe's event to remain, got %d (%v)", events, err)
+		}
+
+		entries, err := store.DataSubjectAuditList(ctx, 10)
+		if err != nil || len(entries) != 1 || entries[0].Action != DataSubjectActionDelete {
+			t.Errorf("expected a delete audit entry, got %+v (%v)", entries, err)
+		}
+	})
+
+	t.Run("anonymize", func(t *testing.T) {
+		store := dataSubjectTestStore(t)
+
+		result, err := store.DataSubjectErase(ctx, DataSubject{UserID: "42"}, DataSubjectErasureAnonymize, "admin")
+		if err != nil || result.Visitors != 2 || result.Events != 2 {
+			t.Fatalf("unexpected result %+v (%v)", result, err)
+		}
+
+		records, err := store.DataSubjectFind(ctx, DataSubject{UserID: "42"})
+		if err != nil || len(records.Visitors) != 0 || len(records.Events) != 0 {
+			t.Errorf("expected nothing left to find, got %d visitors and %d events (%v)", len(records.Visitors), len(records.Events), err)
+		}
+
+		erased, err := store.VisitorList(ctx, VisitorQuery().SetPrivacy(PrivacyReasonErased))
+		if err != nil || len(erased) != 2 {
+			t.Fatalf("expected 2 erased visitors, got %d (%v)", len(erased), err)
+		}
+		for _, v := range erased {
+			if v.GetIpAddress() != "" || v.GetFingerprint() != "" || v.GetUserAgent() != "" || v.GetPath() == "" {
+				t.Errorf("expected an anonymous visitor keeping its path, got %+v", v)
+			}
+		}
+
+		events, err := store.EventCount(ctx, EventQuery())
+		if err != nil || events != 3 {
+			t.Errorf("expected the events to be kept, got %d (%v)", events, err)
+		}
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		store := dataSubjectTestStore(t)
+		if _, err := store.DataSubjectErase(ctx, DataSubject{UserID: "42"}, "shred", "admin"); err == nil {
+			t.Error("expected an error for an unknown mode")
+		}
+	})
+}
+
+func TestStoreDataSubjectEraseTrackedEvents(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "jane-phone")
+	visitor, err := store.VisitorRegisterHit(ctx, r, PageHit{Path: "/pricing"})
+	if err != nil || visitor == nil {
+		t.Fatalf("expected a visitor, got %v (%v)", visitor, err)
+	}
+
+	// The tracker links the first event to the page view and gives it the
+	// page view's calculated fingerprint; the second keeps its own.
+	for _, event := range []EventInterface{
+		NewEvent().SetName("Download").SetClientID(visitor.GetID()),
+		NewEvent().SetName("Signup").SetVisitorID(visitor.GetID()).SetFingerprint("client-hash"),
+	} {
+		if _, _, err := store.EventTrack(ctx, event); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	result, err := store.DataSubjectErase(ctx, DataSubject{IP: "203.0.113.7"}, DataSubjectErasureDelete, "admin")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if result.Visitors != 1 || result.Events != 2 {
+		t.Errorf("expected the visit and both tracked events to be erased, got %+v", result)
+	}
+	if events, err := store.EventCount(ctx, EventQuery()); err != nil || events != 0 {
+		t.Errorf("expected no events left, got %d (%v)", events, err)
+	}
+}
@@ -0,0 +1,76 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	contractsdatabase "github.com/dracory/neat/contracts/database"
+	"github.com/dromara/carbon/v2"
+)
+
+// == ENGAGEMENT ===============================================================
+
+const (
+	// EngagementMaxSeconds caps the visible time recorded for a single page
+	// view, so tabs left open in the foreground do not skew averages.
+	EngagementMaxSeconds = 3600
+
+	// ScrollDepthMax is the maximum scroll depth in percent.
+	ScrollDepthMax = 100
+)
+
+// VisitorEngagementUpdate records engagement for the page-view row with the
+// given ID: visible time in seconds and maximum scroll depth in percent.
+// Heartbeat pings report running totals, so each value only ever increases;
+// values are clamped to EngagementMaxSeconds and ScrollDepthMax. Unknown IDs
+// are ignored.
+func (st *storeImplementation) VisitorEngagementUpdate(ctx context.Context, id string, engagedSeconds int, scrollDepth int) error {
+	if id == "" {
+		return errors.New("visitor id is empty")
+	}
+
+	engagedSeconds = clampInt(engagedSeconds, 0, EngagementMaxSeconds)
+	scrollDepth = clampInt(scrollDepth, 0, ScrollDepthMax)
+
+	// Each column is raised with its own guarded UPDATE, so concurrent
+	// heartbeats never lower a value and other columns written meanwhile
+	// (country, user agent, enrichment) are left untouched.
+	columns := []struct {
+		name  string
+		value int
+	}{
+		{COLUMN_ENGAGEMENT_SECONDS, engagedSeconds},
+		{COLUMN_SCROLL_DEPTH, scrollDepth},
+	}
+
+	for _, column := range columns {
+		_, err := st.db.Query().Table(st.visitorTableName).
+			Where(COLUMN_ID+" = ?", id).
+			Where(st.sqlInteger(column.name)+" < ?", column.value).
+			Update(map[string]any{
+				column.name:       strconv.Itoa(column.value),
+				COLUMN_UPDATED_AT: carbon.Now(carbon.UTC).StdTime(),
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqlInteger returns an SQL expression reading the given string column as an
+// integer, with empty values read as zero.
+func (st *storeImplementation) sqlInteger(column string) string {
+	integer := "INTEGER"
+	if st.db.Query().Driver() == contractsdatabase.DriverMysql {
+		integer = "SIGNED"
+	}
+	return "CAST(COALESCE(NULLIF(" + column + ", ''), '0') AS " + integer + ")"
+}
+
+// clampInt limits v to the range [lo, hi].
+func clampInt(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,134 @@
+package statsstore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVisitorEngagementUpdate(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	visitor := NewVisitor().SetPath("/article")
+	if err := store.VisitorCreate(ctx, visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	steps := []struct {
+		seconds, depth         int
+		wantSeconds, wantDepth string
+	}{
+		{15, 40, "15", "40"},
+		{30, 35, "30", "40"},                            // scroll depth never decreases
+		{10, 80, "30", "80"},                            // visible time never decreases
+		{EngagementMaxSeconds + 50, 150, "3600", "100"}, // clamped
+	}
+
+	for _, step := range steps {
+		if err := store.VisitorEngagementUpdate(ctx, visitor.GetID(), step.seconds, step.depth); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		found, err := store.VisitorFindByID(ctx, visitor.GetID())
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if found.GetEngagementSeconds() != step.wantSeconds || found.GetScrollDepth() != step.wantDepth {
+			t.Errorf("after (%d, %d): got (%s, %s), want (%s, %s)",
+				step.seconds, step.depth,
+				found.GetEngagementSeconds(), found.GetScrollDepth(),
+				step.wantSeconds, step.wantDepth)
+		}
+	}
+}
+
+func TestVisitorEngagementUpdateUnknownID(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := store.VisitorEngagementUpdate(context.Background(), "missing", 10, 10); err != nil {
+		t.Errorf("expected unknown id to be ignored, got %v", err)
+	}
+	if err := store.VisitorEngagementUpdate(context.Background(), "", 10, 10); err == nil {
+		t.Error("expected error for empty id")
+	}
+}
+
+func TestVisitorEngagementUpdateKeepsOtherColumns(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	visitor := NewVisitor().SetPath("/article").SetIpAddress("1.1.1.1")
+	if err := store.VisitorCreate(ctx, visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The enhancer fills in the country between two heartbeats.
+	stale, err := store.VisitorFindByID(ctx, visitor.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.VisitorUpdate(ctx, stale.SetCountry("DE")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := store.VisitorEngagementUpdate(ctx, visitor.GetID(), 9, 120); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := store.VisitorFindByID(ctx, visitor.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found.GetCountry() != "DE" || found.GetIpAddress() != "1.1.1.1" {
+		t.Errorf("expected other columns to be kept, got country %q and IP %q", found.GetCountry(), found.GetIpAddress())
+	}
+	if found.GetEngagementSeconds() != "9" || found.GetScrollDepth() != "100" {
+		t.Errorf("got (%s, %s), want (9, 100)", found.GetEngagementSeconds(), found.GetScrollDepth())
+	}
+}
+
+func TestVisitorUpdateKeepsEngagement(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	visitor := NewVisitor().SetPath("/article")
+	if err := store.VisitorCreate(ctx, visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The enhancer loads the row, a heartbeat arrives, then the enhancer
+	// saves its copy.
+	stale, err := store.VisitorFindByID(ctx, visitor.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.VisitorEngagementUpdate(ctx, visitor.GetID(), 20, 60); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.VisitorUpdate(ctx, stale.SetCountry("DE")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := store.VisitorFindByID(ctx, visitor.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found.GetCountry() != "DE" {
+		t.Errorf("expected the country to be saved, got %q", found.GetCountry())
+	}
+	if found.GetEngagementSeconds() != "20" || found.GetScrollDepth() != "60" {
+		t.Errorf("got (%s, %s), want (20, 60)", found.GetEngagementSeconds(), found.GetScrollDepth())
+	}
+}
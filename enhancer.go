@@ -0,0 +1,400 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// == CONSTANTS ================================================================
+
+const (
+	// EnhancerRateLimitDefault is the default number of geo-IP lookups per
+	// second. It matches the 2-second spacing used by VisitorEnhance.
+	EnhancerRateLimitDefault = 0.5
+
+	// EnhancerWorkersDefault is the default size of the lookup worker pool.
+	EnhancerWorkersDefault = 2
+
+	// EnhancerMaxBatchSizeDefault is the default upper bound for the adaptive
+	// batch size.
+	EnhancerMaxBatchSizeDefault = 500
+
+	// EnhancerIdleIntervalDefault is how long the worker sleeps when the
+	// backlog is empty or a batch made no progress.
+	EnhancerIdleIntervalDefault = time.Minute
+)
+
+// == TYPES ====================================================================
+
+// EnhancerOptions configures the background enhancement worker started by
+// StartEnhancer. Zero values fall back to the defaults above.
+type EnhancerOptions struct {
+	RateLimit    float64       // geo-IP lookups per second (token bucket refill rate)
+	Burst        int           // token bucket capacity; default 1
+	Workers      int           // number of concurrent lookup workers
+	MinBatchSize int           // lower bound for the adaptive batch size; default EnhanceBatchSize
+	MaxBatchSize int           // upper bound for the adaptive batch size
+	IdleInterval time.Duration // sleep when there is nothing (more) to do
+}
+
+// EnhancerStats is a point-in-time snapshot of the enhancement worker.
+type EnhancerStats struct {
+	Running      bool
+	Backlog      int64 // visitor rows with an empty country
+	Processed    int64 // rows that received a country
+	Batches      int64
+	BatchSize    int // current adaptive batch size
+	LookupErrors int64
+	UpdateErrors int64
+	LastBatchAt  time.Time
+	LastError    string
+}
+
+// EnhancerInterface controls a running background enhancement worker.
+type EnhancerInterface interface {
+	// Stop signals the worker to finish and waits for it to exit.
+	Stop()
+	// Stats returns a snapshot of the worker's progress counters.
+	Stats() EnhancerStats
+}
+
+// == STORE METHODS ============================================================
+
+// StartEnhancer starts a managed background worker that continuously
+// enriches visitor rows with an empty country. Unlike VisitorEnhance, which
+// processes a single small batch with a fixed delay between lookups, the
+// worker resolves unique IPs through a pool of workers limited by a token
+// bucket, and grows or shrinks its batch size depending on how well lookups
+// are succeeding.
+//
+// The worker stops when ctx is cancelled or Stop is called. Only one worker
+// may run per store; starting a second one returns an error.
+func (st *storeImplementation) StartEnhancer(ctx context.Context) (EnhancerInterface, error) {
+	if st.geoIPResolver == nil {
+		return nil, errors.New("stats store: GeoIPResolver is not configured")
+	}
+
+	st.enhancerMu.Lock()
+	defer st.enhancerMu.Unlock()
+
+	if st.enhancer != nil && st.enhancer.Stats().Running {
+		return nil, errors.New("stats store: enhancer is already running")
+	}
+
+	e := newEnhancer(st, st.enhancerOptions)
+	e.start(ctx)
+	st.enhancer = e
+
+	return e, nil
+}
+
+// VisitorEnhanceBacklog returns the number of visitor rows still waiting for
+// enrichment (rows with an empty country).
+func (st *storeImplementation) VisitorEnhanceBacklog(ctx context.Context) (int64, error) {
+	return st.VisitorCount(ctx, VisitorQuery().SetCountry("empty"))
+}
+
+// == ENHANCER =================================================================
+
+type enhancer struct {
+	store   *storeImplementation
+	opts    EnhancerOptions
+	limiter *tokenBucket
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	running      atomic.Bool
+	backlog      atomic.Int64
+	processed    atomic.Int64
+	batches      atomic.Int64
+	batchSize    atomic.Int64
+	lookupErrors atomic.Int64
+	updateErrors atomic.Int64
+
+	mu          sync.Mutex
+	lastBatchAt time.Time
+	lastError   string
+}
+
+var _ EnhancerInterface = (*enhancer)(nil)
+
+func newEnhancer(st *storeImplementation, opts EnhancerOptions) *enhancer {
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = EnhancerRateLimitDefault
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = EnhancerWorkersDefault
+	}
+	if opts.MinBatchSize <= 0 {
+		opts.MinBatchSize = st.enhanceBatchSize
+	}
+	if opts.MinBatchSize <= 0 {
+		opts.MinBatchSize = 10
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = EnhancerMaxBatchSizeDefault
+	}
+	if opts.MaxBatchSize < opts.MinBatchSize {
+		opts.MaxBatchSize = opts.MinBatchSize
+	}
+	if opts.IdleInterval <= 0 {
+		opts.IdleInterval = EnhancerIdleIntervalDefault
+	}
+
+	e := &enhancer{
+		store:   st,
+		opts:    opts,
+		limiter: newTokenBucket(opts.RateLimit, opts.Burst),
+		done:    make(chan struct{}),
+	}
+	e.batchSize.Store(int64(opts.MinBatchSize))
+	return e
+}
+
+func (e *enhancer) start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	e.cancel = cancel
+	e.running.Store(true)
+
+	go func() {
+		defer close(e.done)
+		defer e.running.Store(false)
+		e.loop(ctx)
+	}()
+}
+
+// Stop signals the worker to finish and waits for it to exit.
+func (e *enhancer) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	<-e.done
+}
+
+// Stats returns a snapshot of the worker's progress counters.
+func (e *enhancer) Stats() EnhancerStats {
+	e.mu.Lock()
+	lastBatchAt := e.lastBatchAt
+	lastError := e.lastError
+	e.mu.Unlock()
+
+	return EnhancerStats{
+		Running:      e.running.Load(),
+		Backlog:      e.backlog.Load(),
+		Processed:    e.processed.Load(),
+		Batches:      e.batches.Load(),
+		BatchSize:    int(e.batchSize.Load()),
+		LookupErrors: e.lookupErrors.Load(),
+		UpdateErrors: e.updateErrors.Load(),
+		LastBatchAt:  lastBatchAt,
+		LastError:    lastError,
+	}
+}
+
+func (e *enhancer) loop(ctx context.Context) {
+	for {
+		progressed := e.runBatch(ctx)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if progressed {
+			continue
+		}
+
+		select {
+		case <-time.After(e.opts.IdleInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runBatch processes a single batch and reports whether any row was
+// enriched, so the loop knows whether to continue immediately or idle.
+func (e *enhancer) runBatch(ctx context.Context) bool {
+	st := e.store
+	batchSize := int(e.batchSize.Load())
+
+	visitors, err := st.VisitorList(ctx, enhanceBatchQuery(batchSize))
+	if err != nil {
+		if ctx.Err() == nil {
+			e.recordError(err)
+		}
+		return false
+	}
+
+	if len(visitors) == 0 {
+		e.backlog.Store(0)
+		return false
+	}
+
+	ips := uniqueIPs(visitors)
+	resolved, lookupFailures := e.resolveAll(ctx, ips)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	processed, updateFailures := st.enhanceUserAgents(ctx, visitors, resolved)
+
+	e.processed.Add(int64(processed))
+	e.lookupErrors.Add(int64(lookupFailures))
+	e.updateErrors.Add(int64(updateFailures))
+	e.batches.Add(1)
+	e.adaptBatchSize(len(visitors), len(ips), lookupFailures)
+
+	e.mu.Lock()
+	e.lastBatchAt = time.Now()
+	e.mu.Unlock()
+
+	if backlog, err := st.VisitorEnhanceBacklog(ctx); err == nil {
+		e.backlog.Store(backlog)
+	}
+
+	return processed > 0
+}
+
+// resolveAll fans the unique IPs out to the worker pool. Every lookup first
+// takes a token from the rate limiter. It returns the resolved countries and
+// the number of failed lookups.
+func (e *enhancer) resolveAll(ctx context.Context, ips []string) (map[string]string, int) {
+	jobs := make(chan string)
+	resolved := make(map[string]string, len(ips))
+	failures := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	workers := min(e.opts.Workers, len(ips))
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range jobs {
+				if err := e.limiter.Wait(ctx); err != nil {
+					return
+				}
+
+				country, err := e.store.enhanceCountryByIP(ctx, ip)
+
+				// An empty country means the resolver had no answer, e.g. a
+				// non-200 response; the rows are retried later.
+				mu.Lock()
+				if err != nil || country == "" {
+					failures++
+				} else {
+					resolved[ip] = country
+				}
+				mu.Unlock()
+
+				if err != nil && ctx.Err() == nil {
+					e.recordError(err)
+				}
+			}
+		}()
+	}
+
+	for _, ip := range ips {
+		select {
+		case jobs <- ip:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return resolved, failures
+}
+
+// adaptBatchSize doubles the batch size after a full, error-free batch and
+// halves it when more than half of the lookups failed.
+func (e *enhancer) adaptBatchSize(rows, lookups, failures int) {
+	size := int(e.batchSize.Load())
+
+	switch {
+	case lookups > 0 && failures*2 > lookups:
+		size /= 2
+	case failures == 0 && rows >= size:
+		size *= 2
+	}
+
+	size = max(e.opts.MinBatchSize, min(size, e.opts.MaxBatchSize))
+	e.batchSize.Store(int64(size))
+}
+
+func (e *enhancer) recordError(err error) {
+	e.mu.Lock()
+	e.lastError = err.Error()
+	e.mu.Unlock()
+}
+
+// == HELPERS ==================================================================
+
+// uniqueIPs returns the distinct IP addresses of the given visitors in the
+// order they first appear.
+func uniqueIPs(visitors []VisitorInterface) []string {
+	seen := make(map[string]bool, len(visitors))
+	ips := make([]string, 0, len(visitors))
+	for _, v := range visitors {
+		ip := v.GetIpAddress()
+		if !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// tokenBucket is a minimal token-bucket rate limiter. Tokens refill
+// continuously at rate per second up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncGeoIPResolver is a concurrency-safe resolver for the worker pool tests.
+type syncGeoIPResolver struct {
+	mu      sync.Mutex
+	results map[string]string
+	errs    map[string]error
+	calls   int
+}
+
+func (m *syncGeoIPResolver) Resolve(ctx context.Context, ip string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if err, ok := m.errs[ip]; ok {
+		return "", err
+	}
+	if country, ok := m.results[ip]; ok {
+		return country, nil
+	}
+	return CountryUnknown, nil
+}
+
+func initStoreWithEnhancer(t *testing.T, resolver GeoIPResolver, opts EnhancerOptions) StoreInterface {
+	t.Helper()
+
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	// The in-memory SQLite database is per connection; the worker pool must
+	// share a single one.
+	db.SetMaxOpenConns(1)
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		GeoIPResolver:      resolver,
+		EnhanceBatchSize:   2,
+		EnhancerOptions:    opts,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	return store
+}
+
+func waitForEnhancer(t *testing.T, e EnhancerInterface, done func(EnhancerStats) bool) EnhancerStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := e.Stats()
+		if done(stats) {
+			return stats
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("enhancer did not reach expected state, stats: %+v", e.Stats())
+	return EnhancerStats{}
+}
+
+func TestStartEnhancerNoResolver(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := store.StartEnhancer(context.Background()); err == nil {
+		t.Fatal("expected error when no resolver configured")
+	}
+}
+
+func TestStartEnhancerClearsBacklog(t *testing.T) {
+	resolver := &syncGeoIPResolver{results: map[string]string{
+		"8.8.8.8": "US",
+		"1.1.1.1": "AU",
+		"9.9.9.9": "CH",
+	}}
+	store := initStoreWithEnhancer(t, resolver, EnhancerOptions{
+		RateLimit:    1000,
+		Burst:        10,
+		Workers:      3,
+		IdleInterval: 10 * time.Millisecond,
+	})
+
+	ctx := context.Background()
+	for _, ip := range []string{"8.8.8.8", "8.8.8.8", "1.1.1.1", "9.9.9.9", "9.9.9.9"} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress(ip)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	backlog, err := store.VisitorEnhanceBacklog(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if backlog != 5 {
+		t.Fatalf("expected backlog of 5, got %d", backlog)
+	}
+
+	e, err := store.StartEnhancer(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := store.StartEnhancer(ctx); err == nil {
+		t.Error("expected error when starting a second enhancer")
+	}
+
+	stats := waitForEnhancer(t, e, func(s EnhancerStats) bool {
+		return s.Batches > 0 && s.Backlog == 0
+	})
+	e.Stop()
+
+	if stats.Processed < 3 {
+		t.Errorf("expected at least 3 rows processed, got %d", stats.Processed)
+	}
+	if e.Stats().Running {
+		t.Error("expected enhancer to be stopped")
+	}
+
+	list, err := store.VisitorList(ctx, VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for _, v := range list {
+		if v.GetCountry() == "" {
+			t.Errorf("visitor %s with IP %s still has an empty country", v.GetID(), v.GetIpAddress())
+		}
+	}
+}
+
+func TestStartEnhancerCountsLookupErrors(t *testing.T) {
+	resolver := &syncGeoIPResolver{errs: map[string]error{
+		"8.8.8.8": errors.New("network down"),
+	}}
+	store := initStoreWithEnhancer(t, resolver, EnhancerOptions{
+		RateLimit:    1000,
+		Burst:        10,
+		IdleInterval: 10 * time.Millisecond,
+	})
+
+	ctx := context.Background()
+	if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress("8.8.8.8")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	e, err := store.StartEnhancer(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	stats := waitForEnhancer(t, e, func(s EnhancerStats) bool {
+		return s.LookupErrors > 0
+	})
+	e.Stop()
+
+	if stats.Processed != 0 {
+		t.Errorf("expected 0 processed, got %d", stats.Processed)
+	}
+	if stats.Backlog != 1 {
+		t.Errorf("expected backlog of 1, got %d", stats.Backlog)
+	}
+	if stats.LastError == "" {
+		t.Error("expected last error to be recorded")
+	}
+}
+
+func TestVisitorEnhanceSkipsUnresolvableRows(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	// The resolver has no answer for 203.0.113.1, as DefaultGeoIPResolver
+	// on a non-200 response.
+	resolver := &syncGeoIPResolver{results: map[string]string{"203.0.113.1": "", "8.8.8.8": "US"}}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		GeoIPResolver:      resolver,
+		EnhanceBatchSize:   1,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	for _, ip := range []string{"203.0.113.1", "8.8.8.8"} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress(ip)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	for ip, updatedAt := range map[string]string{"203.0.113.1": "2020-01-01 00:00:00", "8.8.8.8": "2021-01-01 00:00:00"} {
+		if _, err := db.Exec("UPDATE visitor_table SET updated_at = ? WHERE ip_address = ?", updatedAt, ip); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	processed, err := store.VisitorEnhance(ctx)
+	if err != nil || processed != 0 {
+		t.Fatalf("expected the unresolvable row not to count as processed, got %d (%v)", processed, err)
+	}
+
+	processed, err = store.VisitorEnhance(ctx)
+	if err != nil || processed != 1 {
+		t.Fatalf("expected the next batch to move on to the other row, got %d (%v)", processed, err)
+	}
+	backlog, err := store.VisitorEnhanceBacklog(ctx)
+	if err != nil || backlog != 1 {
+		t.Errorf("expected the unresolvable row to stay in the backlog, got %d (%v)", backlog, err)
+	}
+}
+
+func TestEnhancerAdaptBatchSize(t *testing.T) {
+	e := newEnhancer(&storeImplementation{}, EnhancerOptions{MinBatchSize: 10, MaxBatchSize: 40})
+
+	e.adaptBatchSize(10, 5, 0)
+	if got := e.Stats().BatchSize; got != 20 {
+		t.Errorf("after full clean batch expected 20, got %d", got)
+	}
+
+	e.adaptBatchSize(20, 5, 0)
+	e.adaptBatchSize(40, 5, 0)
+	if got := e.Stats().BatchSize; got != 40 {
+		t.Errorf("expected batch size capped at 40, got %d", got)
+	}
+
+	e.adaptBatchSize(40, 4, 3)
+	if got := e.Stats().BatchSize; got != 20 {
+		t.Errorf("after failing batch expected 20, got %d", got)
+	}
+
+	e.adaptBatchSize(3, 3, 0)
+	if got := e.Stats().BatchSize; got != 20 {
+		t.Errorf("partial batch should keep size 20, got %d", got)
+	}
+}
+
+func TestTokenBucketRate(t *testing.T) {
+	bucket := newTokenBucket(50, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 6 {
+		if err := bucket.Wait(ctx); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	// First token is immediate, the next 5 take ~20ms each.
+	if elapsed < 80*time.Millisecond {
+		t.Errorf("expected rate limiting to take at least 80ms, took %s", elapsed)
+	}
+}
+
+func TestTokenBucketContextCancel(t *testing.T) {
+	bucket := newTokenBucket(0.001, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := bucket.Wait(ctx); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	cancel()
+	if err := bucket.Wait(ctx); err == nil {
+		t.Error("expected context error")
+	}
+}
@@ -0,0 +1,390 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// == INTERFACE ================================================================
+
+// Enricher adds derived data to a visitor record. Enrichers are chained into
+// a pipeline that runs at ingestion (VisitorRegister, when
+// EnrichAtIngestion is set) and/or in batch (VisitorEnrichBatch).
+//
+// Each enricher has a name and a version. The versions that have been
+// applied are recorded on the row (the enrichment column), so bumping an
+// enricher's version makes VisitorEnrichBatch re-run it over existing rows.
+type Enricher interface {
+	// Name returns a short, stable identifier, e.g. "geo". It must not
+	// contain ":" or ",".
+	Name() string
+	// Version returns the enricher's current version. Increase it whenever
+	// the enricher's output changes and existing rows should be re-enriched.
+	Version() int
+	// Enrich mutates the visitor in place. Returning an error leaves the
+	// enricher unrecorded on the row so that it is retried in the next batch.
+	Enrich(ctx context.Context, visitor VisitorInterface) error
+}
+
+// == BUILT-IN ENRICHERS =======================================================
+
+// UserAgentEnricher fills in empty browser, OS and device fields by parsing
+// the visitor's user agent and stored client hints. Parser is optional and
+// defaults to the cached ParseUserAgent.
+type UserAgentEnricher struct {
+	Parser UserAgentParser
+}
+
+var _ Enricher = UserAgentEnricher{}
+
+// Name implements Enricher.
+func (UserAgentEnricher) Name() string { return "ua" }
+
+// Version implements Enricher.
+func (UserAgentEnricher) Version() int { return 3 }
+
+// Enrich implements Enricher.
+func (e UserAgentEnricher) Enrich(ctx context.Context, visitor VisitorInterface) error {
+	parser := e.Parser
+	if parser == nil {
+		parser = defaultUserAgentParser
+	}
+	fillUserAgentFields(visitor, parser)
+	return nil
+}
+
+// fillUserAgentFields sets the empty UA-derived fields of a visitor from its
+// user agent and client hints. Fields that already have a value are kept.
+func fillUserAgentFields(visitor VisitorInterface, parser UserAgentParser) {
+	uaInfo := clampUserAgentInfo(ApplyClientHints(parser.Parse(visitor.GetUserAgent()), ParseClientHints(visitor.GetClientHints())))
+	if visitor.GetUserBrowser() == "" {
+		visitor.SetUserBrowser(uaInfo.Browser)
+	}
+	if visitor.GetUserBrowserVersion() == "" {
+		visitor.SetUserBrowserVersion(uaInfo.BrowserVersion)
+	}
+	if visitor.GetUserOs() == "" {
+		visitor.SetUserOs(uaInfo.Os)
+	}
+	if visitor.GetUserOsVersion() == "" {
+		visitor.SetUserOsVersion(uaInfo.OsVersion)
+	}
+	if visitor.GetUserDevice() == "" {
+		visitor.SetUserDevice(uaInfo.Device)
+	}
+	if visitor.GetUserDeviceType() == "" {
+		visitor.SetUserDeviceType(uaInfo.DeviceType)
+	}
+	if visitor.GetUserDeviceBrand() == "" {
+		visitor.SetUserDeviceBrand(uaInfo.DeviceBrand)
+	}
+	if visitor.GetUserDeviceModel() == "" {
+		visitor.SetUserDeviceModel(uaInfo.DeviceModel)
+	}
+	if visitor.GetUserBrowserEngine() == "" {
+		visitor.SetUserBrowserEngine(uaInfo.Engine)
+	}
+	// Rows created before webview detection default to "no", so the flag
+	// and app are recomputed rather than only filled when empty.
+	if visitor.GetUserApp() == "" {
+		visitor.SetUserWebview(yesNo(uaInfo.Webview))
+		visitor.SetUserApp(uaInfo.App)
+	}
+}
+
+// GeoIPEnricher sets the country from the visitor's IP address using a
+// GeoIPResolver. Rows that already have a country are left untouched.
+type GeoIPEnricher struct {
+	Resolver GeoIPResolver
+}
+
+var _ Enricher = GeoIPEnricher{}
+
+// Name implements Enricher.
+func (GeoIPEnricher) Name() string { return "geo" }
+
+// Version implements Enricher.
+func (GeoIPEnricher) Version() int { return 1 }
+
+// Enrich implements Enricher.
+func (e GeoIPEnricher) Enrich(ctx context.Context, visitor VisitorInterface) error {
+	if e.Resolver == nil {
+		return errors.New("geo enricher: GeoIPResolver is nil")
+	}
+	if visitor.GetCountry() != "" {
+		return nil
+	}
+	country, err := e.Resolver.Resolve(ctx, visitor.GetIpAddress())
+	if err != nil {
+		return err
+	}
+	if country == "" {
+		// Not recorded as applied, so the row is retried later.
+		return errors.New("geo enricher: no country resolved")
+	}
+	visitor.SetCountry(country)
+	return nil
+}
+
+// BotEnricher sets the bot and threat flags from the visitor's user agent,
+// referrer, IP and path using the same heuristics as VisitorRegister.
+type BotEnricher struct{}
+
+var _ Enricher = BotEnricher{}
+
+// Name implements Enricher.
+func (BotEnricher) Name() string { return "bot" }
+
+// Version implements Enricher.
+func (BotEnricher) Version() int { return 1 }
+
+// Enrich implements Enricher.
+func (BotEnricher) Enrich(ctx context.Context, visitor VisitorInterface) error {
+	isBot := IsBot(visitor.GetUserAgent()) ||
+		IsReferrerSpam(visitor.GetUserReferrer()) ||
+		IsDataCenterIP(visitor.GetIpAddress()) ||
+		IsBotPath(visitor.GetPath())
+	if isBot {
+		visitor.SetBot(VALUE_YES)
+	} else {
+		visitor.SetBot(VALUE_NO)
+	}
+
+	if IsMaliciousPath(visitor.GetPath()) {
+		visitor.SetThreat(VALUE_YES)
+	} else {
+		visitor.SetThreat(VALUE_NO)
+	}
+	return nil
+}
+
+// ChannelEnricher classifies the visitor's referrer into a marketing channel
+// (see ClassifyChannel) and stores it in the channel column.
+type ChannelEnricher struct{}
+
+var _ Enricher = ChannelEnricher{}
+
+// Name implements Enricher.
+func (ChannelEnricher) Name() string { return "channel" }
+
+// Version implements Enricher.
+func (ChannelEnricher) Version() int { return 1 }
+
+// Enrich implements Enricher.
+func (ChannelEnricher) Enrich(ctx context.Context, visitor VisitorInterface) error {
+	visitor.SetChannel(ClassifyChannel(ReferrerDomain(visitor.GetUserReferrer())))
+	return nil
+}
+
+// DefaultEnrichers returns the built-in pipeline: user agent, bot detection,
+// channel classification and, when resolver is not nil, geo-IP.
+func DefaultEnrichers(resolver GeoIPResolver) []Enricher {
+	enrichers := []Enricher{
+		UserAgentEnricher{},
+		BotEnricher{},
+		ChannelEnricher{},
+	}
+	if resolver != nil {
+		enrichers = append(enrichers, GeoIPEnricher{Resolver: resolver})
+	}
+	return enrichers
+}
+
+// enricherIsLocal reports whether an enricher is a built-in one that works
+// in process, without sending the visitor's IP address or user agent
+// anywhere.
+func enricherIsLocal(enricher Enricher) bool {
+	switch enricher.(type) {
+	case UserAgentEnricher, BotEnricher, ChannelEnricher:
+		return true
+	}
+	return false
+}
+
+// == CUSTOM ENRICHERS =========================================================
+
+// NewEnricherFunc wraps a function as an Enricher, e.g. for tagging internal
+// users or looking up a CRM account.
+func NewEnricherFunc(name string, version int, fn func(ctx context.Context, visitor VisitorInterface) error) Enricher {
+	return &enricherFunc{name: name, version: version, fn: fn}
+}
+
+type enricherFunc struct {
+	name    string
+	version int
+	fn      func(ctx context.Context, visitor VisitorInterface) error
+}
+
+func (e *enricherFunc) Name() string { return e.name }
+func (e *enricherFunc) Version() int { return e.version }
+func (e *enricherFunc) Enrich(ctx context.Context, visitor VisitorInterface) error {
+	return e.fn(ctx, visitor)
+}
+
+// == STORE METHODS ============================================================
+
+// SetEnrichers replaces the enrichment pipeline.
+func (st *storeImplementation) SetEnrichers(enrichers []Enricher) {
+	st.enrichersMu.Lock()
+	defer st.enrichersMu.Unlock()
+	st.enrichers = enrichers
+}
+
+// GetEnrichers returns the configured enrichment pipeline.
+func (st *storeImplementation) GetEnrichers() []Enricher {
+	st.enrichersMu.RLock()
+	defer st.enrichersMu.RUnlock()
+	return st.enrichers
+}
+
+// VisitorEnrich runs every enricher whose recorded version on the visitor
+// differs from its current version, and records the versions of the
+// enrichers that succeeded (or were already up to date). The visitor is not
+// persisted. Errors from individual enrichers are joined and returned after
+// the whole pipeline has run.
+func (st *storeImplementation) VisitorEnrich(ctx context.Context, visitor VisitorInterface) error {
+	return st.visitorEnrich(ctx, visitor, nil)
+}
+
+// visitorEnrich runs the pipeline like VisitorEnrich, limited to the
+// enrichers accepted by only when it is not nil. Skipped enrichers are left
+// unrecorded, so VisitorEnrichBatch runs them later.
+func (st *storeImplementation) visitorEnrich(ctx context.Context, visitor VisitorInterface, only func(Enricher) bool) error {
+	if visitor == nil {
+		return errors.New("visitor is nil")
+	}
+
+	enrichers := st.GetEnrichers()
+	recorded := parseEnrichmentVersions(visitor.GetEnrichment())
+
+	// Only versions of enrichers in the current pipeline are kept, so rows
+	// touched by a removed enricher converge to the current signature.
+	applied := make(map[string]int, len(enrichers))
+
+	var errs []error
+	for _, enricher := range enrichers {
+		name := enricher.Name()
+		if version, ok := recorded[name]; ok && version == enricher.Version() {
+			applied[name] = version
+			continue
+		}
+		if only != nil && !only(enricher) {
+			continue
+		}
+
+		if err := enricher.Enrich(ctx, visitor); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("VisitorEnrich: enricher failed",
+					"enricher", name, "id", visitor.GetID(), "error", err)
+			}
+			errs = append(errs, fmt.Errorf("enricher %s: %w", name, err))
+			continue
+		}
+
+		applied[name] = enricher.Version()
+	}
+
+	visitor.SetEnrichment(formatEnrichmentVersions(applied))
+
+	return errors.Join(errs...)
+}
+
+// VisitorEnrichBatch runs the enrichment pipeline over up to
+// EnhanceBatchSize visitor rows whose recorded enricher versions do not
+// match the current pipeline, oldest-updated first, and persists them.
+// Returns the number of rows that are now fully enriched.
+func (st *storeImplementation) VisitorEnrichBatch(ctx context.Context) (int, error) {
+	if len(st.GetEnrichers()) == 0 {
+		return 0, nil
+	}
+
+	batchSize := st.enhanceBatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+
+	signature := st.enrichmentSignature()
+
+	visitors, err := st.VisitorList(ctx, VisitorQuery().
+		SetEnrichmentNot(signature).
+		SetOrderBy(COLUMN_UPDATED_AT).
+		SetSortOrder("asc").
+		SetLimit(batchSize))
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, visitor := range visitors {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+
+		enrichErr := st.VisitorEnrich(ctx, visitor)
+
+		if err := st.VisitorUpdate(ctx, visitor); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("VisitorEnrichBatch: update failed",
+					"id", visitor.GetID(), "error", err)
+			}
+			continue
+		}
+
+		if enrichErr == nil {
+			processed++
+		}
+	}
+
+	return processed, nil
+}
+
+// enrichmentSignature returns the enrichment column value of a row that has
+// been processed by every enricher in the current pipeline.
+func (st *storeImplementation) enrichmentSignature() string {
+	enrichers := st.GetEnrichers()
+	versions := make(map[string]int, len(enrichers))
+	for _, enricher := range enrichers {
+		versions[enricher.Name()] = enricher.Version()
+	}
+	return formatEnrichmentVersions(versions)
+}
+
+// == HELPERS ==================================================================
+
+// parseEnrichmentVersions parses "geo:1,ua:2" into a name to version map.
+// Malformed entries are ignored.
+func parseEnrichmentVersions(value string) map[string]int {
+	versions := map[string]int{}
+	for _, part := range strings.Split(value, ",") {
+		name, version, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			continue
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			continue
+		}
+		versions[name] = v
+	}
+	return versions
+}
+
+// formatEnrichmentVersions formats a name to version map as a sorted,
+// comma-separated list so equal maps always produce equal strings.
+func formatEnrichmentVersions(versions map[string]int) string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+":"+strconv.Itoa(versions[name]))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,211 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnrichmentVersionsRoundTrip(t *testing.T) {
+	versions := parseEnrichmentVersions("ua:1, geo:2,broken,bad:x")
+	if len(versions) != 2 || versions["ua"] != 1 || versions["geo"] != 2 {
+		t.Fatalf("unexpected parsed versions: %v", versions)
+	}
+
+	if got := formatEnrichmentVersions(map[string]int{"ua": 1, "geo": 2, "bot": 1}); got != "bot:1,geo:2,ua:1" {
+		t.Errorf("formatEnrichmentVersions = %q, want sorted list", got)
+	}
+}
+
+func TestVisitorEnrich_BuiltIns(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	resolver := &mockGeoIPResolver{results: map[string]string{"8.8.8.8": "US"}}
+	store.SetEnrichers(DefaultEnrichers(resolver))
+
+	visitor := NewVisitor().
+		SetIpAddress("8.8.8.8").
+		SetUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36").
+		SetUserReferrer("https://www.google.com/search?q=stats")
+
+	if err := store.VisitorEnrich(context.Background(), visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if visitor.GetUserBrowser() != "Chrome" {
+		t.Errorf("browser = %q, want Chrome", visitor.GetUserBrowser())
+	}
+	if visitor.GetCountry() != "US" {
+		t.Errorf("country = %q, want US", visitor.GetCountry())
+	}
+	if visitor.GetChannel() != ChannelOrganicSearch {
+		t.Errorf("channel = %q, want %q", visitor.GetChannel(), ChannelOrganicSearch)
+	}
+	if visitor.GetBot() != VALUE_NO {
+		t.Errorf("bot = %q, want %q", visitor.GetBot(), VALUE_NO)
+	}
+	if visitor.GetEnrichment() != "bot:1,channel:1,geo:1,ua:3" {
+		t.Errorf("enrichment = %q", visitor.GetEnrichment())
+	}
+}
+
+func TestGeoIPEnricher_EmptyCountryIsRetried(t *testing.T) {
+	resolver := &mockGeoIPResolver{results: map[string]string{"8.8.8.8": ""}}
+	visitor := NewVisitor().SetIpAddress("8.8.8.8")
+
+	if err := (GeoIPEnricher{Resolver: resolver}).Enrich(context.Background(), visitor); err == nil {
+		t.Fatal("expected an error for an empty country")
+	}
+	if visitor.GetCountry() != "" {
+		t.Errorf("country = %q, want empty", visitor.GetCountry())
+	}
+}
+
+func TestVisitorEnrich_SkipsUpToDateAndRecordsFailures(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	calls := 0
+	counting := NewEnricherFunc("count", 1, func(ctx context.Context, v VisitorInterface) error {
+		calls++
+		return nil
+	})
+	failing := NewEnricherFunc("crm", 1, func(ctx context.Context, v VisitorInterface) error {
+		return errors.New("crm unavailable")
+	})
+	store.SetEnrichers([]Enricher{counting, failing})
+
+	visitor := NewVisitor()
+	if err := store.VisitorEnrich(context.Background(), visitor); err == nil {
+		t.Fatal("expected error from failing enricher")
+	}
+	if visitor.GetEnrichment() != "count:1" {
+		t.Errorf("enrichment = %q, want count:1", visitor.GetEnrichment())
+	}
+
+	_ = store.VisitorEnrich(context.Background(), visitor)
+	if calls != 1 {
+		t.Errorf("up-to-date enricher ran %d times, want 1", calls)
+	}
+}
+
+func TestVisitorEnrichBatch_ReEnrichesOnVersionBump(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	for _, ua := range []string{"internal-monitor", "Mozilla/5.0"} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetUserAgent(ua)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	tagger := func(version int, tag string) Enricher {
+		return NewEnricherFunc("internal", version, func(ctx context.Context, v VisitorInterface) error {
+			if v.GetUserAgent() == "internal-monitor" {
+				v.AddTag(tag)
+			}
+			return nil
+		})
+	}
+
+	store.SetEnrichers([]Enricher{tagger(1, "internal")})
+	processed, err := store.VisitorEnrichBatch(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if processed != 2 {
+		t.Fatalf("expected 2 rows enriched, got %d", processed)
+	}
+
+	processed, err = store.VisitorEnrichBatch(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if processed != 0 {
+		t.Fatalf("expected no rows to re-enrich, got %d", processed)
+	}
+
+	store.SetEnrichers([]Enricher{tagger(2, "staff")})
+	processed, err = store.VisitorEnrichBatch(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if processed != 2 {
+		t.Fatalf("expected 2 rows re-enriched after version bump, got %d", processed)
+	}
+
+	list, err := store.VisitorList(ctx, VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	tagged := 0
+	for _, v := range list {
+		if v.HasTag("internal") && v.HasTag("staff") {
+			tagged++
+		}
+		if v.GetEnrichment() != "internal:2" {
+			t.Errorf("enrichment = %q, want internal:2", v.GetEnrichment())
+		}
+	}
+	if tagged != 1 {
+		t.Errorf("expected 1 visitor tagged by both versions, got %d", tagged)
+	}
+}
+
+func TestVisitorRegister_EnrichAtIngestion(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		Enrichers:          []Enricher{ChannelEnricher{}},
+		EnrichAtIngestion:  true,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.Header.Set("Referer", "https://www.facebook.com/")
+	if err := store.VisitorRegister(context.Background(), r); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+	if list[0].GetChannel() != ChannelSocial {
+		t.Errorf("channel = %q, want %q", list[0].GetChannel(), ChannelSocial)
+	}
+	if list[0].GetEnrichment() != "channel:1" {
+		t.Errorf("enrichment = %q, want channel:1", list[0].GetEnrichment())
+	}
+}
+
+func TestVisitorAddTag(t *testing.T) {
+	v := NewVisitor().AddTag("a").AddTag(" b ").AddTag("a").AddTag("")
+	if v.GetTags() != "a,b" {
+		t.Errorf("tags = %q, want a,b", v.GetTags())
+	}
+	if !v.HasTag("b") || v.HasTag("c") {
+		t.Error("HasTag returned unexpected result")
+	}
+}
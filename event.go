@@ -0,0 +1,322 @@
+package statsstore
+
+import (
+	"encoding/json"
+
+	"github.com/dracory/neat/database/orm"
+	neatuid "github.com/dracory/neat/support/uid"
+	"github.com/dromara/carbon/v2"
+)
+
+// == TYPE =====================================================================
+
+type eventImplementation struct {
+	orm.ShortID
+
+	NameField            string `db:"name"`
+	VisitorIDField       string `db:"visitor_id"`
+	FingerprintField     string `db:"fingerprint"`
+	DomainField          string `db:"domain"`
+	PathField            string `db:"path"`
+	ReferrerField        string `db:"user_referrer"`
+	PropsField           string `db:"props"`
+	RevenueAmountField   string `db:"revenue_amount"`
+	RevenueCurrencyField string `db:"revenue_currency"`
+	UserIDField          string `db:"user_id"`
+	ClientIDField        string `db:"client_id"`
+	IdempotencyKeyField  string `db:"idempotency_key"`
+	CampaignField        string `db:"campaign"`
+	OrderIDField         string `db:"order_id"`
+	ItemsField           string `db:"items"`
+	orm.CreatedAt
+	orm.UpdatedAt
+}
+
+var _ EventInterface = (*eventImplementation)(nil)
+
+// == CONSTRUCTORS =============================================================
+
+// NewEvent creates a new custom event.
+func NewEvent() EventInterface {
+	o := &eventImplementation{}
+	o.SetID(neatuid.GenerateShortID())
+	o.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	o.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	return o
+}
+
+// == SETTERS AND GETTERS ======================================================
+
+// GetID returns the ID of the event.
+func (o *eventImplementation) GetID() string {
+	return o.ShortID.ID
+}
+
+// SetID sets the ID of the event.
+func (o *eventImplementation) SetID(id string) EventInterface {
+	o.ShortID.ID = id
+	return o
+}
+
+// GetName returns the name of the event.
+func (o *eventImplementation) GetName() string {
+	return o.NameField
+}
+
+// SetName sets the name of the event.
+func (o *eventImplementation) SetName(name string) EventInterface {
+	o.NameField = name
+	return o
+}
+
+// GetVisitorID returns the page-view row the event belongs to.
+func (o *eventImplementation) GetVisitorID() string {
+	return o.VisitorIDField
+}
+
+// SetVisitorID sets the page-view row the event belongs to.
+func (o *eventImplementation) SetVisitorID(visitorID string) EventInterface {
+	o.VisitorIDField = visitorID
+	return o
+}
+
+// GetFingerprint returns the visitor fingerprint of the event.
+func (o *eventImplementation) GetFingerprint() string {
+	return o.FingerprintField
+}
+
+// SetFingerprint sets the visitor fingerprint of the event.
+func (o *eventImplementation) SetFingerprint(fingerprint string) EventInterface {
+	o.FingerprintField = fingerprint
+	return o
+}
+
+// GetDomain returns the site domain of the event.
+func (o *eventImplementation) GetDomain() string {
+	return o.DomainField
+}
+
+// SetDomain sets the site domain of the event.
+func (o *eventImplementation) SetDomain(domain string) EventInterface {
+	o.DomainField = domain
+	return o
+}
+
+// GetPath returns the page path of the event.
+func (o *eventImplementation) GetPath() string {
+	return o.PathField
+}
+
+// SetPath sets the page path of the event.
+func (o *eventImplementation) SetPath(path string) EventInterface {
+	o.PathField = path
+	return o
+}
+
+// GetReferrer returns the referrer of the event.
+func (o *eventImplementation) GetReferrer() string {
+	return o.ReferrerField
+}
+
+// SetReferrer sets the referrer of the event.
+func (o *eventImplementation) SetReferrer(referrer string) EventInterface {
+	o.ReferrerField = referrer
+	return o
+}
+
+// GetProps returns the custom properties as JSON.
+func (o *eventImplementation) GetProps() string {
+	return o.PropsField
+}
+
+// SetProps sets the custom properties as JSON.
+func (o *eventImplementation) SetProps(props string) EventInterface {
+	o.PropsField = props
+	return o
+}
+
+// GetPropsMap returns the custom properties, or an empty map when there are
+// none or they are not valid JSON.
+func (o *eventImplementation) GetPropsMap() map[string]string {
+	props := map[string]string{}
+	if o.PropsField == "" {
+		return props
+	}
+	if err := json.Unmarshal([]byte(o.PropsField), &props); err != nil {
+		return map[string]string{}
+	}
+	return props
+}
+
+// SetPropsMap sets the custom properties. An empty map clears them.
+func (o *eventImplementation) SetPropsMap(props map[string]string) EventInterface {
+	if len(props) == 0 {
+		o.PropsField = ""
+		return o
+	}
+	data, err := json.Marshal(props)
+	if err != nil {
+		return o
+	}
+	o.PropsField = string(data)
+	return o
+}
+
+// GetRevenueAmount returns the revenue amount of the event.
+func (o *eventImplementation) GetRevenueAmount() string {
+	return o.RevenueAmountField
+}
+
+// SetRevenueAmount sets the revenue amount of the event.
+func (o *eventImplementation) SetRevenueAmount(amount string) EventInterface {
+	o.RevenueAmountField = amount
+	return o
+}
+
+// GetRevenueCurrency returns the revenue currency of the event.
+func (o *eventImplementation) GetRevenueCurrency() string {
+	return o.RevenueCurrencyField
+}
+
+// SetRevenueCurrency sets the revenue currency of the event.
+func (o *eventImplementation) SetRevenueCurrency(currency string) EventInterface {
+	o.RevenueCurrencyField = currency
+	return o
+}
+
+// GetUserID returns the application user ID of the event.
+func (o *eventImplementation) GetUserID() string {
+	return o.UserIDField
+}
+
+// SetUserID sets the application user ID of the event.
+func (o *eventImplementation) SetUserID(userID string) EventInterface {
+	o.UserIDField = userID
+	return o
+}
+
+// GetClientID returns the client ID of the event.
+func (o *eventImplementation) GetClientID() string {
+	return o.ClientIDField
+}
+
+// SetClientID sets the client ID of the event.
+func (o *eventImplementation) SetClientID(clientID string) EventInterface {
+	o.ClientIDField = clientID
+	return o
+}
+
+// GetIdempotencyKey returns the idempotency key of the event.
+func (o *eventImplementation) GetIdempotencyKey() string {
+	return o.IdempotencyKeyField
+}
+
+// SetIdempotencyKey sets the idempotency key of the event.
+func (o *eventImplementation) SetIdempotencyKey(key string) EventInterface {
+	o.IdempotencyKeyField = key
+	return o
+}
+
+// GetCampaign returns the campaign or email ID of the event.
+func (o *eventImplementation) GetCampaign() string {
+	return o.CampaignField
+}
+
+// SetCampaign sets the campaign or email ID of the event.
+func (o *eventImplementation) SetCampaign(campaign string) EventInterface {
+	o.CampaignField = campaign
+	return o
+}
+
+// GetOrderID returns the order ID of the event.
+func (o *eventImplementation) GetOrderID() string {
+	return o.OrderIDField
+}
+
+// SetOrderID sets the order ID of the event.
+func (o *eventImplementation) SetOrderID(orderID string) EventInterface {
+	o.OrderIDField = orderID
+	return o
+}
+
+// GetItems returns the order line items as a JSON array.
+func (o *eventImplementation) GetItems() string {
+	return o.ItemsField
+}
+
+// SetItems sets the order line items as a JSON array.
+func (o *eventImplementation) SetItems(items string) EventInterface {
+	o.ItemsField = items
+	return o
+}
+
+// GetItemsList returns the order line items, or an empty list when there
+// are none or they are not valid JSON.
+func (o *eventImplementation) GetItemsList() []OrderItem {
+	items := []OrderItem{}
+	if o.ItemsField == "" {
+		return items
+	}
+	if err := json.Unmarshal([]byte(o.ItemsField), &items); err != nil {
+		return []OrderItem{}
+	}
+	return items
+}
+
+// SetItemsList sets the order line items. An empty list clears them.
+func (o *eventImplementation) SetItemsList(items []OrderItem) EventInterface {
+	if len(items) == 0 {
+		o.ItemsField = ""
+		return o
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return o
+	}
+	o.ItemsField = string(data)
+	return o
+}
+
+// GetCreatedAt returns the created at time of the event.
+func (o *eventImplementation) GetCreatedAt() string {
+	if o.CreatedAt.CreatedAt.IsZero() {
+		return ""
+	}
+	return carbon.CreateFromStdTime(o.CreatedAt.CreatedAt).ToDateTimeString()
+}
+
+// GetCreatedAtCarbon returns the created at time of the event as a carbon object.
+func (o *eventImplementation) GetCreatedAtCarbon() *carbon.Carbon {
+	return carbon.CreateFromStdTime(o.CreatedAt.CreatedAt)
+}
+
+// SetCreatedAt sets the created at time of the event.
+func (o *eventImplementation) SetCreatedAt(createdAt string) EventInterface {
+	if createdAt == "" {
+		return o
+	}
+	o.CreatedAt.CreatedAt = carbon.Parse(createdAt, carbon.UTC).StdTime()
+	return o
+}
+
+// GetUpdatedAt returns the updated at time of the event.
+func (o *eventImplementation) GetUpdatedAt() string {
+	if o.UpdatedAt.UpdatedAt.IsZero() {
+		return ""
+	}
+	return carbon.CreateFromStdTime(o.UpdatedAt.UpdatedAt).ToDateTimeString()
+}
+
+// GetUpdatedAtCarbon returns the updated at time of the event as a carbon object.
+func (o *eventImplementation) GetUpdatedAtCarbon() *carbon.Carbon {
+	return carbon.CreateFromStdTime(o.UpdatedAt.UpdatedAt)
+}
+
+// SetUpdatedAt sets the updated at time of the event.
+func (o *eventImplementation) SetUpdatedAt(updatedAt string) EventInterface {
+	if updatedAt == "" {
+		return o
+	}
+	o.UpdatedAt.UpdatedAt = carbon.Parse(updatedAt, carbon.UTC).StdTime()
+	return o
+}
@@ -0,0 +1,255 @@
+package statsstore
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// == EVENT API HANDLER ========================================================
+
+const (
+	// EventAPIMaxBodyBytes caps the size of an event API request body.
+	EventAPIMaxBodyBytes = 1 << 20
+
+	// EventAPIMaxBatch caps the number of events in one batch request.
+	EventAPIMaxBatch = 100
+)
+
+// Per-event statuses returned by the event API.
+const (
+	EventAPIStatusCreated   = "created"
+	EventAPIStatusDuplicate = "duplicate"
+	EventAPIStatusInvalid   = "invalid"
+)
+
+// EventAPIHandlerOptions configures NewEventAPIHandler.
+type EventAPIHandlerOptions struct {
+	// Store receives the events. Required.
+	Store StoreInterface
+
+	// Tokens are the accepted bearer tokens. Required: the handler rejects
+	// every request when no token is configured.
+	Tokens []string
+}
+
+// eventAPIEvent is a single event in an event API request.
+type eventAPIEvent struct {
+	Name           string          `json:"name"`
+	VisitorHash    string          `json:"visitor_hash"`
+	UserID         string          `json:"user_id"`
+	ClientID       string          `json:"client_id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Timestamp      string          `json:"timestamp"`
+	Domain         string          `json:"domain"`
+	Path           string          `json:"path"`
+	Props          json.RawMessage `json:"props"`
+	Revenue        json.RawMessage `json:"revenue"`
+	OrderID        string          `json:"order_id"`
+	Items          []OrderItem     `json:"items"`
+}
+
+// EventAPIResult is the outcome for one event of an event API request.
+type EventAPIResult struct {
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewEventAPIHandler returns an http.Handler for server-to-server events,
+// e.g. conversions recorded by payment webhooks or background jobs. Requests
+// must carry "Authorization: Bearer <token>" with one of opts.Tokens.
+//
+// The body is a single event or {"events": [...]} with up to
+// EventAPIMaxBatch events:
+//
+//	{
+//	  "name": "Purchase",
+//	  "visitor_hash": "...", "user_id": "42", "client_id": "...",
+//	  "idempotency_key": "order-1001",
+//	  "timestamp": "2024-05-01T10:00:00Z",
+//	  "path": "/checkout", "domain": "example.com",
+//	  "props": {"plan": "pro"},
+//	  "revenue": {"currency": "USD", "amount": "19.99"},
+//	  "order_id": "1001",
+//	  "items": [{"sku": "PRO-1", "name": "Pro plan", "price": 19.99, "quantity": 1}]
+//	}
+//
+// Events are recorded via EventTrack. A single event is answered with its
+// EventAPIResult and status 201 (created), 200 (duplicate idempotency key
+// or order ID) or 400 (invalid). A batch is answered 200 with {"results": [...]} in
+// request order.
+func NewEventAPIHandler(opts EventAPIHandlerOptions) http.Handler {
+	return &eventAPIHandler{opts: opts}
+}
+
+type eventAPIHandler struct {
+	opts EventAPIHandlerOptions
+}
+
+// ServeHTTP implements http.Handler.
+func (h *eventAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Store == nil {
+		http.Error(w, "event api: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="statsstore"`)
+		http.Error(w, "event api: unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, EventAPIMaxBodyBytes))
+	if err != nil {
+		http.Error(w, "event api: payload too large", http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := struct {
+		Events []eventAPIEvent `json:"events"`
+	}{}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		http.Error(w, "event api: invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if batch.Events == nil {
+		single := eventAPIEvent{}
+		if err := json.Unmarshal(body, &single); err != nil {
+			http.Error(w, "event api: invalid payload", http.StatusBadRequest)
+			return
+		}
+		h.serveSingle(w, r, single)
+		return
+	}
+
+	if len(batch.Events) > EventAPIMaxBatch {
+		http.Error(w, "event api: too many events", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]EventAPIResult, 0, len(batch.Events))
+	for _, input := range batch.Events {
+		result, err := h.track(r, input)
+		if err != nil {
+			http.Error(w, "event api: failed to record event", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, result)
+	}
+
+	writeEventAPIJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func (h *eventAPIHandler) serveSingle(w http.ResponseWriter, r *http.Request, input eventAPIEvent) {
+	result, err := h.track(r, input)
+	if err != nil {
+		http.Error(w, "event api: failed to record event", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	switch result.Status {
+	case EventAPIStatusCreated:
+		status = http.StatusCreated
+	case EventAPIStatusInvalid:
+		status = http.StatusBadRequest
+	}
+	writeEventAPIJSON(w, status, result)
+}
+
+// authorized reports whether r carries one of the configured bearer tokens.
+func (h *eventAPIHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if !ok || token == "" {
+		return false
+	}
+
+	authorized := false
+	for _, candidate := range h.opts.Tokens {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			authorized = true
+		}
+	}
+	return authorized
+}
+
+// track converts and records one event. Validation problems are reported
+// in the result; only storage failures return an error.
+func (h *eventAPIHandler) track(r *http.Request, input eventAPIEvent) (EventAPIResult, error) {
+	event, err := input.toEvent()
+	if err != nil {
+		return EventAPIResult{Status: EventAPIStatusInvalid, Error: err.Error()}, nil
+	}
+
+	stored, created, err := h.opts.Store.EventTrack(r.Context(), event)
+	if errors.Is(err, ErrEventInvalid) {
+		return EventAPIResult{Status: EventAPIStatusInvalid, Error: err.Error()}, nil
+	}
+	if err != nil {
+		return EventAPIResult{}, err
+	}
+
+	if !created {
+		return EventAPIResult{ID: stored.GetID(), Status: EventAPIStatusDuplicate}, nil
+	}
+	return EventAPIResult{ID: stored.GetID(), Status: EventAPIStatusCreated}, nil
+}
+
+// toEvent converts the request fields to an event.
+func (input eventAPIEvent) toEvent() (EventInterface, error) {
+	props, err := parsePlausibleProps(input.Props)
+	if err != nil {
+		return nil, errors.New("props must be an object")
+	}
+
+	event := NewEvent().
+		SetName(strings.TrimSpace(input.Name)).
+		SetFingerprint(strings.TrimSpace(input.VisitorHash)).
+		SetUserID(strings.TrimSpace(input.UserID)).
+		SetClientID(strings.TrimSpace(input.ClientID)).
+		SetIdempotencyKey(strings.TrimSpace(input.IdempotencyKey)).
+		SetOrderID(strings.TrimSpace(input.OrderID)).
+		SetItemsList(input.Items).
+		SetDomain(strings.TrimSpace(input.Domain)).
+		SetPath(strings.TrimSpace(input.Path)).
+		SetPropsMap(props)
+
+	if input.Timestamp != "" {
+		timestamp, err := time.Parse(time.RFC3339, input.Timestamp)
+		if err != nil {
+			return nil, errors.New("timestamp must be RFC 3339")
+		}
+		event.SetCreatedAt(timestamp.UTC().Format(time.DateTime))
+	}
+
+	if len(input.Revenue) > 0 && string(input.Revenue) != "null" {
+		amount, currency, err := parsePlausibleRevenue(input.Revenue)
+		if err != nil {
+			return nil, errors.New("revenue must have a numeric amount and a 3-letter currency")
+		}
+		event.SetRevenueAmount(amount).SetRevenueCurrency(currency)
+	}
+
+	return event, nil
+}
+
+func writeEventAPIJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
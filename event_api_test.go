@@ -0,0 +1,138 @@
+package statsstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postEventAPI(handler http.Handler, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestEventAPIHandlerAuth(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	body := `{"name":"Signup","user_id":"42"}`
+
+	handler := NewEventAPIHandler(EventAPIHandlerOptions{Store: store, Tokens: []string{"secret"}})
+	for _, token := range []string{"", "wrong"} {
+		if w := postEventAPI(handler, token, body); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, w.Code)
+		}
+	}
+
+	unconfigured := NewEventAPIHandler(EventAPIHandlerOptions{Store: store})
+	if w := postEventAPI(unconfigured, "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without configured tokens, got %d", w.Code)
+	}
+}
+
+func TestEventAPIHandlerSingle(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewEventAPIHandler(EventAPIHandlerOptions{Store: store, Tokens: []string{"secret"}})
+
+	timestamp := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	body := `{"name":"Purchase","user_id":"42","idempotency_key":"order-1001","timestamp":"` + timestamp + `",` +
+		`"props":{"plan":"pro"},"revenue":{"currency":"usd","amount":"19.99"}}`
+
+	w := postEventAPI(handler, "secret", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	result := EventAPIResult{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	event, err := store.EventFindByID(t.Context(), result.ID)
+	if err != nil || event == nil {
+		t.Fatalf("expected stored event, got %v, %v", event, err)
+	}
+	if event.GetRevenueAmount() != "19.99" || event.GetRevenueCurrency() != "USD" || event.GetPropsMap()["plan"] != "pro" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if got := event.GetCreatedAtCarbon().StdTime().UTC().Format(time.RFC3339); got != timestamp {
+		t.Errorf("created at = %s, want backfilled %s", got, timestamp)
+	}
+
+	// A retry with the same idempotency key is a duplicate.
+	w = postEventAPI(handler, "secret", body)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"duplicate"`) {
+		t.Errorf("expected duplicate, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = postEventAPI(handler, "secret", `{"name":"Purchase","timestamp":"yesterday","user_id":"42"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid timestamp, got %d", w.Code)
+	}
+}
+
+func TestEventAPIHandlerBatch(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewEventAPIHandler(EventAPIHandlerOptions{Store: store, Tokens: []string{"old", "secret"}})
+
+	body := `{"events":[
+		{"name":"Signup","visitor_hash":"abc"},
+		{"name":"Signup"},
+		{"name":"Confirm","client_id":"c-1","idempotency_key":"k1"},
+		{"name":"Confirm","client_id":"c-1","idempotency_key":"k1"},
+		{"name":"Purchase","user_id":"42","order_id":"1001","revenue":{"currency":"USD","amount":"19.99"},
+		 "items":[{"sku":"PRO-1","price":19.99,"quantity":1}]},
+		{"name":"Purchase","user_id":"42","order_id":"1001","revenue":{"currency":"USD","amount":"19.99"}},
+		{"name":"Purchase","user_id":"42","order_id":"1002"}
+	]}`
+	w := postEventAPI(handler, "secret", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	response := struct {
+		Results []EventAPIResult `json:"results"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	want := []string{
+		EventAPIStatusCreated, EventAPIStatusInvalid, EventAPIStatusCreated, EventAPIStatusDuplicate,
+		EventAPIStatusCreated, EventAPIStatusDuplicate, EventAPIStatusInvalid,
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), response.Results)
+	}
+	for i, status := range want {
+		if response.Results[i].Status != status {
+			t.Errorf("result %d = %s, want %s", i, response.Results[i].Status, status)
+		}
+	}
+	if response.Results[2].ID != response.Results[3].ID {
+		t.Error("expected duplicate to return the stored event id")
+	}
+	if response.Results[4].ID != response.Results[5].ID {
+		t.Error("expected duplicate order to return the stored event id")
+	}
+
+	order, err := store.EventFindByID(t.Context(), response.Results[4].ID)
+	if err != nil || order == nil || order.GetOrderID() != "1001" || len(order.GetItemsList()) != 1 {
+		t.Errorf("unexpected order: %+v (%v)", order, err)
+	}
+}
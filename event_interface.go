@@ -0,0 +1,87 @@
+package statsstore
+
+import "github.com/dromara/carbon/v2"
+
+// EventInterface defines the interface for a custom event record (e.g.
+// "Signup", "Download"). Page views are visitors, not events.
+type EventInterface interface {
+	// Setters and Getters
+
+	GetID() string
+	SetID(id string) EventInterface
+
+	GetName() string
+	SetName(name string) EventInterface
+
+	// GetVisitorID returns the ID of the page-view row the event happened
+	// on, when known.
+	GetVisitorID() string
+	SetVisitorID(visitorID string) EventInterface
+
+	// GetFingerprint returns the visitor fingerprint (see
+	// VisitorInterface.FingerprintCalculate), linking the event to a session.
+	GetFingerprint() string
+	SetFingerprint(fingerprint string) EventInterface
+
+	GetDomain() string
+	SetDomain(domain string) EventInterface
+
+	GetPath() string
+	SetPath(path string) EventInterface
+
+	GetReferrer() string
+	SetReferrer(referrer string) EventInterface
+
+	// GetProps returns the custom properties as a JSON object of strings.
+	GetProps() string
+	SetProps(props string) EventInterface
+	GetPropsMap() map[string]string
+	SetPropsMap(props map[string]string) EventInterface
+
+	// GetRevenueAmount returns the revenue as a decimal string, e.g. "19.99".
+	GetRevenueAmount() string
+	SetRevenueAmount(amount string) EventInterface
+
+	// GetRevenueCurrency returns the ISO 4217 currency code of the revenue.
+	GetRevenueCurrency() string
+	SetRevenueCurrency(currency string) EventInterface
+
+	// GetUserID returns the application's own user ID, for events recorded
+	// on behalf of a signed-in user.
+	GetUserID() string
+	SetUserID(userID string) EventInterface
+
+	// GetClientID returns an identifier issued to the browser, such as the
+	// page-view ID returned by the tracker or a Matomo visitor ID.
+	GetClientID() string
+	SetClientID(clientID string) EventInterface
+
+	// GetIdempotencyKey returns the caller-supplied key that makes retries
+	// of the same event safe (see EventTrack).
+	GetIdempotencyKey() string
+	SetIdempotencyKey(key string) EventInterface
+
+	// GetCampaign returns the campaign or email ID the event belongs to,
+	// e.g. from a tracking pixel.
+	GetCampaign() string
+	SetCampaign(campaign string) EventInterface
+
+	// GetOrderID returns the e-commerce order ID. Revenue events with the
+	// same order ID are recorded once (see EventRegister and EventTrack).
+	GetOrderID() string
+	SetOrderID(orderID string) EventInterface
+
+	// GetItems returns the order line items as a JSON array.
+	GetItems() string
+	SetItems(items string) EventInterface
+	GetItemsList() []OrderItem
+	SetItemsList(items []OrderItem) EventInterface
+
+	GetCreatedAt() string
+	GetCreatedAtCarbon() *carbon.Carbon
+	SetCreatedAt(createdAt string) EventInterface
+
+	GetUpdatedAt() string
+	GetUpdatedAtCarbon() *carbon.Carbon
+	SetUpdatedAt(updatedAt string) EventInterface
+}
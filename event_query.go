@@ -0,0 +1,218 @@
+package statsstore
+
+import "errors"
+
+// EventQueryInterface defines the interface for event query operations.
+type EventQueryInterface interface {
+	Validate() error
+
+	HasClientID() bool
+	ClientID() string
+	SetClientID(clientID string) EventQueryInterface
+
+	HasCreatedAtGte() bool
+	CreatedAtGte() string
+	SetCreatedAtGte(createdAtGte string) EventQueryInterface
+
+	HasCreatedAtLte() bool
+	CreatedAtLte() string
+	SetCreatedAtLte(createdAtLte string) EventQueryInterface
+
+	HasFingerprint() bool
+	Fingerprint() string
+	SetFingerprint(fingerprint string) EventQueryInterface
+
+	HasID() bool
+	ID() string
+	SetID(id string) EventQueryInterface
+
+	HasIdempotencyKey() bool
+	IdempotencyKey() string
+	SetIdempotencyKey(key string) EventQueryInterface
+
+	HasLimit() bool
+	Limit() int
+	SetLimit(limit int) EventQueryInterface
+
+	HasName() bool
+	Name() string
+	SetName(name string) EventQueryInterface
+
+	HasNameIn() bool
+	NameIn() []string
+	SetNameIn(nameIn []string) EventQueryInterface
+
+	HasOffset() bool
+	Offset() int
+	SetOffset(offset int) EventQueryInterface
+
+	HasOrderID() bool
+	OrderID() string
+	SetOrderID(orderID string) EventQueryInterface
+
+	HasOrderBy() bool
+	OrderBy() string
+	SetOrderBy(orderBy string) EventQueryInterface
+
+	HasSortOrder() bool
+	SortOrder() string
+	SetSortOrder(sortOrder string) EventQueryInterface
+
+	HasUserID() bool
+	UserID() string
+	SetUserID(userID string) EventQueryInterface
+
+	HasVisitorID() bool
+	VisitorID() string
+	SetVisitorID(visitorID string) EventQueryInterface
+}
+
+// EventQuery is a shortcut for NewEventQuery.
+func EventQuery() EventQueryInterface {
+	return NewEventQuery()
+}
+
+// NewEventQuery creates a new event query.
+func NewEventQuery() EventQueryInterface {
+	return &eventQuery{
+		properties: make(map[string]interface{}),
+	}
+}
+
+var _ EventQueryInterface = (*eventQuery)(nil)
+
+type eventQuery struct {
+	properties map[string]interface{}
+}
+
+func (q *eventQuery) Validate() error {
+	if q.HasCreatedAtGte() && q.CreatedAtGte() == "" {
+		return errors.New("event query: created_at_gte cannot be empty")
+	}
+	if q.HasCreatedAtLte() && q.CreatedAtLte() == "" {
+		return errors.New("event query: created_at_lte cannot be empty")
+	}
+	if q.HasID() && q.ID() == "" {
+		return errors.New("event query: id cannot be empty")
+	}
+	if q.HasNameIn() && len(q.NameIn()) < 1 {
+		return errors.New("event query: name_in cannot be empty array")
+	}
+	if q.HasLimit() && q.Limit() < 0 {
+		return errors.New("event query: limit cannot be negative")
+	}
+	if q.HasOffset() && q.Offset() < 0 {
+		return errors.New("event query: offset cannot be negative")
+	}
+	return nil
+}
+
+func (q *eventQuery) hasProperty(key string) bool {
+	_, ok := q.properties[key]
+	return ok
+}
+
+func (q *eventQuery) HasClientID() bool { return q.hasProperty("client_id") }
+func (q *eventQuery) ClientID() string  { return q.properties["client_id"].(string) }
+func (q *eventQuery) SetClientID(v string) EventQueryInterface {
+	q.properties["client_id"] = v
+	return q
+}
+
+func (q *eventQuery) HasCreatedAtGte() bool { return q.hasProperty("created_at_gte") }
+func (q *eventQuery) CreatedAtGte() string  { return q.properties["created_at_gte"].(string) }
+func (q *eventQuery) SetCreatedAtGte(v string) EventQueryInterface {
+	q.properties["created_at_gte"] = v
+	return q
+}
+
+func (q *eventQuery) HasCreatedAtLte() bool { return q.hasProperty("created_at_lte") }
+func (q *eventQuery) CreatedAtLte() string  { return q.properties["created_at_lte"].(string) }
+func (q *eventQuery) SetCreatedAtLte(v string) EventQueryInterface {
+	q.properties["created_at_lte"] = v
+	return q
+}
+
+func (q *eventQuery) HasFingerprint() bool { return q.hasProperty("fingerprint") }
+func (q *eventQuery) Fingerprint() string  { return q.properties["fingerprint"].(string) }
+func (q *eventQuery) SetFingerprint(v string) EventQueryInterface {
+	q.properties["fingerprint"] = v
+	return q
+}
+
+func (q *eventQuery) HasID() bool { return q.hasProperty("id") }
+func (q *eventQuery) ID() string  { return q.properties["id"].(string) }
+func (q *eventQuery) SetID(v string) EventQueryInterface {
+	q.properties["id"] = v
+	return q
+}
+
+func (q *eventQuery) HasIdempotencyKey() bool { return q.hasProperty("idempotency_key") }
+func (q *eventQuery) IdempotencyKey() string  { return q.properties["idempotency_key"].(string) }
+func (q *eventQuery) SetIdempotencyKey(v string) EventQueryInterface {
+	q.properties["idempotency_key"] = v
+	return q
+}
+
+func (q *eventQuery) HasLimit() bool { return q.hasProperty("limit") }
+func (q *eventQuery) Limit() int     { return q.properties["limit"].(int) }
+func (q *eventQuery) SetLimit(v int) EventQueryInterface {
+	q.properties["limit"] = v
+	return q
+}
+
+func (q *eventQuery) HasName() bool { return q.hasProperty("name") }
+func (q *eventQuery) Name() string  { return q.properties["name"].(string) }
+func (q *eventQuery) SetName(v string) EventQueryInterface {
+	q.properties["name"] = v
+	return q
+}
+
+func (q *eventQuery) HasNameIn() bool  { return q.hasProperty("name_in") }
+func (q *eventQuery) NameIn() []string { return q.properties["name_in"].([]string) }
+func (q *eventQuery) SetNameIn(v []string) EventQueryInterface {
+	q.properties["name_in"] = v
+	return q
+}
+
+func (q *eventQuery) HasOffset() bool { return q.hasProperty("offset") }
+func (q *eventQuery) Offset() int     { return q.properties["offset"].(int) }
+func (q *eventQuery) SetOffset(v int) EventQueryInterface {
+	q.properties["offset"] = v
+	return q
+}
+
+func (q *eventQuery) HasOrderID() bool { return q.hasProperty("order_id") }
+func (q *eventQuery) OrderID() string  { return q.properties["order_id"].(string) }
+func (q *eventQuery) SetOrderID(v string) EventQueryInterface {
+	q.properties["order_id"] = v
+	return q
+}
+
+func (q *eventQuery) HasOrderBy() bool { return q.hasProperty("order_by") }
+func (q *eventQuery) OrderBy() string  { return q.properties["order_by"].(string) }
+func (q *eventQuery) SetOrderBy(v string) EventQueryInterface {
+	q.properties["order_by"] = v
+	return q
+}
+
+func (q *eventQuery) HasSortOrder() bool { return q.hasProperty("sort_order") }
+func (q *eventQuery) SortOrder() string  { return q.properties["sort_order"].(string) }
+func (q *eventQuery) SetSortOrder(v string) EventQueryInterface {
+	q.properties["sort_order"] = v
+	return q
+}
+
+func (q *eventQuery) HasUserID() bool { return q.hasProperty("user_id") }
+func (q *eventQuery) UserID() string  { return q.properties["user_id"].(string) }
+func (q *eventQuery) SetUserID(v string) EventQueryInterface {
+	q.properties["user_id"] = v
+	return q
+}
+
+func (q *eventQuery) HasVisitorID() bool { return q.hasProperty("visitor_id") }
+func (q *eventQuery) VisitorID() string  { return q.properties["visitor_id"].(string) }
+func (q *eventQuery) SetVisitorID(v string) EventQueryInterface {
+	q.properties["visitor_id"] = v
+	return q
+}
@@ -0,0 +1,113 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoreEventCreateAndList(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	signup := NewEvent().
+		SetName("Signup").
+		SetPath("/register").
+		SetFingerprint("fp1").
+		SetPropsMap(map[string]string{"plan": "pro"}).
+		SetRevenueAmount("19.99").
+		SetRevenueCurrency("USD")
+	if err := store.EventCreate(ctx, signup); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.EventCreate(ctx, NewEvent().SetName("Download").SetFingerprint("fp1")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := store.EventFindByID(ctx, signup.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found == nil {
+		t.Fatal("expected event to be found")
+	}
+	if found.GetPropsMap()["plan"] != "pro" || found.GetRevenueAmount() != "19.99" || found.GetRevenueCurrency() != "USD" {
+		t.Errorf("unexpected event: props=%q revenue=%s %s", found.GetProps(), found.GetRevenueAmount(), found.GetRevenueCurrency())
+	}
+
+	count, err := store.EventCount(ctx, EventQuery().SetFingerprint("fp1"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 events for fingerprint, got %d", count)
+	}
+
+	list, err := store.EventList(ctx, EventQuery().SetName("Download"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 || list[0].GetName() != "Download" {
+		t.Errorf("unexpected list: %+v", list)
+	}
+
+	if err := store.EventDeleteByID(ctx, signup.GetID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found, _ := store.EventFindByID(ctx, signup.GetID()); found != nil {
+		t.Error("expected event to be deleted")
+	}
+}
+
+func TestStoreEventCreateValidation(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := store.EventCreate(context.Background(), NewEvent()); err == nil {
+		t.Error("expected error for event without name")
+	}
+	if err := store.EventCreate(context.Background(), NewEvent().SetName(EVENT_NAME_PAGEVIEW)); err == nil {
+		t.Error("expected error for reserved pageview name")
+	}
+	if _, err := store.EventList(context.Background(), EventQuery().SetLimit(-1)); err == nil {
+		t.Error("expected error for invalid query")
+	}
+}
+
+func TestStoreEventRegister(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store.SetExcludedIPs([]string{"10.0.0.9"})
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+
+	recorded, err := store.EventRegister(context.Background(), r, NewEvent().SetName("Signup"))
+	if err != nil || !recorded {
+		t.Fatalf("expected event to be recorded, got %v, %v", recorded, err)
+	}
+
+	list, err := store.EventList(context.Background(), EventQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want := NewVisitor().SetIpAddress("203.0.113.7").SetUserAgent("Mozilla/5.0").FingerprintCalculate()
+	if len(list) != 1 || list[0].GetFingerprint() != want {
+		t.Errorf("expected fingerprint %s, got %+v", want, list)
+	}
+
+	r.RemoteAddr = "10.0.0.9:1234"
+	recorded, err = store.EventRegister(context.Background(), r, NewEvent().SetName("Signup"))
+	if err != nil || recorded {
+		t.Errorf("expected excluded IP to be filtered, got %v, %v", recorded, err)
+	}
+}
@@ -0,0 +1,200 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+// == SERVER-SIDE EVENTS =======================================================
+
+const (
+	// EventBackfillWindowDefault is how far in the past a server-side event
+	// timestamp may be when NewStoreOptions.EventBackfillWindow is not set.
+	EventBackfillWindowDefault = 72 * time.Hour
+
+	// EventClockSkew is how far in the future a server-side event timestamp
+	// may be, to tolerate clock differences between hosts.
+	EventClockSkew = 5 * time.Minute
+)
+
+// ErrEventInvalid is wrapped by EventTrack validation errors, so callers can
+// tell bad input apart from storage failures with errors.Is.
+var ErrEventInvalid = errors.New("invalid event")
+
+// EventTrack records an event on behalf of a visitor without a request from
+// that visitor, e.g. from a payment webhook or an email confirmation job.
+//
+// The event must carry at least one of a fingerprint (visitor hash), a user
+// ID or a client ID. When the client ID (or visitor ID) is the ID of a
+// visitor row, e.g. the page-view ID returned by the tracker, the event is
+// linked to that row and takes over its fingerprint.
+//
+// The created at time may be backfilled up to the store's
+// EventBackfillWindow in the past (and EventClockSkew in the future).
+//
+// When the event has an idempotency key or an order ID that was already
+// recorded, nothing is written and the stored event is returned with created
+// set to false, so retries are safe. Retries handled by different processes
+// are caught by unique indexes on PostgreSQL and SQLite; on MySQL only
+// retries within one process are. Validation errors wrap ErrEventInvalid.
+func (st *storeImplementation) EventTrack(ctx context.Context, event EventInterface) (EventInterface, bool, error) {
+	if event == nil {
+		return nil, false, fmt.Errorf("%w: event is nil", ErrEventInvalid)
+	}
+
+	if err := st.eventTrackValidate(event); err != nil {
+		return nil, false, err
+	}
+
+	if err := st.eventTrackLink(ctx, event); err != nil {
+		return nil, false, err
+	}
+
+	if event.GetIdempotencyKey() == "" && event.GetOrderID() == "" {
+		if err := st.EventCreate(ctx, event); err != nil {
+			return nil, false, err
+		}
+		return event, true, nil
+	}
+
+	existing, err := st.eventCreateOnce(ctx, event)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+	return event, true, nil
+}
+
+// eventCreateOnce stores event unless an event with its idempotency key or
+// order ID is already stored, and returns that event instead.
+func (st *storeImplementation) eventCreateOnce(ctx context.Context, event EventInterface) (EventInterface, error) {
+	// Serialise lookups and inserts so concurrent retries of the same key in
+	// this process do not both insert.
+	st.eventTrackMu.Lock()
+	defer st.eventTrackMu.Unlock()
+
+	existing, err := st.eventFindDuplicate(ctx, event)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	if err := st.EventCreate(ctx, event); err != nil {
+		// Another process may have inserted the same key since the lookup,
+		// in which case the unique index rejected this insert.
+		if existing, findErr := st.eventFindDuplicate(ctx, event); findErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// eventFindDuplicate returns the stored event with the idempotency key or
+// the order ID of event, or nil when there is none.
+func (st *storeImplementation) eventFindDuplicate(ctx context.Context, event EventInterface) (EventInterface, error) {
+	queries := []EventQueryInterface{}
+	if key := event.GetIdempotencyKey(); key != "" {
+		queries = append(queries, EventQuery().SetIdempotencyKey(key).SetLimit(1))
+	}
+	if orderID := event.GetOrderID(); orderID != "" {
+		queries = append(queries, EventQuery().SetOrderID(orderID).SetLimit(1))
+	}
+
+	for _, query := range queries {
+		existing, err := st.EventList(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			return existing[0], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// eventTrackValidate checks the name, identity, idempotency key and
+// timestamp of a server-side event.
+func (st *storeImplementation) eventTrackValidate(event EventInterface) error {
+	name := strings.TrimSpace(event.GetName())
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrEventInvalid)
+	}
+	if name == EVENT_NAME_PAGEVIEW {
+		return fmt.Errorf("%w: name pageview is reserved for visitors", ErrEventInvalid)
+	}
+	if len(name) > eventMaxNameLength {
+		return fmt.Errorf("%w: name is too long", ErrEventInvalid)
+	}
+
+	if event.GetFingerprint() == "" && event.GetUserID() == "" && event.GetClientID() == "" && event.GetVisitorID() == "" {
+		return fmt.Errorf("%w: one of visitor hash, user id or client id is required", ErrEventInvalid)
+	}
+
+	if len(event.GetIdempotencyKey()) > eventMaxIdempotencyKeyLength {
+		return fmt.Errorf("%w: idempotency key is too long", ErrEventInvalid)
+	}
+
+	if err := validateOrder(event); err != nil {
+		return fmt.Errorf("%w: %s", ErrEventInvalid, err.Error())
+	}
+
+	if event.GetCreatedAt() == "" {
+		return nil
+	}
+
+	window := st.eventBackfillWindow
+	if window <= 0 {
+		window = EventBackfillWindowDefault
+	}
+
+	now := carbon.Now(carbon.UTC).StdTime()
+	createdAt := event.GetCreatedAtCarbon().StdTime()
+	if createdAt.Before(now.Add(-window)) {
+		return fmt.Errorf("%w: timestamp is older than the backfill window (%s)", ErrEventInvalid, window)
+	}
+	if createdAt.After(now.Add(EventClockSkew)) {
+		return fmt.Errorf("%w: timestamp is in the future", ErrEventInvalid)
+	}
+
+	return nil
+}
+
+// eventTrackLink links the event to the visitor row named by its visitor ID
+// or client ID, filling in the visitor ID and fingerprint.
+func (st *storeImplementation) eventTrackLink(ctx context.Context, event EventInterface) error {
+	if event.GetFingerprint() != "" && event.GetVisitorID() != "" {
+		return nil
+	}
+
+	id := event.GetVisitorID()
+	if id == "" {
+		id = event.GetClientID()
+	}
+	if id == "" {
+		return nil
+	}
+
+	visitor, err := st.VisitorFindByID(ctx, id)
+	if err != nil || visitor == nil {
+		return err
+	}
+
+	event.SetVisitorID(visitor.GetID())
+	if event.GetFingerprint() == "" {
+		fingerprint := visitor.GetFingerprint()
+		if fingerprint == "" {
+			fingerprint = visitor.FingerprintCalculate()
+		}
+		event.SetFingerprint(fingerprint)
+	}
+
+	return nil
+}
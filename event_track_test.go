@@ -0,0 +1,152 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func TestEventTrackIdempotency(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx := context.Background()
+	first, created, err := store.EventTrack(ctx, NewEvent().SetName("Purchase").SetUserID("42").SetIdempotencyKey("order-1001"))
+	if err != nil || !created {
+		t.Fatalf("expected event to be created, got %v, %v", created, err)
+	}
+
+	second, created, err := store.EventTrack(ctx, NewEvent().SetName("Purchase").SetUserID("42").SetIdempotencyKey("order-1001"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if created || second.GetID() != first.GetID() {
+		t.Errorf("expected duplicate key to return the stored event, got created=%v id=%s", created, second.GetID())
+	}
+
+	count, err := store.EventCount(ctx, EventQuery().SetUserID("42"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 stored event, got %d", count)
+	}
+}
+
+func TestEventUniqueIndexes(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	// Events without a key or an order ID are not constrained.
+	for range 2 {
+		if err := store.EventCreate(ctx, NewEvent().SetName("Signup").SetUserID("42")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	// A second process inserting the same key bypasses the in-process lock;
+	// the index rejects its insert.
+	for _, event := range []func() EventInterface{
+		func() EventInterface {
+			return NewEvent().SetName("Purchase").SetUserID("42").SetIdempotencyKey("retry-1")
+		},
+		func() EventInterface { return NewEvent().SetName("Purchase").SetUserID("42").SetOrderID("order-1") },
+	} {
+		if err := store.EventCreate(ctx, event()); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if err := store.EventCreate(ctx, event()); err == nil {
+			t.Error("expected the duplicate insert to fail")
+		}
+	}
+}
+
+func TestEventTrackBackfillWindow(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store, err := NewStore(NewStoreOptions{
+		DB:                  db,
+		VisitorTableName:    "visitor_table",
+		AutomigrateEnabled:  true,
+		EventBackfillWindow: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	at := func(offset time.Duration) string {
+		return carbon.Now(carbon.UTC).StdTime().Add(offset).Format(time.DateTime)
+	}
+
+	tests := []struct {
+		name    string
+		offset  time.Duration
+		wantErr bool
+	}{
+		{"within window", -23 * time.Hour, false},
+		{"older than window", -25 * time.Hour, true},
+		{"within clock skew", time.Minute, false},
+		{"in the future", time.Hour, true},
+	}
+	for _, tt := range tests {
+		event := NewEvent().SetName("Signup").SetFingerprint("fp").SetCreatedAt(at(tt.offset))
+		_, _, err := store.EventTrack(context.Background(), event)
+		if tt.wantErr && !errors.Is(err, ErrEventInvalid) {
+			t.Errorf("%s: expected ErrEventInvalid, got %v", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+}
+
+func TestEventTrackValidationAndLinking(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	invalid := []EventInterface{
+		NewEvent().SetUserID("42"),
+		NewEvent().SetName(EVENT_NAME_PAGEVIEW).SetUserID("42"),
+		NewEvent().SetName("Signup"),
+	}
+	for _, event := range invalid {
+		if _, _, err := store.EventTrack(ctx, event); !errors.Is(err, ErrEventInvalid) {
+			t.Errorf("expected ErrEventInvalid for %+v, got %v", event, err)
+		}
+	}
+
+	visitor := NewVisitor().SetPath("/pricing").SetIpAddress("203.0.113.7").SetUserAgent("Mozilla/5.0")
+	if err := store.VisitorCreate(ctx, visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	stored, _, err := store.EventTrack(ctx, NewEvent().SetName("Signup").SetClientID(visitor.GetID()))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if stored.GetVisitorID() != visitor.GetID() || stored.GetFingerprint() != visitor.FingerprintCalculate() {
+		t.Errorf("expected event to be linked to the visitor, got visitor=%q fingerprint=%q", stored.GetVisitorID(), stored.GetFingerprint())
+	}
+
+	// An unknown client ID is kept as-is.
+	stored, _, err = store.EventTrack(ctx, NewEvent().SetName("Signup").SetClientID("GA1.2.123.456"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if stored.GetVisitorID() != "" || stored.GetClientID() != "GA1.2.123.456" {
+		t.Errorf("unexpected event: %+v", stored)
+	}
+}
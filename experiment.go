@@ -0,0 +1,340 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// == EXPOSURES ================================================================
+
+const (
+	// ExperimentPropExperiment and ExperimentPropVariant are the props of an
+	// exposure event.
+	ExperimentPropExperiment = "experiment"
+	ExperimentPropVariant    = "variant"
+
+	// ExperimentControlVariant is the variant results are compared against
+	// when an experiment has one with this name.
+	ExperimentControlVariant = "control"
+
+	// ExperimentSignificanceLevel is the p-value below which a difference
+	// to the control is reported as significant.
+	ExperimentSignificanceLevel = 0.05
+
+	// experimentMaxNameLength caps experiment and variant names.
+	experimentMaxNameLength = 64
+
+	// experimentZ95 is the standard normal quantile of a 95% interval.
+	experimentZ95 = 1.959964
+)
+
+// NewExperimentExposure returns the event recording that a visitor saw a
+// variant of an experiment. Record it with EventRegister from the request
+// that rendered the variant, or with EventTrack for a known visitor:
+//
+//	store.EventRegister(ctx, r, statsstore.NewExperimentExposure("checkout-button", "green").SetPath(r.URL.Path))
+func NewExperimentExposure(experiment, variant string) EventInterface {
+	return NewEvent().
+		SetName(EVENT_NAME_EXPERIMENT_EXPOSURE).
+		SetPropsMap(map[string]string{
+			ExperimentPropExperiment: strings.TrimSpace(experiment),
+			ExperimentPropVariant:    strings.TrimSpace(variant),
+		})
+}
+
+// ValidateExperimentExposure checks the experiment and variant names of an
+// exposure.
+func ValidateExperimentExposure(experiment, variant string) error {
+	experiment = strings.TrimSpace(experiment)
+	variant = strings.TrimSpace(variant)
+	if experiment == "" || variant == "" {
+		return errors.New("experiment and variant are required")
+	}
+	if len(experiment) > experimentMaxNameLength || len(variant) > experimentMaxNameLength {
+		return errors.New("experiment and variant names are too long")
+	}
+	return nil
+}
+
+// experimentExposure returns the experiment and variant of an exposure
+// event, or ok false for other events.
+func experimentExposure(e EventInterface) (experiment, variant string, ok bool) {
+	if e.GetName() != EVENT_NAME_EXPERIMENT_EXPOSURE {
+		return "", "", false
+	}
+	props := e.GetPropsMap()
+	experiment = props[ExperimentPropExperiment]
+	variant = props[ExperimentPropVariant]
+	return experiment, variant, experiment != "" && variant != ""
+}
+
+// ExperimentNames returns the experiments with exposures in events, sorted.
+func ExperimentNames(events []EventInterface) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, e := range events {
+		if experiment, _, ok := experimentExposure(e); ok && !seen[experiment] {
+			seen[experiment] = true
+			names = append(names, experiment)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// == RESULTS ==================================================================
+
+// ExperimentVariantResult is the outcome of one variant of an experiment.
+type ExperimentVariantResult struct {
+	Variant string `json:"variant"`
+	Control bool   `json:"control"`
+
+	// Visitors is the number of visitors first exposed to this variant;
+	// Conversions counts those that completed the goal after exposure.
+	Visitors    int64 `json:"visitors"`
+	Conversions int64 `json:"conversions"`
+
+	// ConversionRate is a percentage with its 95% Wilson confidence
+	// interval, ConfidenceLow to ConfidenceHigh.
+	ConversionRate float64 `json:"conversion_rate"`
+	ConfidenceLow  float64 `json:"confidence_low"`
+	ConfidenceHigh float64 `json:"confidence_high"`
+
+	// Uplift is the relative change of the conversion rate over the
+	// control, in percent. PValue is the two-sided p-value of a two
+	// proportion z-test against the control. Both are zero for the control.
+	Uplift      float64 `json:"uplift"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// ExperimentResult is the outcome of an experiment against a goal.
+type ExperimentResult struct {
+	Experiment string `json:"experiment"`
+	Goal       Goal   `json:"goal"`
+
+	// Control is the variant the others are compared against.
+	Control  string                    `json:"control"`
+	Variants []ExperimentVariantResult `json:"variants"`
+
+	// MixedVisitors counts visitors exposed to more than one variant. They
+	// are kept in the variant they saw first.
+	MixedVisitors int64 `json:"mixed_visitors"`
+}
+
+// ComputeExperiment computes the conversion of goal per variant of
+// experiment. Visitors are keyed like ComputeAttribution (user ID when a
+// page view or event links the fingerprint to one), assigned to the variant
+// of their first exposure, and convert when they complete the goal at or
+// after it.
+//
+// The control is the variant named ExperimentControlVariant, or else the
+// first variant by name.
+func ComputeExperiment(experiment string, goal Goal, visitors []VisitorInterface, events []EventInterface) ExperimentResult {
+	identities := attributionIdentities(visitors, events)
+	identity := func(fingerprint string) string {
+		if userID, ok := identities[fingerprint]; ok {
+			return "user:" + userID
+		}
+		return fingerprint
+	}
+
+	exposures := []EventInterface{}
+	for _, e := range events {
+		if name, _, ok := experimentExposure(e); ok && name == experiment {
+			exposures = append(exposures, e)
+		}
+	}
+	sort.SliceStable(exposures, func(a, b int) bool {
+		return exposures[a].GetCreatedAtCarbon().StdTime().Before(exposures[b].GetCreatedAtCarbon().StdTime())
+	})
+
+	type assignment struct {
+		variant string
+		at      time.Time
+		mixed   bool
+	}
+	assignments := map[string]*assignment{}
+	for _, e := range exposures {
+		_, variant, _ := experimentExposure(e)
+		key := identity(e.GetFingerprint())
+		if e.GetUserID() != "" {
+			key = "user:" + e.GetUserID()
+		}
+		if key == "" {
+			continue
+		}
+		if existing, ok := assignments[key]; ok {
+			if existing.variant != variant {
+				existing.mixed = true
+			}
+			continue
+		}
+		assignments[key] = &assignment{variant: variant, at: e.GetCreatedAtCarbon().StdTime()}
+	}
+
+	converted := map[string]bool{}
+	for _, hit := range attributionConversions(goal, visitors, events, identity) {
+		if a, ok := assignments[hit.visitor]; ok && !hit.at.Before(a.at) {
+			converted[hit.visitor] = true
+		}
+	}
+
+	result := ExperimentResult{Experiment: experiment, Goal: goal, Variants: []ExperimentVariantResult{}}
+	byVariant := map[string]*ExperimentVariantResult{}
+	for key, a := range assignments {
+		variant, ok := byVariant[a.variant]
+		if !ok {
+			variant = &ExperimentVariantResult{Variant: a.variant}
+			byVariant[a.variant] = variant
+		}
+		variant.Visitors++
+		if converted[key] {
+			variant.Conversions++
+		}
+		if a.mixed {
+			result.MixedVisitors++
+		}
+	}
+
+	for _, variant := range byVariant {
+		result.Variants = append(result.Variants, *variant)
+	}
+	sort.Slice(result.Variants, func(i, j int) bool { return result.Variants[i].Variant < result.Variants[j].Variant })
+	if len(result.Variants) == 0 {
+		return result
+	}
+
+	control := 0
+	for i, variant := range result.Variants {
+		if strings.EqualFold(variant.Variant, ExperimentControlVariant) {
+			control = i
+			break
+		}
+	}
+	result.Control = result.Variants[control].Variant
+	result.Variants[control].Control = true
+
+	base := result.Variants[control]
+	for i := range result.Variants {
+		variant := &result.Variants[i]
+		variant.ConversionRate = percentage(variant.Conversions, variant.Visitors)
+		low, high := wilsonInterval(variant.Conversions, variant.Visitors)
+		variant.ConfidenceLow = math.Round(low*1000) / 10
+		variant.ConfidenceHigh = math.Round(high*1000) / 10
+
+		if i == control {
+			continue
+		}
+		if base.Conversions > 0 {
+			baseRate := float64(base.Conversions) / float64(base.Visitors)
+			rate := float64(variant.Conversions) / float64(variant.Visitors)
+			variant.Uplift = math.Round((rate-baseRate)/baseRate*1000) / 10
+		}
+		variant.PValue = math.Round(twoProportionPValue(base.Conversions, base.Visitors, variant.Conversions, variant.Visitors)*10000) / 10000
+		variant.Significant = variant.PValue < ExperimentSignificanceLevel
+	}
+
+	return result
+}
+
+// wilsonInterval returns the 95% Wilson score interval of a proportion.
+func wilsonInterval(successes, trials int64) (float64, float64) {
+	if trials <= 0 {
+		return 0, 0
+	}
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := experimentZ95 * experimentZ95
+
+	denominator := 1 + z2/n
+	center := (p + z2/(2*n)) / denominator
+	margin := experimentZ95 * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denominator
+
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// twoProportionPValue returns the two-sided p-value of a pooled two
+// proportion z-test, or 1 when it cannot be computed.
+func twoProportionPValue(successesA, trialsA, successesB, trialsB int64) float64 {
+	if trialsA <= 0 || trialsB <= 0 {
+		return 1
+	}
+	nA, nB := float64(trialsA), float64(trialsB)
+	pooled := float64(successesA+successesB) / (nA + nB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/nA + 1/nB))
+	if se == 0 {
+		return 1
+	}
+	z := (float64(successesB)/nB - float64(successesA)/nA) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// == STORE ====================================================================
+
+// ExperimentList returns the experiments with exposures between from and to.
+func (st *storeImplementation) ExperimentList(ctx context.Context, from, to time.Time) ([]string, error) {
+	query := EventQuery().SetName(EVENT_NAME_EXPERIMENT_EXPOSURE)
+	if !from.IsZero() {
+		query = query.SetCreatedAtGte(from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query = query.SetCreatedAtLte(to.UTC().Format(time.RFC3339))
+	}
+
+	events, err := st.EventList(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExperimentNames(events), nil
+}
+
+// ExperimentResults computes the results of an experiment against the goal
+// with goalID, over the exposures and conversions between from and to.
+func (st *storeImplementation) ExperimentResults(ctx context.Context, experiment, goalID string, from, to time.Time) (ExperimentResult, error) {
+	if strings.TrimSpace(experiment) == "" {
+		return ExperimentResult{}, errors.New("experiment is required")
+	}
+
+	goals, err := st.GoalList(ctx)
+	if err != nil {
+		return ExperimentResult{}, err
+	}
+	goal, ok := findGoal(goals, goalID)
+	if !ok {
+		return ExperimentResult{}, errors.New("goal not found")
+	}
+
+	visitorQuery := VisitorQuery()
+	eventQuery := EventQuery()
+	if !from.IsZero() {
+		since := from.UTC().Format(time.RFC3339)
+		visitorQuery = visitorQuery.SetCreatedAtGte(since)
+		eventQuery = eventQuery.SetCreatedAtGte(since)
+	}
+	if !to.IsZero() {
+		until := to.UTC().Format(time.RFC3339)
+		visitorQuery = visitorQuery.SetCreatedAtLte(until)
+		eventQuery = eventQuery.SetCreatedAtLte(until)
+	}
+
+	visitors := []VisitorInterface{}
+	if goal.Type == GoalTypePage {
+		visitors, err = st.VisitorList(ctx, visitorQuery)
+		if err != nil {
+			return ExperimentResult{}, err
+		}
+	}
+
+	events, err := st.EventList(ctx, eventQuery)
+	if err != nil {
+		return ExperimentResult{}, err
+	}
+
+	return ComputeExperiment(experiment, goal, visitors, events), nil
+}
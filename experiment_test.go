@@ -0,0 +1,133 @@
+package statsstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func experimentTestEvent(event EventInterface, fingerprint string, at time.Time) EventInterface {
+	return event.SetFingerprint(fingerprint).SetCreatedAt(carbon.CreateFromStdTime(at).ToDateTimeString(carbon.UTC))
+}
+
+func TestComputeExperiment(t *testing.T) {
+	exposed := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	goal := Goal{ID: "purchase", Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"}
+
+	events := []EventInterface{}
+	for variant, conversions := range map[string]int{"control": 100, "green": 130} {
+		for i := 0; i < 1000; i++ {
+			fingerprint := fmt.Sprintf("%s-%d", variant, i)
+			events = append(events, experimentTestEvent(NewExperimentExposure("checkout", variant), fingerprint, exposed))
+			if i < conversions {
+				events = append(events, experimentTestEvent(NewEvent().SetName("Purchase"), fingerprint, exposed.Add(time.Minute)))
+			}
+		}
+	}
+
+	// Converted before exposure, and exposed to both variants: kept in the
+	// first variant without a conversion.
+	events = append(events,
+		experimentTestEvent(NewEvent().SetName("Purchase"), "mixed", exposed.Add(-time.Hour)),
+		experimentTestEvent(NewExperimentExposure("checkout", "green"), "mixed", exposed),
+		experimentTestEvent(NewExperimentExposure("checkout", "control"), "mixed", exposed.Add(time.Minute)),
+		experimentTestEvent(NewExperimentExposure("header", "b"), "other", exposed),
+	)
+
+	result := ComputeExperiment("checkout", goal, nil, events)
+
+	if result.Control != "control" || len(result.Variants) != 2 || result.MixedVisitors != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	control, green := result.Variants[0], result.Variants[1]
+	if !control.Control || control.Visitors != 1000 || control.Conversions != 100 || control.ConversionRate != 10 {
+		t.Errorf("unexpected control: %+v", control)
+	}
+	if control.ConfidenceLow != 8.3 || control.ConfidenceHigh != 12 {
+		t.Errorf("control interval = %v-%v, want 8.3-12", control.ConfidenceLow, control.ConfidenceHigh)
+	}
+	if green.Visitors != 1001 || green.Conversions != 130 {
+		t.Errorf("unexpected green: %+v", green)
+	}
+	if green.Uplift != 29.9 {
+		t.Errorf("uplift = %v, want 29.9", green.Uplift)
+	}
+	if green.PValue < 0.03 || green.PValue > 0.04 || !green.Significant {
+		t.Errorf("p-value = %v (significant %v), want about 0.036", green.PValue, green.Significant)
+	}
+
+	if names := ExperimentNames(events); len(names) != 2 || names[0] != "checkout" || names[1] != "header" {
+		t.Errorf("unexpected experiment names: %v", names)
+	}
+}
+
+func TestComputeExperimentNotSignificant(t *testing.T) {
+	exposed := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	goal := Goal{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done"}
+
+	visitors := []VisitorInterface{}
+	events := []EventInterface{}
+	for i, variant := range []string{"a", "b", "a", "b"} {
+		fingerprint := fmt.Sprintf("v%d", i)
+		events = append(events, experimentTestEvent(NewExperimentExposure("hero", variant), fingerprint, exposed))
+		if i < 2 {
+			visitors = append(visitors, attributionTestVisitor(fingerprint, "/signup/done", "", exposed.Add(time.Minute)))
+		}
+	}
+
+	result := ComputeExperiment("hero", goal, visitors, events)
+
+	if result.Control != "a" || len(result.Variants) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if b := result.Variants[1]; b.Conversions != 1 || b.Uplift != 0 || b.PValue != 1 || b.Significant {
+		t.Errorf("unexpected variant b: %+v", b)
+	}
+}
+
+func TestStoreExperimentResults(t *testing.T) {
+	store, err := initStoreWithSettings()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	goal, err := store.GoalSave(ctx, Goal{Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	if recorded, err := store.EventRegister(ctx, r, NewExperimentExposure("pricing", "control").SetPath(r.URL.Path)); err != nil || !recorded {
+		t.Fatalf("expected exposure to be recorded, got %v, %v", recorded, err)
+	}
+	if recorded, err := store.EventRegister(ctx, r, NewEvent().SetName("Purchase")); err != nil || !recorded {
+		t.Fatalf("expected conversion to be recorded, got %v, %v", recorded, err)
+	}
+
+	now := time.Now().UTC()
+	names, err := store.ExperimentList(ctx, now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil || len(names) != 1 || names[0] != "pricing" {
+		t.Fatalf("unexpected experiments: %v (%v)", names, err)
+	}
+
+	result, err := store.ExperimentResults(ctx, "pricing", goal.ID, now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(result.Variants) != 1 || result.Variants[0].Conversions != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if _, err := store.ExperimentResults(ctx, "pricing", "missing", time.Time{}, time.Time{}); err == nil {
+		t.Error("expected an error for an unknown goal")
+	}
+}
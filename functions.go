@@ -17,11 +17,17 @@ type UserAgentInfo struct {
 	OsVersion      string
 	Device         string
 	DeviceType     string
+	DeviceBrand    string
+	DeviceModel    string
+	Engine         string // rendering engine: Blink, WebKit, Gecko, ...
+	Webview        bool   // in-app browser or embedded webview
+	App            string // host app of an in-app browser, e.g. "Instagram"
 }
 
-// ParseUserAgent extracts browser, OS, device, and device-type from a
-// user-agent string using the uasurfer library. Unknown values are left
-// as empty strings.
+// ParseUserAgent extracts browser, OS, device, device-type, device
+// brand/model, rendering engine and in-app browser details from a user-agent
+// string using the uasurfer library. Unknown values are left as empty
+// strings.
 func ParseUserAgent(ua string) UserAgentInfo {
 	info := UserAgentInfo{}
 	if ua == "" {
@@ -59,13 +65,237 @@ func ParseUserAgent(ua string) UserAgentInfo {
 	case uasurfer.PlatformiPod:
 		info.Device = "iPod"
 	}
+	if info.Device != "" {
+		info.DeviceBrand = "Apple"
+		info.DeviceModel = info.Device
+	} else if model := androidModel(ua); model != "" {
+		info.DeviceBrand, info.DeviceModel = DetectDeviceBrand(model)
+		info.Device = deviceName(info.DeviceBrand, info.DeviceModel)
+	}
 
 	// Device type
 	info.DeviceType = deviceTypeToString(parsed.DeviceType)
 
+	// Engine and in-app browser
+	isIOS := parsed.OS.Name == uasurfer.OSiOS || parsed.OS.Name == uasurfer.OSiPadOS
+	info.Engine = browserEngine(ua, isIOS)
+	info.App, info.Webview = inAppBrowser(ua, isIOS)
+
+	return clampUserAgentInfo(info)
+}
+
+// clampUserAgentInfo shortens the fields to the sizes of their visitor
+// columns (see MigrateUp). The device model and app come from the UA string
+// and client hints, which the client controls; an overlong value would make
+// the insert fail on MySQL and PostgreSQL and lose the visit.
+func clampUserAgentInfo(info UserAgentInfo) UserAgentInfo {
+	info.Browser = truncateRunes(info.Browser, 40)
+	info.BrowserVersion = truncateRunes(info.BrowserVersion, 24)
+	info.Os = truncateRunes(info.Os, 40)
+	info.OsVersion = truncateRunes(info.OsVersion, 12)
+	info.Device = truncateRunes(info.Device, 40)
+	info.DeviceType = truncateRunes(info.DeviceType, 12)
+	info.DeviceBrand = truncateRunes(info.DeviceBrand, 40)
+	info.DeviceModel = truncateRunes(info.DeviceModel, 64)
+	info.Engine = truncateRunes(info.Engine, 12)
+	info.App = truncateRunes(info.App, 40)
 	return info
 }
 
+// == ENGINE / IN-APP BROWSER =================================================
+
+// Rendering engines returned in UserAgentInfo.Engine.
+const (
+	EngineBlink    = "Blink"
+	EngineWebKit   = "WebKit"
+	EngineGecko    = "Gecko"
+	EngineEdgeHTML = "EdgeHTML"
+	EngineTrident  = "Trident"
+	EnginePresto   = "Presto"
+)
+
+// browserEngine detects the rendering engine from UA tokens. Every browser
+// on iOS is required to use WebKit, whatever its UA claims.
+func browserEngine(ua string, isIOS bool) string {
+	switch {
+	case isIOS && strings.Contains(ua, "AppleWebKit"):
+		return EngineWebKit
+	case strings.Contains(ua, "Edge/"):
+		return EngineEdgeHTML
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "Chromium/"):
+		return EngineBlink
+	case strings.Contains(ua, "Gecko/") && strings.Contains(ua, "rv:"):
+		return EngineGecko
+	case strings.Contains(ua, "Trident/"):
+		return EngineTrident
+	case strings.Contains(ua, "Presto/"):
+		return EnginePresto
+	case strings.Contains(ua, "AppleWebKit"):
+		return EngineWebKit
+	}
+	return ""
+}
+
+// inAppBrowserTokens maps UA tokens to the host app of an in-app browser.
+// Checked in order, so Messenger is matched before the generic Facebook
+// tokens it also carries.
+var inAppBrowserTokens = []struct {
+	token string
+	app   string
+}{
+	{"Instagram", "Instagram"},
+	{"FBAN/Messenger", "Messenger"},
+	{"Orca-Android", "Messenger"},
+	{"FBAN/", "Facebook"},
+	{"FBAV/", "Facebook"},
+	{"FB_IAB", "Facebook"},
+	{"musical_ly", "TikTok"},
+	{"BytedanceWebview", "TikTok"},
+	{"TikTok", "TikTok"},
+	{"LinkedInApp", "LinkedIn"},
+	{"Twitter for", "Twitter"},
+	{"TwitterAndroid", "Twitter"},
+	{"Snapchat", "Snapchat"},
+	{"Pinterest/", "Pinterest"},
+	{"MicroMessenger", "WeChat"},
+	{" Line/", "LINE"},
+}
+
+// App names used for webviews whose host app is not known.
+const (
+	InAppAndroidWebView = "Android WebView"
+	InAppIOSWebView     = "iOS WebView"
+)
+
+// inAppBrowser returns the host app and true when the UA belongs to an
+// in-app browser or embedded webview. Generic webviews are recognised by the
+// Android "; wv)" marker and, on iOS, by a missing "Safari/" token.
+func inAppBrowser(ua string, isIOS bool) (string, bool) {
+	for _, t := range inAppBrowserTokens {
+		if strings.Contains(ua, t.token) {
+			return t.app, true
+		}
+	}
+	if strings.Contains(ua, "; wv)") {
+		return InAppAndroidWebView, true
+	}
+	if isIOS && strings.Contains(ua, "AppleWebKit") && strings.Contains(ua, "Mobile/") && !strings.Contains(ua, "Safari/") {
+		return InAppIOSWebView, true
+	}
+	return "", false
+}
+
+// == DEVICE BRAND / MODEL ====================================================
+
+// deviceBrandPrefixes maps model-name prefixes found in Android user agents
+// and Sec-CH-UA-Model to the device manufacturer. Checked in order.
+var deviceBrandPrefixes = []struct {
+	prefix string
+	brand  string
+}{
+	{"SM-", "Samsung"}, {"GT-", "Samsung"}, {"Galaxy", "Samsung"},
+	{"Pixel", "Google"}, {"Nexus", "Google"},
+	{"Redmi", "Xiaomi"}, {"POCO", "Xiaomi"}, {"Mi ", "Xiaomi"}, {"MI ", "Xiaomi"}, {"Xiaomi", "Xiaomi"},
+	{"ONEPLUS", "OnePlus"}, {"OnePlus", "OnePlus"},
+	{"CPH", "OPPO"}, {"OPPO", "OPPO"},
+	{"RMX", "realme"},
+	{"vivo", "vivo"}, {"V2", "vivo"},
+	{"motorola", "Motorola"}, {"moto", "Motorola"}, {"Moto", "Motorola"}, {"XT", "Motorola"},
+	{"Nokia", "Nokia"},
+	{"LM-", "LG"}, {"LG-", "LG"},
+	{"SO-", "Sony"}, {"XQ-", "Sony"}, {"Xperia", "Sony"},
+	{"KF", "Amazon"},
+	{"TECNO", "Tecno"}, {"Infinix", "Infinix"},
+	{"HTC", "HTC"}, {"ASUS", "ASUS"}, {"Lenovo", "Lenovo"},
+}
+
+// deviceVendorTokens are vendor names that some browsers (Samsung Internet,
+// Huawei Browser) prepend to the model. They are stripped from the model.
+var deviceVendorTokens = []struct {
+	token string
+	brand string
+}{
+	{"SAMSUNG ", "Samsung"}, {"HUAWEI ", "Huawei"}, {"HONOR ", "Honor"},
+}
+
+// DetectDeviceBrand returns the manufacturer for a device model such as
+// "SM-S918B" or "Pixel 7", and the model with any leading "SAMSUNG " or
+// "HUAWEI " vendor token removed. The brand is empty when unknown. Models
+// are cut to the 64 characters of the user_device_model column.
+func DetectDeviceBrand(model string) (brand string, cleanModel string) {
+	model = truncateRunes(strings.TrimSpace(model), 64)
+	for _, v := range deviceVendorTokens {
+		if strings.HasPrefix(model, v.token) {
+			return v.brand, strings.TrimSpace(strings.TrimPrefix(model, v.token))
+		}
+	}
+	for _, p := range deviceBrandPrefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.brand, model
+		}
+	}
+	return "", model
+}
+
+// androidModel extracts the device model from the platform section of an
+// Android user agent, e.g. "Pixel 7" from
+// "(Linux; Android 13; Pixel 7)". Chrome's reduced UA ("Android 10; K")
+// and WebView markers yield an empty string.
+func androidModel(ua string) string {
+	idx := strings.Index(ua, "Android")
+	if idx < 0 {
+		return ""
+	}
+	start := strings.LastIndex(ua[:idx], "(")
+	end := strings.Index(ua[idx:], ")")
+	if start < 0 || end < 0 {
+		return ""
+	}
+
+	tokens := strings.Split(ua[start+1:idx+end], ";")
+	afterAndroid := false
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if strings.HasPrefix(token, "Android") {
+			afterAndroid = true
+			continue
+		}
+		if !afterAndroid || isPlaceholderModel(token) {
+			continue
+		}
+		if i := strings.Index(token, " Build/"); i >= 0 {
+			token = token[:i]
+		} else if strings.HasPrefix(token, "Build/") {
+			continue
+		}
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// isPlaceholderModel reports whether a UA token is not a device model: empty,
+// a reduced-UA placeholder ("K"), a WebView/security marker or a locale.
+func isPlaceholderModel(token string) bool {
+	switch token {
+	case "", "K", "U", "I", "N", "wv", "Mobile", "Tablet":
+		return true
+	}
+	// Locales such as "en-us" or "ko_KR"
+	if len(token) == 5 && (token[2] == '-' || token[2] == '_') {
+		return true
+	}
+	return false
+}
+
+// deviceName joins brand and model for the user_device column, avoiding a
+// repeated brand for models such as "Nokia G20".
+func deviceName(brand, model string) string {
+	if brand == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(brand)) {
+		return model
+	}
+	return brand + " " + model
+}
+
 // osNameToString maps uasurfer OSName constants to human-readable strings.
 func osNameToString(name uasurfer.OSName) string {
 	switch name {
@@ -106,3 +336,12 @@ func deviceTypeToString(dt uasurfer.DeviceType) string {
 		return strings.ToLower(dt.StringTrimPrefix())
 	}
 }
+
+// yesNo converts a bool to the VALUE_YES/VALUE_NO strings stored in flag
+// columns.
+func yesNo(b bool) string {
+	if b {
+		return VALUE_YES
+	}
+	return VALUE_NO
+}
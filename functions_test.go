@@ -17,6 +17,7 @@ func TestParseUserAgent(t *testing.T) {
 				Os:             "Windows",
 				OsVersion:      "10.0",
 				DeviceType:     "desktop",
+				Engine:         "Gecko",
 			},
 		},
 		{
@@ -28,6 +29,7 @@ func TestParseUserAgent(t *testing.T) {
 				Os:             "Windows",
 				OsVersion:      "10.0",
 				DeviceType:     "desktop",
+				Engine:         "Blink",
 			},
 		},
 		{
@@ -39,6 +41,7 @@ func TestParseUserAgent(t *testing.T) {
 				Os:             "Windows",
 				OsVersion:      "10.0",
 				DeviceType:     "desktop",
+				Engine:         "Blink",
 			},
 		},
 		{
@@ -50,6 +53,7 @@ func TestParseUserAgent(t *testing.T) {
 				Os:             "macOS",
 				OsVersion:      "10.15.7",
 				DeviceType:     "desktop",
+				Engine:         "WebKit",
 			},
 		},
 		{
@@ -60,7 +64,52 @@ func TestParseUserAgent(t *testing.T) {
 				BrowserVersion: "120.0",
 				Os:             "Android",
 				OsVersion:      "13.0",
+				Device:         "Google Pixel 7",
 				DeviceType:     "mobile",
+				DeviceBrand:    "Google",
+				DeviceModel:    "Pixel 7",
+				Engine:         "Blink",
+			},
+		},
+		{
+			name: "Samsung Internet on Android",
+			ua:   "Mozilla/5.0 (Linux; Android 14; SAMSUNG SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			want: UserAgentInfo{
+				Browser:     "Samsung",
+				Os:          "Android",
+				OsVersion:   "14.0",
+				Device:      "Samsung SM-S918B",
+				DeviceType:  "mobile",
+				DeviceBrand: "Samsung",
+				DeviceModel: "SM-S918B",
+				Engine:      "Blink",
+			},
+		},
+		{
+			name: "Android with locale and build",
+			ua:   "Mozilla/5.0 (Linux; U; Android 12; en-us; Redmi Note 11 Build/SKQ1.211103.001) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/100.0.4896.127 Mobile Safari/537.36",
+			want: UserAgentInfo{
+				Browser:        "Chrome",
+				BrowserVersion: "100.0.4896",
+				Os:             "Android",
+				OsVersion:      "12.0",
+				Device:         "Xiaomi Redmi Note 11",
+				DeviceType:     "mobile",
+				DeviceBrand:    "Xiaomi",
+				DeviceModel:    "Redmi Note 11",
+				Engine:         "Blink",
+			},
+		},
+		{
+			name: "Chrome reduced UA on Android",
+			ua:   "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			want: UserAgentInfo{
+				Browser:        "Chrome",
+				BrowserVersion: "124.0",
+				Os:             "Android",
+				OsVersion:      "10.0",
+				DeviceType:     "mobile",
+				Engine:         "Blink",
 			},
 		},
 		{
@@ -73,6 +122,9 @@ func TestParseUserAgent(t *testing.T) {
 				OsVersion:      "17.2",
 				Device:         "iPhone",
 				DeviceType:     "mobile",
+				DeviceBrand:    "Apple",
+				DeviceModel:    "iPhone",
+				Engine:         "WebKit",
 			},
 		},
 		{
@@ -85,6 +137,43 @@ func TestParseUserAgent(t *testing.T) {
 				OsVersion:      "17.2",
 				Device:         "iPad",
 				DeviceType:     "tablet",
+				DeviceBrand:    "Apple",
+				DeviceModel:    "iPad",
+				Engine:         "WebKit",
+			},
+		},
+		{
+			name: "Instagram in-app browser on iPhone",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 312.0.0.32.112 (iPhone15,2; iOS 17_2; en_US; en; scale=3.00; 1179x2556; 548339486)",
+			want: UserAgentInfo{
+				Browser:        "Safari",
+				BrowserVersion: "17.2",
+				Os:             "iOS",
+				OsVersion:      "17.2",
+				Device:         "iPhone",
+				DeviceType:     "mobile",
+				DeviceBrand:    "Apple",
+				DeviceModel:    "iPhone",
+				Engine:         "WebKit",
+				Webview:        true,
+				App:            "Instagram",
+			},
+		},
+		{
+			name: "Android WebView",
+			ua:   "Mozilla/5.0 (Linux; Android 13; Pixel 7 Build/TQ3A.230901.001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.144 Mobile Safari/537.36",
+			want: UserAgentInfo{
+				Browser:        "Chrome",
+				BrowserVersion: "120.0.6099",
+				Os:             "Android",
+				OsVersion:      "13.0",
+				Device:         "Google Pixel 7",
+				DeviceType:     "mobile",
+				DeviceBrand:    "Google",
+				DeviceModel:    "Pixel 7",
+				Engine:         "Blink",
+				Webview:        true,
+				App:            "Android WebView",
 			},
 		},
 		{
@@ -0,0 +1,475 @@
+package statsstore
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// == GOAL TYPES ===============================================================
+
+// Goal types.
+const (
+	// GoalTypePage converts on a page view whose path matches Goal.Path.
+	GoalTypePage = "page"
+
+	// GoalTypeEvent converts on a custom event named Goal.EventName.
+	GoalTypeEvent = "event"
+)
+
+// goalMaxNameLength caps goal and funnel names.
+const goalMaxNameLength = 120
+
+// Goal is a conversion goal.
+type Goal struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Type is GoalTypePage or GoalTypeEvent.
+	Type string `json:"type"`
+
+	// Path is matched against page views of a page goal: an exact path such
+	// as "/signup/done", or a pattern where "*" matches within one path
+	// segment and "**" matches across segments, e.g. "/blog/**".
+	Path string `json:"path,omitempty"`
+
+	// EventName is the custom event name of an event goal.
+	EventName string `json:"event_name,omitempty"`
+
+	// Value, when positive, is credited for every completion, e.g. the
+	// estimated worth of a lead.
+	Value float64 `json:"value,omitempty"`
+}
+
+// Validate checks that the goal can be matched.
+func (g Goal) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return errors.New("goal name is required")
+	}
+	if len(g.Name) > goalMaxNameLength {
+		return errors.New("goal name is too long")
+	}
+	if g.Value < 0 || math.IsNaN(g.Value) || math.IsInf(g.Value, 0) {
+		return errors.New("goal value must be a non-negative number")
+	}
+
+	switch g.Type {
+	case GoalTypePage:
+		if !strings.HasPrefix(g.Path, "/") {
+			return errors.New("page goal path must start with /")
+		}
+	case GoalTypeEvent:
+		if strings.TrimSpace(g.EventName) == "" {
+			return errors.New("event goal needs an event name")
+		}
+	default:
+		return errors.New("goal type must be page or event")
+	}
+
+	return nil
+}
+
+// MatchesPath reports whether a page view of path completes a page goal.
+// To match many paths, use pathMatcher, which compiles the pattern once.
+func (g Goal) MatchesPath(path string) bool {
+	return g.pathMatcher()(path)
+}
+
+// pathMatcher returns a function reporting whether a page view of a path
+// completes a page goal.
+func (g Goal) pathMatcher() func(path string) bool {
+	if g.Type != GoalTypePage {
+		return func(string) bool { return false }
+	}
+	if !strings.Contains(g.Path, "*") {
+		return func(path string) bool { return path == g.Path }
+	}
+	return goalPathPattern(g.Path).MatchString
+}
+
+// MatchesEvent reports whether an event completes an event goal.
+func (g Goal) MatchesEvent(event EventInterface) bool {
+	return g.Type == GoalTypeEvent && event.GetName() == g.EventName
+}
+
+// goalPathPattern compiles a goal path pattern to a regular expression.
+func goalPathPattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Funnel is an ordered list of goals a visitor is expected to complete.
+type Funnel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// GoalIDs are the steps of the funnel, in order.
+	GoalIDs []string `json:"goal_ids"`
+}
+
+// Validate checks the funnel against the defined goals.
+func (f Funnel) Validate(goals []Goal) error {
+	if strings.TrimSpace(f.Name) == "" {
+		return errors.New("funnel name is required")
+	}
+	if len(f.Name) > goalMaxNameLength {
+		return errors.New("funnel name is too long")
+	}
+	if len(f.GoalIDs) < 2 {
+		return errors.New("funnel needs at least two steps")
+	}
+	for _, id := range f.GoalIDs {
+		if _, ok := findGoal(goals, id); !ok {
+			return errors.New("funnel step " + id + " is not a goal")
+		}
+	}
+	return nil
+}
+
+func findGoal(goals []Goal, id string) (Goal, bool) {
+	for _, goal := range goals {
+		if goal.ID == id {
+			return goal, true
+		}
+	}
+	return Goal{}, false
+}
+
+// == CONVERSIONS ==============================================================
+
+// GoalConversion is the outcome of a goal over a set of visitors.
+type GoalConversion struct {
+	Goal Goal `json:"goal"`
+
+	// Visitors is the number of unique visitors that completed the goal.
+	Visitors int64 `json:"visitors"`
+
+	// Completions counts every matching page view or event.
+	Completions int64 `json:"completions"`
+
+	// ConversionRate is Visitors as a percentage of all visitors.
+	ConversionRate float64 `json:"conversion_rate"`
+
+	// Value is Completions times Goal.Value.
+	Value float64 `json:"value"`
+}
+
+// FunnelStep is the outcome of one step of a funnel.
+type FunnelStep struct {
+	Goal Goal `json:"goal"`
+
+	// Visitors is the number of unique visitors that reached this step after
+	// completing all earlier steps in order.
+	Visitors int64 `json:"visitors"`
+
+	// ConversionRate is Visitors as a percentage of the visitors that
+	// reached the first step.
+	ConversionRate float64 `json:"conversion_rate"`
+
+	// DropOff is the number of visitors of the previous step that did not
+	// reach this one; DropOffRate is that as a percentage.
+	DropOff     int64   `json:"drop_off"`
+	DropOffRate float64 `json:"drop_off_rate"`
+}
+
+// FunnelConversion is the outcome of a funnel over a set of visitors.
+type FunnelConversion struct {
+	Funnel Funnel       `json:"funnel"`
+	Steps  []FunnelStep `json:"steps"`
+
+	// ConversionRate is the share of all visitors that completed every step.
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// goalHit is a completion of a goal by a visitor. The visitor is empty for
+// visits recorded aggregate only.
+type goalHit struct {
+	visitor string
+	at      time.Time
+}
+
+// ComputeGoalConversions computes goal conversions over the page views and
+// events of a period. Visitors are identified by user ID when set and by
+// fingerprint otherwise; events are linked to them by user ID, visitor ID
+// or fingerprint. Events of visitors outside visitors are ignored, so
+// passing the page views of one segment (see SegmentVisitors) yields that
+// segment's conversions. Visits recorded aggregate only count as
+// completions but not as visitors.
+func ComputeGoalConversions(goals []Goal, visitors []VisitorInterface, events []EventInterface) []GoalConversion {
+	total := int64(len(visitorKeys(visitors)))
+
+	results := make([]GoalConversion, 0, len(goals))
+	for _, goal := range goals {
+		hits := goalHits(goal, visitors, events)
+
+		unique := map[string]bool{}
+		for _, hit := range hits {
+			if hit.visitor != "" {
+				unique[hit.visitor] = true
+			}
+		}
+
+		results = append(results, GoalConversion{
+			Goal:           goal,
+			Visitors:       int64(len(unique)),
+			Completions:    int64(len(hits)),
+			ConversionRate: percentage(int64(len(unique)), total),
+			Value:          float64(len(hits)) * goal.Value,
+		})
+	}
+
+	return results
+}
+
+// ComputeFunnelConversion computes the step-by-step conversion of a funnel.
+// A visitor reaches a step when it completed the step at or after the time
+// it reached the previous one.
+func ComputeFunnelConversion(funnel Funnel, goals []Goal, visitors []VisitorInterface, events []EventInterface) FunnelConversion {
+	result := FunnelConversion{Funnel: funnel, Steps: []FunnelStep{}}
+
+	// reachedAt holds, per visitor, the time the previous step was reached.
+	var reachedAt map[string]time.Time
+	var first, previous int64
+
+	for i, id := range funnel.GoalIDs {
+		goal, ok := findGoal(goals, id)
+		if !ok {
+			break
+		}
+
+		hits := goalHits(goal, visitors, events)
+		sort.Slice(hits, func(a, b int) bool { return hits[a].at.Before(hits[b].at) })
+
+		next := map[string]time.Time{}
+		for _, hit := range hits {
+			if hit.visitor == "" {
+				continue
+			}
+			if _, done := next[hit.visitor]; done {
+				continue
+			}
+			if i > 0 {
+				since, ok := reachedAt[hit.visitor]
+				if !ok || hit.at.Before(since) {
+					continue
+				}
+			}
+			next[hit.visitor] = hit.at
+		}
+		reachedAt = next
+
+		step := FunnelStep{Goal: goal, Visitors: int64(len(next))}
+		if i == 0 {
+			first = step.Visitors
+		} else {
+			step.DropOff = previous - step.Visitors
+			step.DropOffRate = percentage(step.DropOff, previous)
+		}
+		step.ConversionRate = percentage(step.Visitors, first)
+		previous = step.Visitors
+
+		result.Steps = append(result.Steps, step)
+	}
+
+	if len(result.Steps) == len(funnel.GoalIDs) && len(result.Steps) > 0 {
+		result.ConversionRate = percentage(previous, int64(len(visitorKeys(visitors))))
+	}
+
+	return result
+}
+
+// goalHits returns the completions of goal by the given visitors.
+func goalHits(goal Goal, visitors []VisitorInterface, events []EventInterface) []goalHit {
+	hits := []goalHit{}
+
+	switch goal.Type {
+	case GoalTypePage:
+		matches := goal.pathMatcher()
+		for _, v := range visitors {
+			if matches(v.GetPath()) {
+				hits = append(hits, goalHit{visitor: visitorKey(v), at: v.GetCreatedAtCarbon().StdTime()})
+			}
+		}
+	case GoalTypeEvent:
+		index := newGoalVisitorIndex(visitors)
+		for _, e := range events {
+			if !goal.MatchesEvent(e) {
+				continue
+			}
+			if key, ok := index.eventVisitor(e); ok {
+				hits = append(hits, goalHit{visitor: key, at: e.GetCreatedAtCarbon().StdTime()})
+			}
+		}
+	}
+
+	return hits
+}
+
+// goalVisitorIndex maps the identifiers an event can carry to the key of
+// the visitor whose page views they belong to.
+type goalVisitorIndex struct {
+	byVisitorID   map[string]string
+	byFingerprint map[string]string
+	byUserID      map[string]string
+}
+
+func newGoalVisitorIndex(visitors []VisitorInterface) goalVisitorIndex {
+	index := goalVisitorIndex{
+		byVisitorID:   map[string]string{},
+		byFingerprint: map[string]string{},
+		byUserID:      map[string]string{},
+	}
+	for _, v := range visitors {
+		key := visitorKey(v)
+		if key == "" {
+			continue
+		}
+		index.byVisitorID[v.GetID()] = key
+		index.byFingerprint[visitorFingerprint(v)] = key
+		if userID := v.GetUserID(); userID != "" {
+			index.byUserID[userID] = key
+		}
+	}
+	return index
+}
+
+// eventVisitor returns the key of the visitor an event belongs to, found by
+// its user ID, the page view it links to or its fingerprint. Events of
+// visitors outside the index are not found.
+func (index goalVisitorIndex) eventVisitor(e EventInterface) (string, bool) {
+	if userID := e.GetUserID(); userID != "" {
+		if key, ok := index.byUserID[userID]; ok {
+			return key, true
+		}
+	}
+	if id := e.GetVisitorID(); id != "" {
+		if key, ok := index.byVisitorID[id]; ok {
+			return key, true
+		}
+	}
+	if fingerprint := e.GetFingerprint(); fingerprint != "" {
+		if key, ok := index.byFingerprint[fingerprint]; ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// visitorKey identifies the visitor of a page view: by user ID when the
+// visit is attributed to a user, so the devices of a user count once, and by
+// fingerprint otherwise. Visits recorded aggregate only have no visitor and
+// return "".
+func visitorKey(v VisitorInterface) string {
+	if v.GetPrivacy() != "" {
+		return ""
+	}
+	if userID := v.GetUserID(); userID != "" {
+		return "user:" + userID
+	}
+	return visitorFingerprint(v)
+}
+
+// visitorFingerprint returns the fingerprint of a page view, as stored on
+// the events linked to it.
+func visitorFingerprint(v VisitorInterface) string {
+	if fingerprint := v.GetFingerprint(); fingerprint != "" {
+		return fingerprint
+	}
+	return v.FingerprintCalculate()
+}
+
+func visitorKeys(visitors []VisitorInterface) map[string]bool {
+	keys := make(map[string]bool, len(visitors))
+	for _, v := range visitors {
+		if key := visitorKey(v); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// percentage returns part as a percentage of whole, rounded to one decimal.
+func percentage(part, whole int64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 10
+}
+
+// == SEGMENTS =================================================================
+
+// Segment names accepted by SegmentByName.
+const (
+	SegmentDevice  = "device"
+	SegmentBrowser = "browser"
+	SegmentOS      = "os"
+	SegmentCountry = "country"
+	SegmentChannel = "channel"
+)
+
+// SegmentFunc returns the segment a page view belongs to.
+type SegmentFunc func(visitor VisitorInterface) string
+
+// SegmentByName returns the segment function for one of the Segment*
+// names, or nil when the name is unknown.
+func SegmentByName(name string) SegmentFunc {
+	switch name {
+	case SegmentDevice:
+		return func(v VisitorInterface) string { return v.GetUserDeviceType() }
+	case SegmentBrowser:
+		return func(v VisitorInterface) string { return v.GetUserBrowser() }
+	case SegmentOS:
+		return func(v VisitorInterface) string { return v.GetUserOs() }
+	case SegmentCountry:
+		return func(v VisitorInterface) string { return strings.ToUpper(v.GetCountry()) }
+	case SegmentChannel:
+		return func(v VisitorInterface) string { return ClassifyChannel(ReferrerDomain(v.GetUserReferrer())) }
+	}
+	return nil
+}
+
+// SegmentVisitors splits page views by segment. Every page view of a visitor
+// goes to the segment of the visitor's first page view, so a visitor is
+// counted in one segment only. Empty segment values become "Unknown".
+func SegmentVisitors(visitors []VisitorInterface, segment SegmentFunc) map[string][]VisitorInterface {
+	sorted := make([]VisitorInterface, len(visitors))
+	copy(sorted, visitors)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].GetCreatedAtCarbon().StdTime().Before(sorted[b].GetCreatedAtCarbon().StdTime())
+	})
+
+	visitorSegment := map[string]string{}
+	segments := map[string][]VisitorInterface{}
+	for _, v := range sorted {
+		key := visitorKey(v)
+		name, ok := visitorSegment[key]
+		if !ok || key == "" {
+			name = strings.TrimSpace(segment(v))
+			if name == "" {
+				name = "Unknown"
+			}
+			if key != "" {
+				visitorSegment[key] = name
+			}
+		}
+		segments[name] = append(segments[name], v)
+	}
+
+	return segments
+}
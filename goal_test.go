@@ -0,0 +1,190 @@
+package statsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func goalTestVisitor(fingerprint, path, deviceType string, at time.Time) VisitorInterface {
+	return NewVisitor().
+		SetFingerprint(fingerprint).
+		SetPath(path).
+		SetUserDeviceType(deviceType).
+		SetCreatedAt(carbon.CreateFromStdTime(at).ToDateTimeString(carbon.UTC))
+}
+
+func goalTestEvent(fingerprint, name string, at time.Time) EventInterface {
+	return NewEvent().
+		SetFingerprint(fingerprint).
+		SetName(name).
+		SetCreatedAt(carbon.CreateFromStdTime(at).ToDateTimeString(carbon.UTC))
+}
+
+func TestGoalValidate(t *testing.T) {
+	valid := []Goal{
+		{Name: "Signup", Type: GoalTypePage, Path: "/signup/done"},
+		{Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase", Value: 20},
+	}
+	for _, goal := range valid {
+		if err := goal.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", goal.Name, err)
+		}
+	}
+
+	invalid := map[string]Goal{
+		"no name":       {Type: GoalTypePage, Path: "/"},
+		"unknown type":  {Name: "X", Type: "click"},
+		"relative path": {Name: "X", Type: GoalTypePage, Path: "signup"},
+		"no event name": {Name: "X", Type: GoalTypeEvent},
+		"negative":      {Name: "X", Type: GoalTypeEvent, EventName: "Y", Value: -1},
+	}
+	for name, goal := range invalid {
+		if err := goal.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGoalMatchesPath(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/pricing", "/pricing", true},
+		{"/pricing", "/pricing/team", false},
+		{"/blog/*", "/blog/hello", true},
+		{"/blog/*", "/blog/2024/hello", false},
+		{"/blog/**", "/blog/2024/hello", true},
+		{"/docs/*/install", "/docs/go/install", true},
+		{"/a.b/*", "/aXb/c", false},
+	}
+	for _, c := range cases {
+		goal := Goal{Name: "X", Type: GoalTypePage, Path: c.pattern}
+		if got := goal.MatchesPath(c.path); got != c.want {
+			t.Errorf("%q matches %q = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestComputeGoalConversions(t *testing.T) {
+	now := time.Now().UTC()
+	visitors := []VisitorInterface{
+		goalTestVisitor("a", "/", "desktop", now),
+		goalTestVisitor("a", "/signup/done", "desktop", now.Add(time.Minute)),
+		goalTestVisitor("b", "/", "mobile", now),
+		goalTestVisitor("c", "/signup/done", "mobile", now),
+		goalTestVisitor("d", "/", "mobile", now),
+		// Aggregate-only visits complete goals but are not visitors.
+		goalTestVisitor("", "/signup/done", "mobile", now).SetPrivacy(PrivacyReasonDoNotTrack),
+		goalTestVisitor("", "/", "mobile", now).SetPrivacy(PrivacyReasonDoNotTrack),
+	}
+	events := []EventInterface{
+		goalTestEvent("a", "Purchase", now.Add(2*time.Minute)),
+		goalTestEvent("a", "Purchase", now.Add(3*time.Minute)),
+		goalTestEvent("zzz", "Purchase", now), // not among the visitors
+	}
+	goals := []Goal{
+		{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done"},
+		{ID: "purchase", Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase", Value: 10},
+	}
+
+	results := ComputeGoalConversions(goals, visitors, events)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Visitors != 2 || results[0].Completions != 3 || results[0].ConversionRate != 50 {
+		t.Errorf("unexpected signup conversion: %+v", results[0])
+	}
+	if results[1].Visitors != 1 || results[1].Completions != 2 || results[1].Value != 20 || results[1].ConversionRate != 25 {
+		t.Errorf("unexpected purchase conversion: %+v", results[1])
+	}
+
+	segments := SegmentVisitors(visitors, SegmentByName(SegmentDevice))
+	mobile := ComputeGoalConversions(goals, segments["mobile"], events)
+	if mobile[0].Visitors != 1 || mobile[0].ConversionRate != 33.3 || mobile[1].Visitors != 0 {
+		t.Errorf("unexpected mobile conversions: %+v", mobile)
+	}
+}
+
+func TestComputeGoalConversionsLinksEvents(t *testing.T) {
+	now := time.Now().UTC()
+	byID := goalTestVisitor("a", "/", "desktop", now)
+	byUser := goalTestVisitor("b", "/", "desktop", now).SetUserID("user-1")
+	visitors := []VisitorInterface{byID, byUser, goalTestVisitor("c", "/", "desktop", now)}
+
+	events := []EventInterface{
+		// Server-side events carry another fingerprint, or none at all.
+		goalTestEvent("server", "Purchase", now).SetVisitorID(byID.GetID()),
+		goalTestEvent("", "Purchase", now).SetUserID("user-1"),
+		goalTestEvent("", "Purchase", now).SetUserID("user-2"),
+	}
+	goals := []Goal{{ID: "purchase", Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"}}
+
+	results := ComputeGoalConversions(goals, visitors, events)
+	if results[0].Visitors != 2 || results[0].Completions != 2 {
+		t.Errorf("expected events linked by visitor ID and user ID, got %+v", results[0])
+	}
+}
+
+func TestComputeGoalConversionsByUserID(t *testing.T) {
+	now := time.Now().UTC()
+	visitors := []VisitorInterface{
+		goalTestVisitor("laptop", "/signup/done", "desktop", now).SetUserID("42"),
+		goalTestVisitor("phone", "/signup/done", "mobile", now.Add(time.Hour)).SetUserID("42"),
+		goalTestVisitor("b", "/", "mobile", now),
+	}
+	goals := []Goal{{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done"}}
+
+	results := ComputeGoalConversions(goals, visitors, nil)
+	if results[0].Visitors != 1 || results[0].Completions != 2 || results[0].ConversionRate != 50 {
+		t.Errorf("expected the user's devices to count once, got %+v", results[0])
+	}
+}
+
+func TestComputeFunnelConversion(t *testing.T) {
+	now := time.Now().UTC()
+	visitors := []VisitorInterface{
+		goalTestVisitor("a", "/pricing", "", now),
+		goalTestVisitor("a", "/signup/done", "", now.Add(time.Minute)),
+		goalTestVisitor("b", "/pricing", "", now),
+		goalTestVisitor("b", "/signup/done", "", now.Add(time.Minute)),
+		goalTestVisitor("c", "/pricing", "", now),
+		// d signs up before viewing pricing, so it never reaches step 2.
+		goalTestVisitor("d", "/signup/done", "", now),
+		goalTestVisitor("d", "/pricing", "", now.Add(time.Minute)),
+	}
+	events := []EventInterface{
+		goalTestEvent("a", "Purchase", now.Add(2*time.Minute)),
+		goalTestEvent("c", "Purchase", now.Add(2*time.Minute)),
+	}
+	goals := []Goal{
+		{ID: "pricing", Name: "Pricing", Type: GoalTypePage, Path: "/pricing"},
+		{ID: "signup", Name: "Signup", Type: GoalTypePage, Path: "/signup/done"},
+		{ID: "purchase", Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"},
+	}
+	funnel := Funnel{ID: "f", Name: "Checkout", GoalIDs: []string{"pricing", "signup", "purchase"}}
+
+	result := ComputeFunnelConversion(funnel, goals, visitors, events)
+	if len(result.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(result.Steps))
+	}
+
+	want := []struct {
+		visitors, dropOff int64
+		rate              float64
+	}{
+		{4, 0, 100},
+		{2, 2, 50},
+		{1, 1, 25},
+	}
+	for i, step := range result.Steps {
+		if step.Visitors != want[i].visitors || step.DropOff != want[i].dropOff || step.ConversionRate != want[i].rate {
+			t.Errorf("step %d = %+v, want %+v", i, step, want[i])
+		}
+	}
+	if result.ConversionRate != 25 {
+		t.Errorf("conversion rate = %v, want 25", result.ConversionRate)
+	}
+}
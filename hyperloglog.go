@@ -0,0 +1,317 @@
+package statsstore
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// == CONSTANTS ================================================================
+
+const (
+	// HyperLogLogPrecisionMin and HyperLogLogPrecisionMax bound the
+	// precision of a HyperLogLog sketch, i.e. log2 of its register count.
+	HyperLogLogPrecisionMin = 4
+	HyperLogLogPrecisionMax = 16
+
+	// HyperLogLogErrorBoundDefault is the relative standard error unique
+	// visitor sketches are sized for when NewStoreOptions.UniqueErrorBound
+	// is not set.
+	HyperLogLogErrorBoundDefault = 0.01
+
+	// HyperLogLogPrecisionDefault is the precision matching
+	// HyperLogLogErrorBoundDefault: 16384 registers, 0.81% standard error.
+	HyperLogLogPrecisionDefault = 14
+)
+
+const (
+	// hllVersion is the first byte of an encoded sketch.
+	hllVersion = 1
+
+	// Encoded register layouts: every register, or the index and value of
+	// the non-empty ones.
+	hllDense  = 0
+	hllSparse = 1
+)
+
+// == TYPE =====================================================================
+
+// HyperLogLog estimates the number of distinct strings added to it in a
+// fixed amount of memory (2^precision bytes). Sketches of the same strings
+// merge without double counting, so daily sketches add up to the uniques of
+// any range of days.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// NewHyperLogLog returns an empty sketch of the given precision, between
+// HyperLogLogPrecisionMin and HyperLogLogPrecisionMax.
+func NewHyperLogLog(precision uint8) (*HyperLogLog, error) {
+	if precision < HyperLogLogPrecisionMin || precision > HyperLogLogPrecisionMax {
+		return nil, errors.New("hyperloglog precision must be between 4 and 16")
+	}
+	return &HyperLogLog{precision: precision, registers: make([]uint8, 1<<precision)}, nil
+}
+
+// HyperLogLogPrecision returns the smallest precision whose relative
+// standard error is at most errorBound, e.g. 14 for 0.01. Bounds below what
+// HyperLogLogPrecisionMax achieves get the maximum; a bound of zero or less
+// gets HyperLogLogPrecisionDefault.
+func HyperLogLogPrecision(errorBound float64) uint8 {
+	if errorBound <= 0 {
+		return HyperLogLogPrecisionDefault
+	}
+
+	registers := math.Pow(1.04/errorBound, 2)
+	precision := int(math.Ceil(math.Log2(registers)))
+	if precision < HyperLogLogPrecisionMin {
+		return HyperLogLogPrecisionMin
+	}
+	if precision > HyperLogLogPrecisionMax {
+		return HyperLogLogPrecisionMax
+	}
+	return uint8(precision)
+}
+
+// Precision returns log2 of the register count.
+func (h *HyperLogLog) Precision() uint8 {
+	return h.precision
+}
+
+// RelativeError returns the relative standard error of Count.
+func (h *HyperLogLog) RelativeError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
+
+// Add adds a string to the sketch.
+func (h *HyperLogLog) Add(value string) {
+	hash := hllHash(value)
+	index := hash >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(hash<<h.precision|1<<(h.precision-1)) + 1)
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// Count returns the estimated number of distinct strings added. Small
+// counts use linear counting, which is close to exact.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := hllAlpha(len(h.registers)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// Merge adds the strings of other to the sketch. A sketch of a higher
+// precision is reduced to this one's first; merging one of a lower
+// precision is an error, see MergeHyperLogLogs.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other == nil {
+		return nil
+	}
+	if other.precision < h.precision {
+		return errors.New("hyperloglog: cannot merge a sketch of lower precision")
+	}
+	if other.precision > h.precision {
+		other = other.Reduce(h.precision)
+	}
+
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Reduce returns a copy of the sketch at a lower precision, as if its
+// strings had been added to a sketch of that precision. Precisions not
+// below the sketch's return a plain copy.
+func (h *HyperLogLog) Reduce(precision uint8) *HyperLogLog {
+	if precision >= h.precision || precision < HyperLogLogPrecisionMin {
+		return h.Clone()
+	}
+
+	shift := h.precision - precision
+	reduced, _ := NewHyperLogLog(precision)
+	for i, r := range h.registers {
+		if r == 0 {
+			continue
+		}
+
+		// The index bits dropped by the reduction lead the remaining hash
+		// bits, so they decide the rank unless they are all zero.
+		dropped := uint64(i) & (1<<shift - 1)
+		rank := r + shift
+		if dropped != 0 {
+			rank = shift - uint8(bits.Len64(dropped)) + 1
+		}
+
+		if index := i >> shift; rank > reduced.registers[index] {
+			reduced.registers[index] = rank
+		}
+	}
+	return reduced
+}
+
+// Clone returns a copy of the sketch.
+func (h *HyperLogLog) Clone() *HyperLogLog {
+	registers := make([]uint8, len(h.registers))
+	copy(registers, h.registers)
+	return &HyperLogLog{precision: h.precision, registers: registers}
+}
+
+// MarshalBinary encodes the sketch, listing only the non-empty registers
+// when that is shorter, so sketches of a few visitors take a few bytes.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	nonEmpty := 0
+	for _, r := range h.registers {
+		if r != 0 {
+			nonEmpty++
+		}
+	}
+
+	if 3*nonEmpty >= len(h.registers) {
+		data := make([]byte, 3, 3+len(h.registers))
+		data[0], data[1], data[2] = hllVersion, h.precision, hllDense
+		return append(data, h.registers...), nil
+	}
+
+	data := make([]byte, 3, 3+3*nonEmpty)
+	data[0], data[1], data[2] = hllVersion, h.precision, hllSparse
+	for i, r := range h.registers {
+		if r != 0 {
+			data = binary.BigEndian.AppendUint16(data, uint16(i))
+			data = append(data, r)
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != hllVersion {
+		return errors.New("hyperloglog: unsupported encoding")
+	}
+
+	decoded, err := NewHyperLogLog(data[1])
+	if err != nil {
+		return err
+	}
+
+	body := data[3:]
+	switch data[2] {
+	case hllDense:
+		if len(body) != len(decoded.registers) {
+			return errors.New("hyperloglog: truncated sketch")
+		}
+		copy(decoded.registers, body)
+	case hllSparse:
+		if len(body)%3 != 0 {
+			return errors.New("hyperloglog: truncated sketch")
+		}
+		for i := 0; i < len(body); i += 3 {
+			index := int(binary.BigEndian.Uint16(body[i:]))
+			if index >= len(decoded.registers) {
+				return errors.New("hyperloglog: register out of range")
+			}
+			decoded.registers[index] = body[i+2]
+		}
+	default:
+		return errors.New("hyperloglog: unsupported encoding")
+	}
+
+	*h = *decoded
+	return nil
+}
+
+// MarshalText encodes the sketch as base64, for string columns and JSON.
+func (h *HyperLogLog) MarshalText() ([]byte, error) {
+	data, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, base64.RawStdEncoding.EncodedLen(len(data)))
+	base64.RawStdEncoding.Encode(text, data)
+	return text, nil
+}
+
+// UnmarshalText decodes a sketch encoded by MarshalText.
+func (h *HyperLogLog) UnmarshalText(text []byte) error {
+	data := make([]byte, base64.RawStdEncoding.DecodedLen(len(text)))
+	n, err := base64.RawStdEncoding.Decode(data, text)
+	if err != nil {
+		return err
+	}
+	return h.UnmarshalBinary(data[:n])
+}
+
+// == FUNCTIONS ================================================================
+
+// MergeHyperLogLogs returns the union of the sketches at the lowest
+// precision among them. Nil sketches are skipped; without any sketch it
+// returns nil.
+func MergeHyperLogLogs(sketches ...*HyperLogLog) *HyperLogLog {
+	var merged *HyperLogLog
+	for _, sketch := range sketches {
+		switch {
+		case sketch == nil:
+			continue
+		case merged == nil:
+			merged = sketch.Clone()
+		case sketch.precision < merged.precision:
+			next := sketch.Clone()
+			_ = next.Merge(merged)
+			merged = next
+		default:
+			_ = merged.Merge(sketch)
+		}
+	}
+	return merged
+}
+
+// hllHash hashes a string to 64 well-mixed bits: FNV-1a followed by the
+// MurmurHash3 finalizer, which FNV needs for its high bits to be uniform.
+func hllHash(value string) uint64 {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(value))
+	hash := hasher.Sum64()
+
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// hllAlpha is the bias correction constant for m registers.
+func hllAlpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
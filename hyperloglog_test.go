@@ -0,0 +1,117 @@
+package statsstore
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func hllWith(t *testing.T, precision uint8, from, to int) *HyperLogLog {
+	t.Helper()
+	sketch, err := NewHyperLogLog(precision)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for i := from; i < to; i++ {
+		sketch.Add("visitor-" + strconv.Itoa(i))
+	}
+	return sketch
+}
+
+func TestHyperLogLogPrecision(t *testing.T) {
+	tests := []struct {
+		errorBound float64
+		precision  uint8
+	}{
+		{0, HyperLogLogPrecisionDefault},
+		{HyperLogLogErrorBoundDefault, HyperLogLogPrecisionDefault},
+		{0.02, 12},
+		{0.5, HyperLogLogPrecisionMin},
+		{0.0001, HyperLogLogPrecisionMax},
+	}
+
+	for _, tt := range tests {
+		if got := HyperLogLogPrecision(tt.errorBound); got != tt.precision {
+			t.Errorf("HyperLogLogPrecision(%v) = %d, want %d", tt.errorBound, got, tt.precision)
+		}
+	}
+
+	if _, err := NewHyperLogLog(HyperLogLogPrecisionMax + 1); err == nil {
+		t.Error("expected an error for a precision out of range")
+	}
+}
+
+func TestHyperLogLogCount(t *testing.T) {
+	if count := hllWith(t, HyperLogLogPrecisionDefault, 0, 0).Count(); count != 0 {
+		t.Errorf("expected 0 for an empty sketch, got %d", count)
+	}
+
+	small := hllWith(t, HyperLogLogPrecisionDefault, 0, 50)
+	small.Add("visitor-1")
+	if count := small.Count(); count != 50 {
+		t.Errorf("expected an exact count of 50, got %d", count)
+	}
+
+	for _, n := range []int{10000, 200000} {
+		sketch := hllWith(t, HyperLogLogPrecisionDefault, 0, n)
+		relative := math.Abs(float64(sketch.Count())-float64(n)) / float64(n)
+		if relative > 3*sketch.RelativeError() {
+			t.Errorf("count of %d off by %.2f%%, more than 3 standard errors", n, relative*100)
+		}
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	monday := hllWith(t, 12, 0, 6000)
+	tuesday := hllWith(t, 12, 4000, 10000)
+
+	union := MergeHyperLogLogs(monday, nil, tuesday)
+	relative := math.Abs(float64(union.Count())-10000) / 10000
+	if relative > 3*union.RelativeError() {
+		t.Errorf("union count %d is off by %.2f%%", union.Count(), relative*100)
+	}
+	if monday.Count() == union.Count() {
+		t.Error("expected MergeHyperLogLogs to leave its arguments unchanged")
+	}
+
+	if err := hllWith(t, 14, 0, 10).Merge(monday); err == nil {
+		t.Error("expected an error merging a sketch of lower precision")
+	}
+
+	// Reducing a sketch gives the sketch of the same strings at the lower
+	// precision, so sketches of different precisions merge exactly.
+	reduced := hllWith(t, 14, 0, 6000).Reduce(12)
+	if reduced.Precision() != 12 || string(reduced.registers) != string(monday.registers) {
+		t.Error("expected the reduced sketch to equal one built at the lower precision")
+	}
+	mixed := MergeHyperLogLogs(hllWith(t, 14, 0, 6000), tuesday)
+	if mixed.Precision() != 12 || mixed.Count() != union.Count() {
+		t.Errorf("expected a mixed-precision merge at precision 12 counting %d, got %d at %d", union.Count(), mixed.Count(), mixed.Precision())
+	}
+}
+
+func TestHyperLogLogMarshal(t *testing.T) {
+	for _, n := range []int{0, 3, 20000} {
+		sketch := hllWith(t, HyperLogLogPrecisionDefault, 0, n)
+
+		text, err := sketch.MarshalText()
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if n == 3 && len(text) > 20 {
+			t.Errorf("expected a sparse encoding for 3 visitors, got %d bytes", len(text))
+		}
+
+		decoded := &HyperLogLog{}
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if decoded.Precision() != sketch.Precision() || string(decoded.registers) != string(sketch.registers) {
+			t.Errorf("%d visitors: decoded sketch differs", n)
+		}
+	}
+
+	if err := (&HyperLogLog{}).UnmarshalBinary([]byte{hllVersion, 14, hllDense, 1}); err == nil {
+		t.Error("expected an error for a truncated sketch")
+	}
+}
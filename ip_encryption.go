@@ -0,0 +1,339 @@
+package statsstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	contractsorm "github.com/dracory/neat/contracts/database/orm"
+	"github.com/dromara/carbon/v2"
+)
+
+// == CONSTANTS ================================================================
+
+const (
+	// ipCipherPrefix marks an encrypted IP: "enc:<key id>:<base64 nonce and
+	// ciphertext>". Values without it are plaintext.
+	ipCipherPrefix = "enc:"
+
+	// ipColumnLength is the size of the IP columns, large enough for an
+	// encrypted IPv6 address.
+	ipColumnLength = 160
+
+	// ipKeyIDMaxLength caps key IDs, which are stored with every value.
+	ipKeyIDMaxLength = 16
+
+	// ipIndexKeyMinLength is the minimum size of the blind index key.
+	ipIndexKeyMinLength = 16
+
+	// ipRotateBatchSize is the number of rows IPKeyRotate loads at a time.
+	ipRotateBatchSize = 500
+)
+
+// == TYPES ====================================================================
+
+// IPEncryptionOptions enables AES-GCM encryption of the ip_address and
+// peer_ip_address columns. Lookups by IP (VisitorDeleteByIP, IPIn/IPNotIn
+// queries, geo-IP enrichment) use a deterministic blind index, an
+// HMAC-SHA256 of the IP stored in ip_index.
+//
+// To rotate, add a new key, make it active and run IPKeyRotate; remove the
+// old key once it reports no failures. IndexKey cannot be rotated without
+// recomputing the index, so keep it stable.
+type IPEncryptionOptions struct {
+	// Keys maps key IDs (up to 16 characters, no colon) to AES keys of 16,
+	// 24 or 32 bytes. Old keys stay listed to decrypt existing rows.
+	Keys map[string][]byte
+
+	// ActiveKeyID is the key new values are encrypted with.
+	ActiveKeyID string
+
+	// IndexKey is the HMAC key of the blind index, at least 16 bytes.
+	IndexKey []byte
+}
+
+// IPKeyRotateResult is the outcome of IPKeyRotate.
+type IPKeyRotateResult struct {
+	Scanned int `json:"scanned"`
+	Rotated int `json:"rotated"`
+
+	// Failed counts rows that could not be decrypted, e.g. because their
+	// key is no longer configured. They are left unchanged.
+	Failed int `json:"failed"`
+}
+
+// ipCipher encrypts IP addresses and computes their blind index.
+type ipCipher struct {
+	aeads    map[string]cipher.AEAD
+	active   string
+	indexKey []byte
+}
+
+// == CONSTRUCTOR ==============================================================
+
+func newIPCipher(opts IPEncryptionOptions) (*ipCipher, error) {
+	if len(opts.IndexKey) < ipIndexKeyMinLength {
+		return nil, errors.New("stats store: IP encryption IndexKey must be at least 16 bytes")
+	}
+	if _, ok := opts.Keys[opts.ActiveKeyID]; !ok {
+		return nil, errors.New("stats store: IP encryption ActiveKeyID is not in Keys")
+	}
+
+	c := &ipCipher{
+		aeads:    map[string]cipher.AEAD{},
+		active:   opts.ActiveKeyID,
+		indexKey: append([]byte(nil), opts.IndexKey...),
+	}
+	for id, key := range opts.Keys {
+		if id == "" || len(id) > ipKeyIDMaxLength || strings.Contains(id, ":") {
+			return nil, errors.New("stats store: invalid IP encryption key ID " + id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.New("stats store: IP encryption key " + id + ": " + err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads[id] = aead
+	}
+
+	return c, nil
+}
+
+// == METHODS ==================================================================
+
+// encrypt encrypts ip with the active key. Empty and already encrypted
+// values are returned unchanged.
+func (c *ipCipher) encrypt(ip string) (string, error) {
+	if ip == "" || strings.HasPrefix(ip, ipCipherPrefix) {
+		return ip, nil
+	}
+
+	aead := c.aeads[c.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(ip), []byte(c.active))
+
+	return ipCipherPrefix + c.active + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt returns the plaintext of an encrypted value. Plaintext values are
+// returned unchanged.
+func (c *ipCipher) decrypt(value string) (string, error) {
+	keyID, ok := ipCipherKeyID(value)
+	if !ok {
+		return value, nil
+	}
+
+	aead, found := c.aeads[keyID]
+	if !found {
+		return "", errors.New("unknown IP encryption key " + keyID)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(value[len(ipCipherPrefix)+len(keyID)+1:])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted IP")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// blindIndex returns the HMAC-SHA256 of ip as hex, or an empty string for
+// an empty IP.
+func (c *ipCipher) blindIndex(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ipCipherKeyID returns the key ID of an encrypted value, or ok false for
+// plaintext.
+func ipCipherKeyID(value string) (string, bool) {
+	if !strings.HasPrefix(value, ipCipherPrefix) {
+		return "", false
+	}
+	keyID, _, ok := strings.Cut(value[len(ipCipherPrefix):], ":")
+	return keyID, ok
+}
+
+// == STORE ====================================================================
+
+// ipSeal returns the stored form of an IP, encrypted with the active key,
+// and its blind index. ip may be plaintext or encrypted with any configured
+// key. Without IP encryption it returns ip and an empty index.
+func (st *storeImplementation) ipSeal(ip string) (string, string, error) {
+	if st.ipCipher == nil {
+		return ip, "", nil
+	}
+	plain, err := st.ipCipher.decrypt(ip)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := st.ipCipher.encrypt(plain)
+	if err != nil {
+		return "", "", err
+	}
+	return sealed, st.ipCipher.blindIndex(plain), nil
+}
+
+// ipReveal returns the plaintext of a stored IP. Values that cannot be
+// decrypted are returned as stored, so updating the row keeps them.
+func (st *storeImplementation) ipReveal(value string) string {
+	if st.ipCipher == nil {
+		return value
+	}
+	plain, err := st.ipCipher.decrypt(value)
+	if err != nil {
+		if st.debugEnabled {
+			st.logger.Error("ip-encryption: decrypt failed", "error", err)
+		}
+		return value
+	}
+	return plain
+}
+
+// whereIPIn restricts q to rows with one of ips. With IP encryption it
+// matches the blind index, and the plaintext of rows not yet encrypted.
+func (st *storeImplementation) whereIPIn(q contractsorm.Query, ips []string) contractsorm.Query {
+	clause, args := st.ipMatchClause(ips)
+	return q.Where(clause, args...)
+}
+
+// ipMatchClause returns the condition of whereIPIn, for use in a larger
+// condition.
+func (st *storeImplementation) ipMatchClause(ips []string) (string, []any) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ips)), ", ")
+	plain := make([]any, len(ips))
+	for i, ip := range ips {
+		plain[i] = ip
+	}
+	if st.ipCipher == nil {
+		return COLUMN_IP_ADDRESS + " IN (" + placeholders + ")", plain
+	}
+
+	args := make([]any, 0, 2*len(ips))
+	for _, ip := range ips {
+		args = append(args, st.ipCipher.blindIndex(ip))
+	}
+	args = append(args, plain...)
+
+	return "(" + COLUMN_IP_INDEX + " IN (" + placeholders + ") OR " + COLUMN_IP_ADDRESS + " IN (" + placeholders + "))", args
+}
+
+// whereIPNotIn restricts q to rows with none of ips, see whereIPIn.
+func (st *storeImplementation) whereIPNotIn(q contractsorm.Query, ips []string) contractsorm.Query {
+	plain := make([]any, len(ips))
+	for i, ip := range ips {
+		plain[i] = ip
+	}
+	if st.ipCipher == nil {
+		return q.WhereNotIn(COLUMN_IP_ADDRESS, plain)
+	}
+
+	indexes := make([]any, len(ips))
+	for i, ip := range ips {
+		indexes[i] = st.ipCipher.blindIndex(ip)
+	}
+
+	return q.WhereNotIn(COLUMN_IP_ADDRESS, plain).
+		Where("("+COLUMN_IP_INDEX+" IS NULL OR "+COLUMN_IP_INDEX+" NOT IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(ips)), ", ")+"))", indexes...)
+}
+
+// IPKeyRotate re-encrypts the IPs of all visitor rows (soft-deleted ones
+// included) that are plaintext or encrypted with another than the active
+// key, in batches, and fills in their blind index. Run it after enabling
+// IP encryption or changing ActiveKeyID.
+//
+// If IP encryption is not configured, it returns an error.
+func (st *storeImplementation) IPKeyRotate(ctx context.Context) (IPKeyRotateResult, error) {
+	result := IPKeyRotateResult{}
+	if st.ipCipher == nil {
+		return result, errors.New("stats store: IP encryption is not configured")
+	}
+
+	type ipRow struct {
+		ID            string  `db:"id"`
+		IPAddress     string  `db:"ip_address"`
+		PeerIPAddress string  `db:"peer_ip_address"`
+		IPIndex       *string `db:"ip_index"`
+	}
+
+	current := func(value string) bool {
+		keyID, encrypted := ipCipherKeyID(value)
+		return value == "" || (encrypted && keyID == st.ipCipher.active)
+	}
+
+	for offset := 0; ; offset += ipRotateBatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		rows := []ipRow{}
+		err := st.db.Query().
+			Table(st.visitorTableName).
+			Select([]string{COLUMN_ID, COLUMN_IP_ADDRESS, COLUMN_PEER_IP_ADDRESS, COLUMN_IP_INDEX}).
+			OrderBy(COLUMN_ID, "asc").
+			Limit(ipRotateBatchSize).
+			Offset(offset).
+			Get(&rows)
+		if err != nil {
+			return result, err
+		}
+
+		for _, row := range rows {
+			result.Scanned++
+			indexed := row.IPAddress == "" || (row.IPIndex != nil && *row.IPIndex != "")
+			if current(row.IPAddress) && current(row.PeerIPAddress) && indexed {
+				continue
+			}
+
+			ip, ipIndex, err := st.ipSeal(row.IPAddress)
+			if err != nil {
+				result.Failed++
+				continue
+			}
+			peerIP, _, err := st.ipSeal(row.PeerIPAddress)
+			if err != nil {
+				result.Failed++
+				continue
+			}
+
+			_, err = st.db.Query().
+				Table(st.visitorTableName).
+				Where(COLUMN_ID+" = ?", row.ID).
+				Update(map[string]any{
+					COLUMN_IP_ADDRESS:      ip,
+					COLUMN_PEER_IP_ADDRESS: peerIP,
+					COLUMN_IP_INDEX:        ipIndex,
+					COLUMN_UPDATED_AT:      carbon.Now(carbon.UTC).StdTime(),
+				})
+			if err != nil {
+				return result, err
+			}
+			result.Rotated++
+		}
+
+		if len(rows) < ipRotateBatchSize {
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,209 @@
+package statsstore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func ipEncryptionTestOptions(active string) *IPEncryptionOptions {
+	return &IPEncryptionOptions{
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+			"k2": bytes.Repeat([]byte{2}, 16),
+		},
+		ActiveKeyID: active,
+		IndexKey:    bytes.Repeat([]byte{9}, 32),
+	}
+}
+
+func TestNewIPCipherErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts IPEncryptionOptions
+	}{
+		{"short index key", IPEncryptionOptions{Keys: map[string][]byte{"k1": make([]byte, 32)}, ActiveKeyID: "k1", IndexKey: make([]byte, 8)}},
+		{"unknown active key", IPEncryptionOptions{Keys: map[string][]byte{"k1": make([]byte, 32)}, ActiveKeyID: "k2", IndexKey: make([]byte, 16)}},
+		{"bad key size", IPEncryptionOptions{Keys: map[string][]byte{"k1": make([]byte, 10)}, ActiveKeyID: "k1", IndexKey: make([]byte, 16)}},
+		{"key id with colon", IPEncryptionOptions{Keys: map[string][]byte{"k:1": make([]byte, 32)}, ActiveKeyID: "k:1", IndexKey: make([]byte, 16)}},
+	}
+
+	for _, tt := range tests {
+		if _, err := newIPCipher(tt.opts); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestIPCipherRoundTrip(t *testing.T) {
+	c, err := newIPCipher(*ipEncryptionTestOptions("k1"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, ip := range []string{"203.0.113.7", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		sealed, err := c.encrypt(ip)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if !strings.HasPrefix(sealed, ipCipherPrefix+"k1:") || len(sealed) > ipColumnLength {
+			t.Errorf("unexpected sealed value %q", sealed)
+		}
+		other, _ := c.encrypt(ip)
+		if other == sealed {
+			t.Error("expected a random nonce per encryption")
+		}
+		if c.blindIndex(ip) != c.blindIndex(ip) || c.blindIndex(ip) == c.blindIndex("198.51.100.1") {
+			t.Error("expected a deterministic blind index per IP")
+		}
+
+		plain, err := c.decrypt(sealed)
+		if err != nil || plain != ip {
+			t.Errorf("decrypt(%q) = %q, %v; want %q", sealed, plain, err, ip)
+		}
+	}
+
+	if _, err := c.decrypt(ipCipherPrefix + "k3:AAAA"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if _, err := c.decrypt(ipCipherPrefix + "k2:" + strings.Repeat("A", 40)); err == nil {
+		t.Error("expected an error for a tampered value")
+	}
+}
+
+func TestStoreIPEncryption(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	// A row stored before encryption was enabled.
+	plain, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", AutomigrateEnabled: true})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := plain.VisitorCreate(ctx, NewVisitor().SetIpAddress("198.51.100.1").SetPath("/old")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := plain.IPKeyRotate(ctx); err == nil {
+		t.Error("expected an error without IP encryption")
+	}
+
+	store, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", AutomigrateEnabled: true, IPEncryption: ipEncryptionTestOptions("k1")})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for _, ip := range []string{"203.0.113.7", "203.0.113.7", "203.0.113.8"} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress(ip).SetPeerIpAddress("10.0.0.1").SetPath("/new")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	var raw string
+	if err := db.QueryRow("SELECT ip_address FROM visitor_table WHERE path = '/new' LIMIT 1").Scan(&raw); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !strings.HasPrefix(raw, ipCipherPrefix+"k1:") {
+		t.Errorf("expected the stored IP to be encrypted, got %q", raw)
+	}
+
+	visitors, err := store.VisitorList(ctx, VisitorQuery().SetIPIn([]string{"203.0.113.7", "198.51.100.1"}))
+	if err != nil || len(visitors) != 3 {
+		t.Fatalf("expected 3 visitors by IP, got %d (%v)", len(visitors), err)
+	}
+	for _, v := range visitors {
+		if v.GetIpAddress() != "203.0.113.7" && v.GetIpAddress() != "198.51.100.1" {
+			t.Errorf("expected a decrypted IP, got %q", v.GetIpAddress())
+		}
+		if v.GetPath() == "/new" && v.GetPeerIpAddress() != "10.0.0.1" {
+			t.Errorf("expected a decrypted peer IP, got %q", v.GetPeerIpAddress())
+		}
+	}
+
+	count, err := store.VisitorCount(ctx, VisitorQuery().SetIPNotIn([]string{"203.0.113.7"}))
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 visitors outside the IP, got %d (%v)", count, err)
+	}
+	count, err = store.VisitorCount(ctx, VisitorQuery().SetPathExact("/new").SetDistinct(COLUMN_IP_ADDRESS))
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 distinct encrypted IPs, got %d (%v)", count, err)
+	}
+
+	deleted, err := store.VisitorDeleteByIP(ctx, "203.0.113.8")
+	if err != nil || deleted != 1 {
+		t.Errorf("expected 1 visitor deleted by IP, got %d (%v)", deleted, err)
+	}
+}
+
+func TestStoreIPKeyRotate(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	plain, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", AutomigrateEnabled: true})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := plain.VisitorCreate(ctx, NewVisitor().SetIpAddress("198.51.100.1")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := plain.VisitorCreate(ctx, NewVisitor()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	oldKey, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", IPEncryption: ipEncryptionTestOptions("k1")})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := oldKey.VisitorCreate(ctx, NewVisitor().SetIpAddress("203.0.113.7")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", IPEncryption: ipEncryptionTestOptions("k2")})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	result, err := store.IPKeyRotate(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if want := (IPKeyRotateResult{Scanned: 3, Rotated: 2}); result != want {
+		t.Errorf("IPKeyRotate() = %+v, want %+v", result, want)
+	}
+
+	var encrypted int
+	if err := db.QueryRow("SELECT COUNT(*) FROM visitor_table WHERE ip_address LIKE 'enc:k2:%' AND ip_index <> ''").Scan(&encrypted); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if encrypted != 2 {
+		t.Errorf("expected 2 rows under the new key, got %d", encrypted)
+	}
+
+	result, err = store.IPKeyRotate(ctx)
+	if err != nil || result.Rotated != 0 || result.Failed != 0 {
+		t.Errorf("expected a second run to change nothing, got %+v (%v)", result, err)
+	}
+
+	// Without the new key the rows can no longer be decrypted.
+	options := ipEncryptionTestOptions("k1")
+	delete(options.Keys, "k2")
+	stale, err := NewStore(NewStoreOptions{DB: db, VisitorTableName: "visitor_table", IPEncryption: options})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	result, err = stale.IPKeyRotate(ctx)
+	if err != nil || result.Failed != 2 || result.Rotated != 0 {
+		t.Errorf("expected 2 failures without the key, got %+v (%v)", result, err)
+	}
+
+	count, err := store.VisitorCount(ctx, VisitorQuery().SetIPIn([]string{"198.51.100.1"}))
+	if err != nil || count != 1 {
+		t.Errorf("expected the rotated row to be found by IP, got %d (%v)", count, err)
+	}
+}
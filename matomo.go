@@ -0,0 +1,324 @@
+package statsstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// == MATOMO HANDLER ===========================================================
+
+const (
+	// MatomoMaxBodyBytes caps the size of a (bulk) tracking request body.
+	MatomoMaxBodyBytes = 1 << 20
+
+	// matomoMaxEventPartLength keeps "category/action" within the event name
+	// column.
+	matomoMaxEventPartLength = 59
+)
+
+// Event names recorded for Matomo link tracking.
+const (
+	MatomoEventDownload = EVENT_NAME_DOWNLOAD
+	MatomoEventOutlink  = EVENT_NAME_OUTBOUND_LINK
+)
+
+// transparentGIF is a 1x1 transparent GIF, the default Matomo response.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00,
+	0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00,
+	0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// MatomoHandlerOptions configures NewMatomoHandler.
+type MatomoHandlerOptions struct {
+	// Store receives the page views and events. Required.
+	Store StoreInterface
+
+	// SiteIDs, when not empty, restricts requests to these idsite values.
+	SiteIDs []string
+
+	// AllowedHosts, when not empty, restricts requests to page URLs on these
+	// hosts (e.g. "example.com").
+	AllowedHosts []string
+}
+
+// NewMatomoHandler returns an http.Handler that understands the Matomo
+// (Piwik) HTTP Tracking API, so legacy sites can report to statsstore by
+// pointing their tracker URL (matomo.php / piwik.php) at this handler.
+//
+// Parameters are read from the query string or a form-encoded POST body.
+// A JSON POST body of the form {"requests": ["?idsite=1&rec=1&url=...", ...]}
+// is a bulk request and is answered with a JSON summary.
+//
+//   - action_name / url / urlref / res / _id: a page view, recorded via
+//     VisitorRegisterHit with the title, referrer, screen size and the Matomo
+//     visitor ID as fingerprint
+//   - e_c / e_a / e_n / e_v: an event named "category/action", recorded via
+//     EventRegister with the category, action, name and value as props
+//   - download / link: a MatomoEventDownload or MatomoEventOutlink event
+//
+// ua overrides the request's User-Agent header.
+// Requests without rec=1 and heartbeat pings (ping=1) are accepted and
+// ignored, as Matomo does.
+func NewMatomoHandler(opts MatomoHandlerOptions) http.Handler {
+	return &matomoHandler{opts: opts}
+}
+
+type matomoHandler struct {
+	opts MatomoHandlerOptions
+}
+
+// matomoError is a validation error, reported as 400.
+type matomoError string
+
+func (e matomoError) Error() string { return string(e) }
+
+// ServeHTTP implements http.Handler.
+func (h *matomoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if h.opts.Store == nil {
+		http.Error(w, "matomo: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.serveSingle(w, r, r.URL.Query())
+	case http.MethodPost:
+		h.servePost(w, r)
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *matomoHandler) servePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MatomoMaxBodyBytes))
+	if err != nil {
+		http.Error(w, "matomo: payload too large", http.StatusBadRequest)
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		h.serveBulk(w, r, trimmed)
+		return
+	}
+
+	params := r.URL.Query()
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "matomo: invalid form body", http.StatusBadRequest)
+		return
+	}
+	for key, values := range form {
+		params[key] = values
+	}
+
+	h.serveSingle(w, r, params)
+}
+
+func (h *matomoHandler) serveSingle(w http.ResponseWriter, r *http.Request, params url.Values) {
+	if err := h.track(r, params); err != nil {
+		var badRequest matomoError
+		if errors.As(err, &badRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "matomo: failed to record request", http.StatusInternalServerError)
+		return
+	}
+
+	if params.Get("send_image") == "0" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(transparentGIF)
+}
+
+// matomoBulkResponse mirrors the summary Matomo returns for bulk requests.
+type matomoBulkResponse struct {
+	Status         string `json:"status"`
+	Tracked        int    `json:"tracked"`
+	Invalid        int    `json:"invalid"`
+	InvalidIndices []int  `json:"invalid_indices"`
+}
+
+// serveBulk tracks each request of a bulk body. Invalid requests are counted
+// and skipped; a storage error aborts the batch.
+func (h *matomoHandler) serveBulk(w http.ResponseWriter, r *http.Request, body []byte) {
+	bulk := struct {
+		Requests []string `json:"requests"`
+	}{}
+	if err := json.Unmarshal(body, &bulk); err != nil {
+		http.Error(w, "matomo: invalid bulk payload", http.StatusBadRequest)
+		return
+	}
+
+	response := matomoBulkResponse{Status: "success", InvalidIndices: []int{}}
+	for i, request := range bulk.Requests {
+		params, err := url.ParseQuery(strings.TrimPrefix(request, "?"))
+		if err != nil {
+			response.Invalid++
+			response.InvalidIndices = append(response.InvalidIndices, i)
+			continue
+		}
+
+		var badRequest matomoError
+		switch err := h.track(r, params); {
+		case err == nil:
+			response.Tracked++
+		case errors.As(err, &badRequest):
+			response.Invalid++
+			response.InvalidIndices = append(response.InvalidIndices, i)
+		default:
+			http.Error(w, "matomo: failed to record request", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// track validates a single Matomo request and records it.
+func (h *matomoHandler) track(r *http.Request, params url.Values) error {
+	if params.Get("rec") != "1" || params.Get("ping") == "1" {
+		return nil
+	}
+
+	siteID := strings.TrimSpace(params.Get("idsite"))
+	if siteID == "" {
+		return matomoError("matomo: idsite is required")
+	}
+	if len(h.opts.SiteIDs) > 0 && !slices.Contains(h.opts.SiteIDs, siteID) {
+		return matomoError("matomo: idsite not allowed")
+	}
+
+	pageURL, err := url.Parse(params.Get("url"))
+	if err != nil || pageURL.Host == "" {
+		return matomoError("matomo: invalid url")
+	}
+	if len(h.opts.AllowedHosts) > 0 && !slices.Contains(h.opts.AllowedHosts, pageURL.Hostname()) {
+		return matomoError("matomo: host not allowed")
+	}
+
+	path := pageURL.Path
+	if path == "" {
+		path = "/"
+	}
+	referrer := strings.TrimSpace(params.Get("urlref"))
+	fingerprint := matomoVisitorID(params.Get("_id"))
+	r = matomoRequest(r, params)
+
+	if category, action := strings.TrimSpace(params.Get("e_c")), strings.TrimSpace(params.Get("e_a")); category != "" || action != "" {
+		if category == "" || action == "" {
+			return matomoError("matomo: e_c and e_a are both required for events")
+		}
+
+		props := map[string]string{"category": category, "action": action}
+		if name := strings.TrimSpace(params.Get("e_n")); name != "" {
+			props["name"] = truncateRunes(name, plausibleMaxPropValueLength)
+		}
+		if value := strings.TrimSpace(params.Get("e_v")); value != "" {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				props["value"] = value
+			}
+		}
+
+		name := truncateRunes(category, matomoMaxEventPartLength) + "/" + truncateRunes(action, matomoMaxEventPartLength)
+		return h.recordEvent(r, name, pageURL, path, referrer, fingerprint, props)
+	}
+
+	for _, link := range []struct{ param, name string }{
+		{"download", MatomoEventDownload},
+		{"link", MatomoEventOutlink},
+	} {
+		if target := strings.TrimSpace(params.Get(link.param)); target != "" {
+			props := map[string]string{"url": truncateRunes(target, plausibleMaxPropValueLength)}
+			return h.recordEvent(r, link.name, pageURL, path, referrer, fingerprint, props)
+		}
+	}
+
+	hit := PageHit{
+		Path:        path,
+		Referrer:    referrer,
+		Title:       truncateRunes(strings.TrimSpace(params.Get("action_name")), trackerMaxTitleLength),
+		Fingerprint: fingerprint,
+	}
+	hit.ScreenWidth, hit.ScreenHeight = matomoResolution(params.Get("res"))
+
+	_, err = h.opts.Store.VisitorRegisterHit(r.Context(), r, hit)
+	return err
+}
+
+func (h *matomoHandler) recordEvent(r *http.Request, name string, pageURL *url.URL, path, referrer, fingerprint string, props map[string]string) error {
+	event := NewEvent().
+		SetName(name).
+		SetDomain(pageURL.Hostname()).
+		SetPath(path).
+		SetReferrer(referrer).
+		SetFingerprint(fingerprint).
+		SetPropsMap(props)
+
+	_, err := h.opts.Store.EventRegister(r.Context(), r, event)
+	return err
+}
+
+// matomoRequest applies the ua override to a copy of r.
+func matomoRequest(r *http.Request, params url.Values) *http.Request {
+	ua := params.Get("ua")
+	if ua == "" {
+		return r
+	}
+
+	clone := r.Clone(r.Context())
+	clone.Header.Set("User-Agent", ua)
+	return clone
+}
+
+// matomoVisitorID returns the Matomo visitor ID (_id, 16 hexadecimal
+// characters) in lower case, or an empty string when it is invalid.
+func matomoVisitorID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if len(id) != 16 {
+		return ""
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+	return id
+}
+
+// matomoResolution parses res ("1920x1080"), returning zeros when invalid.
+func matomoResolution(res string) (int, int) {
+	widthText, heightText, ok := strings.Cut(strings.ToLower(res), "x")
+	if !ok {
+		return 0, 0
+	}
+	width, err1 := strconv.Atoi(widthText)
+	height, err2 := strconv.Atoi(heightText)
+	if err1 != nil || err2 != nil ||
+		width <= 0 || width > trackerMaxScreenDimension ||
+		height <= 0 || height > trackerMaxScreenDimension {
+		return 0, 0
+	}
+	return width, height
+}
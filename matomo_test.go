@@ -0,0 +1,185 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const matomoTestUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+func serveMatomo(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	r.Header.Set("User-Agent", matomoTestUA)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestMatomoHandlerPageview(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewMatomoHandler(MatomoHandlerOptions{Store: store, SiteIDs: []string{"1"}})
+
+	params := url.Values{
+		"idsite":      {"1"},
+		"rec":         {"1"},
+		"url":         {"https://example.com/pricing?ref=nav"},
+		"urlref":      {"https://www.google.com/"},
+		"action_name": {"Pricing"},
+		"_id":         {"0123456789ABCDEF"},
+		"res":         {"1920x1080"},
+	}
+	w := serveMatomo(handler, http.MethodGet, "/matomo.php?"+params.Encode(), "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("expected GIF response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+	v := list[0]
+	if v.GetPath() != "/pricing" || v.GetPageTitle() != "Pricing" || v.GetScreenSize() != "1920x1080" {
+		t.Errorf("unexpected visitor: path=%q title=%q screen=%q", v.GetPath(), v.GetPageTitle(), v.GetScreenSize())
+	}
+	if v.GetUserReferrer() != "https://www.google.com/" {
+		t.Errorf("referrer = %q", v.GetUserReferrer())
+	}
+	if v.GetFingerprint() != "0123456789abcdef" {
+		t.Errorf("fingerprint = %q, want Matomo visitor id", v.GetFingerprint())
+	}
+
+	// Requests without rec=1 and pings are ignored.
+	w = serveMatomo(handler, http.MethodGet, "/matomo.php?idsite=1&url=https://example.com/&send_image=0", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if count, _ := store.VisitorCount(context.Background(), VisitorQuery()); count != 1 {
+		t.Errorf("expected ignored request not to be stored, got %d visitors", count)
+	}
+}
+
+func TestMatomoHandlerEvents(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewMatomoHandler(MatomoHandlerOptions{Store: store})
+
+	form := url.Values{
+		"idsite": {"1"},
+		"rec":    {"1"},
+		"url":    {"https://example.com/videos"},
+		"e_c":    {"Videos"},
+		"e_a":    {"Play"},
+		"e_n":    {"Intro"},
+		"e_v":    {"12.5"},
+		"_id":    {"0123456789abcdef"},
+	}
+	w := serveMatomo(handler, http.MethodPost, "/matomo.php", form.Encode())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	download := "/matomo.php?idsite=1&rec=1&url=" + url.QueryEscape("https://example.com/docs") +
+		"&download=" + url.QueryEscape("https://example.com/files/report.pdf")
+	if w := serveMatomo(handler, http.MethodGet, download, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	events, err := store.EventList(context.Background(), EventQuery().SetName("Videos/Play"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	props := events[0].GetPropsMap()
+	if props["category"] != "Videos" || props["action"] != "Play" || props["name"] != "Intro" || props["value"] != "12.5" {
+		t.Errorf("unexpected props: %v", props)
+	}
+	if events[0].GetFingerprint() != "0123456789abcdef" || events[0].GetDomain() != "example.com" {
+		t.Errorf("unexpected event: fingerprint=%q domain=%q", events[0].GetFingerprint(), events[0].GetDomain())
+	}
+
+	downloads, err := store.EventList(context.Background(), EventQuery().SetName(MatomoEventDownload))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(downloads) != 1 || downloads[0].GetPropsMap()["url"] != "https://example.com/files/report.pdf" {
+		t.Errorf("unexpected downloads: %+v", downloads)
+	}
+
+	if count, _ := store.VisitorCount(context.Background(), VisitorQuery()); count != 0 {
+		t.Errorf("expected events not to create visitors, got %d", count)
+	}
+}
+
+func TestMatomoHandlerBulk(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewMatomoHandler(MatomoHandlerOptions{Store: store, SiteIDs: []string{"1"}})
+
+	body := `{"requests": [
+		"?idsite=1&rec=1&url=https%3A%2F%2Fexample.com%2Fa&action_name=A",
+		"?idsite=1&rec=1&url=https%3A%2F%2Fexample.com%2Fb&ua=Mozilla%2F5.0%20(iPhone%3B%20CPU%20iPhone%20OS%2017_2%20like%20Mac%20OS%20X)",
+		"?idsite=2&rec=1&url=https%3A%2F%2Fexample.com%2Fc",
+		"?idsite=1&rec=1&url=https%3A%2F%2Fexample.com%2Fd&e_c=Form",
+		"%zz"
+	]}`
+	w := serveMatomo(handler, http.MethodPost, "/matomo.php", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	response := matomoBulkResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if response.Status != "success" || response.Tracked != 2 || response.Invalid != 3 {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	if len(response.InvalidIndices) != 3 || response.InvalidIndices[0] != 2 {
+		t.Errorf("unexpected invalid indices: %v", response.InvalidIndices)
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery().SetPathExact("/b"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 || list[0].GetUserOs() != "iOS" {
+		t.Errorf("expected ua override to apply, got %+v", list)
+	}
+}
+
+func TestMatomoResolution(t *testing.T) {
+	tests := map[string][2]int{
+		"1920x1080": {1920, 1080},
+		"390X844":   {390, 844},
+		"0x0":       {0, 0},
+		"abc":       {0, 0},
+		"":          {0, 0},
+	}
+	for in, want := range tests {
+		if w, h := matomoResolution(in); w != want[0] || h != want[1] {
+			t.Errorf("matomoResolution(%q) = %d, %d, want %v", in, w, h, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package statsstore
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// == PIXEL HANDLER ============================================================
+
+// pixelMaxCampaignLength matches the campaign column size.
+const pixelMaxCampaignLength = 120
+
+// PixelHandlerOptions configures NewPixelHandler.
+type PixelHandlerOptions struct {
+	// Store receives the page views and events. Required.
+	Store StoreInterface
+
+	// Secret, when set, requires every request to carry a sig parameter
+	// created with PixelSignature (or SignPixelURL), so hits cannot be forged
+	// by editing the URL.
+	Secret []byte
+
+	// AllowedHosts, when not empty, restricts the url parameter to page URLs
+	// on these hosts (e.g. "example.com").
+	AllowedHosts []string
+}
+
+// NewPixelHandler returns an http.Handler serving a 1x1 transparent GIF that
+// records a hit, for HTML emails and AMP or no-JS pages where the JavaScript
+// tracker cannot run:
+//
+//	<img src="/stats/pixel.gif?path=/newsletter/42&campaign=nl-42&sig=..." alt="">
+//
+// Parameters:
+//
+//   - url: the page URL; its path is used when path is not set
+//   - path: the page path; defaults to the path of url, then of the Referer
+//     header, then "/"
+//   - ref: the referrer
+//   - title: the page title
+//   - event: records a named event (e.g. "Email Open") via EventRegister
+//     instead of a page view via VisitorRegisterHit
+//   - campaign: a campaign or email ID, stored with the page view or event
+//   - sig: the signature, required when opts.Secret is set
+//
+// The IP address and user agent come from the request, as for any other hit.
+// Responses carry headers that stop browsers, proxies and email clients from
+// caching the image, so each open reaches the server.
+func NewPixelHandler(opts PixelHandlerOptions) http.Handler {
+	return &pixelHandler{opts: opts}
+}
+
+type pixelHandler struct {
+	opts PixelHandlerOptions
+}
+
+// pixelError is a validation error, reported as 400.
+type pixelError string
+
+func (e pixelError) Error() string { return string(e) }
+
+// ServeHTTP implements http.Handler.
+func (h *pixelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Store == nil {
+		http.Error(w, "pixel: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	if len(h.opts.Secret) > 0 && !VerifyPixelSignature(h.opts.Secret, params) {
+		http.Error(w, "pixel: invalid signature", http.StatusForbidden)
+		return
+	}
+
+	// HEAD requests (e.g. link checkers and email security scanners) are
+	// answered without recording a hit.
+	if r.Method == http.MethodGet {
+		if err := h.track(r, params); err != nil {
+			var badRequest pixelError
+			if errors.As(err, &badRequest) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "pixel: failed to record hit", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "image/gif")
+	header.Set("Cache-Control", "no-cache, no-store, must-revalidate, private, max-age=0")
+	header.Set("Pragma", "no-cache")
+	header.Set("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
+	_, _ = w.Write(transparentGIF)
+}
+
+// track validates the pixel parameters and records the page view or event.
+func (h *pixelHandler) track(r *http.Request, params url.Values) error {
+	var pageURL *url.URL
+	if raw := strings.TrimSpace(params.Get("url")); raw != "" {
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Host == "" {
+			return pixelError("pixel: invalid url")
+		}
+		pageURL = parsed
+	}
+	if pageURL != nil && len(h.opts.AllowedHosts) > 0 && !slices.Contains(h.opts.AllowedHosts, pageURL.Hostname()) {
+		return pixelError("pixel: host not allowed")
+	}
+
+	path := strings.TrimSpace(params.Get("path"))
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return pixelError("pixel: path must start with /")
+	}
+	if path == "" && pageURL != nil {
+		path = pageURL.Path
+	}
+	if path == "" {
+		if u := ParseReferrerURL(r.Referer()); u != nil {
+			path = u.Path
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	campaign := truncateRunes(strings.TrimSpace(params.Get("campaign")), pixelMaxCampaignLength)
+	referrer := strings.TrimSpace(params.Get("ref"))
+
+	if name := strings.TrimSpace(params.Get("event")); name != "" && name != EVENT_NAME_PAGEVIEW {
+		if len(name) > eventMaxNameLength {
+			return pixelError("pixel: event name is too long")
+		}
+
+		domain := ""
+		if pageURL != nil {
+			domain = pageURL.Hostname()
+		}
+
+		event := NewEvent().
+			SetName(name).
+			SetDomain(domain).
+			SetPath(path).
+			SetReferrer(referrer).
+			SetCampaign(campaign)
+
+		_, err := h.opts.Store.EventRegister(r.Context(), r, event)
+		return err
+	}
+
+	hit := PageHit{
+		Path:     path,
+		Referrer: referrer,
+		Title:    truncateRunes(strings.TrimSpace(params.Get("title")), trackerMaxTitleLength),
+		Campaign: campaign,
+	}
+
+	_, err := h.opts.Store.VisitorRegisterHit(r.Context(), r, hit)
+	return err
+}
+
+// == SIGNATURES ===============================================================
+
+// PixelSignature returns the signature of the pixel parameters: the
+// unpadded base64url HMAC-SHA256 of params in their canonical (sorted)
+// encoding, without any sig parameter.
+func PixelSignature(secret []byte, params url.Values) string {
+	return paramsSignature(secret, params)
+}
+
+// VerifyPixelSignature reports whether params carry a valid sig parameter
+// for secret.
+func VerifyPixelSignature(secret []byte, params url.Values) bool {
+	return verifyParamsSignature(secret, params)
+}
+
+// SignPixelURL returns the pixel URL for base (e.g.
+// "https://example.com/stats/pixel.gif", without a query string) with params
+// and their signature in the query string.
+func SignPixelURL(secret []byte, base string, params url.Values) string {
+	return base + "?" + signParams(secret, params).Encode()
+}
@@ -0,0 +1,174 @@
+package statsstore
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func servePixel(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("User-Agent", matomoTestUA)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestPixelHandlerPageview(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPixelHandler(PixelHandlerOptions{Store: store})
+
+	params := url.Values{
+		"url":      {"https://example.com/amp/article?x=1"},
+		"title":    {"Article"},
+		"ref":      {"https://news.example.org/"},
+		"campaign": {"spring-sale"},
+	}
+	w := servePixel(handler, http.MethodGet, "/pixel.gif?"+params.Encode())
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("expected GIF response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.Equal(w.Body.Bytes(), transparentGIF) {
+		t.Error("expected the transparent GIF body")
+	}
+	if w.Header().Get("Cache-Control") != "no-cache, no-store, must-revalidate, private, max-age=0" {
+		t.Errorf("unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+	if w.Header().Get("Pragma") != "no-cache" || w.Header().Get("Expires") == "" {
+		t.Error("expected Pragma and Expires cache-busting headers")
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+	v := list[0]
+	if v.GetPath() != "/amp/article" || v.GetPageTitle() != "Article" || v.GetCampaign() != "spring-sale" {
+		t.Errorf("unexpected visitor: path=%q title=%q campaign=%q", v.GetPath(), v.GetPageTitle(), v.GetCampaign())
+	}
+	if v.GetUserReferrer() != "https://news.example.org/" {
+		t.Errorf("unexpected referrer %q", v.GetUserReferrer())
+	}
+}
+
+func TestPixelHandlerEvent(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPixelHandler(PixelHandlerOptions{Store: store})
+
+	params := url.Values{
+		"event":    {"Email Open"},
+		"path":     {"/newsletter/42"},
+		"campaign": {"nl-42"},
+	}
+	w := servePixel(handler, http.MethodGet, "/pixel.gif?"+params.Encode())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	events, err := store.EventList(context.Background(), EventQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.GetName() != "Email Open" || e.GetPath() != "/newsletter/42" || e.GetCampaign() != "nl-42" {
+		t.Errorf("unexpected event: name=%q path=%q campaign=%q", e.GetName(), e.GetPath(), e.GetCampaign())
+	}
+	if e.GetFingerprint() == "" {
+		t.Error("expected a fingerprint from the request")
+	}
+
+	count, err := store.VisitorCount(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no page view for an event, got %d", count)
+	}
+}
+
+func TestPixelHandlerSignature(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	secret := []byte("pixel-secret")
+	handler := NewPixelHandler(PixelHandlerOptions{Store: store, Secret: secret})
+
+	params := url.Values{"path": {"/welcome"}, "campaign": {"onboarding-1"}}
+	signed := SignPixelURL(secret, "/pixel.gif", params)
+
+	if w := servePixel(handler, http.MethodGet, signed); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a signed URL, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tampered := url.Values{"path": {"/welcome"}, "campaign": {"onboarding-2"}}
+	tampered.Set("sig", PixelSignature(secret, params))
+	forged := []string{
+		"/pixel.gif?" + params.Encode(),
+		"/pixel.gif?" + tampered.Encode(),
+		SignPixelURL([]byte("other-secret"), "/pixel.gif", params),
+	}
+	for _, target := range forged {
+		if w := servePixel(handler, http.MethodGet, target); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", target, w.Code)
+		}
+	}
+
+	count, err := store.VisitorCount(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 1 {
+		t.Errorf("expected only the signed hit to be recorded, got %d", count)
+	}
+}
+
+func TestPixelHandlerInvalid(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPixelHandler(PixelHandlerOptions{Store: store, AllowedHosts: []string{"example.com"}})
+
+	cases := map[string]string{
+		"invalid url":      "/pixel.gif?url=not-a-url",
+		"host not allowed": "/pixel.gif?url=" + url.QueryEscape("https://evil.example.net/"),
+		"relative path":    "/pixel.gif?path=welcome",
+	}
+	for name, target := range cases {
+		if w := servePixel(handler, http.MethodGet, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	if w := servePixel(handler, http.MethodPost, "/pixel.gif"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", w.Code)
+	}
+
+	if w := servePixel(handler, http.MethodHead, "/pixel.gif?path=/"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for HEAD, got %d", w.Code)
+	}
+
+	count, err := store.VisitorCount(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no hits recorded, got %d", count)
+	}
+}
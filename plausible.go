@@ -0,0 +1,326 @@
+package statsstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// == PLAUSIBLE HANDLER ========================================================
+
+const (
+	// plausibleMaxProps, plausibleMaxPropKeyLength and
+	// plausibleMaxPropValueLength mirror the limits Plausible applies to
+	// custom properties.
+	plausibleMaxProps           = 30
+	plausibleMaxPropKeyLength   = 300
+	plausibleMaxPropValueLength = 2000
+)
+
+// PlausibleHandlerOptions configures NewPlausibleHandler.
+type PlausibleHandlerOptions struct {
+	// Store receives the page views and custom events. Required.
+	Store StoreInterface
+
+	// AllowedDomains, when not empty, restricts events to these site domains
+	// (the "domain" field, e.g. "example.com"). Plausible allows a
+	// comma-separated list; the event is accepted when any of them matches.
+	AllowedDomains []string
+}
+
+// plausiblePayload is the Plausible Events API body. The Plausible script
+// sends the short keys (n, u, d, r, p, $, h); the documented API uses the
+// long ones. Both are accepted.
+type plausiblePayload struct {
+	Name        string          `json:"name"`
+	URL         string          `json:"url"`
+	Domain      string          `json:"domain"`
+	Referrer    *string         `json:"referrer"`
+	Props       json.RawMessage `json:"props"`
+	Revenue     json.RawMessage `json:"revenue"`
+	ShortName   string          `json:"n"`
+	ShortURL    string          `json:"u"`
+	ShortDomain string          `json:"d"`
+	ShortRef    *string         `json:"r"`
+	ShortProps  json.RawMessage `json:"p"`
+	ShortRev    json.RawMessage `json:"$"`
+	HashMode    json.RawMessage `json:"h"`
+}
+
+// normalize folds the short keys into the long ones.
+func (p *plausiblePayload) normalize() {
+	if p.Name == "" {
+		p.Name = p.ShortName
+	}
+	if p.URL == "" {
+		p.URL = p.ShortURL
+	}
+	if p.Domain == "" {
+		p.Domain = p.ShortDomain
+	}
+	if p.Referrer == nil {
+		p.Referrer = p.ShortRef
+	}
+	if len(p.Props) == 0 {
+		p.Props = p.ShortProps
+	}
+	if len(p.Revenue) == 0 {
+		p.Revenue = p.ShortRev
+	}
+}
+
+// NewPlausibleHandler returns an http.Handler that accepts the Plausible
+// Events API (POST /api/event), so sites embedding the Plausible script can
+// report to statsstore by pointing its data-api attribute at this handler.
+//
+// "pageview" events are recorded as visitors via VisitorRegisterHit; any
+// other name is recorded as a custom event via EventRegister, with its props
+// and revenue. Both go through the store's filtering. Custom props on page
+// views are not stored.
+//
+//	mux.Handle("/api/event", statsstore.NewPlausibleHandler(statsstore.PlausibleHandlerOptions{Store: store}))
+//	<script defer data-domain="example.com" data-api="/api/event" src="/js/script.js"></script>
+func NewPlausibleHandler(opts PlausibleHandlerOptions) http.Handler {
+	return &plausibleHandler{opts: opts}
+}
+
+type plausibleHandler struct {
+	opts PlausibleHandlerOptions
+}
+
+// ServeHTTP implements http.Handler.
+func (h *plausibleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The script usually posts cross-origin as text/plain, which needs no
+	// preflight; answer one anyway for clients that send it.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.Method {
+	case http.MethodPost:
+		h.collect(w, r)
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *plausibleHandler) collect(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Store == nil {
+		http.Error(w, "plausible: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, TrackerMaxBodyBytes))
+	if err != nil {
+		http.Error(w, "plausible: payload too large", http.StatusBadRequest)
+		return
+	}
+
+	payload := plausiblePayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "plausible: invalid payload", http.StatusBadRequest)
+		return
+	}
+	payload.normalize()
+
+	if err := h.record(r, payload); err != nil {
+		var badRequest plausibleError
+		if errors.As(err, &badRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "plausible: failed to record event", http.StatusInternalServerError)
+		return
+	}
+
+	// Plausible answers 202 with "ok", including for filtered events.
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = io.WriteString(w, "ok")
+}
+
+// plausibleError is a validation error, reported as 400.
+type plausibleError string
+
+func (e plausibleError) Error() string { return string(e) }
+
+// record validates payload and stores it as a page view or custom event.
+func (h *plausibleHandler) record(r *http.Request, payload plausiblePayload) error {
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		return plausibleError("plausible: name is required")
+	}
+	if len(name) > eventMaxNameLength {
+		return plausibleError("plausible: name is too long")
+	}
+
+	domain := strings.TrimSpace(payload.Domain)
+	if domain == "" {
+		return plausibleError("plausible: domain is required")
+	}
+	if !h.domainAllowed(domain) {
+		return plausibleError("plausible: domain not allowed")
+	}
+
+	pageURL, err := url.Parse(payload.URL)
+	if err != nil || pageURL.Host == "" {
+		return plausibleError("plausible: invalid url")
+	}
+
+	path := pageURL.Path
+	if path == "" {
+		path = "/"
+	}
+	if plausibleHashMode(payload.HashMode) && pageURL.Fragment != "" {
+		path += "#" + pageURL.Fragment
+	}
+
+	referrer := ""
+	if payload.Referrer != nil {
+		referrer = strings.TrimSpace(*payload.Referrer)
+	}
+
+	if name == EVENT_NAME_PAGEVIEW {
+		_, err := h.opts.Store.VisitorRegisterHit(r.Context(), r, PageHit{
+			Path:     path,
+			Referrer: referrer,
+		})
+		return err
+	}
+
+	props, err := parsePlausibleProps(payload.Props)
+	if err != nil {
+		return err
+	}
+
+	event := NewEvent().
+		SetName(name).
+		SetDomain(domain).
+		SetPath(path).
+		SetReferrer(referrer).
+		SetPropsMap(props)
+
+	if len(payload.Revenue) > 0 && string(payload.Revenue) != "null" {
+		amount, currency, err := parsePlausibleRevenue(payload.Revenue)
+		if err != nil {
+			return err
+		}
+		event.SetRevenueAmount(amount).SetRevenueCurrency(currency)
+	}
+
+	_, err = h.opts.Store.EventRegister(r.Context(), r, event)
+	return err
+}
+
+// domainAllowed reports whether any domain in the comma-separated list is
+// allowed.
+func (h *plausibleHandler) domainAllowed(domains string) bool {
+	if len(h.opts.AllowedDomains) == 0 {
+		return true
+	}
+	for _, domain := range strings.Split(domains, ",") {
+		if slices.Contains(h.opts.AllowedDomains, strings.TrimSpace(domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// plausibleHashMode reports whether the script runs in hash-based routing
+// mode ("h": 1 or true), in which case the URL fragment is part of the path.
+func plausibleHashMode(raw json.RawMessage) bool {
+	value := strings.TrimSpace(string(raw))
+	return value == "1" || value == "true"
+}
+
+// parsePlausibleProps decodes custom properties. Plausible sends them either
+// as an object or as a JSON-encoded string of an object. Scalar values are
+// converted to strings; nested values are dropped.
+func parsePlausibleProps(raw json.RawMessage) (map[string]string, error) {
+	props := map[string]string{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return props, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if encoded == "" {
+			return props, nil
+		}
+		raw = json.RawMessage(encoded)
+	}
+
+	values := map[string]any{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, plausibleError("plausible: props must be an object")
+	}
+
+	// Keys are visited in sorted order so the props kept over the cap are
+	// the same on every request.
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, rawKey := range keys {
+		if len(props) >= plausibleMaxProps {
+			break
+		}
+		value := values[rawKey]
+		key := strings.TrimSpace(rawKey)
+		if key == "" || len(key) > plausibleMaxPropKeyLength {
+			continue
+		}
+
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		case bool:
+			text = strconv.FormatBool(v)
+		default:
+			continue
+		}
+		props[key] = truncateRunes(text, plausibleMaxPropValueLength)
+	}
+
+	return props, nil
+}
+
+// parsePlausibleRevenue decodes {"currency": "USD", "amount": 19.99}. The
+// amount may be a number or a numeric string.
+func parsePlausibleRevenue(raw json.RawMessage) (string, string, error) {
+	revenue := struct {
+		Currency string          `json:"currency"`
+		Amount   json.RawMessage `json:"amount"`
+	}{}
+	if err := json.Unmarshal(raw, &revenue); err != nil {
+		return "", "", plausibleError("plausible: invalid revenue")
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(revenue.Currency))
+	if len(currency) != 3 {
+		return "", "", plausibleError("plausible: invalid revenue currency")
+	}
+
+	amount := strings.Trim(strings.TrimSpace(string(revenue.Amount)), `"`)
+	if value, err := strconv.ParseFloat(amount, 64); err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", "", plausibleError("plausible: invalid revenue amount")
+	}
+
+	return amount, currency, nil
+}
@@ -0,0 +1,159 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postPlausible(t *testing.T, handler http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/event", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestPlausibleHandlerPageview(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPlausibleHandler(PlausibleHandlerOptions{Store: store})
+
+	w := postPlausible(t, handler, `{"n":"pageview","u":"https://example.com/app#/settings","d":"example.com","r":"https://news.ycombinator.com/","h":1}`)
+	if w.Code != http.StatusAccepted || w.Body.String() != "ok" {
+		t.Fatalf("expected 202 ok, got %d: %s", w.Code, w.Body.String())
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+	if list[0].GetPath() != "/app#/settings" {
+		t.Errorf("path = %q, want /app#/settings", list[0].GetPath())
+	}
+	if list[0].GetUserReferrer() != "https://news.ycombinator.com/" {
+		t.Errorf("referrer = %q", list[0].GetUserReferrer())
+	}
+
+	if count, _ := store.EventCount(context.Background(), EventQuery()); count != 0 {
+		t.Errorf("expected page views not to be stored as events, got %d", count)
+	}
+}
+
+func TestPlausibleHandlerCustomEvent(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPlausibleHandler(PlausibleHandlerOptions{Store: store, AllowedDomains: []string{"example.com"}})
+
+	// Events API long keys, object props and numeric revenue.
+	w := postPlausible(t, handler, `{"name":"Purchase","url":"https://example.com/checkout?step=3","domain":"example.com","props":{"plan":"pro","seats":5,"trial":false,"nested":{"a":1}},"revenue":{"currency":"eur","amount":29.5}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Script short keys with props encoded as a JSON string.
+	w = postPlausible(t, handler, `{"n":"Download","u":"https://example.com/files","d":"example.com","r":null,"p":"{\"file\":\"report.pdf\"}","$":{"currency":"USD","amount":"0"}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	purchases, err := store.EventList(context.Background(), EventQuery().SetName("Purchase"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(purchases) != 1 {
+		t.Fatalf("expected 1 purchase, got %d", len(purchases))
+	}
+	purchase := purchases[0]
+	props := purchase.GetPropsMap()
+	if props["plan"] != "pro" || props["seats"] != "5" || props["trial"] != "false" {
+		t.Errorf("unexpected props: %v", props)
+	}
+	if _, ok := props["nested"]; ok {
+		t.Error("expected nested prop to be dropped")
+	}
+	if purchase.GetRevenueAmount() != "29.5" || purchase.GetRevenueCurrency() != "EUR" {
+		t.Errorf("revenue = %s %s, want 29.5 EUR", purchase.GetRevenueAmount(), purchase.GetRevenueCurrency())
+	}
+	if purchase.GetPath() != "/checkout" || purchase.GetDomain() != "example.com" {
+		t.Errorf("unexpected path/domain: %s %s", purchase.GetPath(), purchase.GetDomain())
+	}
+
+	downloads, err := store.EventList(context.Background(), EventQuery().SetName("Download"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(downloads) != 1 || downloads[0].GetPropsMap()["file"] != "report.pdf" {
+		t.Errorf("unexpected downloads: %+v", downloads)
+	}
+}
+
+func TestPlausibleHandlerRejectsInvalid(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewPlausibleHandler(PlausibleHandlerOptions{Store: store, AllowedDomains: []string{"example.com"}})
+
+	bodies := map[string]string{
+		"not json":       `nope`,
+		"missing name":   `{"u":"https://example.com/","d":"example.com"}`,
+		"missing domain": `{"n":"pageview","u":"https://example.com/"}`,
+		"other domain":   `{"n":"pageview","u":"https://evil.test/","d":"evil.test"}`,
+		"relative url":   `{"n":"pageview","u":"/about","d":"example.com"}`,
+		"bad props":      `{"n":"Signup","u":"https://example.com/","d":"example.com","p":[1,2]}`,
+		"bad currency":   `{"n":"Signup","u":"https://example.com/","d":"example.com","$":{"currency":"dollars","amount":1}}`,
+		"bad amount":     `{"n":"Signup","u":"https://example.com/","d":"example.com","$":{"currency":"USD","amount":"abc"}}`,
+	}
+	for name, body := range bodies {
+		if w := postPlausible(t, handler, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/event", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestParsePlausiblePropsCapIsStable(t *testing.T) {
+	values := map[string]int{}
+	for i := range plausibleMaxProps + 10 {
+		values[fmt.Sprintf("p%02d", i)] = i
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for range 5 {
+		props, err := parsePlausibleProps(raw)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if len(props) != plausibleMaxProps {
+			t.Fatalf("expected %d props, got %d", plausibleMaxProps, len(props))
+		}
+		if _, ok := props["p00"]; !ok {
+			t.Errorf("expected the first keys in order to be kept, got %v", props)
+		}
+		if _, ok := props[fmt.Sprintf("p%02d", plausibleMaxProps)]; ok {
+			t.Errorf("expected keys past the cap to be dropped, got %v", props)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// == CONSTANTS ================================================================
+
+// Privacy reasons stored in the privacy column of visits recorded aggregate
+// only. Visits stored with their identifying fields have an empty reason.
+const (
+	PrivacyReasonDoNotTrack           = "dnt"
+	PrivacyReasonGlobalPrivacyControl = "gpc"
+	PrivacyReasonNoConsent            = "no_consent"
+	PrivacyReasonCookieless           = "cookieless"
+	PrivacyReasonErased               = "erased"
+)
+
+// PrivacyReasons lists the privacy reasons in report order.
+var PrivacyReasons = []string{
+	PrivacyReasonDoNotTrack,
+	PrivacyReasonGlobalPrivacyControl,
+	PrivacyReasonNoConsent,
+	PrivacyReasonCookieless,
+	PrivacyReasonErased,
+}
+
+// == TYPES ====================================================================
+
+// PrivacyOptions configures how VisitorRegister, VisitorRegisterHit and
+// EventRegister respect the privacy signals and consent of visitors. The
+// zero value records every request with its identifying fields.
+//
+// A visit recorded "aggregate only" keeps its path, referrer, campaign,
+// country and the browser, OS and device type parsed from the user agent,
+// but is stored without IP address, user agent, client hints, fingerprint
+// or user ID. Such visits count as page views, not as unique visitors.
+type PrivacyOptions struct {
+	// HonorDoNotTrack treats requests sending "DNT: 1" as opted out.
+	HonorDoNotTrack bool `json:"honor_do_not_track"`
+
+	// HonorGlobalPrivacyControl treats requests sending "Sec-GPC: 1" as
+	// opted out.
+	HonorGlobalPrivacyControl bool `json:"honor_global_privacy_control"`
+
+	// OptOutSkip drops opted-out requests instead of recording them
+	// aggregate only.
+	OptOutSkip bool `json:"opt_out_skip"`
+
+	// ConsentCookie and ConsentFunc require consent before identifying
+	// fields are stored; requests without it are recorded aggregate only.
+	// With ConsentCookie set, consent is given by a cookie of that name
+	// whose value is not empty, "0", "false", "no" or "denied". ConsentFunc,
+	// when set, decides instead.
+	ConsentCookie string                     `json:"consent_cookie"`
+	ConsentFunc   func(r *http.Request) bool `json:"-"`
+
+	// Cookieless records every request aggregate only.
+	Cookieless bool `json:"cookieless"`
+}
+
+// ConsentRequired reports whether identifying fields need consent.
+func (o PrivacyOptions) ConsentRequired() bool {
+	return o.ConsentCookie != "" || o.ConsentFunc != nil
+}
+
+// PrivacyReasonCount is the number of visits recorded aggregate only for
+// one privacy reason.
+type PrivacyReasonCount struct {
+	Reason string `json:"reason"`
+	Label  string `json:"label"`
+	Visits int64  `json:"visits"`
+}
+
+// PrivacyReport shows the effect of the privacy options over a period.
+type PrivacyReport struct {
+	Options         PrivacyOptions `json:"options"`
+	ConsentRequired bool           `json:"consent_required"`
+
+	// Visits counts all recorded visits; Identified those stored with
+	// their identifying fields.
+	Visits     int64                `json:"visits"`
+	Identified int64                `json:"identified"`
+	Reasons    []PrivacyReasonCount `json:"reasons"`
+
+	// Skipped counts the opted-out requests dropped by OptOutSkip since the
+	// store was created. It is kept in memory, per process.
+	Skipped int64 `json:"skipped"`
+}
+
+// == PUBLIC FUNCTIONS =========================================================
+
+// PrivacyReasonLabel returns a human-readable label for a privacy reason.
+func PrivacyReasonLabel(reason string) string {
+	switch reason {
+	case PrivacyReasonDoNotTrack:
+		return "Do Not Track"
+	case PrivacyReasonGlobalPrivacyControl:
+		return "Global Privacy Control"
+	case PrivacyReasonNoConsent:
+		return "No consent"
+	case PrivacyReasonCookieless:
+		return "Cookieless mode"
+	case PrivacyReasonErased:
+		return "Erased on request"
+	case "":
+		return "Identified"
+	default:
+		return reason
+	}
+}
+
+// == STORE ====================================================================
+
+// SetPrivacyOptions replaces the privacy options of the store.
+func (st *storeImplementation) SetPrivacyOptions(opts PrivacyOptions) {
+	st.privacyMu.Lock()
+	defer st.privacyMu.Unlock()
+	st.privacyOptions = opts
+}
+
+// GetPrivacyOptions returns the privacy options of the store.
+func (st *storeImplementation) GetPrivacyOptions() PrivacyOptions {
+	st.privacyMu.RLock()
+	defer st.privacyMu.RUnlock()
+	return st.privacyOptions
+}
+
+// PrivacyReport counts the visits recorded between from and to per privacy
+// reason.
+func (st *storeImplementation) PrivacyReport(ctx context.Context, from, to time.Time) (PrivacyReport, error) {
+	opts := st.GetPrivacyOptions()
+	report := PrivacyReport{
+		Options:         opts,
+		ConsentRequired: opts.ConsentRequired(),
+		Reasons:         []PrivacyReasonCount{},
+		Skipped:         st.privacySkipped.Load(),
+	}
+
+	query := func() VisitorQueryInterface {
+		q := VisitorQuery()
+		if !from.IsZero() {
+			q = q.SetCreatedAtGte(from.UTC().Format(time.RFC3339))
+		}
+		if !to.IsZero() {
+			q = q.SetCreatedAtLte(to.UTC().Format(time.RFC3339))
+		}
+		return q
+	}
+
+	visits, err := st.VisitorCount(ctx, query())
+	if err != nil {
+		return PrivacyReport{}, err
+	}
+	report.Visits = visits
+	report.Identified = visits
+
+	for _, reason := range PrivacyReasons {
+		count, err := st.VisitorCount(ctx, query().SetPrivacy(reason))
+		if err != nil {
+			return PrivacyReport{}, err
+		}
+		report.Identified -= count
+		report.Reasons = append(report.Reasons, PrivacyReasonCount{
+			Reason: reason,
+			Label:  PrivacyReasonLabel(reason),
+			Visits: count,
+		})
+	}
+
+	return report, nil
+}
+
+// privacyDecision returns the privacy reason to record request r under,
+// or skip true when the request must not be recorded at all. Opt-out
+// signals take precedence over the cookieless mode and consent.
+func (st *storeImplementation) privacyDecision(r *http.Request) (reason string, skip bool) {
+	opts := st.GetPrivacyOptions()
+
+	switch {
+	case opts.HonorDoNotTrack && strings.TrimSpace(r.Header.Get("DNT")) == "1":
+		reason = PrivacyReasonDoNotTrack
+	case opts.HonorGlobalPrivacyControl && strings.TrimSpace(r.Header.Get("Sec-GPC")) == "1":
+		reason = PrivacyReasonGlobalPrivacyControl
+	}
+	if reason != "" {
+		if opts.OptOutSkip {
+			st.privacySkipped.Add(1)
+			if st.debugEnabled {
+				st.logger.Info("privacy: skipping opted-out request", "reason", reason)
+			}
+			return "", true
+		}
+		return reason, false
+	}
+
+	if opts.Cookieless {
+		return PrivacyReasonCookieless, false
+	}
+
+	if opts.ConsentRequired() && !privacyConsentGiven(r, opts) {
+		return PrivacyReasonNoConsent, false
+	}
+
+	return "", false
+}
+
+// == PRIVATE FUNCTIONS ========================================================
+
+func privacyConsentGiven(r *http.Request, opts PrivacyOptions) bool {
+	if opts.ConsentFunc != nil {
+		return opts.ConsentFunc(r)
+	}
+
+	cookie, err := r.Cookie(opts.ConsentCookie)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(cookie.Value)) {
+	case "", "0", "false", "no", "denied":
+		return false
+	}
+	return true
+}
+
+// privacyAnonymizeVisitor strips the identifying fields of a visit
+// recorded aggregate only. Visits without a country get CountryUnknown, as
+// their IP is never sent to the geo-IP resolver.
+func privacyAnonymizeVisitor(visitor VisitorInterface, reason string) {
+	visitor.
+		SetIpAddress("").
+		SetPeerIpAddress("").
+		SetUserAgent("").
+		SetClientHints("").
+		SetUserDeviceModel("").
+		SetFingerprint("").
+		SetUserID("").
+		SetPrivacy(reason)
+
+	if visitor.GetCountry() == "" {
+		visitor.SetCountry(CountryUnknown)
+	}
+}
+
+// privacyAnonymizeEvent strips the identifiers of an event recorded
+// aggregate only.
+func privacyAnonymizeEvent(event EventInterface) {
+	event.
+		SetFingerprint("").
+		SetUserID("").
+		SetClientID("").
+		SetVisitorID("")
+}
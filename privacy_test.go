@@ -0,0 +1,200 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func privacyTestRequest(headers map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	return r
+}
+
+func TestVisitorRegisterPrivacyModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		options PrivacyOptions
+		headers map[string]string
+		cookie  string
+		want    string
+		skipped bool
+	}{
+		{"default records everything", PrivacyOptions{}, map[string]string{"DNT": "1"}, "", "", false},
+		{"dnt aggregate only", PrivacyOptions{HonorDoNotTrack: true}, map[string]string{"DNT": "1"}, "", PrivacyReasonDoNotTrack, false},
+		{"dnt off", PrivacyOptions{HonorDoNotTrack: true}, map[string]string{"DNT": "0"}, "", "", false},
+		{"gpc aggregate only", PrivacyOptions{HonorGlobalPrivacyControl: true}, map[string]string{"Sec-GPC": "1"}, "", PrivacyReasonGlobalPrivacyControl, false},
+		{"gpc skipped", PrivacyOptions{HonorGlobalPrivacyControl: true, OptOutSkip: true}, map[string]string{"Sec-GPC": "1"}, "", "", true},
+		{"opt-out before cookieless", PrivacyOptions{HonorDoNotTrack: true, Cookieless: true}, map[string]string{"DNT": "1"}, "", PrivacyReasonDoNotTrack, false},
+		{"cookieless", PrivacyOptions{Cookieless: true}, nil, "yes", PrivacyReasonCookieless, false},
+		{"consent missing", PrivacyOptions{ConsentCookie: "analytics"}, nil, "", PrivacyReasonNoConsent, false},
+		{"consent denied", PrivacyOptions{ConsentCookie: "analytics"}, nil, "denied", PrivacyReasonNoConsent, false},
+		{"consent given", PrivacyOptions{ConsentCookie: "analytics"}, nil, "granted", "", false},
+		{"consent callback", PrivacyOptions{ConsentCookie: "analytics", ConsentFunc: func(r *http.Request) bool { return false }}, nil, "granted", PrivacyReasonNoConsent, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := initStore()
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			store.SetPrivacyOptions(tt.options)
+			ctx := context.Background()
+
+			r := privacyTestRequest(tt.headers)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "analytics", Value: tt.cookie})
+			}
+			visitor, err := store.VisitorRegisterHit(WithUserID(ctx, "42"), r, PageHit{Path: "/pricing", Fingerprint: "client-id"})
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if tt.skipped {
+				if visitor != nil {
+					t.Fatalf("expected the request to be skipped, got %+v", visitor)
+				}
+				report, err := store.PrivacyReport(ctx, time.Time{}, time.Time{})
+				if err != nil || report.Skipped != 1 || report.Visits != 0 {
+					t.Errorf("unexpected report: %+v (%v)", report, err)
+				}
+				return
+			}
+
+			visitors, err := store.VisitorList(ctx, VisitorQuery())
+			if err != nil || len(visitors) != 1 {
+				t.Fatalf("expected 1 visitor, got %d (%v)", len(visitors), err)
+			}
+			stored := visitors[0]
+			if stored.GetPrivacy() != tt.want {
+				t.Errorf("privacy = %q, want %q", stored.GetPrivacy(), tt.want)
+			}
+			if stored.GetPath() != "/pricing" || stored.GetUserBrowser() == "" {
+				t.Errorf("expected aggregate fields to be kept, got path %q browser %q", stored.GetPath(), stored.GetUserBrowser())
+			}
+
+			anonymous := stored.GetIpAddress() == "" && stored.GetUserAgent() == "" && stored.GetFingerprint() == "" && stored.GetUserID() == ""
+			if anonymous != (tt.want != "") {
+				t.Errorf("expected identifying fields stored = %v, got ip %q fingerprint %q user %q",
+					tt.want == "", stored.GetIpAddress(), stored.GetFingerprint(), stored.GetUserID())
+			}
+			if tt.want != "" && stored.GetCountry() != CountryUnknown {
+				t.Errorf("expected country %q for an aggregate-only visit, got %q", CountryUnknown, stored.GetCountry())
+			}
+		})
+	}
+}
+
+func TestVisitorRegisterPrivacyEnrichesLocally(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	resolver := &mockGeoIPResolver{results: map[string]string{"203.0.113.7": "DE"}}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		Enrichers:          DefaultEnrichers(resolver),
+		EnrichAtIngestion:  true,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store.SetPrivacyOptions(PrivacyOptions{HonorDoNotTrack: true})
+	ctx := context.Background()
+
+	r := privacyTestRequest(map[string]string{"DNT": "1"})
+	visitor, err := store.VisitorRegisterHit(ctx, r, PageHit{Path: "/pricing", Referrer: "https://www.google.com/"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if resolver.calls != 0 {
+		t.Errorf("expected the IP of an aggregate-only visit not to be resolved, got %d lookups", resolver.calls)
+	}
+	if visitor.GetCountry() != CountryUnknown || visitor.GetChannel() != ChannelOrganicSearch {
+		t.Errorf("expected country %q and the channel to be set, got %q and %q", CountryUnknown, visitor.GetCountry(), visitor.GetChannel())
+	}
+
+	// The geo enricher is left for the batch, which finds no IP to resolve.
+	if _, err := store.VisitorEnrichBatch(ctx); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if resolver.calls != 0 {
+		t.Errorf("expected no lookups in batch, got %d", resolver.calls)
+	}
+}
+
+func TestEventRegisterPrivacy(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store.SetPrivacyOptions(PrivacyOptions{HonorDoNotTrack: true, HonorGlobalPrivacyControl: true, OptOutSkip: true, Cookieless: true})
+	ctx := context.Background()
+
+	recorded, err := store.EventRegister(ctx, privacyTestRequest(map[string]string{"DNT": "1"}), NewEvent().SetName("Signup"))
+	if err != nil || recorded {
+		t.Errorf("expected the opted-out event to be skipped, got %v, %v", recorded, err)
+	}
+
+	recorded, err = store.EventRegister(WithUserID(ctx, "42"), privacyTestRequest(nil), NewEvent().SetName("Signup").SetClientID("abc"))
+	if err != nil || !recorded {
+		t.Fatalf("expected the event to be recorded, got %v, %v", recorded, err)
+	}
+
+	events, err := store.EventList(ctx, EventQuery())
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d (%v)", len(events), err)
+	}
+	if e := events[0]; e.GetFingerprint() != "" || e.GetUserID() != "" || e.GetClientID() != "" {
+		t.Errorf("expected an anonymous event, got fingerprint %q user %q client %q", e.GetFingerprint(), e.GetUserID(), e.GetClientID())
+	}
+}
+
+func TestStorePrivacyReport(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store.SetPrivacyOptions(PrivacyOptions{HonorDoNotTrack: true, ConsentCookie: "analytics"})
+	ctx := context.Background()
+
+	withConsent := privacyTestRequest(nil)
+	withConsent.AddCookie(&http.Cookie{Name: "analytics", Value: "1"})
+	for _, r := range []*http.Request{
+		privacyTestRequest(map[string]string{"DNT": "1"}),
+		privacyTestRequest(nil),
+		privacyTestRequest(nil),
+		withConsent,
+	} {
+		if err := store.VisitorRegister(ctx, r); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	report, err := store.PrivacyReport(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if report.Visits != 4 || report.Identified != 1 || !report.ConsentRequired || !report.Options.HonorDoNotTrack {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	counts := map[string]int64{}
+	for _, reason := range report.Reasons {
+		counts[reason.Reason] = reason.Visits
+	}
+	if counts[PrivacyReasonDoNotTrack] != 1 || counts[PrivacyReasonNoConsent] != 2 || counts[PrivacyReasonCookieless] != 0 {
+		t.Errorf("unexpected reasons: %+v", report.Reasons)
+	}
+}
@@ -0,0 +1,162 @@
+package statsstore
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// == REDIRECT HANDLER =========================================================
+
+// DefaultDownloadExtensions are the file extensions the redirect handler
+// records as downloads when RedirectHandlerOptions.DownloadExtensions is not
+// set.
+var DefaultDownloadExtensions = []string{
+	"7z", "apk", "csv", "dmg", "doc", "docx", "epub", "exe", "gz", "iso",
+	"json", "key", "mp3", "mp4", "msi", "odp", "ods", "odt", "pdf", "pkg",
+	"ppt", "pptx", "rar", "rtf", "tar", "txt", "wav", "xls", "xlsx", "xml",
+	"zip",
+}
+
+// RedirectHandlerOptions configures NewRedirectHandler.
+type RedirectHandlerOptions struct {
+	// Store receives the click events. Required.
+	Store StoreInterface
+
+	// Secret signs the redirect URLs (see SignRedirectURL). Required: without
+	// a signature check the handler would be an open redirect, so every
+	// request is rejected when no secret is configured.
+	Secret []byte
+
+	// DownloadExtensions are the file extensions (without the dot) recorded
+	// as downloads. Defaults to DefaultDownloadExtensions.
+	DownloadExtensions []string
+}
+
+// NewRedirectHandler returns an http.Handler that records a click on an
+// outbound link or a file download and redirects (302) to its destination:
+//
+//	<a href="/out?url=https%3A%2F%2Fpartner.example.org%2F&sig=...">Partner</a>
+//
+// Parameters:
+//
+//   - url: the destination, an absolute http(s) URL
+//   - download: "1" records a download regardless of the file extension
+//   - sig: the signature created with SignRedirectURL
+//
+// The click is recorded via EventRegister as an EVENT_NAME_DOWNLOAD event
+// when the destination path ends in one of the download extensions, and as
+// an EVENT_NAME_OUTBOUND_LINK event otherwise, with the destination as the
+// "url" prop. The page the link was on is taken from the Referer header.
+//
+// Unsigned or tampered URLs are rejected with 403. Once the signature is
+// valid the visitor is always redirected, even when the event is filtered
+// or cannot be stored.
+func NewRedirectHandler(opts RedirectHandlerOptions) http.Handler {
+	return &redirectHandler{opts: opts}
+}
+
+type redirectHandler struct {
+	opts RedirectHandlerOptions
+}
+
+// ServeHTTP implements http.Handler.
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Store == nil {
+		http.Error(w, "redirect: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	if len(h.opts.Secret) == 0 || !VerifyRedirectSignature(h.opts.Secret, params) {
+		http.Error(w, "redirect: invalid signature", http.StatusForbidden)
+		return
+	}
+
+	target, err := url.Parse(strings.TrimSpace(params.Get("url")))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "redirect: invalid url", http.StatusBadRequest)
+		return
+	}
+
+	// HEAD requests (e.g. link previews and email scanners) are redirected
+	// without recording a click.
+	if r.Method == http.MethodGet {
+		_, _ = h.opts.Store.EventRegister(r.Context(), r, h.event(r, target, params.Get("download") == "1"))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// event builds the click event for target.
+func (h *redirectHandler) event(r *http.Request, target *url.URL, download bool) EventInterface {
+	name := EVENT_NAME_OUTBOUND_LINK
+	if download || h.isDownload(target) {
+		name = EVENT_NAME_DOWNLOAD
+	}
+
+	event := NewEvent().
+		SetName(name).
+		SetPath("/").
+		SetPropsMap(map[string]string{
+			"url": truncateRunes(target.String(), plausibleMaxPropValueLength),
+		})
+
+	if source := ParseReferrerURL(r.Referer()); source != nil {
+		event.SetDomain(source.Hostname())
+		if source.Path != "" {
+			event.SetPath(source.Path)
+		}
+	}
+
+	return event
+}
+
+// isDownload reports whether the target path has a download extension.
+func (h *redirectHandler) isDownload(target *url.URL) bool {
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(target.Path), "."))
+	if extension == "" {
+		return false
+	}
+
+	extensions := h.opts.DownloadExtensions
+	if len(extensions) == 0 {
+		extensions = DefaultDownloadExtensions
+	}
+	return slices.Contains(extensions, extension)
+}
+
+// == SIGNATURES ===============================================================
+
+// RedirectSignature returns the signature of the redirect parameters, as
+// for PixelSignature.
+func RedirectSignature(secret []byte, params url.Values) string {
+	return paramsSignature(secret, params)
+}
+
+// VerifyRedirectSignature reports whether params carry a valid sig
+// parameter for secret.
+func VerifyRedirectSignature(secret []byte, params url.Values) bool {
+	return verifyParamsSignature(secret, params)
+}
+
+// SignRedirectURL returns the redirect URL for base (e.g.
+// "https://example.com/out", without a query string) to the destination
+// target, with its signature in the query string. A download forces the
+// click to be recorded as a download.
+func SignRedirectURL(secret []byte, base, target string, download bool) string {
+	params := url.Values{"url": {target}}
+	if download {
+		params.Set("download", "1")
+	}
+	return base + "?" + signParams(secret, params).Encode()
+}
@@ -0,0 +1,124 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func serveRedirect(handler http.Handler, method, target, referer string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("User-Agent", matomoTestUA)
+	if referer != "" {
+		r.Header.Set("Referer", referer)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRedirectHandlerOutboundLink(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	secret := []byte("redirect-secret")
+	handler := NewRedirectHandler(RedirectHandlerOptions{Store: store, Secret: secret})
+
+	target := "https://partner.example.org/offer?id=7"
+	w := serveRedirect(handler, http.MethodGet, SignRedirectURL(secret, "/out", target, false), "https://example.com/blog/post")
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Location") != target {
+		t.Errorf("Location = %q, want %q", w.Header().Get("Location"), target)
+	}
+
+	events, err := store.EventList(context.Background(), EventQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.GetName() != EVENT_NAME_OUTBOUND_LINK || e.GetPropsMap()["url"] != target {
+		t.Errorf("unexpected event: name=%q props=%q", e.GetName(), e.GetProps())
+	}
+	if e.GetPath() != "/blog/post" || e.GetDomain() != "example.com" {
+		t.Errorf("unexpected source page: domain=%q path=%q", e.GetDomain(), e.GetPath())
+	}
+}
+
+func TestRedirectHandlerDownload(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	secret := []byte("redirect-secret")
+	handler := NewRedirectHandler(RedirectHandlerOptions{Store: store, Secret: secret})
+
+	for _, target := range []string{
+		SignRedirectURL(secret, "/out", "https://cdn.example.com/files/Report.PDF", false),
+		SignRedirectURL(secret, "/out", "https://cdn.example.com/download?id=3", true),
+	} {
+		if w := serveRedirect(handler, http.MethodGet, target, ""); w.Code != http.StatusFound {
+			t.Fatalf("%s: expected 302, got %d", target, w.Code)
+		}
+	}
+
+	count, err := store.EventCount(context.Background(), EventQuery().SetName(EVENT_NAME_DOWNLOAD))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 downloads, got %d", count)
+	}
+}
+
+func TestRedirectHandlerRejectsForgedURLs(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	secret := []byte("redirect-secret")
+	handler := NewRedirectHandler(RedirectHandlerOptions{Store: store, Secret: secret})
+
+	params := url.Values{"url": {"https://partner.example.org/"}}
+	tampered := url.Values{"url": {"https://evil.example.net/"}}
+	tampered.Set("sig", RedirectSignature(secret, params))
+
+	forged := []string{
+		"/out?" + params.Encode(),
+		"/out?" + tampered.Encode(),
+		SignRedirectURL([]byte("other-secret"), "/out", "https://partner.example.org/", false),
+	}
+	for _, target := range forged {
+		w := serveRedirect(handler, http.MethodGet, target, "")
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", target, w.Code)
+		}
+		if w.Header().Get("Location") != "" {
+			t.Errorf("%s: unexpected redirect to %q", target, w.Header().Get("Location"))
+		}
+	}
+
+	if w := serveRedirect(handler, http.MethodGet, SignRedirectURL(secret, "/out", "javascript:alert(1)", false), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-http destination, got %d", w.Code)
+	}
+
+	unconfigured := NewRedirectHandler(RedirectHandlerOptions{Store: store})
+	if w := serveRedirect(unconfigured, http.MethodGet, "/out?"+params.Encode(), ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a secret, got %d", w.Code)
+	}
+
+	count, err := store.EventCount(context.Background(), EventQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no events recorded, got %d", count)
+	}
+}
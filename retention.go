@@ -0,0 +1,303 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	contractsorm "github.com/dracory/neat/contracts/database/orm"
+	neatuid "github.com/dracory/neat/support/uid"
+	"github.com/dromara/carbon/v2"
+)
+
+// == CONSTANTS ================================================================
+
+// Retention rule targets.
+const (
+	// RetentionTargetVisitors purges visitor rows (raw visits).
+	RetentionTargetVisitors = "visitors"
+
+	// RetentionTargetBots purges visitor rows flagged as bot or threat.
+	RetentionTargetBots = "bots"
+
+	// RetentionTargetSoftDeleted purges visitor rows soft-deleted longer ago
+	// than the rule's age.
+	RetentionTargetSoftDeleted = "soft_deleted"
+
+	// RetentionTargetEvents purges custom events.
+	RetentionTargetEvents = "events"
+)
+
+// RetentionTargets lists the retention rule targets.
+var RetentionTargets = []string{
+	RetentionTargetVisitors,
+	RetentionTargetBots,
+	RetentionTargetSoftDeleted,
+	RetentionTargetEvents,
+}
+
+// RetentionBatchSizeDefault is the number of rows RetentionPurge deletes per
+// statement when RetentionPurgeOptions.BatchSize is not set.
+const RetentionBatchSizeDefault = 1000
+
+// retentionMaxNameLength caps retention rule names.
+const retentionMaxNameLength = 120
+
+// == TYPES ====================================================================
+
+// RetentionRule hard-deletes the rows of a target older than an age, e.g.
+// visitors after 13 months or bots after 30 days. The age is MaxAgeMonths
+// plus MaxAgeDays.
+type RetentionRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Target is one of RetentionTargets.
+	Target string `json:"target"`
+
+	MaxAgeMonths int `json:"max_age_months,omitempty"`
+	MaxAgeDays   int `json:"max_age_days,omitempty"`
+}
+
+// Validate checks that the rule can be applied.
+func (r RetentionRule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("retention rule name is required")
+	}
+	if len(r.Name) > retentionMaxNameLength {
+		return errors.New("retention rule name is too long")
+	}
+	if !slices.Contains(RetentionTargets, r.Target) {
+		return errors.New("retention rule target must be one of " + strings.Join(RetentionTargets, ", "))
+	}
+	if r.MaxAgeMonths < 0 || r.MaxAgeDays < 0 || r.MaxAgeMonths+r.MaxAgeDays == 0 {
+		return errors.New("retention rule needs a positive age")
+	}
+	return nil
+}
+
+// Cutoff returns the time before which rows are purged, relative to now.
+func (r RetentionRule) Cutoff(now time.Time) time.Time {
+	return carbon.CreateFromStdTime(now.UTC(), carbon.UTC).
+		SubMonthsNoOverflow(r.MaxAgeMonths).
+		SubDays(r.MaxAgeDays).
+		StdTime()
+}
+
+// RetentionPurgeOptions configures RetentionPurge.
+type RetentionPurgeOptions struct {
+	// DryRun counts the rows the rules would delete without deleting them.
+	DryRun bool
+
+	// BatchSize is the number of rows deleted per statement; default
+	// RetentionBatchSizeDefault. Small batches keep locks short.
+	BatchSize int
+
+	// Pause is waited between batches, letting other writes through.
+	Pause time.Duration
+}
+
+// RetentionRuleResult is the outcome of one rule of RetentionPurge.
+type RetentionRuleResult struct {
+	Rule    RetentionRule `json:"rule"`
+	Cutoff  string        `json:"cutoff"`
+	Matched int64         `json:"matched"`
+	Deleted int64         `json:"deleted"`
+}
+
+// RetentionReport is the outcome of RetentionPurge.
+type RetentionReport struct {
+	DryRun  bool                  `json:"dry_run"`
+	Rules   []RetentionRuleResult `json:"rules"`
+	Deleted int64                 `json:"deleted"`
+}
+
+// == STORE ====================================================================
+
+// RetentionRuleList returns the retention rules, stored as JSON in the
+// settings table.
+func (st *storeImplementation) RetentionRuleList(ctx context.Context) ([]RetentionRule, error) {
+	rules := []RetentionRule{}
+	if err := st.settingGetJSON(ctx, SETTING_RETENTION_RULES, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RetentionRuleSave validates and stores a retention rule, replacing the
+// rule with the same ID. A rule without an ID is added with a new ID.
+// Returns the stored rule.
+func (st *storeImplementation) RetentionRuleSave(ctx context.Context, rule RetentionRule) (RetentionRule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Target = strings.TrimSpace(rule.Target)
+	if err := rule.Validate(); err != nil {
+		return RetentionRule{}, err
+	}
+
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	rules, err := st.RetentionRuleList(ctx)
+	if err != nil {
+		return RetentionRule{}, err
+	}
+
+	if rule.ID == "" {
+		rule.ID = neatuid.GenerateShortID()
+		rules = append(rules, rule)
+	} else {
+		index := slices.IndexFunc(rules, func(r RetentionRule) bool { return r.ID == rule.ID })
+		if index < 0 {
+			return RetentionRule{}, errors.New("retention rule not found")
+		}
+		rules[index] = rule
+	}
+
+	return rule, st.settingSetJSON(ctx, SETTING_RETENTION_RULES, rules)
+}
+
+// RetentionRuleDelete removes a retention rule.
+func (st *storeImplementation) RetentionRuleDelete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("retention rule id is empty")
+	}
+
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	rules, err := st.RetentionRuleList(ctx)
+	if err != nil {
+		return err
+	}
+	rules = slices.DeleteFunc(rules, func(r RetentionRule) bool { return r.ID == id })
+
+	return st.settingSetJSON(ctx, SETTING_RETENTION_RULES, rules)
+}
+
+// RetentionPurge applies the retention rules in order. Rows are deleted
+// in batches of opts.BatchSize, so no statement holds long locks; the job
+// stops between batches when ctx is cancelled, returning the report so far
+// with the context error. With opts.DryRun nothing is deleted and the
+// report shows what would be.
+func (st *storeImplementation) RetentionPurge(ctx context.Context, opts RetentionPurgeOptions) (RetentionReport, error) {
+	report := RetentionReport{DryRun: opts.DryRun, Rules: []RetentionRuleResult{}}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = RetentionBatchSizeDefault
+	}
+
+	rules, err := st.RetentionRuleList(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if rule.Target == RetentionTargetEvents && st.eventTableName == "" {
+			continue
+		}
+
+		cutoff := rule.Cutoff(now)
+		result := RetentionRuleResult{
+			Rule:   rule,
+			Cutoff: carbon.CreateFromStdTime(cutoff, carbon.UTC).ToDateTimeString(carbon.UTC),
+		}
+
+		if err := st.retentionQuery(rule, cutoff).Count(&result.Matched); err != nil {
+			return report, err
+		}
+
+		if !opts.DryRun && result.Matched > 0 {
+			err = st.retentionDelete(ctx, rule, cutoff, batchSize, opts.Pause, &result.Deleted)
+			report.Deleted += result.Deleted
+			report.Rules = append(report.Rules, result)
+			if err != nil {
+				return report, err
+			}
+			if st.debugEnabled {
+				st.logger.Info("retention: purged rows", "rule", rule.Name, "target", rule.Target, "deleted", result.Deleted)
+			}
+			continue
+		}
+
+		report.Rules = append(report.Rules, result)
+	}
+
+	return report, nil
+}
+
+// retentionDelete deletes the rows of rule older than cutoff in batches,
+// adding the number deleted to deleted.
+func (st *storeImplementation) retentionDelete(ctx context.Context, rule RetentionRule, cutoff time.Time, batchSize int, pause time.Duration, deleted *int64) error {
+	table := st.retentionTable(rule)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows []struct {
+			ID string `db:"id"`
+		}
+		err := st.retentionQuery(rule, cutoff).
+			Select([]string{COLUMN_ID}).
+			Limit(batchSize).
+			Get(&rows)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		ids := make([]any, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		result, err := st.db.Query().Table(table).WhereIn(COLUMN_ID, ids).Delete()
+		if err != nil {
+			return err
+		}
+		*deleted += result.RowsAffected
+
+		if len(rows) < batchSize {
+			return nil
+		}
+
+		if pause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+	}
+}
+
+// retentionQuery selects the rows of rule older than cutoff.
+func (st *storeImplementation) retentionQuery(rule RetentionRule, cutoff time.Time) contractsorm.Query {
+	q := st.db.Query().Table(st.retentionTable(rule))
+
+	switch rule.Target {
+	case RetentionTargetBots:
+		return q.Where(COLUMN_CREATED_AT+" < ?", cutoff).
+			Where("("+COLUMN_BOT+" = ? OR "+COLUMN_THREAT+" = ?)", VALUE_YES, VALUE_YES)
+	case RetentionTargetSoftDeleted:
+		return q.Where(COLUMN_SOFT_DELETED_AT+" < ?", cutoff)
+	default:
+		return q.Where(COLUMN_CREATED_AT+" < ?", cutoff)
+	}
+}
+
+func (st *storeImplementation) retentionTable(rule RetentionRule) string {
+	if rule.Target == RetentionTargetEvents {
+		return st.eventTableName
+	}
+	return st.visitorTableName
+}
@@ -0,0 +1,158 @@
+package statsstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func TestRetentionRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule  RetentionRule
+		valid bool
+	}{
+		{RetentionRule{Name: "Raw visits", Target: RetentionTargetVisitors, MaxAgeMonths: 13}, true},
+		{RetentionRule{Name: "Bots", Target: RetentionTargetBots, MaxAgeDays: 30}, true},
+		{RetentionRule{Name: "", Target: RetentionTargetBots, MaxAgeDays: 30}, false},
+		{RetentionRule{Name: "Sessions", Target: "sessions", MaxAgeDays: 30}, false},
+		{RetentionRule{Name: "Forever", Target: RetentionTargetEvents}, false},
+		{RetentionRule{Name: "Negative", Target: RetentionTargetEvents, MaxAgeDays: -1, MaxAgeMonths: 1}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.rule, err, tt.valid)
+		}
+	}
+}
+
+func TestRetentionRuleCutoff(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	cutoff := RetentionRule{MaxAgeMonths: 13}.Cutoff(now)
+	if want := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC); !cutoff.Equal(want) {
+		t.Errorf("Cutoff() = %v, want %v", cutoff, want)
+	}
+
+	cutoff = RetentionRule{MaxAgeDays: 30}.Cutoff(now)
+	if want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC); !cutoff.Equal(want) {
+		t.Errorf("Cutoff() = %v, want %v", cutoff, want)
+	}
+}
+
+func TestStoreRetentionRules(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	if _, err := store.RetentionRuleSave(ctx, RetentionRule{Name: "Bots", Target: "robots", MaxAgeDays: 30}); err == nil {
+		t.Error("expected an error for an invalid rule")
+	}
+
+	rule, err := store.RetentionRuleSave(ctx, RetentionRule{Name: " Bots ", Target: RetentionTargetBots, MaxAgeDays: 30})
+	if err != nil || rule.ID == "" || rule.Name != "Bots" {
+		t.Fatalf("unexpected rule %+v (%v)", rule, err)
+	}
+
+	rule.MaxAgeDays = 60
+	if _, err := store.RetentionRuleSave(ctx, rule); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	rules, err := store.RetentionRuleList(ctx)
+	if err != nil || len(rules) != 1 || rules[0].MaxAgeDays != 60 {
+		t.Fatalf("unexpected rules %+v (%v)", rules, err)
+	}
+
+	if err := store.RetentionRuleDelete(ctx, rule.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	rules, err = store.RetentionRuleList(ctx)
+	if err != nil || len(rules) != 0 {
+		t.Errorf("expected no rules, got %+v (%v)", rules, err)
+	}
+}
+
+func TestStoreRetentionPurge(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	daysAgo := func(days int) string {
+		return carbon.Now(carbon.UTC).SubDays(days).ToDateTimeString(carbon.UTC)
+	}
+
+	for _, visitor := range []VisitorInterface{
+		NewVisitor().SetCreatedAt(daysAgo(500)),
+		NewVisitor().SetCreatedAt(daysAgo(450)),
+		NewVisitor().SetCreatedAt(daysAgo(10)),
+		NewVisitor().SetCreatedAt(daysAgo(40)).SetBot(VALUE_YES),
+		NewVisitor().SetCreatedAt(daysAgo(40)).SetThreat(VALUE_YES),
+		NewVisitor().SetCreatedAt(daysAgo(5)).SetBot(VALUE_YES),
+		NewVisitor().SetCreatedAt(daysAgo(20)).SetSoftDeletedAt(daysAgo(15)),
+	} {
+		if err := store.VisitorCreate(ctx, visitor); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+	for _, days := range []int{100, 3} {
+		if err := store.EventCreate(ctx, NewEvent().SetName("Signup").SetCreatedAt(daysAgo(days))); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, rule := range []RetentionRule{
+		{Name: "Raw visits", Target: RetentionTargetVisitors, MaxAgeMonths: 13},
+		{Name: "Bots", Target: RetentionTargetBots, MaxAgeDays: 30},
+		{Name: "Soft-deleted", Target: RetentionTargetSoftDeleted, MaxAgeDays: 7},
+		{Name: "Events", Target: RetentionTargetEvents, MaxAgeDays: 90},
+	} {
+		if _, err := store.RetentionRuleSave(ctx, rule); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	preview, err := store.RetentionPurge(ctx, RetentionPurgeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	matched := []int64{}
+	for _, result := range preview.Rules {
+		matched = append(matched, result.Matched)
+	}
+	if !preview.DryRun || preview.Deleted != 0 || len(matched) != 4 ||
+		matched[0] != 2 || matched[1] != 2 || matched[2] != 1 || matched[3] != 1 {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	if count, _ := store.VisitorCount(ctx, VisitorQuery().SetSoftDeletedIncluded(true)); count != 7 {
+		t.Errorf("expected a dry run to keep all visitors, got %d", count)
+	}
+
+	report, err := store.RetentionPurge(ctx, RetentionPurgeOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if report.DryRun || report.Deleted != 6 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	visitors, err := store.VisitorCount(ctx, VisitorQuery().SetSoftDeletedIncluded(true))
+	if err != nil || visitors != 2 {
+		t.Errorf("expected 2 visitors to remain, got %d (%v)", visitors, err)
+	}
+	events, err := store.EventCount(ctx, EventQuery())
+	if err != nil || events != 1 {
+		t.Errorf("expected 1 event to remain, got %d (%v)", events, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.RetentionPurge(cancelled, RetentionPurgeOptions{}); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
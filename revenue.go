@@ -0,0 +1,339 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// == ORDERS ===================================================================
+
+// OrderItem is a line item of an order, stored as JSON on the revenue event.
+type OrderItem struct {
+	SKU      string  `json:"sku,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+}
+
+// validateOrder checks the order fields of a revenue event: an order needs
+// a revenue amount, and its items must be a JSON array.
+func validateOrder(event EventInterface) error {
+	orderID := event.GetOrderID()
+	if len(orderID) > eventMaxOrderIDLength {
+		return errors.New("order id is too long")
+	}
+	if orderID != "" && event.GetRevenueAmount() == "" {
+		return errors.New("order needs a revenue amount")
+	}
+
+	if items := event.GetItems(); items != "" {
+		list := []OrderItem{}
+		if err := json.Unmarshal([]byte(items), &list); err != nil {
+			return errors.New("items must be an array of line items")
+		}
+	}
+
+	return nil
+}
+
+// == CURRENCY RATES ===========================================================
+
+// CurrencyRatesBaseDefault is the reporting currency when none is
+// configured.
+const CurrencyRatesBaseDefault = "USD"
+
+// CurrencyRates converts revenue to a single reporting currency.
+type CurrencyRates struct {
+	// Base is the ISO 4217 code revenue is reported in.
+	Base string `json:"base"`
+
+	// Rates holds the value of one unit of each other currency in Base,
+	// e.g. {"EUR": 1.08} when Base is "USD".
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Validate checks the currency codes and that every rate is positive.
+func (c CurrencyRates) Validate() error {
+	if !validCurrencyCode(c.Base) {
+		return errors.New("base currency must be a 3-letter code")
+	}
+	for currency, rate := range c.Rates {
+		if !validCurrencyCode(currency) {
+			return errors.New("currency " + currency + " must be a 3-letter code")
+		}
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return errors.New("rate of " + currency + " must be a positive number")
+		}
+	}
+	return nil
+}
+
+// Convert returns amount in currency converted to Base. It returns false
+// when there is no rate for the currency.
+func (c CurrencyRates) Convert(amount float64, currency string) (float64, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == c.Base {
+		return amount, true
+	}
+	rate, ok := c.Rates[currency]
+	if !ok {
+		return 0, false
+	}
+	return amount * rate, true
+}
+
+func validCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// == REVENUE ==================================================================
+
+// RevenueBreakdown is the revenue credited to one source, campaign, landing
+// page or country.
+type RevenueBreakdown struct {
+	Label   string  `json:"label"`
+	Orders  int64   `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+// RevenueReport is the revenue of a period in the reporting currency.
+type RevenueReport struct {
+	Currency string `json:"currency"`
+
+	// Orders counts revenue events, each order ID once.
+	Orders            int64   `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
+
+	// Unconverted counts the orders left out because their currency has no
+	// rate.
+	Unconverted int64 `json:"unconverted"`
+
+	BySource      []RevenueBreakdown `json:"by_source"`
+	ByCampaign    []RevenueBreakdown `json:"by_campaign"`
+	ByLandingPage []RevenueBreakdown `json:"by_landing_page"`
+	ByCountry     []RevenueBreakdown `json:"by_country"`
+}
+
+// ComputeRevenue sums the revenue events in events, converted with rates,
+// and credits each order to the visit it was placed in: the last visit of
+// the visitor (or user, see ComputeAttribution) that started before the
+// order, within AttributionLookbackDefault. Orders without such a visit are
+// credited to "(Direct)" and an unknown landing page and country.
+//
+// Events sharing an order ID count once, so orders recorded twice (e.g. by
+// the tracker and a payment webhook) are not double counted.
+func ComputeRevenue(visitors []VisitorInterface, events []EventInterface, rates CurrencyRates) RevenueReport {
+	if rates.Base == "" {
+		rates.Base = CurrencyRatesBaseDefault
+	}
+
+	identities := attributionIdentities(visitors, events)
+	identity := func(fingerprint string) string {
+		if userID, ok := identities[fingerprint]; ok {
+			return "user:" + userID
+		}
+		return fingerprint
+	}
+	touchpoints := attributionTouchpoints(visitors, identity)
+
+	bySource := map[string]*RevenueBreakdown{}
+	byCampaign := map[string]*RevenueBreakdown{}
+	byLandingPage := map[string]*RevenueBreakdown{}
+	byCountry := map[string]*RevenueBreakdown{}
+	report := RevenueReport{Currency: rates.Base}
+
+	seen := map[string]bool{}
+	for _, e := range events {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(e.GetRevenueAmount()), 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			continue
+		}
+
+		if orderID := e.GetOrderID(); orderID != "" {
+			if seen[orderID] {
+				continue
+			}
+			seen[orderID] = true
+		}
+
+		converted, ok := rates.Convert(amount, e.GetRevenueCurrency())
+		if !ok {
+			report.Unconverted++
+			continue
+		}
+
+		report.Orders++
+		report.Revenue += converted
+
+		key := identity(e.GetFingerprint())
+		if e.GetUserID() != "" {
+			key = "user:" + e.GetUserID()
+		}
+		touch := revenueTouchpoint(touchpoints[key], e.GetCreatedAtCarbon().StdTime())
+		if campaign := strings.TrimSpace(e.GetCampaign()); campaign != "" {
+			touch.Campaign = campaign
+		}
+
+		addRevenueBreakdown(bySource, touch.Source, converted)
+		addRevenueBreakdown(byCampaign, touch.Campaign, converted)
+		addRevenueBreakdown(byLandingPage, touch.LandingPage, converted)
+		addRevenueBreakdown(byCountry, touch.Country, converted)
+	}
+
+	report.Revenue = math.Round(report.Revenue*100) / 100
+	if report.Orders > 0 {
+		report.AverageOrderValue = math.Round(report.Revenue/float64(report.Orders)*100) / 100
+	}
+	report.BySource = sortedRevenueBreakdowns(bySource)
+	report.ByCampaign = sortedRevenueBreakdowns(byCampaign)
+	report.ByLandingPage = sortedRevenueBreakdowns(byLandingPage)
+	report.ByCountry = sortedRevenueBreakdowns(byCountry)
+
+	return report
+}
+
+// revenueTouchpoint returns the last visit (in time order) that started at
+// or before an order placed at orderedAt, within AttributionLookbackDefault.
+func revenueTouchpoint(touchpoints []Touchpoint, orderedAt time.Time) Touchpoint {
+	for i := len(touchpoints) - 1; i >= 0; i-- {
+		touch := touchpoints[i]
+		if touch.At.After(orderedAt) {
+			continue
+		}
+		if touch.At.Before(orderedAt.Add(-AttributionLookbackDefault)) {
+			break
+		}
+		return touch
+	}
+
+	return Touchpoint{
+		At:          orderedAt,
+		Channel:     ChannelDirect,
+		Source:      touchpointDirect,
+		Medium:      "direct",
+		Campaign:    attributionNone,
+		LandingPage: "(unknown)",
+		Country:     "Unknown",
+	}
+}
+
+func addRevenueBreakdown(breakdowns map[string]*RevenueBreakdown, label string, revenue float64) {
+	breakdown, ok := breakdowns[label]
+	if !ok {
+		breakdown = &RevenueBreakdown{Label: label}
+		breakdowns[label] = breakdown
+	}
+	breakdown.Orders++
+	breakdown.Revenue += revenue
+}
+
+// sortedRevenueBreakdowns returns the breakdowns by revenue descending,
+// rounded to two decimals.
+func sortedRevenueBreakdowns(breakdowns map[string]*RevenueBreakdown) []RevenueBreakdown {
+	list := make([]RevenueBreakdown, 0, len(breakdowns))
+	for _, breakdown := range breakdowns {
+		list = append(list, RevenueBreakdown{
+			Label:   breakdown.Label,
+			Orders:  breakdown.Orders,
+			Revenue: math.Round(breakdown.Revenue*100) / 100,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Revenue != list[j].Revenue {
+			return list[i].Revenue > list[j].Revenue
+		}
+		return list[i].Label < list[j].Label
+	})
+	return list
+}
+
+// == STORE ====================================================================
+
+// CurrencyRatesGet returns the configured currency rates, stored as JSON in
+// the settings table. Without configuration, revenue is reported in
+// CurrencyRatesBaseDefault and other currencies are not converted.
+func (st *storeImplementation) CurrencyRatesGet(ctx context.Context) (CurrencyRates, error) {
+	rates := CurrencyRates{}
+	if err := st.settingGetJSON(ctx, SETTING_CURRENCY_RATES, &rates); err != nil {
+		return CurrencyRates{}, err
+	}
+	if rates.Base == "" {
+		rates.Base = CurrencyRatesBaseDefault
+	}
+	if rates.Rates == nil {
+		rates.Rates = map[string]float64{}
+	}
+	return rates, nil
+}
+
+// CurrencyRatesSave validates and stores the currency rates. Currency codes
+// are upper-cased; a rate for the base currency itself is dropped.
+func (st *storeImplementation) CurrencyRatesSave(ctx context.Context, rates CurrencyRates) error {
+	normalized := CurrencyRates{
+		Base:  strings.ToUpper(strings.TrimSpace(rates.Base)),
+		Rates: map[string]float64{},
+	}
+	for currency, rate := range rates.Rates {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if currency != normalized.Base {
+			normalized.Rates[currency] = rate
+		}
+	}
+
+	if err := normalized.Validate(); err != nil {
+		return err
+	}
+
+	return st.settingSetJSON(ctx, SETTING_CURRENCY_RATES, normalized)
+}
+
+// Revenue reports the revenue of the orders placed between from and to,
+// converted with the configured currency rates. Visits from
+// AttributionLookbackDefault before from onwards are loaded, so orders are
+// credited to visits that started before the period.
+func (st *storeImplementation) Revenue(ctx context.Context, from, to time.Time) (RevenueReport, error) {
+	rates, err := st.CurrencyRatesGet(ctx)
+	if err != nil {
+		return RevenueReport{}, err
+	}
+
+	visitorQuery := VisitorQuery()
+	eventQuery := EventQuery()
+	if !from.IsZero() {
+		visitorQuery = visitorQuery.SetCreatedAtGte(from.Add(-AttributionLookbackDefault).UTC().Format(time.RFC3339))
+		eventQuery = eventQuery.SetCreatedAtGte(from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		until := to.UTC().Format(time.RFC3339)
+		visitorQuery = visitorQuery.SetCreatedAtLte(until)
+		eventQuery = eventQuery.SetCreatedAtLte(until)
+	}
+
+	visitors, err := st.VisitorList(ctx, visitorQuery)
+	if err != nil {
+		return RevenueReport{}, err
+	}
+
+	events, err := st.EventList(ctx, eventQuery)
+	if err != nil {
+		return RevenueReport{}, err
+	}
+
+	return ComputeRevenue(visitors, events, rates), nil
+}
@@ -0,0 +1,167 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func revenueTestEvent(fingerprint, orderID, amount, currency string, at time.Time) EventInterface {
+	return NewEvent().
+		SetName("Purchase").
+		SetFingerprint(fingerprint).
+		SetOrderID(orderID).
+		SetRevenueAmount(amount).
+		SetRevenueCurrency(currency).
+		SetCreatedAt(carbon.CreateFromStdTime(at).ToDateTimeString(carbon.UTC))
+}
+
+func revenueBreakdown(breakdowns []RevenueBreakdown, label string) RevenueBreakdown {
+	for _, breakdown := range breakdowns {
+		if breakdown.Label == label {
+			return breakdown
+		}
+	}
+	return RevenueBreakdown{}
+}
+
+func TestComputeRevenue(t *testing.T) {
+	ordered := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	visitors := []VisitorInterface{
+		attributionTestVisitor("a", "/pricing", "https://www.google.com/?utm_campaign=spring", ordered.Add(-10*time.Minute)).SetCountry("de"),
+		attributionTestVisitor("a", "/checkout", "", ordered.Add(-2*time.Minute)).SetCountry("de"),
+		attributionTestVisitor("b", "/", "", ordered.Add(-5*time.Minute)).SetCountry("US"),
+	}
+	events := []EventInterface{
+		revenueTestEvent("a", "1001", "100", "EUR", ordered),
+		revenueTestEvent("a", "1001", "100", "EUR", ordered.Add(time.Minute)),
+		revenueTestEvent("b", "1002", "20.50", "USD", ordered),
+		revenueTestEvent("b", "", "5", "usd", ordered),
+		revenueTestEvent("c", "1003", "10", "GBP", ordered),
+		NewEvent().SetName("Signup").SetFingerprint("a"),
+	}
+	rates := CurrencyRates{Base: "USD", Rates: map[string]float64{"EUR": 1.1}}
+
+	report := ComputeRevenue(visitors, events, rates)
+
+	if report.Currency != "USD" || report.Orders != 3 || report.Revenue != 135.5 || report.Unconverted != 1 {
+		t.Fatalf("unexpected totals: %+v", report)
+	}
+	if report.AverageOrderValue != 45.17 {
+		t.Errorf("average order value = %v, want 45.17", report.AverageOrderValue)
+	}
+
+	if got := revenueBreakdown(report.BySource, "google.com"); got.Orders != 1 || got.Revenue != 110 {
+		t.Errorf("google.com = %+v", got)
+	}
+	if got := revenueBreakdown(report.BySource, "(Direct)"); got.Orders != 2 || got.Revenue != 25.5 {
+		t.Errorf("(Direct) = %+v", got)
+	}
+	if got := revenueBreakdown(report.ByCampaign, "spring"); got.Revenue != 110 {
+		t.Errorf("spring = %+v", got)
+	}
+	if got := revenueBreakdown(report.ByLandingPage, "/pricing"); got.Revenue != 110 {
+		t.Errorf("/pricing = %+v", got)
+	}
+	if got := revenueBreakdown(report.ByCountry, "DE"); got.Revenue != 110 {
+		t.Errorf("DE = %+v", got)
+	}
+	if report.BySource[0].Label != "google.com" {
+		t.Errorf("expected sources by revenue, got %+v", report.BySource)
+	}
+}
+
+func TestEventTrackOrderDedupe(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	order := func() EventInterface {
+		return NewEvent().SetName("Purchase").SetUserID("42").SetOrderID("1001").
+			SetRevenueAmount("19.99").SetRevenueCurrency("USD").
+			SetItemsList([]OrderItem{{SKU: "PRO-1", Name: "Pro plan", Price: 19.99, Quantity: 1}})
+	}
+
+	first, created, err := store.EventTrack(ctx, order())
+	if err != nil || !created {
+		t.Fatalf("expected order to be created, got %v, %v", created, err)
+	}
+	second, created, err := store.EventTrack(ctx, order())
+	if err != nil || created || second.GetID() != first.GetID() {
+		t.Errorf("expected duplicate order to return the stored event, got created=%v err=%v", created, err)
+	}
+
+	items := second.GetItemsList()
+	if len(items) != 1 || items[0].SKU != "PRO-1" || items[0].Quantity != 1 {
+		t.Errorf("unexpected items: %+v", items)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	recorded, err := store.EventRegister(ctx, r, order())
+	if err != nil || recorded {
+		t.Errorf("expected EventRegister to skip the duplicate order, got %v, %v", recorded, err)
+	}
+
+	_, _, err = store.EventTrack(ctx, NewEvent().SetName("Purchase").SetUserID("42").SetOrderID("1002"))
+	if !errors.Is(err, ErrEventInvalid) {
+		t.Errorf("expected an order without revenue to be invalid, got %v", err)
+	}
+
+	count, err := store.EventCount(ctx, EventQuery().SetOrderID("1001"))
+	if err != nil || count != 1 {
+		t.Errorf("expected 1 stored order, got %d (%v)", count, err)
+	}
+}
+
+func TestStoreCurrencyRatesAndRevenue(t *testing.T) {
+	store, err := initStoreWithSettings()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	rates, err := store.CurrencyRatesGet(ctx)
+	if err != nil || rates.Base != CurrencyRatesBaseDefault || len(rates.Rates) != 0 {
+		t.Fatalf("unexpected default rates: %+v (%v)", rates, err)
+	}
+
+	if err := store.CurrencyRatesSave(ctx, CurrencyRates{Base: "eur", Rates: map[string]float64{"usd": 0.5, "EUR": 1}}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	rates, err = store.CurrencyRatesGet(ctx)
+	if err != nil || rates.Base != "EUR" || len(rates.Rates) != 1 || rates.Rates["USD"] != 0.5 {
+		t.Fatalf("unexpected rates: %+v (%v)", rates, err)
+	}
+
+	if err := store.CurrencyRatesSave(ctx, CurrencyRates{Base: "EUR", Rates: map[string]float64{"USD": -1}}); err == nil {
+		t.Error("expected an error for a negative rate")
+	}
+	if err := store.CurrencyRatesSave(ctx, CurrencyRates{Base: "euro"}); err == nil {
+		t.Error("expected an error for an invalid base currency")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := store.EventCreate(ctx, revenueTestEvent("a", "1001", "40", "USD", now.Add(-time.Hour))); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.EventCreate(ctx, revenueTestEvent("a", "1002", "40", "USD", now.Add(-48*time.Hour))); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	report, err := store.Revenue(ctx, now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if report.Currency != "EUR" || report.Orders != 1 || report.Revenue != 20 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
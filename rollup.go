@@ -0,0 +1,535 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	contractsorm "github.com/dracory/neat/contracts/database/orm"
+	contractsschema "github.com/dracory/neat/contracts/database/schema"
+	neatuid "github.com/dracory/neat/support/uid"
+	"github.com/dromara/carbon/v2"
+)
+
+// == CONSTANTS ================================================================
+
+// Rollup dimensions. Every rolled-up day has one RollupDimensionTotal row
+// with an empty value, and one row per distinct value of the others.
+const (
+	RollupDimensionTotal    = "total"
+	RollupDimensionPath     = "path"
+	RollupDimensionReferrer = "referrer"
+	RollupDimensionCountry  = "country"
+	RollupDimensionDevice   = "device"
+	RollupDimensionBrowser  = "browser"
+	RollupDimensionOS       = "os"
+	RollupDimensionChannel  = "channel"
+)
+
+// RollupDimensions lists the rollup dimensions.
+var RollupDimensions = []string{
+	RollupDimensionTotal,
+	RollupDimensionPath,
+	RollupDimensionReferrer,
+	RollupDimensionCountry,
+	RollupDimensionDevice,
+	RollupDimensionBrowser,
+	RollupDimensionOS,
+	RollupDimensionChannel,
+}
+
+const (
+	// rollupDateFormat is the format of the rollup date column.
+	rollupDateFormat = "2006-01-02"
+
+	// rollupMaxValueLength matches the rollup value column size.
+	rollupMaxValueLength = 510
+
+	// rollupMaxDays caps the days RollupBuild processes in one call.
+	rollupMaxDays = 3660
+
+	// rollupInsertBatchSize is the number of rollup rows inserted per
+	// statement, keeping the bound parameters within every driver's limit.
+	rollupInsertBatchSize = 200
+)
+
+// == TYPES ====================================================================
+
+// RollupRow is the daily count of one dimension value. Visitors is the
+// number of distinct IP addresses, as the admin dashboard counts them,
+// estimated by Sketch. Rows built by earlier versions have no sketch.
+type RollupRow struct {
+	Date      string       `json:"date"`
+	Dimension string       `json:"dimension"`
+	Value     string       `json:"value"`
+	Pageviews int64        `json:"pageviews"`
+	Visitors  int64        `json:"visitors"`
+	Sketch    *HyperLogLog `json:"-"`
+}
+
+// RollupResult is the outcome of RollupBuild.
+type RollupResult struct {
+	// Days counts the days rolled up; Skipped the days without raw visits,
+	// and Protected the days before a retention cutoff, whose existing
+	// rollup rows were kept.
+	Days      int `json:"days"`
+	Skipped   int `json:"skipped"`
+	Protected int `json:"protected"`
+	Rows      int `json:"rows"`
+}
+
+// UniqueVisitorsEstimate is the outcome of UniqueVisitors.
+type UniqueVisitorsEstimate struct {
+	Visitors uint64 `json:"visitors"`
+
+	// RelativeError is the relative standard error of Visitors.
+	RelativeError float64 `json:"relative_error"`
+
+	// RollupDays counts the days read from rollup sketches, RawDays the
+	// days read from raw visits.
+	RollupDays int `json:"rollup_days"`
+	RawDays    int `json:"raw_days"`
+}
+
+// == MIGRATE ==================================================================
+
+// migrateRollupTable creates the daily rollup table if it does not exist,
+// or adds the sketch column that earlier versions of it lack.
+func (st *storeImplementation) migrateRollupTable() error {
+	if st.rollupTableName == "" {
+		return nil
+	}
+
+	if st.db.Schema().HasTable(st.rollupTableName) {
+		return st.migrateAddColumn(st.rollupTableName, COLUMN_SKETCH, func(table contractsschema.Blueprint) {
+			table.LongText(COLUMN_SKETCH)
+		})
+	}
+
+	err := st.db.Schema().Create(st.rollupTableName, func(table contractsschema.Blueprint) {
+		table.String(COLUMN_ID, 40)
+		table.Primary(COLUMN_ID)
+		table.String(COLUMN_DATE, 10)
+		table.String(COLUMN_DIMENSION, 20)
+		table.String(COLUMN_VALUE, rollupMaxValueLength)
+		table.String(COLUMN_PAGEVIEWS, 20)
+		table.String(COLUMN_VISITORS, 20)
+		table.LongText(COLUMN_SKETCH)
+		table.DateTime(COLUMN_CREATED_AT)
+
+		table.Index(COLUMN_DATE)
+		table.Index(COLUMN_DIMENSION)
+	})
+
+	if err != nil && st.debugEnabled {
+		st.logger.Error("MigrateUp: rollup table creation failed", "error", err)
+	}
+
+	return err
+}
+
+// == STORE ====================================================================
+
+// RollupBuild compacts the raw visits of each UTC day from from to to
+// (inclusive) into daily rollup rows: page views and unique visitors in
+// total and by path, referrer domain, country, device type, browser, OS
+// and channel, each with a unique-visitor sketch. A day's rows are
+// replaced on every run, so the job is idempotent.
+//
+// Days without raw visits keep their existing rows. So do days that start
+// before the cutoff of a visitors or bots retention rule: a purge may have
+// removed part of their raw visits, so they are never rebuilt. Roll a day
+// up before the rules reach it.
+func (st *storeImplementation) RollupBuild(ctx context.Context, from, to time.Time) (RollupResult, error) {
+	result := RollupResult{}
+	if st.rollupTableName == "" {
+		return result, errors.New("stats store: rollup table is not configured")
+	}
+
+	start := carbon.CreateFromStdTime(from.UTC(), carbon.UTC).StartOfDay()
+	end := carbon.CreateFromStdTime(to.UTC(), carbon.UTC).StartOfDay()
+	if start.Gt(end) {
+		return result, errors.New("rollup range start is after its end")
+	}
+	if start.DiffInDays(end) >= rollupMaxDays {
+		return result, errors.New("rollup range is too long")
+	}
+
+	cutoff, err := st.rollupRetentionCutoff(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for day := start; day.Lte(end); day = day.Copy().AddDay() {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if day.StdTime().Before(cutoff) {
+			result.Protected++
+			continue
+		}
+
+		visitors, err := st.VisitorList(ctx, VisitorQuery().
+			SetCreatedAtGte(day.ToDateTimeString(carbon.UTC)).
+			SetCreatedAtLte(day.Copy().EndOfDay().ToDateTimeString(carbon.UTC)))
+		if err != nil {
+			return result, err
+		}
+		if len(visitors) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		date := day.StdTime().Format(rollupDateFormat)
+		rows := ComputeRollup(date, visitors, st.hllPrecision)
+		if err := st.rollupReplace(date, rows); err != nil {
+			return result, err
+		}
+		result.Days++
+		result.Rows += len(rows)
+	}
+
+	if st.debugEnabled {
+		st.logger.Info("rollup: built", "days", result.Days, "skipped", result.Skipped, "protected", result.Protected, "rows", result.Rows)
+	}
+
+	return result, nil
+}
+
+// RollupList returns the rollup rows of dimension for the UTC days from
+// from to to (inclusive), ordered by date. An empty dimension returns all
+// of them.
+func (st *storeImplementation) RollupList(ctx context.Context, dimension string, from, to time.Time) ([]RollupRow, error) {
+	if st.rollupTableName == "" {
+		return []RollupRow{}, nil
+	}
+
+	q := st.db.Query().
+		Table(st.rollupTableName).
+		Where(COLUMN_DATE+" >= ?", from.UTC().Format(rollupDateFormat)).
+		Where(COLUMN_DATE+" <= ?", to.UTC().Format(rollupDateFormat))
+	if dimension != "" {
+		q = q.Where(COLUMN_DIMENSION+" = ?", dimension)
+	}
+
+	return st.rollupGet(q)
+}
+
+// UniqueVisitors estimates the distinct visitors (IP addresses) of the UTC
+// days from from to to (inclusive), e.g. over the last 90 days, by merging
+// the daily sketches of the rolled-up days with a sketch of the raw visits
+// of the others. An empty dimension counts all visitors; otherwise only the
+// visitors with value in that dimension, e.g. RollupDimensionPath and
+// "/pricing". The estimate is within NewStoreOptions.UniqueErrorBound
+// (relative standard error) of the exact count, or the error of the
+// least precise rollup sketch when the bound has since been lowered.
+func (st *storeImplementation) UniqueVisitors(ctx context.Context, dimension, value string, from, to time.Time) (UniqueVisitorsEstimate, error) {
+	estimate := UniqueVisitorsEstimate{}
+	if dimension == "" || dimension == RollupDimensionTotal {
+		dimension, value = RollupDimensionTotal, ""
+	}
+
+	start := carbon.CreateFromStdTime(from.UTC(), carbon.UTC).StartOfDay()
+	end := carbon.CreateFromStdTime(to.UTC(), carbon.UTC).EndOfDay()
+	if start.Gt(end) {
+		return estimate, errors.New("unique visitors range start is after its end")
+	}
+
+	// Days are covered by their total row; a day without visitors of value
+	// has a total row but no row of its own.
+	totals, err := st.RollupList(ctx, RollupDimensionTotal, start.StdTime(), end.StdTime())
+	if err != nil {
+		return estimate, err
+	}
+	covered := map[string]bool{}
+	for _, row := range totals {
+		if row.Sketch != nil {
+			covered[row.Date] = true
+		}
+	}
+	estimate.RollupDays = len(covered)
+
+	sketches := []*HyperLogLog{}
+	if dimension == RollupDimensionTotal {
+		for _, row := range totals {
+			sketches = append(sketches, row.Sketch)
+		}
+	} else if len(covered) > 0 {
+		rows, err := st.rollupGet(st.db.Query().
+			Table(st.rollupTableName).
+			Where(COLUMN_DATE+" >= ?", start.StdTime().Format(rollupDateFormat)).
+			Where(COLUMN_DATE+" <= ?", end.StdTime().Format(rollupDateFormat)).
+			Where(COLUMN_DIMENSION+" = ?", dimension).
+			Where(COLUMN_VALUE+" = ?", value))
+		if err != nil {
+			return estimate, err
+		}
+		for _, row := range rows {
+			if covered[row.Date] {
+				sketches = append(sketches, row.Sketch)
+			}
+		}
+	}
+
+	// Raw visits are loaded from the first day without a rollup.
+	first := start.Copy()
+	for first.Lte(end) && covered[first.StdTime().Format(rollupDateFormat)] {
+		first = first.AddDay()
+	}
+
+	raw, _ := NewHyperLogLog(st.hllPrecision)
+	if first.Lte(end) {
+		visitors, err := st.VisitorList(ctx, VisitorQuery().
+			SetCreatedAtGte(first.ToDateTimeString(carbon.UTC)).
+			SetCreatedAtLte(end.ToDateTimeString(carbon.UTC)))
+		if err != nil {
+			return estimate, err
+		}
+
+		rawDays := map[string]bool{}
+		for _, v := range visitors {
+			createdAt := v.GetCreatedAtCarbon()
+			if createdAt == nil || covered[createdAt.ToDateString(carbon.UTC)] {
+				continue
+			}
+			rawDays[createdAt.ToDateString(carbon.UTC)] = true
+			identifier := rollupIdentifier(v)
+			if identifier != "" && rollupDimensionValues(v)[dimension] == value {
+				raw.Add(identifier)
+			}
+		}
+		estimate.RawDays = len(rawDays)
+	}
+
+	merged := MergeHyperLogLogs(append(sketches, raw)...)
+	estimate.Visitors = merged.Count()
+	estimate.RelativeError = merged.RelativeError()
+
+	return estimate, nil
+}
+
+// rollupGet runs a rollup table query, ordered by date.
+func (st *storeImplementation) rollupGet(q contractsorm.Query) ([]RollupRow, error) {
+	type rollupRow struct {
+		Date      string `db:"date"`
+		Dimension string `db:"dimension"`
+		Value     string `db:"value"`
+		Pageviews string `db:"pageviews"`
+		Visitors  string `db:"visitors"`
+		Sketch    string `db:"sketch"`
+	}
+
+	var rows []rollupRow
+	if err := q.OrderBy(COLUMN_DATE, "asc").Get(&rows); err != nil {
+		return []RollupRow{}, err
+	}
+
+	list := make([]RollupRow, 0, len(rows))
+	for _, r := range rows {
+		pageviews, _ := strconv.ParseInt(r.Pageviews, 10, 64)
+		visitors, _ := strconv.ParseInt(r.Visitors, 10, 64)
+
+		var sketch *HyperLogLog
+		if r.Sketch != "" {
+			sketch = &HyperLogLog{}
+			if err := sketch.UnmarshalText([]byte(r.Sketch)); err != nil {
+				if st.debugEnabled {
+					st.logger.Error("rollup: invalid sketch", "date", r.Date, "error", err)
+				}
+				sketch = nil
+			}
+		}
+
+		list = append(list, RollupRow{
+			Date:      r.Date,
+			Dimension: r.Dimension,
+			Value:     r.Value,
+			Pageviews: pageviews,
+			Visitors:  visitors,
+			Sketch:    sketch,
+		})
+	}
+
+	return list, nil
+}
+
+// rollupReplace replaces the rollup rows of date with rows in one
+// transaction, so readers never see a day half rebuilt.
+func (st *storeImplementation) rollupReplace(date string, rows []RollupRow) error {
+	now := carbon.Now(carbon.UTC).StdTime()
+	records := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		sketch := ""
+		if row.Sketch != nil {
+			text, err := row.Sketch.MarshalText()
+			if err != nil {
+				return err
+			}
+			sketch = string(text)
+		}
+
+		records = append(records, map[string]any{
+			COLUMN_ID:         neatuid.GenerateShortID(),
+			COLUMN_DATE:       row.Date,
+			COLUMN_DIMENSION:  row.Dimension,
+			COLUMN_VALUE:      row.Value,
+			COLUMN_PAGEVIEWS:  strconv.FormatInt(row.Pageviews, 10),
+			COLUMN_VISITORS:   strconv.FormatInt(row.Visitors, 10),
+			COLUMN_SKETCH:     sketch,
+			COLUMN_CREATED_AT: now,
+		})
+	}
+
+	err := st.db.Transaction(func(tx contractsorm.Query) error {
+		if _, err := tx.Table(st.rollupTableName).Where(COLUMN_DATE+" = ?", date).Delete(); err != nil {
+			return err
+		}
+
+		for batch := range slices.Chunk(records, rollupInsertBatchSize) {
+			if err := tx.Table(st.rollupTableName).Create(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && st.debugEnabled {
+		st.logger.Error("rollup: replace failed", "date", date, "error", err)
+	}
+
+	return err
+}
+
+// == PUBLIC FUNCTIONS =========================================================
+
+// ComputeRollup aggregates the visits of one day into rollup rows, the
+// total first, then by dimension and value, with unique-visitor sketches of
+// the given precision (see HyperLogLogPrecision). Values are those the
+// admin dashboard reports: the path ("/" when empty), the referrer domain
+// (empty for direct visits), the country code, device type, browser and OS
+// as stored, and the channel classified from the referrer domain.
+func ComputeRollup(date string, visitors []VisitorInterface, precision uint8) []RollupRow {
+	type counter struct {
+		pageviews int64
+		visitors  *HyperLogLog
+	}
+	counters := map[string]map[string]*counter{}
+	for _, dimension := range RollupDimensions {
+		counters[dimension] = map[string]*counter{}
+	}
+
+	if precision < HyperLogLogPrecisionMin || precision > HyperLogLogPrecisionMax {
+		precision = HyperLogLogPrecisionDefault
+	}
+
+	for _, v := range visitors {
+		identifier := rollupIdentifier(v)
+
+		for dimension, value := range rollupDimensionValues(v) {
+			c, ok := counters[dimension][value]
+			if !ok {
+				sketch, _ := NewHyperLogLog(precision)
+				c = &counter{visitors: sketch}
+				counters[dimension][value] = c
+			}
+			c.pageviews++
+			if identifier != "" {
+				c.visitors.Add(identifier)
+			}
+		}
+	}
+
+	rows := []RollupRow{}
+	for _, dimension := range RollupDimensions {
+		values := make([]string, 0, len(counters[dimension]))
+		for value := range counters[dimension] {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			c := counters[dimension][value]
+			rows = append(rows, RollupRow{
+				Date:      date,
+				Dimension: dimension,
+				Value:     value,
+				Pageviews: c.pageviews,
+				Visitors:  int64(c.visitors.Count()),
+				Sketch:    c.visitors,
+			})
+		}
+	}
+
+	return rows
+}
+
+// == PRIVATE FUNCTIONS ========================================================
+
+// rollupRetentionCutoff returns the latest cutoff of the retention rules
+// that purge visitor rows counted by the rollups, or the zero time when
+// there are none. Soft-deleted rows are not counted, so their rule does
+// not matter.
+func (st *storeImplementation) rollupRetentionCutoff(ctx context.Context) (time.Time, error) {
+	if st.settingsTableName == "" {
+		return time.Time{}, nil
+	}
+
+	rules, err := st.RetentionRuleList(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	latest := time.Time{}
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		if rule.Target != RetentionTargetVisitors && rule.Target != RetentionTargetBots {
+			continue
+		}
+		if cutoff := rule.Cutoff(now); cutoff.After(latest) {
+			latest = cutoff
+		}
+	}
+
+	return latest, nil
+}
+
+// rollupIdentifier returns the string a visit is counted as unique by: its
+// IP address, as computePeriodStats in the admin dashboard does. Visits
+// recorded aggregate only return "" and count as page views only.
+func rollupIdentifier(v VisitorInterface) string {
+	if v.GetPrivacy() != "" {
+		return ""
+	}
+	if ip := v.GetIpAddress(); ip != "" {
+		return ip
+	}
+	return "unknown-ip"
+}
+
+// rollupDimensionValues returns the value of each rollup dimension for a
+// visit.
+func rollupDimensionValues(v VisitorInterface) map[string]string {
+	path := v.GetPath()
+	if path == "" {
+		path = "/"
+	}
+	domain := ReferrerDomain(v.GetUserReferrer())
+
+	values := map[string]string{
+		RollupDimensionTotal:    "",
+		RollupDimensionPath:     path,
+		RollupDimensionReferrer: domain,
+		RollupDimensionCountry:  v.GetCountry(),
+		RollupDimensionDevice:   strings.TrimSpace(v.GetUserDeviceType()),
+		RollupDimensionBrowser:  strings.TrimSpace(v.GetUserBrowser()),
+		RollupDimensionOS:       strings.TrimSpace(v.GetUserOs()),
+		RollupDimensionChannel:  ClassifyChannel(domain),
+	}
+	for dimension, value := range values {
+		values[dimension] = truncateRunes(value, rollupMaxValueLength)
+	}
+	return values
+}
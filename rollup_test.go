@@ -0,0 +1,271 @@
+package statsstore
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestComputeRollup(t *testing.T) {
+	visitors := []VisitorInterface{
+		NewVisitor().SetIpAddress("1.1.1.1").SetPath("/pricing").SetCountry("US").SetUserReferrer("https://www.google.com/search?q=x"),
+		NewVisitor().SetIpAddress("1.1.1.1").SetPath("/signup").SetCountry("US"),
+		NewVisitor().SetIpAddress("2.2.2.2").SetPath("/pricing").SetCountry("DE").SetUserBrowser("Firefox"),
+		NewVisitor().SetPath(""),
+		NewVisitor().SetPath("/pricing").SetPrivacy(PrivacyReasonDoNotTrack),
+		NewVisitor().SetPath("/pricing").SetPrivacy(PrivacyReasonCookieless),
+	}
+
+	rows := ComputeRollup("2025-03-01", visitors, HyperLogLogPrecisionDefault)
+
+	find := func(dimension, value string) RollupRow {
+		for _, row := range rows {
+			if row.Dimension == dimension && row.Value == value {
+				return row
+			}
+		}
+		t.Fatalf("no %s row for %q in %+v", dimension, value, rows)
+		return RollupRow{}
+	}
+
+	if rows[0].Dimension != RollupDimensionTotal {
+		t.Errorf("expected the total row first, got %+v", rows[0])
+	}
+
+	tests := []struct {
+		dimension, value    string
+		pageviews, visitors int64
+	}{
+		// Aggregate-only visits count as page views only.
+		{RollupDimensionTotal, "", 6, 3},
+		{RollupDimensionPath, "/pricing", 4, 2},
+		{RollupDimensionPath, "/", 1, 1},
+		{RollupDimensionReferrer, "google.com", 1, 1},
+		{RollupDimensionReferrer, "", 5, 3},
+		{RollupDimensionCountry, "US", 2, 1},
+		{RollupDimensionBrowser, "Firefox", 1, 1},
+		{RollupDimensionChannel, ChannelOrganicSearch, 1, 1},
+		{RollupDimensionChannel, ChannelDirect, 5, 3},
+	}
+
+	for _, tt := range tests {
+		row := find(tt.dimension, tt.value)
+		if row.Date != "2025-03-01" || row.Pageviews != tt.pageviews || row.Visitors != tt.visitors {
+			t.Errorf("%s %q: got %+v, want %d page views and %d visitors", tt.dimension, tt.value, row, tt.pageviews, tt.visitors)
+		}
+	}
+}
+
+func TestStoreRollupBuild(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	for _, v := range []struct{ ip, path, createdAt string }{
+		{"1.1.1.1", "/", "2025-03-01 08:00:00"},
+		{"1.1.1.1", "/pricing", "2025-03-01 09:00:00"},
+		{"2.2.2.2", "/", "2025-03-01 23:59:59"},
+		{"3.3.3.3", "/", "2025-03-03 10:00:00"},
+	} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress(v.ip).SetPath(v.path).SetCreatedAt(v.createdAt)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	if _, err := store.RollupBuild(ctx, to, from); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+
+	result, err := store.RollupBuild(ctx, from, to)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if result.Days != 2 || result.Skipped != 1 || result.Rows == 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// Re-running replaces the rows instead of adding to them.
+	again, err := store.RollupBuild(ctx, from, to)
+	if err != nil || again != result {
+		t.Fatalf("expected the same result on a re-run, got %+v (%v)", again, err)
+	}
+
+	totals, err := store.RollupList(ctx, RollupDimensionTotal, from, to)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(totals) != 2 ||
+		totals[0].Date != "2025-03-01" || totals[0].Pageviews != 3 || totals[0].Visitors != 2 ||
+		totals[1].Date != "2025-03-03" || totals[1].Pageviews != 1 || totals[1].Visitors != 1 {
+		t.Errorf("unexpected totals %+v", totals)
+	}
+
+	all, err := store.RollupList(ctx, "", from, to)
+	if err != nil || len(all) != result.Rows {
+		t.Errorf("expected %d rows, got %d (%v)", result.Rows, len(all), err)
+	}
+
+	// Days whose raw rows are gone keep their rollups.
+	if _, err := store.VisitorDeleteByIP(ctx, "3.3.3.3"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := store.RollupBuild(ctx, from, to); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	totals, err = store.RollupList(ctx, RollupDimensionTotal, to, to)
+	if err != nil || len(totals) != 1 || totals[0].Pageviews != 1 {
+		t.Errorf("expected the purged day's rollup to be kept, got %+v (%v)", totals, err)
+	}
+}
+
+func TestStoreRollupBuildManyRows(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	// More paths than fit one insert batch, and one longer than the column.
+	long := "/" + strings.Repeat("é", rollupMaxValueLength)
+	paths := []string{long}
+	for i := range rollupInsertBatchSize + 50 {
+		paths = append(paths, "/page-"+strconv.Itoa(i))
+	}
+	for _, path := range paths {
+		visitor := NewVisitor().SetIpAddress("1.1.1.1").SetPath(path).SetCreatedAt("2025-03-01 10:00:00")
+		if err := store.VisitorCreate(ctx, visitor); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	result, err := store.RollupBuild(ctx, day, day)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	rows, err := store.RollupList(ctx, RollupDimensionPath, day, day)
+	if err != nil || len(rows) != len(paths) {
+		t.Fatalf("expected %d path rows, got %d (%v)", len(paths), len(rows), err)
+	}
+	for _, row := range rows {
+		if strings.HasPrefix(row.Value, "/é") && (!utf8.ValidString(row.Value) || utf8.RuneCountInString(row.Value) != rollupMaxValueLength) {
+			t.Errorf("expected the long path cut to %d whole characters, got %d bytes", rollupMaxValueLength, len(row.Value))
+		}
+	}
+
+	all, err := store.RollupList(ctx, "", day, day)
+	if err != nil || len(all) != result.Rows {
+		t.Errorf("expected %d rows, got %d (%v)", result.Rows, len(all), err)
+	}
+}
+
+func TestStoreRollupBuildRetentionCutoff(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		SettingsTableName:  "settings_table",
+		AutomigrateEnabled: true,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	if _, err := store.RetentionRuleSave(ctx, RetentionRule{Name: "Bots", Target: RetentionTargetBots, MaxAgeDays: 10}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	old := time.Now().UTC().AddDate(0, 0, -20).Truncate(24 * time.Hour).Add(12 * time.Hour)
+	recent := time.Now().UTC().AddDate(0, 0, -2).Truncate(24 * time.Hour).Add(12 * time.Hour)
+	for _, at := range []time.Time{old, recent} {
+		visitor := NewVisitor().SetIpAddress("1.1.1.1").SetCreatedAt(at.Format(time.DateTime))
+		if err := store.VisitorCreate(ctx, visitor); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	result, err := store.RollupBuild(ctx, old, recent)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if result.Days != 1 || result.Protected < 10 {
+		t.Errorf("expected only the recent day to be rolled up, got %+v", result)
+	}
+
+	rows, err := store.RollupList(ctx, RollupDimensionTotal, old, old)
+	if err != nil || len(rows) != 0 {
+		t.Errorf("expected no rollup for a day past the retention cutoff, got %+v (%v)", rows, err)
+	}
+}
+
+func TestStoreUniqueVisitors(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		UniqueErrorBound:   0.02,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	for _, v := range []struct{ ip, path, createdAt string }{
+		{"1.1.1.1", "/pricing", "2025-03-01 08:00:00"},
+		{"2.2.2.2", "/", "2025-03-01 09:00:00"},
+		{"1.1.1.1", "/", "2025-03-02 08:00:00"},
+		{"3.3.3.3", "/pricing", "2025-03-02 09:00:00"},
+		{"4.4.4.4", "/", "2025-03-03 08:00:00"},
+		{"1.1.1.1", "/pricing", "2025-03-03 09:00:00"},
+	} {
+		if err := store.VisitorCreate(ctx, NewVisitor().SetIpAddress(v.ip).SetPath(v.path).SetCreatedAt(v.createdAt)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	// The third day is not rolled up and is read from the raw visits.
+	if _, err := store.RollupBuild(ctx, from, to.AddDate(0, 0, -1)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	estimate, err := store.UniqueVisitors(ctx, "", "", from, to)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if estimate.Visitors != 4 || estimate.RollupDays != 2 || estimate.RawDays != 1 {
+		t.Errorf("unexpected estimate %+v", estimate)
+	}
+	if want := 1.04 / 64; math.Abs(estimate.RelativeError-want) > 1e-9 {
+		t.Errorf("expected a relative error of %v for the configured bound, got %v", want, estimate.RelativeError)
+	}
+
+	pricing, err := store.UniqueVisitors(ctx, RollupDimensionPath, "/pricing", from, to)
+	if err != nil || pricing.Visitors != 2 {
+		t.Errorf("expected 2 visitors of /pricing, got %+v (%v)", pricing, err)
+	}
+
+	if _, err := store.UniqueVisitors(ctx, "", "", to, from); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+}
@@ -0,0 +1,390 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dromara/carbon/v2"
+)
+
+// == CONSTANTS ================================================================
+
+// Built-in detectors of the scrubber.
+const (
+	ScrubDetectorEmail     = "email"      // user@example.com, also URL-encoded
+	ScrubDetectorJWT       = "jwt"        // eyJ... JSON web tokens
+	ScrubDetectorUUID      = "uuid"       // 8-4-4-4-12 hex UUIDs
+	ScrubDetectorHexToken  = "hex_token"  // 32+ hex characters (reset tokens, hashes)
+	ScrubDetectorCard      = "card"       // 13-19 digit numbers passing the Luhn check
+	ScrubDetectorNumericID = "numeric_id" // all-digit path segments, e.g. /orders/12345
+)
+
+// ScrubDetectorsDefault are the detectors enabled when ScrubberOptions lists
+// none. Numeric IDs are opt-in, as they also match years and page numbers.
+var ScrubDetectorsDefault = []string{
+	ScrubDetectorEmail,
+	ScrubDetectorJWT,
+	ScrubDetectorUUID,
+	ScrubDetectorHexToken,
+	ScrubDetectorCard,
+}
+
+// ScrubQueryParamsDefault are the query parameters whose values are always
+// replaced when ScrubberOptions lists none.
+var ScrubQueryParamsDefault = []string{
+	"email",
+	"token",
+	"access_token",
+	"id_token",
+	"reset_token",
+	"password",
+	"secret",
+	"api_key",
+	"key",
+	"code",
+}
+
+// ScrubPlaceholderRedacted replaces the values of scrubbed query parameters.
+const ScrubPlaceholderRedacted = "[redacted]"
+
+// scrubBatchSize is the number of rows ScrubExisting loads at a time.
+const scrubBatchSize = 500
+
+var (
+	scrubEmailPattern    = regexp.MustCompile(`[A-Za-z0-9._+\-]+(?:@|%40)[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	scrubJWTPattern      = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]{5,}\.eyJ[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]*`)
+	scrubUUIDPattern     = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	scrubHexTokenPattern = regexp.MustCompile(`(?i)\b[0-9a-f]{32,}\b`)
+	scrubCardPattern     = regexp.MustCompile(`\b(?:\d[ +\-]?){12,18}\d\b`)
+)
+
+// == TYPES ====================================================================
+
+// ScrubRule is a user-defined scrubbing rule: every match of Pattern is
+// replaced with Replacement, which may refer to groups ($1, ${name}).
+type ScrubRule struct {
+	Name        string
+	Pattern     string
+	Replacement string
+}
+
+// ScrubberOptions configures a Scrubber.
+type ScrubberOptions struct {
+	// Detectors lists the built-in detectors to run; default
+	// ScrubDetectorsDefault. Use an empty, non-nil slice for none.
+	Detectors []string
+
+	// QueryParams lists the query parameters (case-insensitive) whose
+	// values are replaced with ScrubPlaceholderRedacted; default
+	// ScrubQueryParamsDefault. Use an empty, non-nil slice for none.
+	QueryParams []string
+
+	// Rules run before the built-in detectors, in order.
+	Rules []ScrubRule
+}
+
+// ScrubResult is the outcome of ScrubExisting.
+type ScrubResult struct {
+	VisitorsScanned int `json:"visitors_scanned"`
+	VisitorsUpdated int `json:"visitors_updated"`
+	EventsScanned   int `json:"events_scanned"`
+	EventsUpdated   int `json:"events_updated"`
+}
+
+type scrubPattern struct {
+	pattern     *regexp.Regexp
+	replacement string
+	replace     func(match string) string
+}
+
+// Scrubber removes personal data such as emails, tokens and card numbers
+// from paths, referrers and query strings before they are stored. Set it
+// with NewStoreOptions.Scrubber. A Scrubber is safe for concurrent use.
+type Scrubber struct {
+	patterns    []scrubPattern
+	queryParams map[string]bool
+	numericIDs  bool
+}
+
+// == CONSTRUCTOR ==============================================================
+
+// NewScrubber compiles a scrubber. It returns an error for an unknown
+// detector or an invalid rule pattern.
+func NewScrubber(opts ScrubberOptions) (*Scrubber, error) {
+	detectors := opts.Detectors
+	if detectors == nil {
+		detectors = ScrubDetectorsDefault
+	}
+	queryParams := opts.QueryParams
+	if queryParams == nil {
+		queryParams = ScrubQueryParamsDefault
+	}
+
+	s := &Scrubber{queryParams: map[string]bool{}}
+	for _, param := range queryParams {
+		if param = strings.ToLower(strings.TrimSpace(param)); param != "" {
+			s.queryParams[param] = true
+		}
+	}
+
+	for _, rule := range opts.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errors.New("scrub rule " + rule.Name + ": " + err.Error())
+		}
+		s.patterns = append(s.patterns, scrubPattern{pattern: pattern, replacement: rule.Replacement})
+	}
+
+	// Built-in detectors run in a fixed order, so e.g. a JWT is not
+	// partially replaced as a hex token.
+	known := []string{ScrubDetectorEmail, ScrubDetectorJWT, ScrubDetectorUUID, ScrubDetectorHexToken, ScrubDetectorCard, ScrubDetectorNumericID}
+	for _, detector := range detectors {
+		if !slices.Contains(known, detector) {
+			return nil, errors.New("unknown scrub detector: " + detector)
+		}
+	}
+	for _, detector := range known {
+		if !slices.Contains(detectors, detector) {
+			continue
+		}
+		switch detector {
+		case ScrubDetectorEmail:
+			s.patterns = append(s.patterns, scrubPattern{pattern: scrubEmailPattern, replacement: "[email]"})
+		case ScrubDetectorJWT:
+			s.patterns = append(s.patterns, scrubPattern{pattern: scrubJWTPattern, replacement: "[jwt]"})
+		case ScrubDetectorUUID:
+			s.patterns = append(s.patterns, scrubPattern{pattern: scrubUUIDPattern, replacement: "[uuid]"})
+		case ScrubDetectorHexToken:
+			s.patterns = append(s.patterns, scrubPattern{pattern: scrubHexTokenPattern, replacement: "[token]"})
+		case ScrubDetectorCard:
+			s.patterns = append(s.patterns, scrubPattern{pattern: scrubCardPattern, replace: scrubCard})
+		case ScrubDetectorNumericID:
+			s.numericIDs = true
+		}
+	}
+
+	return s, nil
+}
+
+// == PUBLIC METHODS ===========================================================
+
+// Scrub returns value, a path or URL, with its personal data replaced by
+// placeholders: the values of sensitive query parameters first, then the
+// matches of the rules and detectors.
+func (s *Scrubber) Scrub(value string) string {
+	if s == nil || value == "" {
+		return value
+	}
+
+	base, query, fragment := value, "", ""
+	if i := strings.IndexByte(base, '#'); i >= 0 {
+		base, fragment = base[:i], base[i:]
+	}
+	if i := strings.IndexByte(base, '?'); i >= 0 {
+		base, query = base[:i], base[i+1:]
+	}
+
+	if s.numericIDs {
+		base = scrubNumericSegments(base)
+	}
+
+	scrubbed := base
+	if query != "" {
+		scrubbed += "?" + s.scrubQuery(query)
+	}
+	scrubbed += fragment
+
+	for _, p := range s.patterns {
+		if p.replace != nil {
+			scrubbed = p.pattern.ReplaceAllStringFunc(scrubbed, p.replace)
+		} else {
+			scrubbed = p.pattern.ReplaceAllString(scrubbed, p.replacement)
+		}
+	}
+
+	return scrubbed
+}
+
+// scrubQuery replaces the values of sensitive parameters in a raw query
+// string, keeping the order and encoding of the others.
+func (s *Scrubber) scrubQuery(query string) string {
+	if len(s.queryParams) == 0 {
+		return query
+	}
+
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		name, _, hasValue := strings.Cut(pair, "=")
+		if hasValue && s.queryParams[strings.ToLower(name)] {
+			pairs[i] = name + "=" + ScrubPlaceholderRedacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// == STORE ====================================================================
+
+// scrubVisitor scrubs the path and referrer of a visitor in place.
+func (st *storeImplementation) scrubVisitor(visitor VisitorInterface) {
+	if st.scrubber == nil {
+		return
+	}
+	visitor.SetPath(st.scrubber.Scrub(visitor.GetPath()))
+	visitor.SetUserReferrer(st.scrubber.Scrub(visitor.GetUserReferrer()))
+}
+
+// scrubEvent scrubs the path and referrer of an event in place.
+func (st *storeImplementation) scrubEvent(event EventInterface) {
+	if st.scrubber == nil {
+		return
+	}
+	event.SetPath(st.scrubber.Scrub(event.GetPath()))
+	event.SetReferrer(st.scrubber.Scrub(event.GetReferrer()))
+}
+
+// ScrubExisting runs the configured scrubber over the paths and referrers
+// of the stored visitors (soft-deleted ones included) and events, in
+// batches, updating the rows that change. Run it once after enabling or
+// extending scrubbing.
+//
+// If no Scrubber was configured, it returns an error.
+func (st *storeImplementation) ScrubExisting(ctx context.Context) (ScrubResult, error) {
+	result := ScrubResult{}
+	if st.scrubber == nil {
+		return result, errors.New("stats store: no Scrubber configured")
+	}
+
+	for offset := 0; ; offset += scrubBatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		visitors, err := st.VisitorList(ctx, VisitorQuery().
+			SetSoftDeletedIncluded(true).
+			SetOrderBy(COLUMN_ID).
+			SetSortOrder("ASC").
+			SetLimit(scrubBatchSize).
+			SetOffset(offset))
+		if err != nil {
+			return result, err
+		}
+
+		for _, visitor := range visitors {
+			result.VisitorsScanned++
+			path := st.scrubber.Scrub(visitor.GetPath())
+			referrer := st.scrubber.Scrub(visitor.GetUserReferrer())
+			if path == visitor.GetPath() && referrer == visitor.GetUserReferrer() {
+				continue
+			}
+			if err := st.scrubUpdate(st.visitorTableName, visitor.GetID(), path, referrer); err != nil {
+				return result, err
+			}
+			result.VisitorsUpdated++
+		}
+
+		if len(visitors) < scrubBatchSize {
+			break
+		}
+	}
+
+	for offset := 0; ; offset += scrubBatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		events, err := st.EventList(ctx, EventQuery().
+			SetOrderBy(COLUMN_ID).
+			SetSortOrder("ASC").
+			SetLimit(scrubBatchSize).
+			SetOffset(offset))
+		if err != nil {
+			return result, err
+		}
+
+		for _, event := range events {
+			result.EventsScanned++
+			path := st.scrubber.Scrub(event.GetPath())
+			referrer := st.scrubber.Scrub(event.GetReferrer())
+			if path == event.GetPath() && referrer == event.GetReferrer() {
+				continue
+			}
+			if err := st.scrubUpdate(st.eventTableName, event.GetID(), path, referrer); err != nil {
+				return result, err
+			}
+			result.EventsUpdated++
+		}
+
+		if len(events) < scrubBatchSize {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// scrubUpdate stores the scrubbed path and referrer of one row.
+func (st *storeImplementation) scrubUpdate(table, id, path, referrer string) error {
+	_, err := st.db.Query().
+		Table(table).
+		Where(COLUMN_ID+" = ?", id).
+		Update(map[string]any{
+			COLUMN_PATH:          path,
+			COLUMN_USER_REFERRER: referrer,
+			COLUMN_UPDATED_AT:    carbon.Now(carbon.UTC).StdTime(),
+		})
+	return err
+}
+
+// == PRIVATE FUNCTIONS ========================================================
+
+// scrubCard replaces a card-like number that passes the Luhn check.
+func scrubCard(match string) string {
+	digits := make([]int, 0, len(match))
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	if sum%10 != 0 {
+		return match
+	}
+	return "[card]"
+}
+
+// scrubNumericSegments replaces the all-digit segments of a path with
+// "[id]".
+func scrubNumericSegments(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		numeric := true
+		for _, r := range segment {
+			if r < '0' || r > '9' {
+				numeric = false
+				break
+			}
+		}
+		if numeric {
+			segments[i] = "[id]"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,47 @@
+package statsstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+)
+
+// signatureParam is the query parameter carrying a URL signature.
+const signatureParam = "sig"
+
+// paramsSignature returns the unpadded base64url HMAC-SHA256 of params in
+// their canonical (sorted) encoding, without any sig parameter.
+func paramsSignature(secret []byte, params url.Values) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsignedParams(params).Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyParamsSignature reports whether params carry a valid sig parameter
+// for secret.
+func verifyParamsSignature(secret []byte, params url.Values) bool {
+	signature := params.Get(signatureParam)
+	if signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(paramsSignature(secret, params)))
+}
+
+// signParams returns a copy of params with their sig parameter set.
+func signParams(secret []byte, params url.Values) url.Values {
+	signed := unsignedParams(params)
+	signed.Set(signatureParam, paramsSignature(secret, signed))
+	return signed
+}
+
+// unsignedParams returns a copy of params without the sig parameter.
+func unsignedParams(params url.Values) url.Values {
+	unsigned := url.Values{}
+	for key, values := range params {
+		if key != signatureParam {
+			unsigned[key] = values
+		}
+	}
+	return unsigned
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dracory/neat"
+	contractsdatabase "github.com/dracory/neat/contracts/database"
 	contractsorm "github.com/dracory/neat/contracts/database/orm"
 	contractsschema "github.com/dracory/neat/contracts/database/schema"
-	"github.com/dracory/req"
 	"github.com/dromara/carbon/v2"
 )
 
@@ -26,6 +31,10 @@ var _ StoreInterface = (*storeImplementation)(nil)
 type storeImplementation struct {
 	visitorTableName     string
 	settingsTableName    string
+	eventTableName       string
+	auditTableName       string
+	rollupTableName      string
+	hllPrecision         uint8
 	db                   *neat.Database
 	automigrateEnabled   bool
 	debugEnabled         bool
@@ -33,64 +42,84 @@ type storeImplementation struct {
 	botAutoTagEnabled    bool
 	excludedPathPrefixes []string
 	excludedIPs          []string
+	clientIPOptions      ClientIPOptions
+	trustedProxies       []netip.Prefix
 	geoIPResolver        GeoIPResolver
 	enhanceBatchSize     int
+	enhancerOptions      EnhancerOptions
+	enhancer             *enhancer
+	enhancerMu           sync.Mutex
+	enrichers            []Enricher
+	enrichersMu          sync.RWMutex
+	enrichAtIngestion    bool
+	userAgentParser      UserAgentParser
+	eventBackfillWindow  time.Duration
+	eventTrackMu         sync.Mutex
+	userIDResolver       UserIDResolver
+	privacyOptions       PrivacyOptions
+	privacyMu            sync.RWMutex
+	privacySkipped       atomic.Int64
+	settingsMu           sync.Mutex
+	scrubber             *Scrubber
+	ipCipher             *ipCipher
 	logger               *slog.Logger
 }
 
 // == MIGRATE ==================================================================
 
-// MigrateUp creates the visitor table and excluded IPs table if they do not already exist.
+// MigrateUp creates the visitor, event and settings tables if they do not already exist.
 func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) error {
 	if st.db.Schema().HasTable(st.visitorTableName) {
 		if st.debugEnabled {
 			st.logger.Info("MigrateUp: table already exists", "table", st.visitorTableName)
 		}
 
-		// Add bot/threat columns to existing tables that predate them.
+		// Add columns to existing tables that predate them.
 		// neat's HasColumn guards against re-adding.
-		if !st.db.Schema().HasColumn(st.visitorTableName, COLUMN_BOT) {
-			if err := st.db.Schema().Table(st.visitorTableName, func(table contractsschema.Blueprint) {
-				table.String(COLUMN_BOT, 3).Default(VALUE_NO)
-			}); err != nil {
-				if st.debugEnabled {
-					st.logger.Error("MigrateUp: add bot column failed", "error", err)
-				}
-				return err
-			}
+		columns := []struct {
+			name   string
+			define func(table contractsschema.Blueprint)
+		}{
+			{COLUMN_BOT, func(table contractsschema.Blueprint) { table.String(COLUMN_BOT, 3).Default(VALUE_NO) }},
+			{COLUMN_THREAT, func(table contractsschema.Blueprint) { table.String(COLUMN_THREAT, 3).Default(VALUE_NO) }},
+			{COLUMN_PEER_IP_ADDRESS, func(table contractsschema.Blueprint) { table.String(COLUMN_PEER_IP_ADDRESS, ipColumnLength) }},
+			{COLUMN_CHANNEL, func(table contractsschema.Blueprint) { table.String(COLUMN_CHANNEL, 40) }},
+			{COLUMN_TAGS, func(table contractsschema.Blueprint) { table.String(COLUMN_TAGS, 510) }},
+			{COLUMN_ENRICHMENT, func(table contractsschema.Blueprint) { table.String(COLUMN_ENRICHMENT, 255) }},
+			{COLUMN_USER_DEVICE_BRAND, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_DEVICE_BRAND, 40) }},
+			{COLUMN_USER_DEVICE_MODEL, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_DEVICE_MODEL, 64) }},
+			{COLUMN_CLIENT_HINTS, func(table contractsschema.Blueprint) { table.Text(COLUMN_CLIENT_HINTS) }},
+			{COLUMN_USER_BROWSER_ENGINE, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_BROWSER_ENGINE, 12) }},
+			{COLUMN_USER_WEBVIEW, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_WEBVIEW, 3).Default(VALUE_NO) }},
+			{COLUMN_USER_APP, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_APP, 40) }},
+			{COLUMN_PAGE_TITLE, func(table contractsschema.Blueprint) { table.String(COLUMN_PAGE_TITLE, 255) }},
+			{COLUMN_SCREEN_SIZE, func(table contractsschema.Blueprint) { table.String(COLUMN_SCREEN_SIZE, 12) }},
+			{COLUMN_ENGAGEMENT_SECONDS, func(table contractsschema.Blueprint) { table.String(COLUMN_ENGAGEMENT_SECONDS, 10) }},
+			{COLUMN_SCROLL_DEPTH, func(table contractsschema.Blueprint) { table.String(COLUMN_SCROLL_DEPTH, 3) }},
+			{COLUMN_CAMPAIGN, func(table contractsschema.Blueprint) { table.String(COLUMN_CAMPAIGN, 120) }},
+			{COLUMN_USER_ID, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_ID, 64) }},
+			{COLUMN_PRIVACY, func(table contractsschema.Blueprint) { table.String(COLUMN_PRIVACY, 12) }},
+			{COLUMN_IP_INDEX, func(table contractsschema.Blueprint) { table.String(COLUMN_IP_INDEX, 64) }},
 		}
-		if !st.db.Schema().HasColumn(st.visitorTableName, COLUMN_THREAT) {
-			if err := st.db.Schema().Table(st.visitorTableName, func(table contractsschema.Blueprint) {
-				table.String(COLUMN_THREAT, 3).Default(VALUE_NO)
-			}); err != nil {
-				if st.debugEnabled {
-					st.logger.Error("MigrateUp: add threat column failed", "error", err)
-				}
+		for _, column := range columns {
+			if err := st.migrateAddColumn(st.visitorTableName, column.name, column.define); err != nil {
 				return err
 			}
 		}
 
-		// Add indexes on bot/threat columns for existing tables that predate them.
-		// neat generates index names as "{table}_{column}_index".
-		botIndexName := strings.ToLower(st.visitorTableName + "_" + COLUMN_BOT + "_index")
-		if !st.db.Schema().HasIndex(st.visitorTableName, botIndexName) {
-			if err := st.db.Schema().Table(st.visitorTableName, func(table contractsschema.Blueprint) {
-				table.Index(COLUMN_BOT)
-			}); err != nil {
-				if st.debugEnabled {
-					st.logger.Error("MigrateUp: add bot index failed", "error", err)
-				}
+		// Widen columns that were created too narrow by earlier versions.
+		if err := st.migrateWidenColumn(st.visitorTableName, COLUMN_USER_OS, 40); err != nil {
+			return err
+		}
+		for _, column := range []string{COLUMN_IP_ADDRESS, COLUMN_PEER_IP_ADDRESS} {
+			if err := st.migrateWidenColumn(st.visitorTableName, column, ipColumnLength); err != nil {
 				return err
 			}
 		}
-		threatIndexName := strings.ToLower(st.visitorTableName + "_" + COLUMN_THREAT + "_index")
-		if !st.db.Schema().HasIndex(st.visitorTableName, threatIndexName) {
-			if err := st.db.Schema().Table(st.visitorTableName, func(table contractsschema.Blueprint) {
-				table.Index(COLUMN_THREAT)
-			}); err != nil {
-				if st.debugEnabled {
-					st.logger.Error("MigrateUp: add threat index failed", "error", err)
-				}
+
+		// Add indexes for existing tables that predate them.
+		for _, column := range []string{COLUMN_BOT, COLUMN_THREAT, COLUMN_USER_ID, COLUMN_IP_INDEX} {
+			if err := st.migrateAddIndex(st.visitorTableName, column); err != nil {
 				return err
 			}
 		}
@@ -100,12 +129,12 @@ func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) err
 			table.Primary(COLUMN_ID)
 			table.String(COLUMN_PATH, 510)
 			table.String(COLUMN_FINGERPRINT, 40)
-			table.String(COLUMN_IP_ADDRESS, 40)
+			table.String(COLUMN_IP_ADDRESS, ipColumnLength)
 			table.String(COLUMN_COUNTRY, 2)
 			table.String(COLUMN_USER_ACCEPT_LANGUAGE, 100)
 			table.String(COLUMN_USER_ACCEPT_ENCODING, 40)
 			table.String(COLUMN_USER_AGENT, 510)
-			table.String(COLUMN_USER_OS, 12)
+			table.String(COLUMN_USER_OS, 40)
 			table.String(COLUMN_USER_OS_VERSION, 12)
 			table.String(COLUMN_USER_DEVICE, 40)
 			table.String(COLUMN_USER_DEVICE_TYPE, 12)
@@ -114,6 +143,24 @@ func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) err
 			table.String(COLUMN_USER_REFERRER, 510)
 			table.String(COLUMN_BOT, 3).Default(VALUE_NO)
 			table.String(COLUMN_THREAT, 3).Default(VALUE_NO)
+			table.String(COLUMN_PEER_IP_ADDRESS, ipColumnLength)
+			table.String(COLUMN_CHANNEL, 40)
+			table.String(COLUMN_TAGS, 510)
+			table.String(COLUMN_ENRICHMENT, 255)
+			table.String(COLUMN_USER_DEVICE_BRAND, 40)
+			table.String(COLUMN_USER_DEVICE_MODEL, 64)
+			table.Text(COLUMN_CLIENT_HINTS)
+			table.String(COLUMN_USER_BROWSER_ENGINE, 12)
+			table.String(COLUMN_USER_WEBVIEW, 3).Default(VALUE_NO)
+			table.String(COLUMN_USER_APP, 40)
+			table.String(COLUMN_PAGE_TITLE, 255)
+			table.String(COLUMN_SCREEN_SIZE, 12)
+			table.String(COLUMN_ENGAGEMENT_SECONDS, 10)
+			table.String(COLUMN_SCROLL_DEPTH, 3)
+			table.String(COLUMN_CAMPAIGN, 120)
+			table.String(COLUMN_USER_ID, 64)
+			table.String(COLUMN_PRIVACY, 12)
+			table.String(COLUMN_IP_INDEX, 64)
 			table.DateTime(COLUMN_CREATED_AT)
 			table.DateTime(COLUMN_UPDATED_AT)
 			table.DateTime(COLUMN_SOFT_DELETED_AT)
@@ -123,6 +170,8 @@ func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) err
 			table.Index(COLUMN_FINGERPRINT)
 			table.Index(COLUMN_BOT)
 			table.Index(COLUMN_THREAT)
+			table.Index(COLUMN_USER_ID)
+			table.Index(COLUMN_IP_INDEX)
 		})
 
 		if err != nil {
@@ -133,6 +182,18 @@ func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) err
 		}
 	}
 
+	if err := st.migrateEventTable(); err != nil {
+		return err
+	}
+
+	if err := st.migrateAuditTable(); err != nil {
+		return err
+	}
+
+	if err := st.migrateRollupTable(); err != nil {
+		return err
+	}
+
 	if st.settingsTableName != "" && !st.db.Schema().HasTable(st.settingsTableName) {
 		err := st.db.Schema().Create(st.settingsTableName, func(table contractsschema.Blueprint) {
 			table.String(COLUMN_KEY, 100)
@@ -153,8 +214,147 @@ func (st *storeImplementation) MigrateUp(ctx context.Context, tx ...*sql.Tx) err
 	return nil
 }
 
+// migrateAddColumn adds a column to an existing table if it is missing.
+func (st *storeImplementation) migrateAddColumn(tableName, column string, define func(table contractsschema.Blueprint)) error {
+	if st.db.Schema().HasColumn(tableName, column) {
+		return nil
+	}
+
+	if err := st.db.Schema().Table(tableName, define); err != nil {
+		if st.debugEnabled {
+			st.logger.Error("MigrateUp: add column failed", "table", tableName, "column", column, "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// migrateAddIndex adds a single-column index to an existing table if it is
+// missing. neat generates index names as "{table}_{column}_index".
+func (st *storeImplementation) migrateAddIndex(tableName, column string) error {
+	indexName := strings.ToLower(tableName + "_" + column + "_index")
+	if st.db.Schema().HasIndex(tableName, indexName) {
+		return nil
+	}
+
+	if err := st.db.Schema().Table(tableName, func(table contractsschema.Blueprint) {
+		table.Index(column)
+	}); err != nil {
+		if st.debugEnabled {
+			st.logger.Error("MigrateUp: add index failed", "table", tableName, "column", column, "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// migrateWidenColumn increases the length of a VARCHAR column on an existing
+// table when it is shorter than length. SQLite does not enforce VARCHAR
+// lengths, so only MySQL and PostgreSQL columns are altered.
+func (st *storeImplementation) migrateWidenColumn(tableName, column string, length int) error {
+	driver := st.db.Query().Driver()
+	if driver != contractsdatabase.DriverMysql && driver != contractsdatabase.DriverPostgres {
+		return nil
+	}
+
+	columns, err := st.db.Schema().GetColumns(tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if c.Name != column {
+			continue
+		}
+
+		// Types are reported as e.g. "varchar(12)" or "character varying(12)".
+		current := 0
+		if open := strings.LastIndex(c.Type, "("); open >= 0 {
+			current, _ = strconv.Atoi(strings.TrimSuffix(c.Type[open+1:], ")"))
+		}
+		if current == 0 || current >= length {
+			return nil
+		}
+
+		var sqlStr string
+		if driver == contractsdatabase.DriverMysql {
+			// MODIFY replaces the whole definition, so the nullability,
+			// default, collation and comment are repeated.
+			sqlStr = fmt.Sprintf("ALTER TABLE `%s` MODIFY `%s` VARCHAR(%d)", tableName, column, length) + mysqlColumnAttributes(c)
+		} else {
+			sqlStr = fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" TYPE VARCHAR(%d)`, tableName, column, length)
+		}
+
+		if err := st.db.Schema().Sql(sqlStr); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("MigrateUp: widen column failed", "table", tableName, "column", column, "error", err)
+			}
+			return err
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// mysqlColumnAttributes returns the attributes of an existing MySQL column
+// as they follow its type in a column definition. neat reports a missing
+// default as an empty one, so a NOT NULL column keeps an empty-string
+// default.
+func mysqlColumnAttributes(c contractsschema.Column) string {
+	quote := func(value string) string {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), "'", "''") + "'"
+	}
+
+	attributes := ""
+	if c.Collation != "" {
+		attributes += " COLLATE " + c.Collation
+	}
+	if c.Nullable {
+		attributes += " NULL"
+	} else {
+		attributes += " NOT NULL"
+	}
+	if c.Default != "" || !c.Nullable {
+		attributes += " DEFAULT " + quote(c.Default)
+	}
+	if c.Comment != "" {
+		attributes += " COMMENT " + quote(c.Comment)
+	}
+	return attributes
+}
+
 // MigrateDown drops the visitor table and settings table.
 func (st *storeImplementation) MigrateDown(ctx context.Context, tx ...*sql.Tx) error {
+	if st.rollupTableName != "" && st.db.Schema().HasTable(st.rollupTableName) {
+		if err := st.db.Schema().Drop(st.rollupTableName); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("MigrateDown: rollup table drop failed", "error", err)
+			}
+			return err
+		}
+	}
+
+	if st.auditTableName != "" && st.db.Schema().HasTable(st.auditTableName) {
+		if err := st.db.Schema().Drop(st.auditTableName); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("MigrateDown: audit table drop failed", "error", err)
+			}
+			return err
+		}
+	}
+
+	if st.eventTableName != "" && st.db.Schema().HasTable(st.eventTableName) {
+		if err := st.db.Schema().Drop(st.eventTableName); err != nil {
+			if st.debugEnabled {
+				st.logger.Error("MigrateDown: event table drop failed", "error", err)
+			}
+			return err
+		}
+	}
+
 	if st.settingsTableName != "" && st.db.Schema().HasTable(st.settingsTableName) {
 		if err := st.db.Schema().Drop(st.settingsTableName); err != nil {
 			if st.debugEnabled {
@@ -258,44 +458,37 @@ func (st *storeImplementation) GetExcludedIPs() []string {
 // == VISITOR OPERATIONS =======================================================
 
 // VisitorRegister creates a visitor from an HTTP request.
+// The client IP is resolved via ResolveClientIP using the configured trusted
+// proxies, and the direct peer IP is recorded alongside it.
 // If BotFilterEnabled is true, bot/threat traffic is skipped (not inserted).
 // If BotAutoTagEnabled is true, bot/threat flags are computed and set on the
 // row. The two flags are independent.
 func (st *storeImplementation) VisitorRegister(ctx context.Context, r *http.Request) error {
-	path := r.URL.Path
-
-	for _, prefix := range st.excludedPathPrefixes {
-		if strings.HasPrefix(path, prefix) {
-			if st.debugEnabled {
-				st.logger.Info("path-filter: skipping excluded path", "path", path, "prefix", prefix)
-			}
-			return nil
-		}
-	}
+	_, err := st.VisitorRegisterHit(ctx, r, PageHit{
+		Path:     r.URL.Path,
+		Referrer: r.Header.Get("Referer"),
+	})
+	return err
+}
 
-	ip := req.GetIP(r)
+// VisitorRegisterHit creates a visitor for a page hit reported on behalf of
+// request r, e.g. by the JavaScript tracker. The path, referrer, title and
+// screen size come from hit; the IP, user agent and client hints come from
+// r. Filtering and enrichment are the same as for VisitorRegister.
+// Returns the created visitor, or nil when the hit was filtered out.
+func (st *storeImplementation) VisitorRegisterHit(ctx context.Context, r *http.Request, hit PageHit) (VisitorInterface, error) {
+	path := hit.Path
+	ip, peerIP := resolveClientIP(r, st.clientIPOptions, st.trustedProxies)
 	userAgent := r.UserAgent()
-	referrer := r.Header.Get("Referer")
+	referrer := hit.Referrer
 
-	if slices.Contains(st.excludedIPs, ip) {
-		if st.debugEnabled {
-			st.logger.Info("ip-filter: skipping excluded IP", "ip", ip)
-		}
-		return nil
+	if st.isFilteredHit(path, ip, userAgent, referrer) {
+		return nil, nil
 	}
 
-	// BotFilterEnabled: detect and skip bot/threat traffic at ingestion.
-	if st.botFilterEnabled {
-		isBot := IsBot(userAgent) || IsReferrerSpam(referrer) || IsDataCenterIP(ip) || IsBotPath(path)
-		isThreat := IsMaliciousPath(path)
-
-		if isBot || isThreat {
-			if st.debugEnabled {
-				st.logger.Info("bot-filter: skipping bot/threat visit",
-					"user_agent", userAgent, "ip", ip, "path", path)
-			}
-			return nil
-		}
+	privacyReason, skip := st.privacyDecision(r)
+	if skip {
+		return nil, nil
 	}
 
 	// BotAutoTagEnabled: compute and set bot/threat flags on the row.
@@ -320,11 +513,15 @@ func (st *storeImplementation) VisitorRegister(ctx context.Context, r *http.Requ
 		}
 	}
 
-	uaInfo := ParseUserAgent(userAgent)
+	// Client hints carry the real OS version and device model that
+	// Chromium's reduced UA string hides.
+	clientHints := ClientHintsFromRequest(r)
+	uaInfo := clampUserAgentInfo(ApplyClientHints(st.userAgentParser.Parse(userAgent), clientHints))
 
 	visitor := NewVisitor().
 		SetPath(path).
 		SetIpAddress(ip).
+		SetPeerIpAddress(peerIP).
 		SetUserAgent(userAgent).
 		SetUserBrowser(uaInfo.Browser).
 		SetUserBrowserVersion(uaInfo.BrowserVersion).
@@ -332,19 +529,103 @@ func (st *storeImplementation) VisitorRegister(ctx context.Context, r *http.Requ
 		SetUserOsVersion(uaInfo.OsVersion).
 		SetUserDevice(uaInfo.Device).
 		SetUserDeviceType(uaInfo.DeviceType).
+		SetUserDeviceBrand(uaInfo.DeviceBrand).
+		SetUserDeviceModel(uaInfo.DeviceModel).
+		SetClientHints(clientHints.Encode()).
+		SetUserBrowserEngine(uaInfo.Engine).
+		SetUserWebview(yesNo(uaInfo.Webview)).
+		SetUserApp(uaInfo.App).
+		SetPageTitle(hit.Title).
+		SetScreenSize(hit.ScreenSize()).
+		SetCampaign(hit.Campaign).
+		SetUserID(st.requestUserID(ctx, r)).
 		SetUserReferrer(referrer).
 		SetBot(botVal).
 		SetThreat(threatVal)
 
-	return st.VisitorCreate(ctx, visitor)
+	if hit.Fingerprint != "" {
+		visitor.SetFingerprint(hit.Fingerprint)
+	} else {
+		// Stored so that data-subject requests by fingerprint find the row.
+		visitor.SetFingerprint(visitor.FingerprintCalculate())
+	}
+
+	if st.enrichAtIngestion {
+		// The IP of an aggregate-only visit must not leave the process, so
+		// only the local enrichers run before it is stripped; the others
+		// run in batch on the stripped row.
+		var only func(Enricher) bool
+		if privacyReason != "" {
+			only = enricherIsLocal
+		}
+
+		// Enricher failures must not drop the visit; failed enrichers are
+		// left unrecorded and picked up by VisitorEnrichBatch.
+		if err := st.visitorEnrich(ctx, visitor, only); err != nil && st.debugEnabled {
+			st.logger.Info("VisitorRegister: enrichment incomplete", "error", err)
+		}
+	}
+
+	if privacyReason != "" {
+		privacyAnonymizeVisitor(visitor, privacyReason)
+	}
+
+	if err := st.VisitorCreate(ctx, visitor); err != nil {
+		return nil, err
+	}
+
+	return visitor, nil
+}
+
+// isFilteredHit reports whether a hit must be skipped at ingestion because of
+// an excluded path prefix, an excluded IP or, when BotFilterEnabled is set,
+// bot/threat detection.
+func (st *storeImplementation) isFilteredHit(path, ip, userAgent, referrer string) bool {
+	for _, prefix := range st.excludedPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			if st.debugEnabled {
+				st.logger.Info("path-filter: skipping excluded path", "path", path, "prefix", prefix)
+			}
+			return true
+		}
+	}
+
+	if slices.Contains(st.excludedIPs, ip) {
+		if st.debugEnabled {
+			st.logger.Info("ip-filter: skipping excluded IP", "ip", ip)
+		}
+		return true
+	}
+
+	// BotFilterEnabled: detect and skip bot/threat traffic at ingestion.
+	if st.botFilterEnabled {
+		isBot := IsBot(userAgent) || IsReferrerSpam(referrer) || IsDataCenterIP(ip) || IsBotPath(path)
+		isThreat := IsMaliciousPath(path)
+
+		if isBot || isThreat {
+			if st.debugEnabled {
+				st.logger.Info("bot-filter: skipping bot/threat visit",
+					"user_agent", userAgent, "ip", ip, "path", path)
+			}
+			return true
+		}
+	}
+
+	return false
 }
 
 // VisitorCount counts visitors based on a query.
 func (st *storeImplementation) VisitorCount(ctx context.Context, query VisitorQueryInterface) (int64, error) {
 	if query.HasDistinct() && query.Distinct() != "" {
+		distinct := query.Distinct()
+		if distinct == COLUMN_IP_ADDRESS && st.ipCipher != nil {
+			// Encrypted IPs differ per row; the blind index does not.
+			distinct = COLUMN_IP_INDEX
+		}
+
 		q := st.buildQuery(query)
 		var results []map[string]any
-		err := q.Select("DISTINCT " + query.Distinct()).Get(&results)
+		err := q.Select("DISTINCT " + distinct).Get(&results)
 		if err != nil {
 			return 0, err
 		}
@@ -400,13 +681,24 @@ func (st *storeImplementation) VisitorCreate(ctx context.Context, visitor Visito
 	}
 	visitor.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
 
+	st.scrubVisitor(visitor)
 	st.ensureBotThreatFlags(visitor)
 
+	ip, ipIndex, err := st.ipSeal(visitor.GetIpAddress())
+	if err != nil {
+		return err
+	}
+	peerIP, _, err := st.ipSeal(visitor.GetPeerIpAddress())
+	if err != nil {
+		return err
+	}
+
 	row := map[string]any{
 		COLUMN_ID:                   visitor.GetID(),
 		COLUMN_PATH:                 visitor.GetPath(),
 		COLUMN_FINGERPRINT:          visitor.GetFingerprint(),
-		COLUMN_IP_ADDRESS:           visitor.GetIpAddress(),
+		COLUMN_IP_ADDRESS:           ip,
+		COLUMN_IP_INDEX:             ipIndex,
 		COLUMN_COUNTRY:              visitor.GetCountry(),
 		COLUMN_USER_ACCEPT_LANGUAGE: visitor.GetUserAcceptLanguage(),
 		COLUMN_USER_ACCEPT_ENCODING: visitor.GetUserAcceptEncoding(),
@@ -420,6 +712,23 @@ func (st *storeImplementation) VisitorCreate(ctx context.Context, visitor Visito
 		COLUMN_USER_REFERRER:        visitor.GetUserReferrer(),
 		COLUMN_BOT:                  visitor.GetBot(),
 		COLUMN_THREAT:               visitor.GetThreat(),
+		COLUMN_PEER_IP_ADDRESS:      peerIP,
+		COLUMN_CHANNEL:              visitor.GetChannel(),
+		COLUMN_TAGS:                 visitor.GetTags(),
+		COLUMN_ENRICHMENT:           visitor.GetEnrichment(),
+		COLUMN_USER_DEVICE_BRAND:    visitor.GetUserDeviceBrand(),
+		COLUMN_USER_DEVICE_MODEL:    visitor.GetUserDeviceModel(),
+		COLUMN_CLIENT_HINTS:         visitor.GetClientHints(),
+		COLUMN_USER_BROWSER_ENGINE:  visitor.GetUserBrowserEngine(),
+		COLUMN_USER_WEBVIEW:         visitor.GetUserWebview(),
+		COLUMN_USER_APP:             visitor.GetUserApp(),
+		COLUMN_PAGE_TITLE:           visitor.GetPageTitle(),
+		COLUMN_SCREEN_SIZE:          visitor.GetScreenSize(),
+		COLUMN_ENGAGEMENT_SECONDS:   visitor.GetEngagementSeconds(),
+		COLUMN_SCROLL_DEPTH:         visitor.GetScrollDepth(),
+		COLUMN_CAMPAIGN:             visitor.GetCampaign(),
+		COLUMN_USER_ID:              visitor.GetUserID(),
+		COLUMN_PRIVACY:              visitor.GetPrivacy(),
 		COLUMN_CREATED_AT:           visitor.GetCreatedAtCarbon().StdTime(),
 		COLUMN_UPDATED_AT:           visitor.GetUpdatedAtCarbon().StdTime(),
 		COLUMN_SOFT_DELETED_AT:      visitor.GetSoftDeletedAtCarbon().StdTime(),
@@ -457,10 +766,8 @@ func (st *storeImplementation) VisitorDeleteByIP(ctx context.Context, ip string)
 		return 0, errors.New("visitor ip is empty")
 	}
 
-	rowsAffected, err := st.db.Query().
-		Table(st.visitorTableName).
-		Where(COLUMN_IP_ADDRESS+" = ?", ip).
-		Delete()
+	q := st.db.Query().Table(st.visitorTableName)
+	rowsAffected, err := st.whereIPIn(q, []string{ip}).Delete()
 	if err != nil {
 		return 0, err
 	}
@@ -488,8 +795,11 @@ func (st *storeImplementation) VisitorFindByID(ctx context.Context, id string) (
 
 // VisitorList lists visitors based on a query.
 func (st *storeImplementation) VisitorList(ctx context.Context, query VisitorQueryInterface) ([]VisitorInterface, error) {
-	q := st.buildQuery(query)
+	return st.visitorListQuery(st.buildQuery(query))
+}
 
+// visitorListQuery loads the visitors selected by q.
+func (st *storeImplementation) visitorListQuery(q contractsorm.Query) ([]VisitorInterface, error) {
 	type visitorRow struct {
 		ID                 string    `db:"id"`
 		Path               string    `db:"path"`
@@ -508,6 +818,23 @@ func (st *storeImplementation) VisitorList(ctx context.Context, query VisitorQue
 		UserReferrer       string    `db:"user_referrer"`
 		Bot                string    `db:"bot"`
 		Threat             string    `db:"threat"`
+		PeerIpAddress      string    `db:"peer_ip_address"`
+		Channel            string    `db:"channel"`
+		Tags               string    `db:"tags"`
+		Enrichment         string    `db:"enrichment"`
+		UserDeviceBrand    string    `db:"user_device_brand"`
+		UserDeviceModel    string    `db:"user_device_model"`
+		ClientHints        string    `db:"client_hints"`
+		UserBrowserEngine  string    `db:"user_browser_engine"`
+		UserWebview        string    `db:"user_webview"`
+		UserApp            string    `db:"user_app"`
+		PageTitle          string    `db:"page_title"`
+		ScreenSize         string    `db:"screen_size"`
+		EngagementSeconds  string    `db:"engagement_seconds"`
+		ScrollDepth        string    `db:"scroll_depth"`
+		Campaign           string    `db:"campaign"`
+		UserID             string    `db:"user_id"`
+		Privacy            string    `db:"privacy"`
 		CreatedAt          time.Time `db:"created_at"`
 		UpdatedAt          time.Time `db:"updated_at"`
 		SoftDeletedAt      time.Time `db:"soft_deleted_at"`
@@ -524,7 +851,7 @@ func (st *storeImplementation) VisitorList(ctx context.Context, query VisitorQue
 		v.SetID(r.ID)
 		v.SetPath(r.Path)
 		v.SetFingerprint(r.Fingerprint)
-		v.SetIpAddress(r.IPAddress)
+		v.SetIpAddress(st.ipReveal(r.IPAddress))
 		v.SetCountry(r.Country)
 		v.SetUserAcceptLanguage(r.UserAcceptLanguage)
 		v.SetUserAcceptEncoding(r.UserAcceptEncoding)
@@ -538,6 +865,23 @@ func (st *storeImplementation) VisitorList(ctx context.Context, query VisitorQue
 		v.SetUserReferrer(r.UserReferrer)
 		v.SetBot(r.Bot)
 		v.SetThreat(r.Threat)
+		v.SetPeerIpAddress(st.ipReveal(r.PeerIpAddress))
+		v.SetChannel(r.Channel)
+		v.SetTags(r.Tags)
+		v.SetEnrichment(r.Enrichment)
+		v.SetUserDeviceBrand(r.UserDeviceBrand)
+		v.SetUserDeviceModel(r.UserDeviceModel)
+		v.SetClientHints(r.ClientHints)
+		v.SetUserBrowserEngine(r.UserBrowserEngine)
+		v.SetUserWebview(r.UserWebview)
+		v.SetUserApp(r.UserApp)
+		v.SetPageTitle(r.PageTitle)
+		v.SetScreenSize(r.ScreenSize)
+		v.SetEngagementSeconds(r.EngagementSeconds)
+		v.SetScrollDepth(r.ScrollDepth)
+		v.SetCampaign(r.Campaign)
+		v.SetUserID(r.UserID)
+		v.SetPrivacy(r.Privacy)
 		v.CreatedAt.CreatedAt = r.CreatedAt
 		v.UpdatedAt.UpdatedAt = r.UpdatedAt
 		v.SoftDeletesMaxDate.SoftDeletedAt = r.SoftDeletedAt
@@ -580,7 +924,8 @@ func (st *storeImplementation) VisitorSoftDeleteByID(ctx context.Context, id str
 	return st.VisitorSoftDelete(ctx, visitor)
 }
 
-// VisitorUpdate updates a visitor.
+// VisitorUpdate updates a visitor. The engagement columns are written only
+// by VisitorEngagementUpdate, so a stale copy never lowers them.
 func (st *storeImplementation) VisitorUpdate(ctx context.Context, visitor VisitorInterface) error {
 	if visitor == nil {
 		return errors.New("visitor is nil")
@@ -590,10 +935,20 @@ func (st *storeImplementation) VisitorUpdate(ctx context.Context, visitor Visito
 
 	st.ensureBotThreatFlags(visitor)
 
+	ip, ipIndex, err := st.ipSeal(visitor.GetIpAddress())
+	if err != nil {
+		return err
+	}
+	peerIP, _, err := st.ipSeal(visitor.GetPeerIpAddress())
+	if err != nil {
+		return err
+	}
+
 	row := map[string]any{
 		COLUMN_PATH:                 visitor.GetPath(),
 		COLUMN_FINGERPRINT:          visitor.GetFingerprint(),
-		COLUMN_IP_ADDRESS:           visitor.GetIpAddress(),
+		COLUMN_IP_ADDRESS:           ip,
+		COLUMN_IP_INDEX:             ipIndex,
 		COLUMN_COUNTRY:              visitor.GetCountry(),
 		COLUMN_USER_ACCEPT_LANGUAGE: visitor.GetUserAcceptLanguage(),
 		COLUMN_USER_ACCEPT_ENCODING: visitor.GetUserAcceptEncoding(),
@@ -607,11 +962,26 @@ func (st *storeImplementation) VisitorUpdate(ctx context.Context, visitor Visito
 		COLUMN_USER_REFERRER:        visitor.GetUserReferrer(),
 		COLUMN_BOT:                  visitor.GetBot(),
 		COLUMN_THREAT:               visitor.GetThreat(),
+		COLUMN_PEER_IP_ADDRESS:      peerIP,
+		COLUMN_CHANNEL:              visitor.GetChannel(),
+		COLUMN_TAGS:                 visitor.GetTags(),
+		COLUMN_ENRICHMENT:           visitor.GetEnrichment(),
+		COLUMN_USER_DEVICE_BRAND:    visitor.GetUserDeviceBrand(),
+		COLUMN_USER_DEVICE_MODEL:    visitor.GetUserDeviceModel(),
+		COLUMN_CLIENT_HINTS:         visitor.GetClientHints(),
+		COLUMN_USER_BROWSER_ENGINE:  visitor.GetUserBrowserEngine(),
+		COLUMN_USER_WEBVIEW:         visitor.GetUserWebview(),
+		COLUMN_USER_APP:             visitor.GetUserApp(),
+		COLUMN_PAGE_TITLE:           visitor.GetPageTitle(),
+		COLUMN_SCREEN_SIZE:          visitor.GetScreenSize(),
+		COLUMN_CAMPAIGN:             visitor.GetCampaign(),
+		COLUMN_USER_ID:              visitor.GetUserID(),
+		COLUMN_PRIVACY:              visitor.GetPrivacy(),
 		COLUMN_UPDATED_AT:           visitor.GetUpdatedAtCarbon().StdTime(),
 		COLUMN_SOFT_DELETED_AT:      visitor.GetSoftDeletedAtCarbon().StdTime(),
 	}
 
-	_, err := st.db.Query().
+	_, err = st.db.Query().
 		Table(st.visitorTableName).
 		Where(COLUMN_ID+" = ?", visitor.GetID()).
 		Update(row)
@@ -635,6 +1005,8 @@ func (st *storeImplementation) VisitorUpdate(ctx context.Context, visitor Visito
 //
 // Records whose geo-IP lookup fails are still updated with UA data, but
 // their country field is left empty so they get retried on the next call.
+// The update moves them behind the rows not yet tried, so IPs that never
+// resolve cannot hold up the rest.
 // Returns the number of records that were fully processed (country + UA).
 func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error) {
 	if st.geoIPResolver == nil {
@@ -646,9 +1018,7 @@ func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error)
 		batchSize = 10
 	}
 
-	visitors, err := st.VisitorList(ctx, VisitorQuery().
-		SetCountry("empty").
-		SetLimit(batchSize))
+	visitors, err := st.VisitorList(ctx, enhanceBatchQuery(batchSize))
 	if err != nil {
 		return 0, err
 	}
@@ -658,15 +1028,7 @@ func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error)
 	}
 
 	// Collect unique IPs to resolve each only once
-	seenIPs := make(map[string]bool)
-	ipOrder := []string{}
-	for _, v := range visitors {
-		ip := v.GetIpAddress()
-		if !seenIPs[ip] {
-			seenIPs[ip] = true
-			ipOrder = append(ipOrder, ip)
-		}
-	}
+	ipOrder := uniqueIPs(visitors)
 
 	// Resolve each unique IP once, then bulk-update country for ALL records
 	// with that IP (not just the current batch) to minimize DB writes.
@@ -682,60 +1044,65 @@ func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error)
 			}
 		}
 
-		country, err := st.geoIPResolver.Resolve(ctx, ip)
-		if err != nil {
-			if st.debugEnabled {
-				st.logger.Error("VisitorEnhance: geo-IP lookup failed",
-					"ip", ip, "error", err)
-			}
+		country, err := st.enhanceCountryByIP(ctx, ip)
+		if err != nil || country == "" {
 			continue
 		}
 
-		_, err = st.db.Query().
-			Table(st.visitorTableName).
-			Where(COLUMN_IP_ADDRESS+" = ?", ip).
-			Where(COLUMN_COUNTRY+" = ?", "").
-			Update(map[string]any{
-				COLUMN_COUNTRY:    country,
-				COLUMN_UPDATED_AT: carbon.Now(carbon.UTC).StdTime(),
-			})
-		if err != nil {
-			if st.debugEnabled {
-				st.logger.Error("VisitorEnhance: bulk country update failed",
-					"ip", ip, "error", err)
-			}
-			continue
+		resolvedCountries[ip] = country
+	}
+
+	processed, _ := st.enhanceUserAgents(ctx, visitors, resolvedCountries)
+
+	return processed, nil
+}
+
+// enhanceCountryByIP resolves the country for ip and bulk-updates every
+// visitor row with that IP whose country is still empty. An empty country
+// from the resolver leaves the rows untouched.
+func (st *storeImplementation) enhanceCountryByIP(ctx context.Context, ip string) (string, error) {
+	country, err := st.geoIPResolver.Resolve(ctx, ip)
+	if err != nil {
+		if st.debugEnabled {
+			st.logger.Error("VisitorEnhance: geo-IP lookup failed",
+				"ip", ip, "error", err)
 		}
+		return "", err
+	}
+	if country == "" {
+		return "", nil
+	}
 
-		resolvedCountries[ip] = country
+	q := st.db.Query().Table(st.visitorTableName)
+	_, err = st.whereIPIn(q, []string{ip}).
+		Where(COLUMN_COUNTRY+" = ?", "").
+		Update(map[string]any{
+			COLUMN_COUNTRY:    country,
+			COLUMN_UPDATED_AT: carbon.Now(carbon.UTC).StdTime(),
+		})
+	if err != nil {
+		if st.debugEnabled {
+			st.logger.Error("VisitorEnhance: bulk country update failed",
+				"ip", ip, "error", err)
+		}
+		return "", err
 	}
 
-	// Update UA fields per record (UA differs per visitor even for the same IP)
+	return country, nil
+}
+
+// enhanceUserAgents fills in empty UA-derived fields for each visitor, sets
+// the country from resolvedCountries, and persists the row. It returns the
+// number of rows that received a country and the number of failed updates.
+func (st *storeImplementation) enhanceUserAgents(ctx context.Context, visitors []VisitorInterface, resolvedCountries map[string]string) (int, int) {
 	processed := 0
+	failed := 0
 	for _, visitor := range visitors {
-		uaInfo := ParseUserAgent(visitor.GetUserAgent())
-		if visitor.GetUserBrowser() == "" {
-			visitor.SetUserBrowser(uaInfo.Browser)
-		}
-		if visitor.GetUserBrowserVersion() == "" {
-			visitor.SetUserBrowserVersion(uaInfo.BrowserVersion)
-		}
-		if visitor.GetUserOs() == "" {
-			visitor.SetUserOs(uaInfo.Os)
-		}
-		if visitor.GetUserOsVersion() == "" {
-			visitor.SetUserOsVersion(uaInfo.OsVersion)
-		}
-		if visitor.GetUserDevice() == "" {
-			visitor.SetUserDevice(uaInfo.Device)
-		}
-		if visitor.GetUserDeviceType() == "" {
-			visitor.SetUserDeviceType(uaInfo.DeviceType)
-		}
+		fillUserAgentFields(visitor, st.userAgentParser)
 
 		// Set country from the resolved map so VisitorUpdate persists it
 		ipResolved := false
-		if country, ok := resolvedCountries[visitor.GetIpAddress()]; ok {
+		if country := resolvedCountries[visitor.GetIpAddress()]; country != "" {
 			visitor.SetCountry(country)
 			ipResolved = true
 		}
@@ -745,6 +1112,7 @@ func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error)
 				st.logger.Error("VisitorEnhance: update failed",
 					"id", visitor.GetID(), "error", err)
 			}
+			failed++
 			continue
 		}
 
@@ -753,7 +1121,18 @@ func (st *storeImplementation) VisitorEnhance(ctx context.Context) (int, error)
 		}
 	}
 
-	return processed, nil
+	return processed, failed
+}
+
+// enhanceBatchQuery selects the next rows without a country, least
+// recently updated first. Every attempt updates a row, so rows whose IP
+// does not resolve rotate to the back instead of filling every batch.
+func enhanceBatchQuery(batchSize int) VisitorQueryInterface {
+	return VisitorQuery().
+		SetCountry("empty").
+		SetOrderBy(COLUMN_UPDATED_AT).
+		SetSortOrder("asc").
+		SetLimit(batchSize)
 }
 
 // == QUERY BUILDER ============================================================
@@ -775,19 +1154,11 @@ func (st *storeImplementation) buildQuery(query VisitorQueryInterface) contracts
 	}
 
 	if query.HasIPIn() && len(query.IPIn()) > 0 {
-		args := make([]any, len(query.IPIn()))
-		for i, ip := range query.IPIn() {
-			args[i] = ip
-		}
-		q = q.WhereIn(COLUMN_IP_ADDRESS, args)
+		q = st.whereIPIn(q, query.IPIn())
 	}
 
 	if query.HasIPNotIn() && len(query.IPNotIn()) > 0 {
-		args := make([]any, len(query.IPNotIn()))
-		for i, ip := range query.IPNotIn() {
-			args[i] = ip
-		}
-		q = q.WhereNotIn(COLUMN_IP_ADDRESS, args)
+		q = st.whereIPNotIn(q, query.IPNotIn())
 	}
 
 	if query.HasCountry() && query.Country() != "" {
@@ -820,6 +1191,18 @@ func (st *storeImplementation) buildQuery(query VisitorQueryInterface) contracts
 		q = q.Where(COLUMN_THREAT+" = ?", query.Threat())
 	}
 
+	if query.HasUserID() && query.UserID() != "" {
+		q = q.Where(COLUMN_USER_ID+" = ?", query.UserID())
+	}
+
+	if query.HasPrivacy() && query.Privacy() != "" {
+		q = q.Where(COLUMN_PRIVACY+" = ?", query.Privacy())
+	}
+
+	if query.HasEnrichmentNot() {
+		q = q.Where("("+COLUMN_ENRICHMENT+" IS NULL OR "+COLUMN_ENRICHMENT+" <> ?)", query.EnrichmentNot())
+	}
+
 	if query.HasCreatedAtGte() && query.CreatedAtGte() != "" {
 		if createdAt, ok := parseCreatedAt(query.CreatedAtGte()); ok {
 			q = q.Where(COLUMN_CREATED_AT+" >= ?", createdAt)
@@ -0,0 +1,413 @@
+package statsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	contractsdatabase "github.com/dracory/neat/contracts/database"
+	contractsorm "github.com/dracory/neat/contracts/database/orm"
+	contractsschema "github.com/dracory/neat/contracts/database/schema"
+	"github.com/dracory/str"
+	"github.com/dromara/carbon/v2"
+)
+
+const (
+	// eventMaxNameLength matches the event name column size.
+	eventMaxNameLength = 120
+
+	// eventMaxIdempotencyKeyLength matches the idempotency_key column size.
+	eventMaxIdempotencyKeyLength = 120
+
+	// eventMaxOrderIDLength matches the order_id column size.
+	eventMaxOrderIDLength = 120
+)
+
+// == MIGRATE ==================================================================
+
+// migrateEventTable creates the custom events table if it does not exist,
+// or adds the columns that earlier versions of it lack.
+func (st *storeImplementation) migrateEventTable() error {
+	if st.eventTableName == "" {
+		return nil
+	}
+
+	if st.db.Schema().HasTable(st.eventTableName) {
+		columns := []struct {
+			name   string
+			define func(table contractsschema.Blueprint)
+		}{
+			{COLUMN_USER_ID, func(table contractsschema.Blueprint) { table.String(COLUMN_USER_ID, 64) }},
+			{COLUMN_CLIENT_ID, func(table contractsschema.Blueprint) { table.String(COLUMN_CLIENT_ID, 64) }},
+			{COLUMN_IDEMPOTENCY_KEY, func(table contractsschema.Blueprint) {
+				table.String(COLUMN_IDEMPOTENCY_KEY, eventMaxIdempotencyKeyLength)
+			}},
+			{COLUMN_CAMPAIGN, func(table contractsschema.Blueprint) { table.String(COLUMN_CAMPAIGN, 120) }},
+			{COLUMN_ORDER_ID, func(table contractsschema.Blueprint) { table.String(COLUMN_ORDER_ID, eventMaxOrderIDLength) }},
+			{COLUMN_ITEMS, func(table contractsschema.Blueprint) { table.Text(COLUMN_ITEMS) }},
+		}
+		for _, column := range columns {
+			if err := st.migrateAddColumn(st.eventTableName, column.name, column.define); err != nil {
+				return err
+			}
+		}
+
+		for _, column := range []string{COLUMN_USER_ID, COLUMN_IDEMPOTENCY_KEY, COLUMN_ORDER_ID} {
+			if err := st.migrateAddIndex(st.eventTableName, column); err != nil {
+				return err
+			}
+		}
+		st.migrateEventUniqueIndexes()
+		return nil
+	}
+
+	err := st.db.Schema().Create(st.eventTableName, func(table contractsschema.Blueprint) {
+		table.String(COLUMN_ID, 40)
+		table.Primary(COLUMN_ID)
+		table.String(COLUMN_NAME, eventMaxNameLength)
+		table.String(COLUMN_VISITOR_ID, 40)
+		table.String(COLUMN_FINGERPRINT, 40)
+		table.String(COLUMN_DOMAIN, 255)
+		table.String(COLUMN_PATH, 510)
+		table.String(COLUMN_USER_REFERRER, 510)
+		table.Text(COLUMN_PROPS)
+		table.String(COLUMN_REVENUE_AMOUNT, 24)
+		table.String(COLUMN_REVENUE_CURRENCY, 3)
+		table.String(COLUMN_USER_ID, 64)
+		table.String(COLUMN_CLIENT_ID, 64)
+		table.String(COLUMN_IDEMPOTENCY_KEY, eventMaxIdempotencyKeyLength)
+		table.String(COLUMN_CAMPAIGN, 120)
+		table.String(COLUMN_ORDER_ID, eventMaxOrderIDLength)
+		table.Text(COLUMN_ITEMS)
+		table.DateTime(COLUMN_CREATED_AT)
+		table.DateTime(COLUMN_UPDATED_AT)
+
+		table.Index(COLUMN_CREATED_AT)
+		table.Index(COLUMN_NAME)
+		table.Index(COLUMN_FINGERPRINT)
+		table.Index(COLUMN_USER_ID)
+		table.Index(COLUMN_IDEMPOTENCY_KEY)
+		table.Index(COLUMN_ORDER_ID)
+	})
+
+	if err != nil {
+		if st.debugEnabled {
+			st.logger.Error("MigrateUp: event table creation failed", "error", err)
+		}
+		return err
+	}
+
+	st.migrateEventUniqueIndexes()
+	return nil
+}
+
+// migrateEventUniqueIndexes adds unique indexes on the non-empty idempotency
+// keys and order IDs, so EventTrack retries running in different processes
+// cannot both insert. MySQL has no partial indexes and unset values are
+// stored as empty strings, so there only the in-process lock applies. A
+// table that already holds duplicates keeps working without the index.
+func (st *storeImplementation) migrateEventUniqueIndexes() {
+	if st.db.Query().Driver() == contractsdatabase.DriverMysql {
+		return
+	}
+
+	for _, column := range []string{COLUMN_IDEMPOTENCY_KEY, COLUMN_ORDER_ID} {
+		indexName := strings.ToLower(st.eventTableName + "_" + column + "_unique")
+		if st.db.Schema().HasIndex(st.eventTableName, indexName) {
+			continue
+		}
+
+		sqlStr := fmt.Sprintf(`CREATE UNIQUE INDEX "%s" ON "%s" ("%s") WHERE "%s" <> ''`,
+			indexName, st.eventTableName, column, column)
+		if err := st.db.Schema().Sql(sqlStr); err != nil && st.debugEnabled {
+			st.logger.Error("MigrateUp: add unique index failed", "table", st.eventTableName, "column", column, "error", err)
+		}
+	}
+}
+
+// == EVENT OPERATIONS =========================================================
+
+// EventRegister records a custom event reported on behalf of request r. The
+// fingerprint is computed from the client IP and user agent of r, as for
+// visitors, so events can be joined to sessions. Filtering is the same as
+// for VisitorRegister. Returns false when the event was filtered out, or
+// when its order ID was already recorded.
+func (st *storeImplementation) EventRegister(ctx context.Context, r *http.Request, event EventInterface) (bool, error) {
+	if event == nil {
+		return false, errors.New("event is nil")
+	}
+
+	ip, _ := resolveClientIP(r, st.clientIPOptions, st.trustedProxies)
+	userAgent := r.UserAgent()
+
+	if st.isFilteredHit(event.GetPath(), ip, userAgent, event.GetReferrer()) {
+		return false, nil
+	}
+
+	privacyReason, skip := st.privacyDecision(r)
+	if skip {
+		return false, nil
+	}
+	if privacyReason != "" {
+		privacyAnonymizeEvent(event)
+	} else if event.GetFingerprint() == "" {
+		// Same hash as VisitorInterface.FingerprintCalculate.
+		event.SetFingerprint(str.MD5(ip + userAgent))
+	}
+
+	if privacyReason == "" && event.GetUserID() == "" {
+		event.SetUserID(st.requestUserID(ctx, r))
+	}
+
+	if event.GetOrderID() != "" {
+		if err := validateOrder(event); err != nil {
+			return false, err
+		}
+
+		existing, err := st.eventCreateOnce(ctx, event)
+		if err != nil || existing != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := st.EventCreate(ctx, event); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// EventCount counts events based on a query.
+func (st *storeImplementation) EventCount(ctx context.Context, query EventQueryInterface) (int64, error) {
+	if err := query.Validate(); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := st.buildEventQuery(query).Count(&count)
+	return count, err
+}
+
+// EventCreate creates a new event.
+func (st *storeImplementation) EventCreate(ctx context.Context, event EventInterface) error {
+	if event == nil {
+		return errors.New("event is nil")
+	}
+
+	if strings.TrimSpace(event.GetName()) == "" {
+		return errors.New("event name is empty")
+	}
+
+	if event.GetName() == EVENT_NAME_PAGEVIEW {
+		return errors.New("event name pageview is reserved for visitors")
+	}
+
+	if event.GetCreatedAt() == "" {
+		event.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	}
+	event.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	st.scrubEvent(event)
+
+	row := map[string]any{
+		COLUMN_ID:               event.GetID(),
+		COLUMN_NAME:             event.GetName(),
+		COLUMN_VISITOR_ID:       event.GetVisitorID(),
+		COLUMN_FINGERPRINT:      event.GetFingerprint(),
+		COLUMN_DOMAIN:           event.GetDomain(),
+		COLUMN_PATH:             event.GetPath(),
+		COLUMN_USER_REFERRER:    event.GetReferrer(),
+		COLUMN_PROPS:            event.GetProps(),
+		COLUMN_REVENUE_AMOUNT:   event.GetRevenueAmount(),
+		COLUMN_REVENUE_CURRENCY: event.GetRevenueCurrency(),
+		COLUMN_USER_ID:          event.GetUserID(),
+		COLUMN_CLIENT_ID:        event.GetClientID(),
+		COLUMN_IDEMPOTENCY_KEY:  event.GetIdempotencyKey(),
+		COLUMN_CAMPAIGN:         event.GetCampaign(),
+		COLUMN_ORDER_ID:         event.GetOrderID(),
+		COLUMN_ITEMS:            event.GetItems(),
+		COLUMN_CREATED_AT:       event.GetCreatedAtCarbon().StdTime(),
+		COLUMN_UPDATED_AT:       event.GetUpdatedAtCarbon().StdTime(),
+	}
+
+	return st.db.Query().Table(st.eventTableName).Create(row)
+}
+
+// EventDeleteByID permanently deletes an event by ID.
+func (st *storeImplementation) EventDeleteByID(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("event id is empty")
+	}
+
+	_, err := st.db.Query().
+		Table(st.eventTableName).
+		Where(COLUMN_ID+" = ?", id).
+		Delete()
+
+	return err
+}
+
+// EventFindByID finds an event by ID.
+func (st *storeImplementation) EventFindByID(ctx context.Context, id string) (EventInterface, error) {
+	if id == "" {
+		return nil, errors.New("event id is empty")
+	}
+
+	list, err := st.EventList(ctx, EventQuery().SetID(id).SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) > 0 {
+		return list[0], nil
+	}
+
+	return nil, nil
+}
+
+// EventList lists events based on a query.
+func (st *storeImplementation) EventList(ctx context.Context, query EventQueryInterface) ([]EventInterface, error) {
+	if err := query.Validate(); err != nil {
+		return []EventInterface{}, err
+	}
+
+	return st.eventListQuery(st.buildEventQuery(query))
+}
+
+// eventListQuery loads the events selected by q.
+func (st *storeImplementation) eventListQuery(q contractsorm.Query) ([]EventInterface, error) {
+	type eventRow struct {
+		ID              string    `db:"id"`
+		Name            string    `db:"name"`
+		VisitorID       string    `db:"visitor_id"`
+		Fingerprint     string    `db:"fingerprint"`
+		Domain          string    `db:"domain"`
+		Path            string    `db:"path"`
+		Referrer        string    `db:"user_referrer"`
+		Props           string    `db:"props"`
+		RevenueAmount   string    `db:"revenue_amount"`
+		RevenueCurrency string    `db:"revenue_currency"`
+		UserID          string    `db:"user_id"`
+		ClientID        string    `db:"client_id"`
+		IdempotencyKey  string    `db:"idempotency_key"`
+		Campaign        string    `db:"campaign"`
+		OrderID         string    `db:"order_id"`
+		Items           string    `db:"items"`
+		CreatedAt       time.Time `db:"created_at"`
+		UpdatedAt       time.Time `db:"updated_at"`
+	}
+
+	var rows []eventRow
+	if err := q.Get(&rows); err != nil {
+		return []EventInterface{}, err
+	}
+
+	list := make([]EventInterface, 0, len(rows))
+	for _, r := range rows {
+		e := &eventImplementation{}
+		e.SetID(r.ID)
+		e.SetName(r.Name)
+		e.SetVisitorID(r.VisitorID)
+		e.SetFingerprint(r.Fingerprint)
+		e.SetDomain(r.Domain)
+		e.SetPath(r.Path)
+		e.SetReferrer(r.Referrer)
+		e.SetProps(r.Props)
+		e.SetRevenueAmount(r.RevenueAmount)
+		e.SetRevenueCurrency(r.RevenueCurrency)
+		e.SetUserID(r.UserID)
+		e.SetClientID(r.ClientID)
+		e.SetIdempotencyKey(r.IdempotencyKey)
+		e.SetCampaign(r.Campaign)
+		e.SetOrderID(r.OrderID)
+		e.SetItems(r.Items)
+		e.CreatedAt.CreatedAt = r.CreatedAt
+		e.UpdatedAt.UpdatedAt = r.UpdatedAt
+		list = append(list, e)
+	}
+
+	return list, nil
+}
+
+// == QUERY BUILDER ============================================================
+
+func (st *storeImplementation) buildEventQuery(query EventQueryInterface) contractsorm.Query {
+	q := st.db.Query().Table(st.eventTableName)
+
+	if query.HasID() && query.ID() != "" {
+		q = q.Where(COLUMN_ID+" = ?", query.ID())
+	}
+
+	if query.HasName() && query.Name() != "" {
+		q = q.Where(COLUMN_NAME+" = ?", query.Name())
+	}
+
+	if query.HasNameIn() && len(query.NameIn()) > 0 {
+		args := make([]any, len(query.NameIn()))
+		for i, name := range query.NameIn() {
+			args[i] = name
+		}
+		q = q.WhereIn(COLUMN_NAME, args)
+	}
+
+	if query.HasVisitorID() && query.VisitorID() != "" {
+		q = q.Where(COLUMN_VISITOR_ID+" = ?", query.VisitorID())
+	}
+
+	if query.HasFingerprint() && query.Fingerprint() != "" {
+		q = q.Where(COLUMN_FINGERPRINT+" = ?", query.Fingerprint())
+	}
+
+	if query.HasUserID() && query.UserID() != "" {
+		q = q.Where(COLUMN_USER_ID+" = ?", query.UserID())
+	}
+
+	if query.HasClientID() && query.ClientID() != "" {
+		q = q.Where(COLUMN_CLIENT_ID+" = ?", query.ClientID())
+	}
+
+	if query.HasIdempotencyKey() && query.IdempotencyKey() != "" {
+		q = q.Where(COLUMN_IDEMPOTENCY_KEY+" = ?", query.IdempotencyKey())
+	}
+
+	if query.HasOrderID() && query.OrderID() != "" {
+		q = q.Where(COLUMN_ORDER_ID+" = ?", query.OrderID())
+	}
+
+	if query.HasCreatedAtGte() && query.CreatedAtGte() != "" {
+		if createdAt, ok := parseCreatedAt(query.CreatedAtGte()); ok {
+			q = q.Where(COLUMN_CREATED_AT+" >= ?", createdAt)
+		} else {
+			return q.Where("1 = 0")
+		}
+	}
+	if query.HasCreatedAtLte() && query.CreatedAtLte() != "" {
+		if createdAt, ok := parseCreatedAt(query.CreatedAtLte()); ok {
+			q = q.Where(COLUMN_CREATED_AT+" <= ?", createdAt)
+		} else {
+			return q.Where("1 = 0")
+		}
+	}
+
+	if query.HasLimit() && query.Limit() > 0 {
+		q = q.Limit(query.Limit())
+	}
+
+	if query.HasOffset() && query.Offset() > 0 {
+		q = q.Offset(query.Offset())
+	}
+
+	if query.HasOrderBy() && query.OrderBy() != "" {
+		sortOrder := "desc"
+		if query.HasSortOrder() && query.SortOrder() != "" {
+			sortOrder = query.SortOrder()
+		}
+		q = q.OrderBy(query.OrderBy(), sortOrder)
+	}
+
+	return q
+}
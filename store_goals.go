@@ -0,0 +1,169 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	neatuid "github.com/dracory/neat/support/uid"
+)
+
+// == GOALS ====================================================================
+
+// GoalList returns the defined goals, stored as JSON in the settings table.
+func (st *storeImplementation) GoalList(ctx context.Context) ([]Goal, error) {
+	goals := []Goal{}
+	if err := st.settingGetJSON(ctx, SETTING_GOALS, &goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// GoalSave validates and stores a goal, replacing the goal with the same
+// ID. A goal without an ID is added with a new ID. Returns the stored goal.
+func (st *storeImplementation) GoalSave(ctx context.Context, goal Goal) (Goal, error) {
+	goal.Name = strings.TrimSpace(goal.Name)
+	goal.Path = strings.TrimSpace(goal.Path)
+	goal.EventName = strings.TrimSpace(goal.EventName)
+	if err := goal.Validate(); err != nil {
+		return Goal{}, err
+	}
+
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	goals, err := st.GoalList(ctx)
+	if err != nil {
+		return Goal{}, err
+	}
+
+	if goal.ID == "" {
+		goal.ID = neatuid.GenerateShortID()
+		goals = append(goals, goal)
+	} else {
+		index := slices.IndexFunc(goals, func(g Goal) bool { return g.ID == goal.ID })
+		if index < 0 {
+			return Goal{}, errors.New("goal not found")
+		}
+		goals[index] = goal
+	}
+
+	return goal, st.settingSetJSON(ctx, SETTING_GOALS, goals)
+}
+
+// GoalDelete removes a goal. Goals used by a funnel cannot be deleted.
+func (st *storeImplementation) GoalDelete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("goal id is empty")
+	}
+
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	funnels, err := st.FunnelList(ctx)
+	if err != nil {
+		return err
+	}
+	for _, funnel := range funnels {
+		if slices.Contains(funnel.GoalIDs, id) {
+			return errors.New("goal is used by funnel " + funnel.Name)
+		}
+	}
+
+	goals, err := st.GoalList(ctx)
+	if err != nil {
+		return err
+	}
+	goals = slices.DeleteFunc(goals, func(g Goal) bool { return g.ID == id })
+
+	return st.settingSetJSON(ctx, SETTING_GOALS, goals)
+}
+
+// == FUNNELS ==================================================================
+
+// FunnelList returns the defined funnels, stored as JSON in the settings
+// table.
+func (st *storeImplementation) FunnelList(ctx context.Context) ([]Funnel, error) {
+	funnels := []Funnel{}
+	if err := st.settingGetJSON(ctx, SETTING_FUNNELS, &funnels); err != nil {
+		return nil, err
+	}
+	return funnels, nil
+}
+
+// FunnelSave validates and stores a funnel, replacing the funnel with the
+// same ID. A funnel without an ID is added with a new ID. Returns the stored
+// funnel.
+func (st *storeImplementation) FunnelSave(ctx context.Context, funnel Funnel) (Funnel, error) {
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	goals, err := st.GoalList(ctx)
+	if err != nil {
+		return Funnel{}, err
+	}
+
+	funnel.Name = strings.TrimSpace(funnel.Name)
+	if err := funnel.Validate(goals); err != nil {
+		return Funnel{}, err
+	}
+
+	funnels, err := st.FunnelList(ctx)
+	if err != nil {
+		return Funnel{}, err
+	}
+
+	if funnel.ID == "" {
+		funnel.ID = neatuid.GenerateShortID()
+		funnels = append(funnels, funnel)
+	} else {
+		index := slices.IndexFunc(funnels, func(f Funnel) bool { return f.ID == funnel.ID })
+		if index < 0 {
+			return Funnel{}, errors.New("funnel not found")
+		}
+		funnels[index] = funnel
+	}
+
+	return funnel, st.settingSetJSON(ctx, SETTING_FUNNELS, funnels)
+}
+
+// FunnelDelete removes a funnel.
+func (st *storeImplementation) FunnelDelete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("funnel id is empty")
+	}
+
+	st.settingsMu.Lock()
+	defer st.settingsMu.Unlock()
+
+	funnels, err := st.FunnelList(ctx)
+	if err != nil {
+		return err
+	}
+	funnels = slices.DeleteFunc(funnels, func(f Funnel) bool { return f.ID == id })
+
+	return st.settingSetJSON(ctx, SETTING_FUNNELS, funnels)
+}
+
+// == JSON SETTINGS ============================================================
+
+// settingGetJSON decodes the JSON setting key into target, leaving target
+// unchanged when the setting is absent.
+func (st *storeImplementation) settingGetJSON(ctx context.Context, key string, target any) error {
+	value, err := st.SettingGet(ctx, key)
+	if err != nil || value == "" {
+		return err
+	}
+	return json.Unmarshal([]byte(value), target)
+}
+
+// settingSetJSON stores value as the JSON setting key.
+func (st *storeImplementation) settingSetJSON(ctx context.Context, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return st.SettingSet(ctx, key, string(data))
+}
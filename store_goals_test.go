@@ -0,0 +1,101 @@
+package statsstore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGoalSaveListDelete(t *testing.T) {
+	store, err := initStoreWithSettings()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	signup, err := store.GoalSave(ctx, Goal{Name: " Signup ", Type: GoalTypePage, Path: "/signup/done"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if signup.ID == "" || signup.Name != "Signup" {
+		t.Fatalf("unexpected goal: %+v", signup)
+	}
+
+	if _, err := store.GoalSave(ctx, Goal{Name: "Broken", Type: GoalTypePage}); err == nil {
+		t.Error("expected a validation error")
+	}
+
+	signup.Value = 5
+	if _, err := store.GoalSave(ctx, signup); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := store.GoalSave(ctx, Goal{ID: "missing", Name: "X", Type: GoalTypeEvent, EventName: "X"}); err == nil {
+		t.Error("expected an error for an unknown goal ID")
+	}
+
+	goals, err := store.GoalList(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(goals) != 1 || goals[0].Value != 5 {
+		t.Fatalf("unexpected goals: %+v", goals)
+	}
+
+	if err := store.GoalDelete(ctx, signup.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	goals, err = store.GoalList(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(goals) != 0 {
+		t.Errorf("expected no goals, got %+v", goals)
+	}
+}
+
+func TestFunnelSaveListDelete(t *testing.T) {
+	store, err := initStoreWithSettings()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	pricing, err := store.GoalSave(ctx, Goal{Name: "Pricing", Type: GoalTypePage, Path: "/pricing"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	purchase, err := store.GoalSave(ctx, Goal{Name: "Purchase", Type: GoalTypeEvent, EventName: "Purchase"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := store.FunnelSave(ctx, Funnel{Name: "Short", GoalIDs: []string{pricing.ID}}); err == nil {
+		t.Error("expected an error for a single-step funnel")
+	}
+	if _, err := store.FunnelSave(ctx, Funnel{Name: "Unknown", GoalIDs: []string{pricing.ID, "missing"}}); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+
+	funnel, err := store.FunnelSave(ctx, Funnel{Name: "Checkout", GoalIDs: []string{pricing.ID, purchase.ID}})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	funnels, err := store.FunnelList(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(funnels) != 1 || funnels[0].ID != funnel.ID || len(funnels[0].GoalIDs) != 2 {
+		t.Fatalf("unexpected funnels: %+v", funnels)
+	}
+
+	if err := store.GoalDelete(ctx, pricing.ID); err == nil {
+		t.Error("expected an error deleting a goal used by a funnel")
+	}
+
+	if err := store.FunnelDelete(ctx, funnel.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := store.GoalDelete(ctx, pricing.ID); err != nil {
+		t.Errorf("unexpected error after deleting the funnel: %v", err)
+	}
+}
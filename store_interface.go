@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"net/http"
+	"time"
 )
 
 // StoreInterface defines the interface for a stats store.
@@ -28,6 +29,57 @@ type StoreInterface interface {
 	SetExcludedIPs(ips []string)
 	GetExcludedIPs() []string
 
+	// SetPrivacyOptions replaces the Do-Not-Track, Global Privacy Control,
+	// consent and cookieless modes applied at ingestion.
+	SetPrivacyOptions(opts PrivacyOptions)
+	GetPrivacyOptions() PrivacyOptions
+	// PrivacyReport counts the visits of a period recorded aggregate only,
+	// per privacy reason.
+	PrivacyReport(ctx context.Context, from, to time.Time) (PrivacyReport, error)
+
+	// ScrubExisting runs the configured Scrubber over the paths and
+	// referrers of the stored visitors and events, updating changed rows.
+	ScrubExisting(ctx context.Context) (ScrubResult, error)
+
+	// IPKeyRotate encrypts plaintext IP addresses and re-encrypts those of
+	// older keys with the active IP encryption key, in batches.
+	IPKeyRotate(ctx context.Context) (IPKeyRotateResult, error)
+
+	// DataSubjectFind returns the visitors and events tied to the IP,
+	// fingerprint or user ID of a data subject.
+	DataSubjectFind(ctx context.Context, subject DataSubject) (DataSubjectRecords, error)
+	// DataSubjectExport returns the records of a data subject as JSON, for
+	// an access request, and records it in the audit log.
+	DataSubjectExport(ctx context.Context, subject DataSubject, actor string) ([]byte, error)
+	// DataSubjectErase deletes or anonymizes the records of a data subject
+	// and records it in the audit log.
+	DataSubjectErase(ctx context.Context, subject DataSubject, mode string, actor string) (DataSubjectEraseResult, error)
+	// DataSubjectAuditList returns the latest data-subject audit log
+	// entries, newest first.
+	DataSubjectAuditList(ctx context.Context, limit int) ([]DataSubjectAuditEntry, error)
+
+	// RetentionRuleList, RetentionRuleSave and RetentionRuleDelete manage
+	// the retention rules, stored in the settings table.
+	RetentionRuleList(ctx context.Context) ([]RetentionRule, error)
+	RetentionRuleSave(ctx context.Context, rule RetentionRule) (RetentionRule, error)
+	RetentionRuleDelete(ctx context.Context, id string) error
+	// RetentionPurge deletes the rows matched by the retention rules in
+	// batches, or only counts them on a dry run.
+	RetentionPurge(ctx context.Context, opts RetentionPurgeOptions) (RetentionReport, error)
+
+	// RollupBuild compacts the raw visits of the UTC days from from to to
+	// into daily rollup rows, replacing the rows of those days except the
+	// ones a retention rule may have purged.
+	RollupBuild(ctx context.Context, from, to time.Time) (RollupResult, error)
+	// RollupList returns the rollup rows of a dimension ("" for all) for
+	// the UTC days from from to to.
+	RollupList(ctx context.Context, dimension string, from, to time.Time) ([]RollupRow, error)
+	// UniqueVisitors estimates the distinct visitors of the UTC days from
+	// from to to, merging daily rollup sketches with the raw visits of the
+	// days not rolled up. A dimension and value narrow it down, e.g.
+	// RollupDimensionPath and "/pricing".
+	UniqueVisitors(ctx context.Context, dimension, value string, from, to time.Time) (UniqueVisitorsEstimate, error)
+
 	ExcludedIPList(ctx context.Context) ([]string, error)
 	ExcludedIPAdd(ctx context.Context, ip string) error
 	ExcludedIPRemove(ctx context.Context, ip string) error
@@ -45,6 +97,51 @@ type StoreInterface interface {
 	// Returns an empty map if the settings table does not exist or is empty.
 	SettingList(ctx context.Context) (map[string]string, error)
 
+	// GoalList returns the defined conversion goals.
+	GoalList(ctx context.Context) ([]Goal, error)
+	// GoalSave validates and adds or replaces a goal (by ID).
+	GoalSave(ctx context.Context, goal Goal) (Goal, error)
+	// GoalDelete removes a goal that is not used by any funnel.
+	GoalDelete(ctx context.Context, id string) error
+	// GoalAttribution credits the conversions of a goal to the channels,
+	// mediums and campaigns of the visits that preceded them.
+	GoalAttribution(ctx context.Context, goalID string, opts AttributionOptions) (AttributionReport, error)
+	// FunnelList returns the defined funnels.
+	FunnelList(ctx context.Context) ([]Funnel, error)
+	// FunnelSave validates and adds or replaces a funnel (by ID).
+	FunnelSave(ctx context.Context, funnel Funnel) (Funnel, error)
+	// FunnelDelete removes a funnel.
+	FunnelDelete(ctx context.Context, id string) error
+
+	// ExperimentList returns the experiments with exposures in the period.
+	ExperimentList(ctx context.Context, from, to time.Time) ([]string, error)
+	// ExperimentResults computes the per-variant conversion of a goal for an
+	// experiment, with confidence intervals and significance.
+	ExperimentResults(ctx context.Context, experiment, goalID string, from, to time.Time) (ExperimentResult, error)
+
+	// CurrencyRatesGet returns the rates revenue is converted with.
+	CurrencyRatesGet(ctx context.Context) (CurrencyRates, error)
+	// CurrencyRatesSave validates and stores the currency rates.
+	CurrencyRatesSave(ctx context.Context, rates CurrencyRates) error
+	// Revenue reports the revenue of the orders placed between from and to
+	// by source, campaign, landing page and country.
+	Revenue(ctx context.Context, from, to time.Time) (RevenueReport, error)
+
+	// EventRegister records a custom event on behalf of request r, applying
+	// the same filtering as VisitorRegister. Returns false when filtered or
+	// when the order ID was already recorded.
+	EventRegister(ctx context.Context, r *http.Request, event EventInterface) (bool, error)
+	// EventTrack records a server-side event on behalf of a visitor linked
+	// by visitor hash, user ID or client ID, with idempotency-key dedupe and
+	// timestamp backfilling. created is false for a duplicate key or order
+	// ID.
+	EventTrack(ctx context.Context, event EventInterface) (stored EventInterface, created bool, err error)
+	EventCount(ctx context.Context, query EventQueryInterface) (int64, error)
+	EventCreate(ctx context.Context, event EventInterface) error
+	EventDeleteByID(ctx context.Context, id string) error
+	EventFindByID(ctx context.Context, id string) (EventInterface, error)
+	EventList(ctx context.Context, query EventQueryInterface) ([]EventInterface, error)
+
 	VisitorCount(ctx context.Context, query VisitorQueryInterface) (int64, error)
 	VisitorCreate(ctx context.Context, user VisitorInterface) error
 	VisitorDelete(ctx context.Context, user VisitorInterface) error
@@ -53,6 +150,11 @@ type StoreInterface interface {
 	VisitorFindByID(ctx context.Context, userID string) (VisitorInterface, error)
 	VisitorList(ctx context.Context, query VisitorQueryInterface) ([]VisitorInterface, error)
 	VisitorRegister(ctx context.Context, r *http.Request) error
+	VisitorRegisterHit(ctx context.Context, r *http.Request, hit PageHit) (VisitorInterface, error)
+
+	// VisitorEngagementUpdate records visible time and maximum scroll depth
+	// for a page-view row, as reported by the tracker's heartbeat pings.
+	VisitorEngagementUpdate(ctx context.Context, id string, engagedSeconds int, scrollDepth int) error
 	VisitorSoftDelete(ctx context.Context, user VisitorInterface) error
 	VisitorSoftDeleteByID(ctx context.Context, id string) error
 	VisitorUpdate(ctx context.Context, user VisitorInterface) error
@@ -67,4 +169,29 @@ type StoreInterface interface {
 	//
 	// If no GeoIPResolver was configured, it returns 0 and an error.
 	VisitorEnhance(ctx context.Context) (int, error)
+
+	// VisitorEnhanceBacklog returns the number of visitor rows still waiting
+	// for enrichment (rows with an empty country).
+	VisitorEnhanceBacklog(ctx context.Context) (int64, error)
+
+	// StartEnhancer starts a managed background worker that keeps running
+	// VisitorEnhance-style enrichment with a token-bucket rate limit, a lookup
+	// worker pool and an adaptive batch size (see EnhancerOptions). Stop the
+	// worker via the returned EnhancerInterface or by cancelling ctx.
+	//
+	// If no GeoIPResolver was configured, or a worker is already running, it
+	// returns an error.
+	StartEnhancer(ctx context.Context) (EnhancerInterface, error)
+
+	SetEnrichers(enrichers []Enricher)
+	GetEnrichers() []Enricher
+
+	// VisitorEnrich runs the enrichment pipeline on a single visitor in
+	// memory, skipping enrichers whose version is already recorded on it.
+	VisitorEnrich(ctx context.Context, visitor VisitorInterface) error
+
+	// VisitorEnrichBatch runs the enrichment pipeline over a batch of rows
+	// whose recorded enricher versions are out of date and persists them.
+	// Returns the number of rows that are now fully enriched.
+	VisitorEnrichBatch(ctx context.Context) (int, error)
 }
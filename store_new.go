@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/dracory/neat"
 )
@@ -14,6 +15,10 @@ import (
 type NewStoreOptions struct {
 	VisitorTableName     string
 	SettingsTableName    string
+	EventTableName       string  // custom events table; default DEFAULT_EVENT_TABLE
+	AuditTableName       string  // data-subject audit log table; default DEFAULT_AUDIT_TABLE
+	RollupTableName      string  // daily rollup table; default DEFAULT_ROLLUP_TABLE
+	UniqueErrorBound     float64 // relative standard error of unique-visitor sketches; default HyperLogLogErrorBoundDefault
 	DB                   *sql.DB
 	AutomigrateEnabled   bool
 	DebugEnabled         bool
@@ -21,8 +26,19 @@ type NewStoreOptions struct {
 	BotAutoTagEnabled    bool // when true, compute and set bot/threat flags on inserted rows. Also auto-computes flags on VisitorCreate/VisitorUpdate.
 	ExcludedPathPrefixes []string
 	ExcludedIPs          []string
-	GeoIPResolver        GeoIPResolver // optional; enables VisitorEnhance for batch country enrichment
-	EnhanceBatchSize     int           // number of records per VisitorEnhance call; default 10
+	TrustedProxies       []string             // CIDRs or IPs of reverse proxies; forwarding headers are only honoured from these peers
+	ClientIPHeaders      []string             // forwarding header precedence when TrustedProxies is set; default ClientIPHeadersDefault
+	GeoIPResolver        GeoIPResolver        // optional; enables VisitorEnhance for batch country enrichment
+	EnhanceBatchSize     int                  // number of records per VisitorEnhance call; default 10
+	EnhancerOptions      EnhancerOptions      // rate limit, worker pool and batch bounds for StartEnhancer
+	Enrichers            []Enricher           // optional enrichment pipeline; see DefaultEnrichers
+	EnrichAtIngestion    bool                 // when true, VisitorRegister runs the enrichment pipeline before inserting
+	UserAgentParser      UserAgentParser      // optional; defaults to ParseUserAgent behind an LRU cache (NewDefaultUserAgentParser)
+	EventBackfillWindow  time.Duration        // how far back EventTrack timestamps may go; default EventBackfillWindowDefault
+	UserIDResolver       UserIDResolver       // optional; returns the signed-in application user ID of a request without one set by WithUserID
+	Privacy              PrivacyOptions       // Do-Not-Track, Global Privacy Control, consent and cookieless modes; default records everything
+	Scrubber             *Scrubber            // optional; removes emails, tokens and other personal data from paths and referrers before they are stored
+	IPEncryption         *IPEncryptionOptions // optional; AES-GCM encryption of stored IP addresses with a blind index for lookups
 }
 
 // NewStore creates a new stats store.
@@ -45,10 +61,42 @@ func NewStore(opts NewStoreOptions) (StoreInterface, error) {
 		settingsTable = DEFAULT_SETTINGS_TABLE
 	}
 
+	eventTable := opts.EventTableName
+	if eventTable == "" {
+		eventTable = DEFAULT_EVENT_TABLE
+	}
+
+	auditTable := opts.AuditTableName
+	if auditTable == "" {
+		auditTable = DEFAULT_AUDIT_TABLE
+	}
+
+	rollupTable := opts.RollupTableName
+	if rollupTable == "" {
+		rollupTable = DEFAULT_ROLLUP_TABLE
+	}
+
+	userAgentParser := opts.UserAgentParser
+	if userAgentParser == nil {
+		userAgentParser = NewDefaultUserAgentParser()
+	}
+
+	var ipCipher *ipCipher
+	if opts.IPEncryption != nil {
+		ipCipher, err = newIPCipher(*opts.IPEncryption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	store := &storeImplementation{
 		visitorTableName:     opts.VisitorTableName,
 		settingsTableName:    settingsTable,
+		eventTableName:       eventTable,
+		auditTableName:       auditTable,
+		rollupTableName:      rollupTable,
+		hllPrecision:         HyperLogLogPrecision(opts.UniqueErrorBound),
 		db:                   neatDB,
 		automigrateEnabled:   opts.AutomigrateEnabled,
 		debugEnabled:         opts.DebugEnabled,
@@ -56,9 +104,23 @@ func NewStore(opts NewStoreOptions) (StoreInterface, error) {
 		botAutoTagEnabled:    opts.BotAutoTagEnabled,
 		excludedPathPrefixes: opts.ExcludedPathPrefixes,
 		excludedIPs:          opts.ExcludedIPs,
-		geoIPResolver:        opts.GeoIPResolver,
-		enhanceBatchSize:     opts.EnhanceBatchSize,
-		logger:               logger,
+		clientIPOptions: ClientIPOptions{
+			TrustedProxies: opts.TrustedProxies,
+			Headers:        opts.ClientIPHeaders,
+		},
+		trustedProxies:      parseTrustedProxies(opts.TrustedProxies),
+		geoIPResolver:       opts.GeoIPResolver,
+		enhanceBatchSize:    opts.EnhanceBatchSize,
+		enhancerOptions:     opts.EnhancerOptions,
+		enrichers:           opts.Enrichers,
+		enrichAtIngestion:   opts.EnrichAtIngestion,
+		userAgentParser:     userAgentParser,
+		eventBackfillWindow: opts.EventBackfillWindow,
+		userIDResolver:      opts.UserIDResolver,
+		privacyOptions:      opts.Privacy,
+		scrubber:            opts.Scrubber,
+		ipCipher:            ipCipher,
+		logger:              logger,
 	}
 
 	if store.automigrateEnabled {
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	contractsschema "github.com/dracory/neat/contracts/database/schema"
 	_ "modernc.org/sqlite"
 )
 
@@ -536,3 +537,20 @@ func TestStoreVisitorRegisterExcludedPath(t *testing.T) {
 		t.Fatalf("expected 1 visitor (admin path excluded), got %d", count)
 	}
 }
+
+func TestMysqlColumnAttributes(t *testing.T) {
+	tests := []struct {
+		column contractsschema.Column
+		want   string
+	}{
+		{contractsschema.Column{Nullable: true}, " NULL"},
+		{contractsschema.Column{Nullable: false, Collation: "utf8mb4_unicode_ci"}, " COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT ''"},
+		{contractsschema.Column{Nullable: true, Default: "it's", Comment: "OS"}, " NULL DEFAULT 'it''s' COMMENT 'OS'"},
+	}
+
+	for _, tt := range tests {
+		if got := mysqlColumnAttributes(tt.column); got != tt.want {
+			t.Errorf("mysqlColumnAttributes(%+v) = %q, want %q", tt.column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package statsstore
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// == PAGE HIT =================================================================
+
+// PageHit describes a page view reported by the client (see
+// VisitorRegisterHit). Fields the server cannot see on its own, such as SPA
+// routes, the client-side referrer, the page title and the screen size, are
+// carried here.
+type PageHit struct {
+	Path         string
+	Referrer     string
+	Title        string
+	ScreenWidth  int
+	ScreenHeight int
+
+	// Fingerprint, when set, replaces the IP/user-agent fingerprint, e.g.
+	// with a client-side visitor ID.
+	Fingerprint string
+
+	// Campaign is a campaign or email ID, e.g. from a tracking pixel.
+	Campaign string
+}
+
+// ScreenSize returns the screen size as "WIDTHxHEIGHT", or an empty string
+// when unknown.
+func (h PageHit) ScreenSize() string {
+	if h.ScreenWidth <= 0 || h.ScreenHeight <= 0 {
+		return ""
+	}
+	return strconv.Itoa(h.ScreenWidth) + "x" + strconv.Itoa(h.ScreenHeight)
+}
+
+// == TRACKER HANDLER ==========================================================
+
+// trackerScript is the client-side tracker served on GET.
+//
+//go:embed tracker.js
+var trackerScript []byte
+
+const (
+	// TrackerMaxBodyBytes caps the size of a beacon payload.
+	TrackerMaxBodyBytes = 8 << 10
+
+	// trackerMaxTitleLength matches the page_title column size.
+	trackerMaxTitleLength = 255
+
+	// trackerMaxScreenDimension rejects nonsense screen sizes.
+	trackerMaxScreenDimension = 100000
+)
+
+// TrackerHandlerOptions configures NewTrackerHandler.
+type TrackerHandlerOptions struct {
+	// Store receives the page hits. Required.
+	Store StoreInterface
+
+	// AllowedHosts, when not empty, restricts beacons to page URLs on these
+	// hosts (e.g. "example.com"), so other sites cannot post hits.
+	AllowedHosts []string
+}
+
+// Payload kinds posted by tracker.js.
+const (
+	trackerKindPageview   = "pageview"
+	trackerKindEngagement = "engagement"
+	trackerKindExperiment = "experiment"
+)
+
+// trackerPayload is the JSON body posted by tracker.js. Page views carry the
+// page fields; engagement pings carry the page-view ID returned for the page
+// view, the visible seconds and the maximum scroll depth so far. Experiment
+// exposures carry the page URL, the experiment and the variant.
+type trackerPayload struct {
+	Kind     string `json:"k"`
+	URL      string `json:"u"`
+	Referrer string `json:"r"`
+	Title    string `json:"t"`
+	Width    int    `json:"w"`
+	Height   int    `json:"h"`
+	ID       string `json:"id"`
+	Seconds  int    `json:"s"`
+	Scroll   int    `json:"d"`
+
+	Experiment string `json:"x"`
+	Variant    string `json:"v"`
+}
+
+// NewTrackerHandler returns an http.Handler for the first-party JavaScript
+// tracker. GET serves the tracker script. POST accepts page views, recorded
+// via VisitorRegisterHit so they go through the same filtering and
+// enrichment as VisitorRegister, engagement heartbeat pings
+// (navigator.sendBeacon), recorded via VisitorEngagementUpdate, and A/B
+// experiment exposures, recorded via EventRegister (see
+// NewExperimentExposure).
+//
+// Mount it on a single path and include it as a script:
+//
+//	mux.Handle("/stats/t.js", statsstore.NewTrackerHandler(statsstore.TrackerHandlerOptions{Store: store}))
+//	<script defer src="/stats/t.js"></script>
+func NewTrackerHandler(opts TrackerHandlerOptions) http.Handler {
+	return &trackerHandler{opts: opts}
+}
+
+type trackerHandler struct {
+	opts TrackerHandlerOptions
+}
+
+// ServeHTTP implements http.Handler.
+func (h *trackerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.serveScript(w)
+	case http.MethodPost:
+		h.collect(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *trackerHandler) serveScript(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(trackerScript)
+}
+
+func (h *trackerHandler) collect(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Store == nil {
+		http.Error(w, "tracker: store is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := h.readPayload(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch payload.Kind {
+	case "", trackerKindPageview:
+		h.collectPageview(w, r, payload)
+	case trackerKindEngagement:
+		h.collectEngagement(w, r, payload)
+	case trackerKindExperiment:
+		h.collectExperiment(w, r, payload)
+	default:
+		http.Error(w, "tracker: unknown payload kind", http.StatusBadRequest)
+	}
+}
+
+// collectPageview records a page view and responds with its ID, which the
+// script sends back with its engagement pings.
+func (h *trackerHandler) collectPageview(w http.ResponseWriter, r *http.Request, payload trackerPayload) {
+	hit, err := h.parseHit(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	visitor, err := h.opts.Store.VisitorRegisterHit(r.Context(), r, hit)
+	if err != nil {
+		http.Error(w, "tracker: failed to record hit", http.StatusInternalServerError)
+		return
+	}
+
+	// Filtered hits get no ID, so the script sends no engagement pings.
+	if visitor == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"id": visitor.GetID()})
+}
+
+// collectEngagement records a heartbeat ping for an earlier page view.
+func (h *trackerHandler) collectEngagement(w http.ResponseWriter, r *http.Request, payload trackerPayload) {
+	if payload.ID == "" {
+		http.Error(w, "tracker: missing page-view id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.opts.Store.VisitorEngagementUpdate(r.Context(), payload.ID, payload.Seconds, payload.Scroll); err != nil {
+		http.Error(w, "tracker: failed to record engagement", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// collectExperiment records that the visitor saw a variant of an
+// experiment on the page.
+func (h *trackerHandler) collectExperiment(w http.ResponseWriter, r *http.Request, payload trackerPayload) {
+	hit, err := h.parseHit(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ValidateExperimentExposure(payload.Experiment, payload.Variant); err != nil {
+		http.Error(w, "tracker: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pageURL, _ := url.Parse(payload.URL)
+	event := NewExperimentExposure(payload.Experiment, payload.Variant).
+		SetPath(hit.Path).
+		SetDomain(pageURL.Hostname()).
+		SetReferrer(hit.Referrer)
+
+	if _, err := h.opts.Store.EventRegister(r.Context(), r, event); err != nil {
+		http.Error(w, "tracker: failed to record experiment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readPayload decodes a beacon body. sendBeacon posts strings as
+// text/plain, so the body is decoded as JSON regardless of content type.
+func (h *trackerHandler) readPayload(w http.ResponseWriter, r *http.Request) (trackerPayload, error) {
+	payload := trackerPayload{}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, TrackerMaxBodyBytes))
+	if err != nil {
+		return payload, errors.New("tracker: payload too large")
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return payload, errors.New("tracker: invalid payload")
+	}
+
+	return payload, nil
+}
+
+// parseHit validates a page-view payload and converts it to a PageHit.
+func (h *trackerHandler) parseHit(payload trackerPayload) (PageHit, error) {
+	pageURL, err := url.Parse(payload.URL)
+	if err != nil || pageURL.Host == "" {
+		return PageHit{}, errors.New("tracker: invalid page URL")
+	}
+
+	if len(h.opts.AllowedHosts) > 0 && !slices.Contains(h.opts.AllowedHosts, pageURL.Hostname()) {
+		return PageHit{}, errors.New("tracker: host not allowed")
+	}
+
+	path := pageURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	hit := PageHit{
+		Path:     path,
+		Referrer: strings.TrimSpace(payload.Referrer),
+		Title:    truncateRunes(strings.TrimSpace(payload.Title), trackerMaxTitleLength),
+	}
+	if payload.Width > 0 && payload.Width <= trackerMaxScreenDimension &&
+		payload.Height > 0 && payload.Height <= trackerMaxScreenDimension {
+		hit.ScreenWidth = payload.Width
+		hit.ScreenHeight = payload.Height
+	}
+
+	return hit, nil
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
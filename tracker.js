@@ -0,0 +1,227 @@
+/*
+ * First-party page-view tracker for statsstore (see NewTrackerHandler).
+ *
+ * <script defer src="/stats/t.js"></script>
+ *
+ * Options (data attributes on the script tag):
+ *   data-endpoint  URL to post to (default: the script URL)
+ *   data-spa       "false" disables history (pushState/popstate) tracking
+ *
+ * A/B experiments: report the variant a visitor saw with
+ *   window.statsstore.experiment("checkout-button", "green");
+ * Calls made before the script loads can be queued:
+ *   window.statsstore = window.statsstore || { q: [] };
+ *   window.statsstore.q.push(["experiment", "checkout-button", "green"]);
+ */
+(function () {
+    "use strict";
+
+    var script = document.currentScript;
+    if (!script) {
+        return;
+    }
+
+    var endpoint = script.getAttribute("data-endpoint") || script.src.split("?")[0];
+    var trackHistory = script.getAttribute("data-spa") !== "false";
+    var heartbeatMs = 15000;
+    var lastUrl = null;
+
+    // Engagement state of the current page view.
+    var pageId = null;
+    var visibleMs = 0;
+    var visibleSince = null;
+    var maxScroll = 0;
+    var lastSentSeconds = -1;
+    var lastSentScroll = -1;
+
+    function beacon(body) {
+        if (navigator.sendBeacon && navigator.sendBeacon(endpoint, body)) {
+            return;
+        }
+        if (window.fetch) {
+            fetch(endpoint, { method: "POST", body: body, keepalive: true, credentials: "omit" });
+        }
+    }
+
+    // == ENGAGEMENT ===========================================================
+
+    function now() {
+        return Date.now();
+    }
+
+    function visibleSeconds() {
+        var total = visibleMs;
+        if (visibleSince !== null) {
+            total += now() - visibleSince;
+        }
+        return Math.round(total / 1000);
+    }
+
+    function updateScroll() {
+        var doc = document.documentElement;
+        var height = Math.max(doc.scrollHeight, document.body ? document.body.scrollHeight : 0);
+        if (height <= 0) {
+            return;
+        }
+        var bottom = (window.pageYOffset || doc.scrollTop) + window.innerHeight;
+        var percent = Math.min(100, Math.round(bottom / height * 100));
+        if (percent > maxScroll) {
+            maxScroll = percent;
+        }
+    }
+
+    function sendEngagement() {
+        if (!pageId) {
+            return;
+        }
+        var seconds = visibleSeconds();
+        if (seconds === lastSentSeconds && maxScroll === lastSentScroll) {
+            return;
+        }
+        lastSentSeconds = seconds;
+        lastSentScroll = maxScroll;
+        beacon(JSON.stringify({ k: "engagement", id: pageId, s: seconds, d: maxScroll }));
+    }
+
+    function resetEngagement() {
+        pageId = null;
+        visibleMs = 0;
+        visibleSince = document.visibilityState === "visible" ? now() : null;
+        maxScroll = 0;
+        lastSentSeconds = -1;
+        lastSentScroll = -1;
+        updateScroll();
+    }
+
+    function onVisibilityChange() {
+        if (document.visibilityState === "visible") {
+            if (visibleSince === null) {
+                visibleSince = now();
+            }
+            return;
+        }
+        if (visibleSince !== null) {
+            visibleMs += now() - visibleSince;
+            visibleSince = null;
+        }
+        sendEngagement();
+    }
+
+    // == PAGE VIEWS ===========================================================
+
+    function track(referrer) {
+        var url = location.href;
+        if (url === lastUrl) {
+            return;
+        }
+        lastUrl = url;
+        resetEngagement();
+
+        var body = JSON.stringify({
+            k: "pageview",
+            u: url,
+            r: referrer || "",
+            t: document.title,
+            w: window.screen ? screen.width : 0,
+            h: window.screen ? screen.height : 0
+        });
+
+        // fetch is used so the page-view ID can be read for engagement pings.
+        if (!window.fetch) {
+            beacon(body);
+            return;
+        }
+        fetch(endpoint, { method: "POST", body: body, keepalive: true, credentials: "omit" })
+            .then(function (response) {
+                return response.status === 200 ? response.json() : null;
+            })
+            .then(function (data) {
+                if (data && data.id && url === lastUrl) {
+                    pageId = data.id;
+                }
+            })
+            .catch(function () {});
+    }
+
+    function trackNavigation(previousUrl) {
+        sendEngagement();
+        // Let the router update document.title before reading it.
+        setTimeout(function () { track(previousUrl); }, 0);
+    }
+
+    // == EXPERIMENTS ===========================================================
+
+    function experiment(name, variant) {
+        if (!name || !variant) {
+            return;
+        }
+        beacon(JSON.stringify({
+            k: "experiment",
+            u: location.href,
+            r: document.referrer || "",
+            x: String(name),
+            v: String(variant)
+        }));
+    }
+
+    function exposeApi() {
+        var api = window.statsstore || {};
+        var queue = api.q || [];
+        api.experiment = experiment;
+        api.q = { push: function (call) { run(call); } };
+        window.statsstore = api;
+        for (var i = 0; i < queue.length; i++) {
+            run(queue[i]);
+        }
+    }
+
+    function run(call) {
+        if (call && call[0] === "experiment") {
+            experiment(call[1], call[2]);
+        }
+    }
+
+    function start() {
+        track(document.referrer);
+
+        document.addEventListener("visibilitychange", onVisibilityChange);
+        window.addEventListener("pagehide", sendEngagement);
+        window.addEventListener("scroll", updateScroll, { passive: true });
+        setInterval(function () {
+            if (document.visibilityState === "visible") {
+                sendEngagement();
+            }
+        }, heartbeatMs);
+
+        if (!trackHistory || !window.history || !history.pushState) {
+            return;
+        }
+
+        var pushState = history.pushState;
+        history.pushState = function () {
+            var previousUrl = location.href;
+            var result = pushState.apply(this, arguments);
+            if (location.href !== previousUrl) {
+                trackNavigation(previousUrl);
+            }
+            return result;
+        };
+
+        window.addEventListener("popstate", function () {
+            trackNavigation(lastUrl);
+        });
+    }
+
+    exposeApi();
+
+    if (document.visibilityState === "prerender") {
+        document.addEventListener("visibilitychange", function onVisible() {
+            if (document.visibilityState === "visible") {
+                document.removeEventListener("visibilitychange", onVisible);
+                start();
+            }
+        });
+    } else {
+        start();
+    }
+})();
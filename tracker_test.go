@@ -0,0 +1,198 @@
+package statsstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postBeacon(t *testing.T, handler http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/stats/t.js", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestTrackerHandlerServesScript(t *testing.T) {
+	handler := NewTrackerHandler(TrackerHandlerOptions{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats/t.js", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "sendBeacon") {
+		t.Error("expected the tracker script to be served")
+	}
+}
+
+func TestTrackerHandlerRecordsHit(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewTrackerHandler(TrackerHandlerOptions{Store: store})
+
+	w := postBeacon(t, handler, `{"k":"pageview","u":"https://example.com/app/settings?tab=1","r":"https://example.com/app","t":"Settings","w":1920,"h":1080}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	response := map[string]string{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response["id"] == "" {
+		t.Fatalf("expected page-view id in response, got %q", w.Body.String())
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 visitor, got %d", len(list))
+	}
+
+	v := list[0]
+	if v.GetPath() != "/app/settings" {
+		t.Errorf("path = %q, want /app/settings", v.GetPath())
+	}
+	if v.GetUserReferrer() != "https://example.com/app" {
+		t.Errorf("referrer = %q", v.GetUserReferrer())
+	}
+	if v.GetPageTitle() != "Settings" {
+		t.Errorf("page title = %q, want Settings", v.GetPageTitle())
+	}
+	if v.GetScreenSize() != "1920x1080" {
+		t.Errorf("screen size = %q, want 1920x1080", v.GetScreenSize())
+	}
+	if v.GetUserBrowser() != "Chrome" {
+		t.Errorf("browser = %q, want Chrome", v.GetUserBrowser())
+	}
+	if v.GetID() != response["id"] {
+		t.Errorf("response id = %q, want %q", response["id"], v.GetID())
+	}
+}
+
+func TestTrackerHandlerRecordsEngagement(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewTrackerHandler(TrackerHandlerOptions{Store: store})
+
+	visitor := NewVisitor().SetPath("/article")
+	if err := store.VisitorCreate(context.Background(), visitor); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	w := postBeacon(t, handler, `{"k":"engagement","id":"`+visitor.GetID()+`","s":42,"d":75}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	found, err := store.VisitorFindByID(context.Background(), visitor.GetID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found.GetEngagementSeconds() != "42" || found.GetScrollDepth() != "75" {
+		t.Errorf("engagement = (%s, %s), want (42, 75)", found.GetEngagementSeconds(), found.GetScrollDepth())
+	}
+
+	if w := postBeacon(t, handler, `{"k":"engagement","s":1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for ping without id, got %d", w.Code)
+	}
+}
+
+func TestTrackerHandlerRecordsExperiment(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewTrackerHandler(TrackerHandlerOptions{Store: store, AllowedHosts: []string{"example.com"}})
+
+	w := postBeacon(t, handler, `{"k":"experiment","u":"https://example.com/pricing?plan=pro","x":"pricing","v":"green"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	events, err := store.EventList(context.Background(), EventQuery().SetName(EVENT_NAME_EXPERIMENT_EXPOSURE))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 exposure, got %d", len(events))
+	}
+	props := events[0].GetPropsMap()
+	if props[ExperimentPropExperiment] != "pricing" || props[ExperimentPropVariant] != "green" ||
+		events[0].GetPath() != "/pricing" || events[0].GetDomain() != "example.com" {
+		t.Errorf("unexpected exposure: %+v", events[0])
+	}
+
+	for _, body := range []string{
+		`{"k":"experiment","u":"https://example.com/","x":"pricing"}`,
+		`{"k":"experiment","u":"https://other.example/","x":"pricing","v":"green"}`,
+	} {
+		if w := postBeacon(t, handler, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestTrackerHandlerAppliesFiltering(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store.SetExcludedPathPrefixes([]string{"/admin"})
+	handler := NewTrackerHandler(TrackerHandlerOptions{Store: store})
+
+	w := postBeacon(t, handler, `{"u":"https://example.com/admin/users"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+
+	count, err := store.VisitorCount(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 0 {
+		t.Errorf("expected excluded path to be skipped, got %d visitors", count)
+	}
+}
+
+func TestTrackerHandlerRejectsInvalidBeacons(t *testing.T) {
+	store, err := initStore()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	handler := NewTrackerHandler(TrackerHandlerOptions{
+		Store:        store,
+		AllowedHosts: []string{"example.com"},
+	})
+
+	for name, body := range map[string]string{
+		"not json":     `nope`,
+		"relative URL": `{"u":"/only/a/path"}`,
+		"foreign host": `{"u":"https://evil.test/"}`,
+		"too large":    `{"u":"https://example.com/","t":"` + strings.Repeat("x", TrackerMaxBodyBytes) + `"}`,
+	} {
+		if w := postBeacon(t, handler, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/stats/t.js", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package statsstore
+
+import (
+	"container/list"
+	"sync"
+)
+
+// == USER AGENT PARSER ========================================================
+
+// UserAgentCacheSizeDefault is the default number of distinct user-agent
+// strings kept by the cached parser.
+const UserAgentCacheSizeDefault = 1024
+
+// UserAgentParser turns a user-agent string into UserAgentInfo. Implement it
+// to plug in a different parser (e.g. a regex database); set it via
+// NewStoreOptions.UserAgentParser. Implementations must be safe for
+// concurrent use.
+type UserAgentParser interface {
+	Parse(ua string) UserAgentInfo
+}
+
+// UserAgentParserFunc adapts a function to UserAgentParser.
+type UserAgentParserFunc func(ua string) UserAgentInfo
+
+// Parse implements UserAgentParser.
+func (f UserAgentParserFunc) Parse(ua string) UserAgentInfo {
+	return f(ua)
+}
+
+// NewDefaultUserAgentParser returns ParseUserAgent behind an LRU cache of
+// UserAgentCacheSizeDefault entries.
+func NewDefaultUserAgentParser() UserAgentParser {
+	return NewCachedUserAgentParser(UserAgentParserFunc(ParseUserAgent), UserAgentCacheSizeDefault)
+}
+
+// defaultUserAgentParser is used where no store-configured parser is
+// available, e.g. a UserAgentEnricher without a Parser.
+var defaultUserAgentParser = NewDefaultUserAgentParser()
+
+// == LRU CACHE ================================================================
+
+// NewCachedUserAgentParser wraps parser with a least-recently-used cache
+// holding at most size user-agent strings. A size of 0 or less disables
+// caching and returns parser unchanged.
+func NewCachedUserAgentParser(parser UserAgentParser, size int) UserAgentParser {
+	if size <= 0 {
+		return parser
+	}
+	return &cachedUserAgentParser{
+		parser:  parser,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+type cachedUserAgentParser struct {
+	parser UserAgentParser
+	size   int
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type uaCacheEntry struct {
+	ua   string
+	info UserAgentInfo
+}
+
+// Parse implements UserAgentParser.
+func (c *cachedUserAgentParser) Parse(ua string) UserAgentInfo {
+	c.mu.Lock()
+	if el, ok := c.entries[ua]; ok {
+		c.order.MoveToFront(el)
+		info := el.Value.(*uaCacheEntry).info
+		c.mu.Unlock()
+		return info
+	}
+	c.mu.Unlock()
+
+	// Parse outside the lock so a slow parser does not serialise callers.
+	info := c.parser.Parse(ua)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[ua]; ok {
+		c.order.MoveToFront(el)
+		return info
+	}
+	c.entries[ua] = c.order.PushFront(&uaCacheEntry{ua: ua, info: info})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*uaCacheEntry).ua)
+	}
+	return info
+}
+
+// Len returns the number of cached user-agent strings.
+func (c *cachedUserAgentParser) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,136 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkUserAgents is a small mix of common UAs, mirroring real traffic
+// where a handful of strings dominate.
+var benchmarkUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:153.0) Gecko/20100101 Firefox/153.0",
+}
+
+type countingUserAgentParser struct {
+	calls atomic.Int64
+}
+
+func (p *countingUserAgentParser) Parse(ua string) UserAgentInfo {
+	p.calls.Add(1)
+	return UserAgentInfo{Browser: "Custom", Os: ua}
+}
+
+func TestCachedUserAgentParserHitsCache(t *testing.T) {
+	inner := &countingUserAgentParser{}
+	parser := NewCachedUserAgentParser(inner, 10)
+
+	for range 5 {
+		if got := parser.Parse("ua-1"); got.Browser != "Custom" || got.Os != "ua-1" {
+			t.Fatalf("unexpected result: %+v", got)
+		}
+	}
+
+	if calls := inner.calls.Load(); calls != 1 {
+		t.Errorf("expected 1 call to the wrapped parser, got %d", calls)
+	}
+}
+
+func TestCachedUserAgentParserEvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &countingUserAgentParser{}
+	parser := NewCachedUserAgentParser(inner, 2).(*cachedUserAgentParser)
+
+	parser.Parse("a")
+	parser.Parse("b")
+	parser.Parse("a") // a is now most recently used
+	parser.Parse("c") // evicts b
+
+	if parser.Len() != 2 {
+		t.Fatalf("expected cache bounded at 2, got %d", parser.Len())
+	}
+
+	inner.calls.Store(0)
+	parser.Parse("a")
+	if inner.calls.Load() != 0 {
+		t.Error("expected a to still be cached")
+	}
+	parser.Parse("b")
+	if inner.calls.Load() != 1 {
+		t.Error("expected b to have been evicted")
+	}
+}
+
+func TestCachedUserAgentParserDisabled(t *testing.T) {
+	inner := &countingUserAgentParser{}
+	if parser := NewCachedUserAgentParser(inner, 0); parser != UserAgentParser(inner) {
+		t.Error("expected size 0 to return the wrapped parser")
+	}
+}
+
+func TestVisitorRegister_CustomUserAgentParser(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	inner := &countingUserAgentParser{}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		UserAgentParser:    inner,
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", "my-agent")
+	if err := store.VisitorRegister(context.Background(), r); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := store.VisitorList(context.Background(), VisitorQuery())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(list) != 1 || list[0].GetUserBrowser() != "Custom" {
+		t.Fatalf("expected custom parser output to be stored, got %+v", list)
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("expected custom parser to be called once, got %d", inner.calls.Load())
+	}
+}
+
+func BenchmarkParseUserAgent(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		ParseUserAgent(benchmarkUserAgents[i%len(benchmarkUserAgents)])
+	}
+}
+
+func BenchmarkCachedUserAgentParser(b *testing.B) {
+	parser := NewDefaultUserAgentParser()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		parser.Parse(benchmarkUserAgents[i%len(benchmarkUserAgents)])
+	}
+}
+
+func BenchmarkCachedUserAgentParserParallel(b *testing.B) {
+	parser := NewDefaultUserAgentParser()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			parser.Parse(benchmarkUserAgents[i%len(benchmarkUserAgents)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// == CONSTANTS ================================================================
+
+// visitorMaxUserIDLength matches the user_id column size.
+const visitorMaxUserIDLength = 64
+
+// userIDContextKey is the context key of the application user ID.
+type userIDContextKey struct{}
+
+// == TYPES ====================================================================
+
+// UserIDResolver returns the application's own ID of the user signed in on
+// a request, or an empty string for anonymous visitors. Set it in
+// NewStoreOptions when the ID is easier to read from the request (e.g. a
+// session cookie) than to put in the context with WithUserID.
+type UserIDResolver func(r *http.Request) string
+
+// == PUBLIC FUNCTIONS =========================================================
+
+// WithUserID returns a copy of ctx carrying the application's own ID of the
+// signed-in user. VisitorRegister, VisitorRegisterHit and EventRegister
+// record it on the visit or event, so one account can be followed across
+// devices and fingerprints. Set it from the authentication middleware:
+//
+//	next.ServeHTTP(w, r.WithContext(statsstore.WithUserID(r.Context(), user.ID)))
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+// UserIDFromContext returns the user ID set with WithUserID, or an empty
+// string.
+func UserIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	userID, _ := ctx.Value(userIDContextKey{}).(string)
+	return userID
+}
+
+// == PRIVATE METHODS ==========================================================
+
+// requestUserID returns the user ID of a request: the one in ctx, then the
+// one in the request context, then NewStoreOptions.UserIDResolver. IDs longer
+// than the column are ignored rather than truncated, so they never link
+// the wrong account.
+func (st *storeImplementation) requestUserID(ctx context.Context, r *http.Request) string {
+	userID := UserIDFromContext(ctx)
+	if userID == "" && r != nil {
+		userID = UserIDFromContext(r.Context())
+	}
+	if userID == "" && r != nil && st.userIDResolver != nil {
+		userID = st.userIDResolver(r)
+	}
+
+	userID = strings.TrimSpace(userID)
+	if len(userID) > visitorMaxUserIDLength {
+		if st.debugEnabled {
+			st.logger.Info("user-id: ignoring user ID longer than the column", "length", len(userID))
+		}
+		return ""
+	}
+
+	return userID
+}
@@ -0,0 +1,90 @@
+package statsstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func userIDTestRequest(userAgent string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/pricing", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", userAgent)
+	return r
+}
+
+func TestVisitorRegisterUserID(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		VisitorTableName:   "visitor_table",
+		AutomigrateEnabled: true,
+		UserIDResolver: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	ctx := context.Background()
+
+	// Same account on two devices, via the context and the request context.
+	if err := store.VisitorRegister(WithUserID(ctx, "42"), userIDTestRequest("Mozilla/5.0 (Windows NT 10.0)")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	phone := userIDTestRequest("Mozilla/5.0 (iPhone)")
+	phone = phone.WithContext(WithUserID(phone.Context(), "42"))
+	if err := store.VisitorRegister(ctx, phone); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Another account through the resolver, and an anonymous visit.
+	other := userIDTestRequest("Mozilla/5.0 (Macintosh)")
+	other.Header.Set("X-User", "7")
+	if err := store.VisitorRegister(ctx, other); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	anonymous := userIDTestRequest("Mozilla/5.0 (X11; Linux)")
+	anonymous.Header.Set("X-User", strings.Repeat("x", visitorMaxUserIDLength+1))
+	if err := store.VisitorRegister(ctx, anonymous); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	visitors, err := store.VisitorList(ctx, VisitorQuery().SetUserID("42"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(visitors) != 2 || visitors[0].FingerprintCalculate() == visitors[1].FingerprintCalculate() {
+		t.Fatalf("expected 2 visits on different devices, got %d", len(visitors))
+	}
+
+	if count, err := store.VisitorCount(ctx, VisitorQuery().SetUserID("7")); err != nil || count != 1 {
+		t.Errorf("expected 1 visit of user 7, got %d (%v)", count, err)
+	}
+	if count, err := store.VisitorCount(ctx, VisitorQuery()); err != nil || count != 4 {
+		t.Errorf("expected 4 visits, got %d (%v)", count, err)
+	}
+
+	recorded, err := store.EventRegister(WithUserID(ctx, "42"), userIDTestRequest("Mozilla/5.0 (iPhone)"), NewEvent().SetName("Signup"))
+	if err != nil || !recorded {
+		t.Fatalf("expected the event to be recorded, got %v, %v", recorded, err)
+	}
+	events, err := store.EventList(ctx, EventQuery().SetUserID("42"))
+	if err != nil || len(events) != 1 {
+		t.Errorf("expected 1 event of user 42, got %d (%v)", len(events), err)
+	}
+}
+
+func TestUserIDFromContext(t *testing.T) {
+	if got := UserIDFromContext(context.Background()); got != "" {
+		t.Errorf("expected no user ID, got %q", got)
+	}
+	if got := UserIDFromContext(WithUserID(context.Background(), "42")); got != "42" {
+		t.Errorf("expected user ID 42, got %q", got)
+	}
+}
@@ -1,6 +1,8 @@
 package statsstore
 
 import (
+	"strings"
+
 	"github.com/dracory/neat/database/orm"
 	"github.com/dracory/neat/database/soft_delete"
 	neatuid "github.com/dracory/neat/support/uid"
@@ -29,6 +31,23 @@ type visitorImplementation struct {
 	UserReferrerField       string `db:"user_referrer"`
 	BotField                string `db:"bot"`
 	ThreatField             string `db:"threat"`
+	PeerIpAddressField      string `db:"peer_ip_address"`
+	ChannelField            string `db:"channel"`
+	TagsField               string `db:"tags"`
+	EnrichmentField         string `db:"enrichment"`
+	UserDeviceBrandField    string `db:"user_device_brand"`
+	UserDeviceModelField    string `db:"user_device_model"`
+	ClientHintsField        string `db:"client_hints"`
+	UserBrowserEngineField  string `db:"user_browser_engine"`
+	UserWebviewField        string `db:"user_webview"`
+	UserAppField            string `db:"user_app"`
+	PageTitleField          string `db:"page_title"`
+	ScreenSizeField         string `db:"screen_size"`
+	EngagementSecondsField  string `db:"engagement_seconds"`
+	ScrollDepthField        string `db:"scroll_depth"`
+	CampaignField           string `db:"campaign"`
+	UserIDField             string `db:"user_id"`
+	PrivacyField            string `db:"privacy"`
 	orm.CreatedAt
 	orm.UpdatedAt
 	soft_delete.SoftDeletesMaxDate
@@ -116,6 +135,57 @@ func NewVisitorFromExistingData(data map[string]string) VisitorInterface {
 	if v, ok := data[COLUMN_THREAT]; ok {
 		o.SetThreat(v)
 	}
+	if v, ok := data[COLUMN_PEER_IP_ADDRESS]; ok {
+		o.SetPeerIpAddress(v)
+	}
+	if v, ok := data[COLUMN_CHANNEL]; ok {
+		o.SetChannel(v)
+	}
+	if v, ok := data[COLUMN_TAGS]; ok {
+		o.SetTags(v)
+	}
+	if v, ok := data[COLUMN_ENRICHMENT]; ok {
+		o.SetEnrichment(v)
+	}
+	if v, ok := data[COLUMN_USER_DEVICE_BRAND]; ok {
+		o.SetUserDeviceBrand(v)
+	}
+	if v, ok := data[COLUMN_USER_DEVICE_MODEL]; ok {
+		o.SetUserDeviceModel(v)
+	}
+	if v, ok := data[COLUMN_CLIENT_HINTS]; ok {
+		o.SetClientHints(v)
+	}
+	if v, ok := data[COLUMN_USER_BROWSER_ENGINE]; ok {
+		o.SetUserBrowserEngine(v)
+	}
+	if v, ok := data[COLUMN_USER_WEBVIEW]; ok {
+		o.SetUserWebview(v)
+	}
+	if v, ok := data[COLUMN_USER_APP]; ok {
+		o.SetUserApp(v)
+	}
+	if v, ok := data[COLUMN_PAGE_TITLE]; ok {
+		o.SetPageTitle(v)
+	}
+	if v, ok := data[COLUMN_SCREEN_SIZE]; ok {
+		o.SetScreenSize(v)
+	}
+	if v, ok := data[COLUMN_ENGAGEMENT_SECONDS]; ok {
+		o.SetEngagementSeconds(v)
+	}
+	if v, ok := data[COLUMN_SCROLL_DEPTH]; ok {
+		o.SetScrollDepth(v)
+	}
+	if v, ok := data[COLUMN_CAMPAIGN]; ok {
+		o.SetCampaign(v)
+	}
+	if v, ok := data[COLUMN_USER_ID]; ok {
+		o.SetUserID(v)
+	}
+	if v, ok := data[COLUMN_PRIVACY]; ok {
+		o.SetPrivacy(v)
+	}
 	if v, ok := data[COLUMN_CREATED_AT]; ok {
 		o.SetCreatedAt(v)
 	}
@@ -137,6 +207,35 @@ func (o *visitorImplementation) FingerprintCalculate() string {
 	return hash
 }
 
+// HasTag reports whether the visitor carries the given tag.
+func (o *visitorImplementation) HasTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return false
+	}
+	for _, existing := range strings.Split(o.TagsField, ",") {
+		if strings.TrimSpace(existing) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag appends a tag to the visitor's comma-separated tags, ignoring
+// empty and duplicate tags.
+func (o *visitorImplementation) AddTag(tag string) VisitorInterface {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || o.HasTag(tag) {
+		return o
+	}
+	if o.TagsField == "" {
+		o.TagsField = tag
+	} else {
+		o.TagsField += "," + tag
+	}
+	return o
+}
+
 // IsSoftDeleted returns true if the visitor is soft deleted.
 func (o *visitorImplementation) IsSoftDeleted() bool {
 	return o.SoftDeletesMaxDate.IsSoftDeleted()
@@ -396,3 +495,190 @@ func (o *visitorImplementation) SetThreat(threat string) VisitorInterface {
 	o.ThreatField = threat
 	return o
 }
+
+// GetPeerIpAddress returns the peer (direct connection) IP address of the visitor.
+func (o *visitorImplementation) GetPeerIpAddress() string {
+	return o.PeerIpAddressField
+}
+
+// SetPeerIpAddress sets the peer (direct connection) IP address of the visitor.
+func (o *visitorImplementation) SetPeerIpAddress(peerIpAddress string) VisitorInterface {
+	o.PeerIpAddressField = peerIpAddress
+	return o
+}
+
+// GetChannel returns the marketing channel of the visitor.
+func (o *visitorImplementation) GetChannel() string {
+	return o.ChannelField
+}
+
+// SetChannel sets the marketing channel of the visitor.
+func (o *visitorImplementation) SetChannel(channel string) VisitorInterface {
+	o.ChannelField = channel
+	return o
+}
+
+// GetTags returns the comma-separated tags of the visitor.
+func (o *visitorImplementation) GetTags() string {
+	return o.TagsField
+}
+
+// SetTags sets the comma-separated tags of the visitor.
+func (o *visitorImplementation) SetTags(tags string) VisitorInterface {
+	o.TagsField = tags
+	return o
+}
+
+// GetEnrichment returns the applied enricher versions of the visitor.
+func (o *visitorImplementation) GetEnrichment() string {
+	return o.EnrichmentField
+}
+
+// SetEnrichment sets the applied enricher versions of the visitor.
+func (o *visitorImplementation) SetEnrichment(enrichment string) VisitorInterface {
+	o.EnrichmentField = enrichment
+	return o
+}
+
+// GetUserDeviceBrand returns the device brand of the visitor.
+func (o *visitorImplementation) GetUserDeviceBrand() string {
+	return o.UserDeviceBrandField
+}
+
+// SetUserDeviceBrand sets the device brand of the visitor.
+func (o *visitorImplementation) SetUserDeviceBrand(userDeviceBrand string) VisitorInterface {
+	o.UserDeviceBrandField = userDeviceBrand
+	return o
+}
+
+// GetUserDeviceModel returns the device model of the visitor.
+func (o *visitorImplementation) GetUserDeviceModel() string {
+	return o.UserDeviceModelField
+}
+
+// SetUserDeviceModel sets the device model of the visitor.
+func (o *visitorImplementation) SetUserDeviceModel(userDeviceModel string) VisitorInterface {
+	o.UserDeviceModelField = userDeviceModel
+	return o
+}
+
+// GetClientHints returns the raw User-Agent Client Hints (JSON) of the visitor.
+func (o *visitorImplementation) GetClientHints() string {
+	return o.ClientHintsField
+}
+
+// SetClientHints sets the raw User-Agent Client Hints (JSON) of the visitor.
+func (o *visitorImplementation) SetClientHints(clientHints string) VisitorInterface {
+	o.ClientHintsField = clientHints
+	return o
+}
+
+// GetUserBrowserEngine returns the browser rendering engine of the visitor.
+func (o *visitorImplementation) GetUserBrowserEngine() string {
+	return o.UserBrowserEngineField
+}
+
+// SetUserBrowserEngine sets the browser rendering engine of the visitor.
+func (o *visitorImplementation) SetUserBrowserEngine(userBrowserEngine string) VisitorInterface {
+	o.UserBrowserEngineField = userBrowserEngine
+	return o
+}
+
+// GetUserWebview returns the in-app browser / webview flag (yes/no) of the visitor.
+func (o *visitorImplementation) GetUserWebview() string {
+	return o.UserWebviewField
+}
+
+// SetUserWebview sets the in-app browser / webview flag (yes/no) of the visitor.
+func (o *visitorImplementation) SetUserWebview(userWebview string) VisitorInterface {
+	o.UserWebviewField = userWebview
+	return o
+}
+
+// GetUserApp returns the in-app browser host app of the visitor.
+func (o *visitorImplementation) GetUserApp() string {
+	return o.UserAppField
+}
+
+// SetUserApp sets the in-app browser host app of the visitor.
+func (o *visitorImplementation) SetUserApp(userApp string) VisitorInterface {
+	o.UserAppField = userApp
+	return o
+}
+
+// GetPageTitle returns the page title of the visitor.
+func (o *visitorImplementation) GetPageTitle() string {
+	return o.PageTitleField
+}
+
+// SetPageTitle sets the page title of the visitor.
+func (o *visitorImplementation) SetPageTitle(pageTitle string) VisitorInterface {
+	o.PageTitleField = pageTitle
+	return o
+}
+
+// GetScreenSize returns the screen size (WIDTHxHEIGHT) of the visitor.
+func (o *visitorImplementation) GetScreenSize() string {
+	return o.ScreenSizeField
+}
+
+// SetScreenSize sets the screen size (WIDTHxHEIGHT) of the visitor.
+func (o *visitorImplementation) SetScreenSize(screenSize string) VisitorInterface {
+	o.ScreenSizeField = screenSize
+	return o
+}
+
+// GetEngagementSeconds returns the visible time on page in seconds of the visitor.
+func (o *visitorImplementation) GetEngagementSeconds() string {
+	return o.EngagementSecondsField
+}
+
+// SetEngagementSeconds sets the visible time on page in seconds of the visitor.
+func (o *visitorImplementation) SetEngagementSeconds(engagementSeconds string) VisitorInterface {
+	o.EngagementSecondsField = engagementSeconds
+	return o
+}
+
+// GetScrollDepth returns the maximum scroll depth in percent of the visitor.
+func (o *visitorImplementation) GetScrollDepth() string {
+	return o.ScrollDepthField
+}
+
+// SetScrollDepth sets the maximum scroll depth in percent of the visitor.
+func (o *visitorImplementation) SetScrollDepth(scrollDepth string) VisitorInterface {
+	o.ScrollDepthField = scrollDepth
+	return o
+}
+
+// GetCampaign returns the campaign or email ID of the visitor.
+func (o *visitorImplementation) GetCampaign() string {
+	return o.CampaignField
+}
+
+// SetCampaign sets the campaign or email ID of the visitor.
+func (o *visitorImplementation) SetCampaign(campaign string) VisitorInterface {
+	o.CampaignField = campaign
+	return o
+}
+
+// GetUserID returns the application user ID of the visitor.
+func (o *visitorImplementation) GetUserID() string {
+	return o.UserIDField
+}
+
+// SetUserID sets the application user ID of the visitor.
+func (o *visitorImplementation) SetUserID(userID string) VisitorInterface {
+	o.UserIDField = userID
+	return o
+}
+
+// GetPrivacy returns the privacy mode the visit was stored under of the visitor.
+func (o *visitorImplementation) GetPrivacy() string {
+	return o.PrivacyField
+}
+
+// SetPrivacy sets the privacy mode the visit was stored under of the visitor.
+func (o *visitorImplementation) SetPrivacy(privacy string) VisitorInterface {
+	o.PrivacyField = privacy
+	return o
+}
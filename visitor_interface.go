@@ -7,6 +7,8 @@ type VisitorInterface interface {
 	// Methods
 	FingerprintCalculate() string
 	IsSoftDeleted() bool
+	HasTag(tag string) bool
+	AddTag(tag string) VisitorInterface
 
 	// Setters and Getters
 
@@ -72,4 +74,55 @@ type VisitorInterface interface {
 
 	GetThreat() string
 	SetThreat(threat string) VisitorInterface
+
+	GetPeerIpAddress() string
+	SetPeerIpAddress(peerIpAddress string) VisitorInterface
+
+	GetChannel() string
+	SetChannel(channel string) VisitorInterface
+
+	GetTags() string
+	SetTags(tags string) VisitorInterface
+
+	GetEnrichment() string
+	SetEnrichment(enrichment string) VisitorInterface
+
+	GetUserDeviceBrand() string
+	SetUserDeviceBrand(userDeviceBrand string) VisitorInterface
+
+	GetUserDeviceModel() string
+	SetUserDeviceModel(userDeviceModel string) VisitorInterface
+
+	GetClientHints() string
+	SetClientHints(clientHints string) VisitorInterface
+
+	GetUserBrowserEngine() string
+	SetUserBrowserEngine(userBrowserEngine string) VisitorInterface
+
+	GetUserWebview() string
+	SetUserWebview(userWebview string) VisitorInterface
+
+	GetUserApp() string
+	SetUserApp(userApp string) VisitorInterface
+
+	GetPageTitle() string
+	SetPageTitle(pageTitle string) VisitorInterface
+
+	GetScreenSize() string
+	SetScreenSize(screenSize string) VisitorInterface
+
+	GetEngagementSeconds() string
+	SetEngagementSeconds(engagementSeconds string) VisitorInterface
+
+	GetScrollDepth() string
+	SetScrollDepth(scrollDepth string) VisitorInterface
+
+	GetCampaign() string
+	SetCampaign(campaign string) VisitorInterface
+
+	GetUserID() string
+	SetUserID(userID string) VisitorInterface
+
+	GetPrivacy() string
+	SetPrivacy(privacy string) VisitorInterface
 }
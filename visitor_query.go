@@ -77,6 +77,18 @@ type VisitorQueryInterface interface {
 	HasThreat() bool
 	Threat() string
 	SetThreat(threat string) VisitorQueryInterface
+
+	HasUserID() bool
+	UserID() string
+	SetUserID(userID string) VisitorQueryInterface
+
+	HasPrivacy() bool
+	Privacy() string
+	SetPrivacy(privacy string) VisitorQueryInterface
+
+	HasEnrichmentNot() bool
+	EnrichmentNot() string
+	SetEnrichmentNot(enrichment string) VisitorQueryInterface
 }
 
 // VisitorQuery is a shortcut for NewVisitorQuery.
@@ -264,3 +276,39 @@ func (q *visitorQuery) SetThreat(v string) VisitorQueryInterface {
 	q.properties["threat"] = v
 	return q
 }
+
+func (q *visitorQuery) HasUserID() bool { return q.hasProperty("user_id") }
+func (q *visitorQuery) UserID() string {
+	if !q.HasUserID() {
+		return ""
+	}
+	return q.properties["user_id"].(string)
+}
+func (q *visitorQuery) SetUserID(v string) VisitorQueryInterface {
+	q.properties["user_id"] = v
+	return q
+}
+
+func (q *visitorQuery) HasPrivacy() bool { return q.hasProperty("privacy") }
+func (q *visitorQuery) Privacy() string {
+	if !q.HasPrivacy() {
+		return ""
+	}
+	return q.properties["privacy"].(string)
+}
+func (q *visitorQuery) SetPrivacy(v string) VisitorQueryInterface {
+	q.properties["privacy"] = v
+	return q
+}
+
+func (q *visitorQuery) HasEnrichmentNot() bool { return q.hasProperty("enrichment_not") }
+func (q *visitorQuery) EnrichmentNot() string {
+	if !q.HasEnrichmentNot() {
+		return ""
+	}
+	return q.properties["enrichment_not"].(string)
+}
+func (q *visitorQuery) SetEnrichmentNot(v string) VisitorQueryInterface {
+	q.properties["enrichment_not"] = v
+	return q
+}